@@ -5,8 +5,11 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"dlookup/lookup"
 )
 
 // Keybindings defines the configurable key actions.
@@ -20,12 +23,114 @@ type Keybindings struct {
 	Confirm     string `yaml:"confirm"`      // Enter key in most contexts
 	WatchToggle string `yaml:"watch_toggle"` // Key to toggle watch mode input
 	Export      string `yaml:"export"`       // Key to trigger file export
+	Cancel      string `yaml:"cancel"`       // Key to abort the running lookup
+	Refresh     string `yaml:"refresh"`      // Key to re-run a lookup, bypassing the cache
+	Server      string `yaml:"server"`       // Key to choose the DNS server a tab queries
+	Sort        string `yaml:"sort"`         // Key to change the order of a range lookup's table
+	Copy        string `yaml:"copy"`         // Key to copy a result's suggestion, such as a flattened SPF record
 	// Potentially add keys for list navigation, viewport scrolling if needed
 }
 
+// WhoisConfig configures the built-in WHOIS client.
+type WhoisConfig struct {
+	// Servers maps a TLD (without the leading dot, e.g. "com" or "co.uk") to the
+	// WHOIS server to ask, overriding the table shipped with the binary.
+	Servers map[string]string `yaml:"servers"`
+}
+
+// ResolverConfig names a DNS server so it can be chosen with --server or in
+// the TUI by name instead of by address.
+type ResolverConfig struct {
+	Name string `yaml:"name"`
+	// Address is an IP address or host name, optionally with a port.
+	Address string `yaml:"address"`
+}
+
+// PolicyConfig is the retry and rate-limit policy of a provider. Fields left
+// at zero keep the value of the default policy.
+type PolicyConfig struct {
+	MaxAttempts    int           `yaml:"max_attempts,omitempty"`
+	InitialBackoff time.Duration `yaml:"initial_backoff,omitempty"`
+	MaxBackoff     time.Duration `yaml:"max_backoff,omitempty"`
+	// RateLimit is the number of queries per second sent to one server.
+	RateLimit float64 `yaml:"rate_limit,omitempty"`
+	Burst     int     `yaml:"burst,omitempty"`
+}
+
+// apply returns base with the fields set in c replaced.
+func (c PolicyConfig) apply(base lookup.Policy) lookup.Policy {
+	if c.MaxAttempts > 0 {
+		base.MaxAttempts = c.MaxAttempts
+	}
+	if c.InitialBackoff > 0 {
+		base.InitialBackoff = c.InitialBackoff
+	}
+	if c.MaxBackoff > 0 {
+		base.MaxBackoff = c.MaxBackoff
+	}
+	if c.RateLimit > 0 {
+		base.RateLimit = c.RateLimit
+	}
+	if c.Burst > 0 {
+		base.Burst = c.Burst
+	}
+	return base
+}
+
+// LookupConfig holds settings that apply to every lookup.
+type LookupConfig struct {
+	// DefaultTimeout bounds lookups whose provider has no entry in Timeouts.
+	DefaultTimeout time.Duration `yaml:"default_timeout"`
+	// Timeouts maps provider flag names (e.g. "whois", "dig-dig-a") to timeouts.
+	Timeouts map[string]time.Duration `yaml:"timeouts"`
+	// Concurrency caps how many lookups run at once; 0 means no limit.
+	Concurrency int `yaml:"concurrency"`
+	// ProviderConcurrency caps concurrent lookups per provider flag name.
+	ProviderConcurrency map[string]int `yaml:"provider_concurrency"`
+	// Policy is the retry and rate-limit policy of every provider; Policies
+	// overrides it per provider flag name.
+	Policy   PolicyConfig            `yaml:"policy"`
+	Policies map[string]PolicyConfig `yaml:"policies"`
+	// MaxRangeAddresses caps how many addresses a CIDR block or address
+	// range may expand to.
+	MaxRangeAddresses int `yaml:"max_range_addresses"`
+}
+
+// CacheConfig controls the lookup result cache.
+type CacheConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// DefaultTTL keeps results that carry no record TTLs, such as WHOIS
+	// output, when their provider has no entry in TTLs.
+	DefaultTTL time.Duration `yaml:"default_ttl"`
+	// TTLs maps provider flag names (e.g. "whois", "rdap") to how long their
+	// results are kept. DNS results always follow the DNS TTLs instead.
+	TTLs map[string]time.Duration `yaml:"ttls"`
+}
+
+// EmailConfig controls the email security audit.
+type EmailConfig struct {
+	// DKIMSelectors are the selectors whose DKIM keys are looked up. When
+	// empty, a built-in list of selectors common mail services use is tried.
+	DKIMSelectors []string `yaml:"dkim_selectors"`
+}
+
+// ConsistencyConfig controls the name server consistency check.
+type ConsistencyConfig struct {
+	// Types are the record types compared across a zone's servers, besides
+	// the SOA serial and the NS set. When empty, A, AAAA and MX are compared.
+	Types []string `yaml:"types"`
+}
+
 // AppConfig holds the application configuration.
 type AppConfig struct {
-	Keybindings Keybindings `yaml:"keybindings"`
+	Keybindings Keybindings       `yaml:"keybindings"`
+	Lookup      LookupConfig      `yaml:"lookup"`
+	Cache       CacheConfig       `yaml:"cache"`
+	Whois       WhoisConfig       `yaml:"whois"`
+	Email       EmailConfig       `yaml:"email"`
+	Consistency ConsistencyConfig `yaml:"consistency"`
+	// Resolvers are the named DNS servers offered when choosing a server.
+	Resolvers []ResolverConfig `yaml:"resolvers"`
 	// Add other configuration sections here later (e.g., colors, default_interval)
 }
 
@@ -42,6 +147,11 @@ func DefaultKeybindings() Keybindings {
 		Confirm:     "enter",  // Unchanged
 		WatchToggle: "w",      // Unchanged
 		Export:      "ctrl+x", // Default export key
+		Cancel:      "esc",    // Abort a lookup while it is loading
+		Refresh:     "r",      // Re-run the lookup without the cache
+		Server:      "s",      // Choose the DNS server for the tab
+		Sort:        "o",      // Sort a range lookup's table by the next column
+		Copy:        "y",      // Copy a flattened SPF record to the clipboard
 	}
 }
 
@@ -49,7 +159,96 @@ func DefaultKeybindings() Keybindings {
 func DefaultConfig() AppConfig {
 	return AppConfig{
 		Keybindings: DefaultKeybindings(),
+		Lookup: LookupConfig{
+			DefaultTimeout: 15 * time.Second,
+			Timeouts: map[string]time.Duration{
+				"whois":        30 * time.Second,
+				"whois-native": 30 * time.Second,
+				"rdap":         30 * time.Second,
+				"report":       2 * time.Minute,
+			},
+			Concurrency: 16,
+			ProviderConcurrency: map[string]int{
+				"whois":        2,
+				"whois-native": 2,
+				"rdap":         4,
+			},
+			Policy: PolicyConfig{
+				MaxAttempts:    3,
+				InitialBackoff: 500 * time.Millisecond,
+				MaxBackoff:     8 * time.Second,
+			},
+			Policies: map[string]PolicyConfig{
+				"whois":        {RateLimit: 1, Burst: 2},
+				"whois-native": {RateLimit: 1, Burst: 2},
+				"rdap":         {RateLimit: 2, Burst: 4},
+			},
+			MaxRangeAddresses: 1024,
+		},
+		Cache: CacheConfig{
+			Enabled:    true,
+			DefaultTTL: time.Minute,
+			TTLs: map[string]time.Duration{
+				"whois":        time.Hour,
+				"whois-native": time.Hour,
+				"rdap":         time.Hour,
+			},
+		},
+		Whois: WhoisConfig{
+			Servers: map[string]string{},
+		},
+		Resolvers: []ResolverConfig{
+			{Name: "google", Address: "8.8.8.8"},
+			{Name: "cloudflare", Address: "1.1.1.1"},
+			{Name: "quad9", Address: "9.9.9.9"},
+		},
+	}
+}
+
+// applyConfig pushes the lookup-related settings into the lookup package.
+func applyConfig(config AppConfig) {
+	if config.Lookup.DefaultTimeout > 0 {
+		lookup.DefaultTimeout = config.Lookup.DefaultTimeout
+	}
+	for flagName, timeout := range config.Lookup.Timeouts {
+		lookup.SetProviderTimeout(flagName, timeout)
+	}
+	lookup.DefaultPolicy = config.Lookup.Policy.apply(lookup.DefaultPolicy)
+	for flagName, policy := range config.Lookup.Policies {
+		lookup.SetProviderPolicy(flagName, policy.apply(lookup.DefaultPolicy))
+	}
+	lookup.DefaultScheduler.SetLimits(config.Lookup.Concurrency, config.Lookup.ProviderConcurrency)
+	if config.Lookup.MaxRangeAddresses > 0 {
+		lookup.MaxRangeAddresses = config.Lookup.MaxRangeAddresses
+	}
+	lookup.DefaultCache.Enabled = config.Cache.Enabled
+	if config.Cache.DefaultTTL > 0 {
+		lookup.DefaultCache.DefaultTTL = config.Cache.DefaultTTL
+	}
+	for flagName, ttl := range config.Cache.TTLs {
+		lookup.DefaultCache.SetTTL(flagName, ttl)
+	}
+	lookup.DefaultWhoisClient.Servers = config.Whois.Servers
+	if len(config.Email.DKIMSelectors) > 0 {
+		lookup.DKIMSelectors = config.Email.DKIMSelectors
+	}
+	if len(config.Consistency.Types) > 0 {
+		var types []lookup.RRType
+		for _, name := range config.Consistency.Types {
+			t, err := lookup.ParseRRType(name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: consistency.types: %v\n", err)
+				continue
+			}
+			types = append(types, t)
+		}
+		lookup.ConsistencyTypes = types
+	}
+	resolvers := make([]lookup.NamedResolver, 0, len(config.Resolvers))
+	for _, r := range config.Resolvers {
+		resolvers = append(resolvers, lookup.NamedResolver{Name: r.Name, Address: r.Address})
 	}
+	lookup.SetNamedResolvers(resolvers)
 }
 
 // getConfigPath determines the path for the configuration file.
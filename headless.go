@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"dlookup/lookup"
+)
+
+// Exit statuses of dlookup.
+const (
+	exitOK           = 0
+	exitLookupFailed = 1 // At least one lookup failed
+	exitUsage        = 2 // Bad flags, or input that is unreadable or empty
+	exitInterrupted  = 130
+)
+
+// usageFatal prints a usage error to stderr and exits with exitUsage.
+func usageFatal(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(exitUsage)
+}
+
+// pendingLookup is a scheduled lookup of a single input or of a range.
+type pendingLookup interface {
+	Wait() (*lookup.Result, error)
+}
+
+// runHeadless runs each of providers over domains without the TUI, writing
+// the results to w in format, and returns the exit status. Results are
+// grouped by domain, in the order the providers are given. An address range
+// yields one result per provider holding the lookups of all its addresses.
+// Without any domains there is nothing to look up, which is a usage error;
+// the caller has already said where it looked.
+func runHeadless(ctx context.Context, providers []lookup.LookupProvider, domains []string, format string, w io.Writer) int {
+	if len(domains) == 0 {
+		return exitUsage
+	}
+	enc, err := lookup.NewEncoder(w, format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	for _, provider := range providers {
+		if !provider.CheckAvailability() {
+			fmt.Fprintf(os.Stderr, "Error: required command for %s not found\n", provider.Name())
+			return exitLookupFailed
+		}
+	}
+
+	// Lookups run concurrently within the scheduler's limits, but results
+	// are written in order.
+	// Providers that do not apply to an input, such as DIG (A) for an IP
+	// address, are skipped.
+	jobs := make([]pendingLookup, 0, len(domains)*len(providers))
+	for _, domain := range domains {
+		kind := lookup.DetectInputKind(domain)
+		for _, provider := range providers {
+			if !lookup.Accepts(provider, kind) {
+				fmt.Fprintf(os.Stderr, "Skipping %s: it does not apply to %s (%s)\n", provider.Name(), domain, kind)
+				continue
+			}
+			if kind == lookup.KindRange {
+				job, err := lookup.DefaultScheduler.ScheduleRange(ctx, provider, domain)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Skipping %s: %v\n", domain, err)
+					continue
+				}
+				jobs = append(jobs, job)
+				continue
+			}
+			jobs = append(jobs, lookup.DefaultScheduler.Schedule(ctx, provider, domain))
+		}
+	}
+	failed := 0
+	for _, job := range jobs {
+		result, _ := job.Wait()
+		if ctx.Err() != nil {
+			break
+		}
+		if resultFailed(result) {
+			failed++
+		}
+		if err := enc.Encode(result); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+			return exitLookupFailed
+		}
+	}
+	if err := enc.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		return exitLookupFailed
+	}
+
+	if ctx.Err() != nil {
+		fmt.Fprintln(os.Stderr, "Interrupted.")
+		return exitInterrupted
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d lookups failed.\n", failed, len(jobs))
+		return exitLookupFailed
+	}
+	return exitOK
+}
+
+// resultFailed reports whether r, or any lookup of a report, failed.
+func resultFailed(r *lookup.Result) bool {
+	if r.Err != nil {
+		return true
+	}
+	for _, child := range r.Results {
+		if resultFailed(child) {
+			return true
+		}
+	}
+	return false
+}
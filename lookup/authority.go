@@ -0,0 +1,145 @@
+package lookup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// NameServer is an authoritative name server of a zone.
+type NameServer struct {
+	Host string `json:"host"`
+	// Addr is the address queries are sent to, without a port.
+	Addr string `json:"addr"`
+}
+
+func (s NameServer) String() string {
+	return fmt.Sprintf("%s (%s)", strings.TrimSuffix(s.Host, "."), s.Addr)
+}
+
+// findNameServers returns the zone that name belongs to and its name
+// servers, asking r. It walks up from name until a name with NS records is
+// found, taking a short cut when a response names the zone in its SOA.
+// Name servers whose address cannot be found are left out.
+func findNameServers(ctx context.Context, r *Resolver, name string) (string, []NameServer, error) {
+	zone := Fqdn(name)
+	var resp *Message
+	for {
+		var err error
+		resp, _, err = r.Query(ctx, zone, TypeNS)
+		if err != nil {
+			return "", nil, fmt.Errorf("NS query for %s failed: %w", zone, err)
+		}
+		if hasOwnRecords(resp.Answer, zone, TypeNS) {
+			break
+		}
+		next := ""
+		for _, rr := range resp.Authority {
+			if rr.Type == TypeSOA && !strings.EqualFold(rr.Name, zone) && isSubdomain(zone, rr.Name) {
+				next = rr.Name
+				break
+			}
+		}
+		if next == "" {
+			next = parentZone(zone)
+		}
+		if next == "." || next == "" {
+			return "", nil, fmt.Errorf("no name servers found for %s", Fqdn(name))
+		}
+		zone = next
+	}
+
+	var servers []NameServer
+	for _, rr := range resp.Answer {
+		ns, ok := rr.Data.(*NSRecord)
+		if !ok || !strings.EqualFold(rr.Name, zone) {
+			continue
+		}
+		addr := glueAddr(resp.Additional, ns.Host)
+		if addr == "" {
+			addr = lookupAddr(ctx, r, ns.Host)
+		}
+		if addr != "" {
+			servers = append(servers, NameServer{Host: ns.Host, Addr: addr})
+		}
+	}
+	if len(servers) == 0 {
+		return zone, nil, fmt.Errorf("no addresses found for the name servers of %s", zone)
+	}
+	return zone, servers, nil
+}
+
+// hasOwnRecords reports whether rrs holds a record of type t owned by name.
+func hasOwnRecords(rrs []RR, name string, t RRType) bool {
+	for _, rr := range rrs {
+		if rr.Type == t && strings.EqualFold(rr.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSubdomain reports whether child is zone or lies below it.
+func isSubdomain(child, zone string) bool {
+	child, zone = strings.ToLower(Fqdn(child)), strings.ToLower(Fqdn(zone))
+	return zone == "." || child == zone || strings.HasSuffix(child, "."+zone)
+}
+
+// parentZone strips the first label of a fully qualified name.
+func parentZone(name string) string {
+	if name == "." {
+		return ""
+	}
+	if i := strings.IndexByte(name, '.'); i >= 0 && i+1 < len(name) {
+		return name[i+1:]
+	}
+	return "."
+}
+
+// glueAddr returns the first address of host among the additional records.
+func glueAddr(additional []RR, host string) string {
+	for _, t := range []RRType{TypeA, TypeAAAA} {
+		for _, rr := range additional {
+			if rr.Type == t && strings.EqualFold(rr.Name, host) {
+				return rr.Data.String()
+			}
+		}
+	}
+	return ""
+}
+
+// lookupAddr resolves host to its first IPv4 address, or IPv6 address if it
+// has none, or returns "".
+func lookupAddr(ctx context.Context, r *Resolver, host string) string {
+	for _, t := range []RRType{TypeA, TypeAAAA} {
+		resp, _, err := r.Query(ctx, host, t)
+		if err != nil {
+			continue
+		}
+		for _, rr := range resp.Answer {
+			if rr.Type == t {
+				return rr.Data.String()
+			}
+		}
+	}
+	return ""
+}
+
+// queryAuthoritative sends a non-recursive query for name and type t to the
+// name server at addr.
+func queryAuthoritative(ctx context.Context, addr, name string, t RRType) (*Message, error) {
+	q := NewQuery(name, t)
+	q.RecursionDesired = false
+	resp, _, err := resolverFor(addr).Exchange(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Rcode != RcodeSuccess && resp.Rcode != RcodeNameError {
+		return resp, &RcodeError{Name: Fqdn(name), Server: addr, Rcode: resp.Rcode}
+	}
+	if !resp.Authoritative {
+		// A referral, or a lame server that does not serve the zone.
+		return resp, fmt.Errorf("%s is not authoritative for %s", addr, Fqdn(name))
+	}
+	return resp, nil
+}
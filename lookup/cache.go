@@ -0,0 +1,175 @@
+package lookup
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache keeps successful lookup results so repeating a lookup, or running
+// the comprehensive report again, does not query the servers again. Results
+// with DNS records live for the smallest TTL among them, and empty DNS
+// answers for the negative-caching TTL of their zone; others, such as WHOIS
+// and RDAP, for the TTL configured for their provider.
+type Cache struct {
+	// Enabled turns the cache on. It is off in a zero Cache.
+	Enabled bool
+	// DefaultTTL applies to results without record TTLs whose provider has
+	// no TTL set with SetTTL.
+	DefaultTTL time.Duration
+
+	mu      sync.Mutex
+	ttls    map[string]time.Duration
+	entries map[cacheKey]cacheEntry
+}
+
+type cacheKey struct {
+	flagName string
+	server   string
+	domain   string
+}
+
+type cacheEntry struct {
+	result  *Result
+	stored  time.Time
+	expires time.Time
+}
+
+// DefaultCache is the cache used by RunLookup. It is disabled until the
+// application enables it.
+var DefaultCache = &Cache{DefaultTTL: 5 * time.Minute}
+
+// SetTTL sets how long results of the provider with the given flag name are
+// kept when they carry no record TTLs. Zero restores DefaultTTL.
+func (c *Cache) SetTTL(flagName string, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ttls == nil {
+		c.ttls = make(map[string]time.Duration)
+	}
+	if d <= 0 {
+		delete(c.ttls, flagName)
+		return
+	}
+	c.ttls[flagName] = d
+}
+
+// TTL returns how long r, a result of provider, may be cached: the smallest
+// record TTL, which may be zero, or the provider's configured TTL if r has
+// no records. DNS answers only live as long as DNS allows: one without
+// records for its NegativeTTL, and one whose records carry no TTLs (as with
+// `dig +short`) not at all.
+func (c *Cache) TTL(provider LookupProvider, r *Result) time.Duration {
+	if len(r.Records) > 0 && !r.NoTTLs {
+		minTTL := r.Records[0].TTL
+		for _, rr := range r.Records[1:] {
+			minTTL = min(minTTL, rr.TTL)
+		}
+		return time.Duration(minTTL) * time.Second
+	}
+	if isDNSQuery(provider) {
+		if r.NoTTLs {
+			return 0
+		}
+		return time.Duration(r.NegativeTTL) * time.Second
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d, ok := c.ttls[provider.FlagName()]; ok {
+		return d
+	}
+	return c.DefaultTTL
+}
+
+// isDNSQuery reports whether provider's results are plain DNS answers.
+func isDNSQuery(provider LookupProvider) bool {
+	switch provider.(type) {
+	case *DigProvider, *NativeDNSProvider:
+		return true
+	}
+	return false
+}
+
+// Get returns a copy of the cached result of provider for domain, as
+// answered by server ("" for the system resolver), marked as cached, if there
+// is one that has not expired.
+func (c *Cache) Get(provider LookupProvider, server, domain string) (*Result, bool) {
+	if !c.Enabled || isLive(provider) {
+		return nil, false
+	}
+	key := newCacheKey(provider, server, domain)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !time.Now().Before(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	r := *e.result
+	r.Cached = true
+	r.CachedAt = e.stored
+	r.Expires = e.expires
+	return &r, true
+}
+
+// Put stores r, a result of provider for domain from server. Failed lookups,
+// results with a TTL of zero, results of a LiveProvider and reports (whose
+// lookups are cached one by one) are not stored.
+func (c *Cache) Put(provider LookupProvider, server, domain string, r *Result) {
+	if !c.Enabled || isLive(provider) || r == nil || r.Err != nil || r.Cached || len(r.Results) > 0 {
+		return
+	}
+	ttl := c.TTL(provider, r)
+	if ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[cacheKey]cacheEntry)
+	}
+	now := time.Now()
+	c.entries[newCacheKey(provider, server, domain)] = cacheEntry{result: r, stored: now, expires: now.Add(ttl)}
+}
+
+// Clear drops every cached result.
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
+
+func newCacheKey(provider LookupProvider, server, domain string) cacheKey {
+	return cacheKey{flagName: provider.FlagName(), server: server, domain: strings.ToLower(strings.TrimSuffix(domain, "."))}
+}
+
+type refreshKey struct{}
+
+// WithRefresh returns a context whose lookups bypass the cache. Their
+// results still replace what is cached.
+func WithRefresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, refreshKey{}, true)
+}
+
+func isRefresh(ctx context.Context) bool {
+	refresh, _ := ctx.Value(refreshKey{}).(bool)
+	return refresh
+}
+
+// cachedResult returns the cached result for a lookup unless ctx asks for a
+// refresh.
+func cachedResult(ctx context.Context, provider LookupProvider, domain string) (*Result, bool) {
+	if isRefresh(ctx) {
+		return nil, false
+	}
+	r, ok := DefaultCache.Get(provider, ServerFromContext(ctx), domain)
+	if ok {
+		r.Provider = provider.Name()
+		r.Query = domain
+	}
+	return r, ok
+}
@@ -0,0 +1,192 @@
+package lookup_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"dlookup/lookup"
+)
+
+// enableCache turns DefaultCache on for the duration of a test.
+func enableCache(t *testing.T) {
+	t.Helper()
+	lookup.DefaultCache.Clear()
+	lookup.DefaultCache.Enabled = true
+	t.Cleanup(func() {
+		lookup.DefaultCache.Enabled = false
+		lookup.DefaultCache.Clear()
+	})
+}
+
+// callCountProvider returns result (or err) and counts its calls.
+type callCountProvider struct {
+	mockProvider
+	result *lookup.Result
+	err    error
+	calls  int
+}
+
+func (p *callCountProvider) Execute(ctx context.Context, domain string) (*lookup.Result, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	r := *p.result
+	return &r, nil
+}
+
+func rrWithTTL(ttl uint32) lookup.RR {
+	return aRecord("example.com.", "192.0.2.1", ttl)
+}
+
+func TestCache_TTL(t *testing.T) {
+	var c lookup.Cache
+	c.DefaultTTL = time.Minute
+	c.SetTTL("whois", time.Hour)
+	dns := &mockProvider{flagName: "dns-a"}
+	whois := &mockProvider{flagName: "whois"}
+	digA, _ := lookup.GetProvider("DIG (A)")
+	nativeA, _ := lookup.GetProvider("DNS (A)")
+
+	tests := []struct {
+		name     string
+		provider lookup.LookupProvider
+		result   *lookup.Result
+		want     time.Duration
+	}{
+		{"smallest record TTL", dns, &lookup.Result{Records: []lookup.RR{rrWithTTL(300), rrWithTTL(60), rrWithTTL(3600)}}, 60 * time.Second},
+		{"TTL of zero", dns, &lookup.Result{Records: []lookup.RR{rrWithTTL(300), rrWithTTL(0)}}, 0},
+		{"records without TTLs", dns, &lookup.Result{Records: []lookup.RR{rrWithTTL(0)}, NoTTLs: true}, time.Minute},
+		{"no records, provider TTL", whois, &lookup.Result{Stdout: "Registrar: Example"}, time.Hour},
+		{"no records, default TTL", dns, &lookup.Result{}, time.Minute},
+		{"DNS answer without TTLs", digA, &lookup.Result{Records: []lookup.RR{rrWithTTL(0)}, NoTTLs: true}, 0},
+		{"empty DNS answer, SOA minimum", nativeA, &lookup.Result{NegativeTTL: 30}, 30 * time.Second},
+		{"empty DNS answer without SOA", digA, &lookup.Result{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.TTL(tt.provider, tt.result); got != tt.want {
+				t.Errorf("TTL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunLookup_ServesFromCache(t *testing.T) {
+	enableCache(t)
+	p := &callCountProvider{
+		mockProvider: mockProvider{name: "cache-test", flagName: "cache-test"},
+		result:       &lookup.Result{Records: []lookup.RR{rrWithTTL(300)}, Stdout: "live"},
+	}
+	ctx := context.Background()
+
+	first, err := lookup.RunLookup(ctx, p, "Example.com")
+	if err != nil || first.Cached {
+		t.Fatalf("first RunLookup() = (cached %v, %v), want a live result", first.Cached, err)
+	}
+	second, err := lookup.RunLookup(ctx, p, "example.com.")
+	if err != nil {
+		t.Fatalf("second RunLookup() error = %v", err)
+	}
+	if p.calls != 1 {
+		t.Errorf("provider called %d times, want 1", p.calls)
+	}
+	if !second.Cached || second.Stdout != "live" || second.Query != "example.com." {
+		t.Errorf("second RunLookup() = %+v, want the cached result for the new query", second)
+	}
+	if got := second.Expires.Sub(second.CachedAt); got != 300*time.Second {
+		t.Errorf("cached for %s, want the record TTL of 5m0s", got)
+	}
+	if first.Cached {
+		t.Error("serving from the cache marked the stored result as cached")
+	}
+
+	data, _ := json.Marshal(second)
+	var decoded map[string]any
+	json.Unmarshal(data, &decoded)
+	if decoded["cached"] != true || decoded["cached_at"] == nil || decoded["expires"] == nil {
+		t.Errorf("JSON of a cached result = %s, want cached, cached_at and expires", data)
+	}
+
+	refreshed, err := lookup.RunLookup(lookup.WithRefresh(ctx), p, "example.com")
+	if err != nil || refreshed.Cached || p.calls != 2 {
+		t.Errorf("refresh: cached %v, %d calls, err %v; want a live result from a second call", refreshed.Cached, p.calls, err)
+	}
+}
+
+func TestRunLookup_CacheExpiresAndSkipsFailures(t *testing.T) {
+	enableCache(t)
+	lookup.DefaultCache.SetTTL("cache-expiry", 20*time.Millisecond)
+	defer lookup.DefaultCache.SetTTL("cache-expiry", 0)
+	p := &callCountProvider{
+		mockProvider: mockProvider{name: "cache-expiry", flagName: "cache-expiry"},
+		result:       &lookup.Result{Stdout: "Registrar: Example"},
+	}
+	ctx := context.Background()
+
+	lookup.RunLookup(ctx, p, "example.com")
+	lookup.RunLookup(ctx, p, "example.com")
+	if p.calls != 1 {
+		t.Fatalf("provider called %d times before expiry, want 1", p.calls)
+	}
+	time.Sleep(30 * time.Millisecond)
+	lookup.RunLookup(ctx, p, "example.com")
+	if p.calls != 2 {
+		t.Errorf("provider called %d times after expiry, want 2", p.calls)
+	}
+
+	failing := &callCountProvider{
+		mockProvider: mockProvider{name: "cache-failing", flagName: "cache-failing"},
+		err:          errors.New("no such domain"),
+	}
+	lookup.RunLookup(ctx, failing, "example.com")
+	lookup.RunLookup(ctx, failing, "example.com")
+	if failing.calls != 2 {
+		t.Errorf("failing provider called %d times, want 2 (errors are not cached)", failing.calls)
+	}
+}
+
+func TestRunLookup_ZeroTTLNotCached(t *testing.T) {
+	enableCache(t)
+	p := &callCountProvider{
+		mockProvider: mockProvider{name: "cache-zero-ttl", flagName: "cache-zero-ttl"},
+		result:       &lookup.Result{Records: []lookup.RR{rrWithTTL(0)}, Stdout: "live"},
+	}
+	for i := 0; i < 2; i++ {
+		if result, err := lookup.RunLookup(context.Background(), p, "example.com"); err != nil || result.Cached {
+			t.Fatalf("RunLookup() = (cached %v, %v), want a live result", result.Cached, err)
+		}
+	}
+	if p.calls != 2 {
+		t.Errorf("provider called %d times, want 2 (a TTL of zero is not cached)", p.calls)
+	}
+}
+
+func TestScheduler_CachedLookupSkipsQueue(t *testing.T) {
+	enableCache(t)
+	p := &callCountProvider{
+		mockProvider: mockProvider{name: "cache-sched", flagName: "cache-sched"},
+		result:       &lookup.Result{Stdout: "live"},
+	}
+	if _, err := lookup.RunLookup(context.Background(), p, "example.com"); err != nil {
+		t.Fatalf("RunLookup() error = %v", err)
+	}
+
+	// With no free slot, only a cached lookup can finish.
+	blocker := newGateProvider("cache-sched-blocker")
+	s := lookup.NewScheduler(1, nil)
+	busy := s.Schedule(context.Background(), blocker, "busy.example")
+	// The blocker must finish before enableCache's cleanup touches the cache.
+	defer func() {
+		close(blocker.release)
+		busy.Wait()
+	}()
+
+	result, _ := s.Schedule(context.Background(), p, "example.com").Wait()
+	if !result.Cached || p.calls != 1 {
+		t.Errorf("Wait() = cached %v after %d calls, want the cached result", result.Cached, p.calls)
+	}
+}
@@ -0,0 +1,595 @@
+package lookup
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseDigAnswer parses the records dig prints for `+noall +answer`. Given
+// dig's full output it reads only the ANSWER SECTION. Comment lines (";;")
+// and blank lines are skipped.
+func ParseDigAnswer(output string) ([]RR, error) {
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ";; ANSWER SECTION:") {
+			lines = lines[i+1:]
+			for j, l := range lines {
+				if strings.TrimSpace(l) == "" {
+					lines = lines[:j]
+					break
+				}
+			}
+			break
+		}
+	}
+
+	var records []RR
+	for n, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		rr, err := parseDigRR(line)
+		if err != nil {
+			return records, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		records = append(records, rr)
+	}
+	return records, nil
+}
+
+// parseDigRR parses one answer line: owner, TTL, class, type and rdata.
+func parseDigRR(line string) (RR, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return RR{}, fmt.Errorf("malformed record %q", line)
+	}
+	rr := RR{Name: fields[0], Class: ClassINET}
+	i := 1
+	if ttl, err := strconv.ParseUint(fields[i], 10, 32); err == nil {
+		rr.TTL = uint32(ttl)
+		i++
+	}
+	if i < len(fields) {
+		if class, err := ParseClass(fields[i]); err == nil {
+			rr.Class = class
+			i++
+		}
+	}
+	if i >= len(fields) {
+		return RR{}, fmt.Errorf("malformed record %q", line)
+	}
+	t, err := ParseRRType(fields[i])
+	if err != nil {
+		return RR{}, err
+	}
+	rr.Type = t
+
+	// The rdata is everything after the type field, with its spacing (and
+	// so any quoted strings) kept intact.
+	rest := line
+	for j := 0; j <= i; j++ {
+		rest = strings.TrimLeft(rest, " \t")
+		rest = rest[len(fields[j]):]
+	}
+	if rr.Data, err = ParseRData(t, strings.TrimSpace(rest)); err != nil {
+		return RR{}, fmt.Errorf("%s record for %s: %w", t, rr.Name, err)
+	}
+	return rr, nil
+}
+
+// ParseDigShort parses `dig +short` output for a query of type qtype on
+// name. +short prints only rdata, so TTLs are zero. When the name is an
+// alias, dig first prints the CNAME targets; those become CNAME records and
+// the following records are owned by the last target.
+func ParseDigShort(name string, qtype RRType, output string) ([]RR, error) {
+	var records []RR
+	owner := Fqdn(name)
+	for n, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		t := qtype
+		if qtype != TypeCNAME && isShortCNAME(qtype, line) {
+			t = TypeCNAME
+		}
+		data, err := ParseRData(t, line)
+		if err != nil {
+			return records, fmt.Errorf("line %d: %s record for %s: %w", n+1, t, owner, err)
+		}
+		records = append(records, RR{Name: owner, Type: t, Class: ClassINET, Data: data})
+		if cname, ok := data.(*CNAMERecord); ok && t != qtype {
+			owner = cname.Target
+		}
+	}
+	return records, nil
+}
+
+// isShortCNAME reports whether a +short line for qtype is really an alias
+// target: a single domain name where qtype's rdata would look different.
+func isShortCNAME(qtype RRType, line string) bool {
+	if strings.ContainsAny(line, " \t") || !strings.HasSuffix(line, ".") {
+		return false
+	}
+	switch qtype {
+	case TypeA, TypeAAAA, TypeMX, TypeTXT, TypeSOA:
+		return true
+	}
+	return false
+}
+
+// ParseRData parses rdata in the presentation format dig prints. Types this
+// package does not decode are returned as *GenericRecord, and the RFC 3597
+// generic form (`\# len hex`) as *UnknownRecord.
+func ParseRData(t RRType, s string) (RData, error) {
+	if strings.HasPrefix(s, `\# `) {
+		return parseGenericRData(s)
+	}
+	fields := strings.Fields(s)
+	switch t {
+	case TypeA, TypeAAAA:
+		if len(fields) != 1 {
+			return nil, fmt.Errorf("invalid address %q", s)
+		}
+		ip := net.ParseIP(fields[0])
+		if t == TypeA {
+			if ip == nil || ip.To4() == nil {
+				return nil, fmt.Errorf("invalid IPv4 address %q", s)
+			}
+			return &ARecord{IP: ip.To4()}, nil
+		}
+		if ip == nil || ip.To4() != nil {
+			return nil, fmt.Errorf("invalid IPv6 address %q", s)
+		}
+		return &AAAARecord{IP: ip}, nil
+	case TypeNS, TypeCNAME, TypePTR:
+		if len(fields) != 1 {
+			return nil, fmt.Errorf("invalid domain name %q", s)
+		}
+		switch t {
+		case TypeNS:
+			return &NSRecord{Host: fields[0]}, nil
+		case TypeCNAME:
+			return &CNAMERecord{Target: fields[0]}, nil
+		}
+		return &PTRRecord{Ptr: fields[0]}, nil
+	case TypeMX:
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid MX rdata %q", s)
+		}
+		pref, err := strconv.ParseUint(fields[0], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MX preference %q", fields[0])
+		}
+		return &MXRecord{Preference: uint16(pref), Exchange: fields[1]}, nil
+	case TypeTXT:
+		strs, err := parseCharacterStrings(s)
+		if err != nil {
+			return nil, err
+		}
+		return &TXTRecord{Strings: strs}, nil
+	case TypeSOA:
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("invalid SOA rdata %q", s)
+		}
+		var nums [5]uint32
+		for i, f := range fields[2:] {
+			n, err := strconv.ParseUint(f, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid SOA field %q", f)
+			}
+			nums[i] = uint32(n)
+		}
+		return &SOARecord{
+			MName: fields[0], RName: fields[1],
+			Serial: nums[0], Refresh: nums[1], Retry: nums[2], Expire: nums[3], Minimum: nums[4],
+		}, nil
+	case TypeSRV:
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("invalid SRV rdata %q", s)
+		}
+		nums, err := parseUints(t, fields[:3], 16, 16, 16)
+		if err != nil {
+			return nil, err
+		}
+		return &SRVRecord{Priority: uint16(nums[0]), Weight: uint16(nums[1]), Port: uint16(nums[2]), Target: fields[3]}, nil
+	case TypeCAA:
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("invalid CAA rdata %q", s)
+		}
+		nums, err := parseUints(t, fields[:1], 8)
+		if err != nil {
+			return nil, err
+		}
+		value, err := parseCharacterStrings(rdataAfter(s, fields[:2]))
+		if err != nil || len(value) != 1 {
+			return nil, fmt.Errorf("invalid CAA value in %q", s)
+		}
+		return &CAARecord{Flags: uint8(nums[0]), Tag: fields[1], Value: value[0]}, nil
+	case TypeDS, TypeTLSA:
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("invalid %s rdata %q", t, s)
+		}
+		bits := []int{16, 8, 8}
+		if t == TypeTLSA {
+			bits = []int{8, 8, 8}
+		}
+		nums, err := parseUints(t, fields[:3], bits...)
+		if err != nil {
+			return nil, err
+		}
+		data, err := hex.DecodeString(strings.Join(fields[3:], ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s data in %q", t, s)
+		}
+		if t == TypeTLSA {
+			return &TLSARecord{Usage: uint8(nums[0]), Selector: uint8(nums[1]), MatchingType: uint8(nums[2]), Data: data}, nil
+		}
+		return &DSRecord{KeyTag: uint16(nums[0]), Algorithm: uint8(nums[1]), DigestType: uint8(nums[2]), Digest: data}, nil
+	case TypeDNSKEY:
+		if i := strings.IndexByte(s, ';'); i >= 0 {
+			fields = strings.Fields(s[:i])
+		}
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("invalid DNSKEY rdata %q", s)
+		}
+		nums, err := parseUints(t, fields[:3], 16, 8, 8)
+		if err != nil {
+			return nil, err
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.Join(fields[3:], ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid DNSKEY public key in %q", s)
+		}
+		return &DNSKEYRecord{Flags: uint16(nums[0]), Protocol: uint8(nums[1]), Algorithm: uint8(nums[2]), PublicKey: key}, nil
+	case TypeRRSIG:
+		if len(fields) < 9 {
+			return nil, fmt.Errorf("invalid RRSIG rdata %q", s)
+		}
+		covered, err := ParseRRType(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		nums, err := parseUints(t, fields[1:4], 8, 8, 32)
+		if err != nil {
+			return nil, err
+		}
+		var times [2]uint32
+		for i, f := range fields[4:6] {
+			if times[i], err = parseRRSIGTime(f); err != nil {
+				return nil, err
+			}
+		}
+		keyTag, err := parseUints(t, fields[6:7], 16)
+		if err != nil {
+			return nil, err
+		}
+		sig, err := base64.StdEncoding.DecodeString(strings.Join(fields[8:], ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid RRSIG signature in %q", s)
+		}
+		return &RRSIGRecord{
+			TypeCovered: covered, Algorithm: uint8(nums[0]), Labels: uint8(nums[1]), OrigTTL: uint32(nums[2]),
+			Expiration: times[0], Inception: times[1], KeyTag: uint16(keyTag[0]), SignerName: fields[7], Signature: sig,
+		}, nil
+	case TypeNSEC:
+		if len(fields) < 1 {
+			return nil, fmt.Errorf("invalid NSEC rdata %q", s)
+		}
+		types, err := parseTypeList(fields[1:])
+		if err != nil {
+			return nil, err
+		}
+		return &NSECRecord{NextName: fields[0], Types: types}, nil
+	case TypeNSEC3:
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("invalid NSEC3 rdata %q", s)
+		}
+		nums, err := parseUints(t, fields[:3], 8, 8, 16)
+		if err != nil {
+			return nil, err
+		}
+		var salt []byte
+		if fields[3] != "-" {
+			if salt, err = hex.DecodeString(fields[3]); err != nil {
+				return nil, fmt.Errorf("invalid NSEC3 salt in %q", s)
+			}
+		}
+		next, err := nsec3Encoding.DecodeString(strings.ToUpper(fields[4]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid NSEC3 next hashed owner name in %q", s)
+		}
+		types, err := parseTypeList(fields[5:])
+		if err != nil {
+			return nil, err
+		}
+		return &NSEC3Record{
+			HashAlgorithm: uint8(nums[0]), Flags: uint8(nums[1]), Iterations: uint16(nums[2]),
+			Salt: salt, NextHashed: next, Types: types,
+		}, nil
+	case TypeSSHFP:
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("invalid SSHFP rdata %q", s)
+		}
+		nums, err := parseUints(t, fields[:2], 8, 8)
+		if err != nil {
+			return nil, err
+		}
+		fp, err := hex.DecodeString(strings.Join(fields[2:], ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid SSHFP fingerprint in %q", s)
+		}
+		return &SSHFPRecord{Algorithm: uint8(nums[0]), Type: uint8(nums[1]), Fingerprint: fp}, nil
+	case TypeNAPTR:
+		if len(fields) < 6 {
+			return nil, fmt.Errorf("invalid NAPTR rdata %q", s)
+		}
+		nums, err := parseUints(t, fields[:2], 16, 16)
+		if err != nil {
+			return nil, err
+		}
+		strs, err := parseCharacterStrings(rdataAfter(s, fields[:2]))
+		if err != nil || len(strs) != 4 {
+			return nil, fmt.Errorf("invalid NAPTR rdata %q", s)
+		}
+		return &NAPTRRecord{
+			Order: uint16(nums[0]), Preference: uint16(nums[1]),
+			Flags: strs[0], Services: strs[1], Regexp: strs[2], Replacement: strs[3],
+		}, nil
+	case TypeSVCB, TypeHTTPS:
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid %s rdata %q", t, s)
+		}
+		nums, err := parseUints(t, fields[:1], 16)
+		if err != nil {
+			return nil, err
+		}
+		r := &SVCBRecord{Priority: uint16(nums[0]), Target: fields[1]}
+		if r.Params, err = parseSvcParams(rdataAfter(s, fields[:2])); err != nil {
+			return nil, fmt.Errorf("%s rdata %q: %w", t, s, err)
+		}
+		return r, nil
+	}
+	return &GenericRecord{Text: s}, nil
+}
+
+// parseRRSIGTime parses an RRSIG validity time, given either as
+// YYYYMMDDHHmmSS or as seconds since the epoch (RFC 4034, section 3.2).
+func parseRRSIGTime(s string) (uint32, error) {
+	if len(s) == len(rrsigTimeFormat) {
+		if t, err := time.Parse(rrsigTimeFormat, s); err == nil {
+			return uint32(t.Unix()), nil
+		}
+	}
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid RRSIG time %q", s)
+	}
+	return uint32(n), nil
+}
+
+// rdataAfter returns what follows the leading fields of s, with its spacing
+// (and so any quoted strings) kept intact.
+func rdataAfter(s string, fields []string) string {
+	for _, f := range fields {
+		s = strings.TrimLeft(s, " \t")[len(f):]
+	}
+	return strings.TrimSpace(s)
+}
+
+// parseUints parses the leading numeric fields of t's rdata, each of the
+// given bit size.
+func parseUints(t RRType, fields []string, bits ...int) ([]uint64, error) {
+	nums := make([]uint64, len(fields))
+	for i, f := range fields {
+		n, err := strconv.ParseUint(f, 10, bits[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s field %q", t, f)
+		}
+		nums[i] = n
+	}
+	return nums, nil
+}
+
+// parseTypeList parses the type mnemonics of an NSEC or NSEC3 type bitmap.
+func parseTypeList(fields []string) ([]RRType, error) {
+	types := make([]RRType, 0, len(fields))
+	for _, f := range fields {
+		t, err := ParseRRType(f)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+// parseSvcParams parses the key=value parameters of SVCB and HTTPS rdata.
+// Values may be quoted; the keys are sorted as the wire format requires.
+func parseSvcParams(s string) ([]SvcParam, error) {
+	var params []SvcParam
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		end := 0
+		for quoted := false; end < len(s); end++ {
+			c := s[end]
+			if c == '\\' {
+				end++
+				continue
+			}
+			if c == '"' {
+				quoted = !quoted
+			}
+			if !quoted && (c == ' ' || c == '\t') {
+				break
+			}
+		}
+		token := s[:min(end, len(s))]
+		s = s[min(end, len(s)):]
+
+		name, value, _ := strings.Cut(token, "=")
+		if strings.HasPrefix(value, `"`) {
+			strs, err := parseCharacterStrings(value)
+			if err != nil || len(strs) != 1 {
+				return nil, fmt.Errorf("invalid value in %q", token)
+			}
+			value = strs[0]
+		}
+		p, err := parseSvcParam(name, value)
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, p)
+	}
+	sort.Slice(params, func(i, j int) bool { return params[i].Key < params[j].Key })
+	return params, nil
+}
+
+func parseSvcParam(name, value string) (SvcParam, error) {
+	key, ok := svcParamKey(name)
+	if !ok {
+		return SvcParam{}, fmt.Errorf("unknown parameter %q", name)
+	}
+	p := SvcParam{Key: key}
+	switch key {
+	case SvcParamMandatory:
+		for _, k := range strings.Split(value, ",") {
+			mk, ok := svcParamKey(k)
+			if !ok {
+				return p, fmt.Errorf("unknown mandatory key %q", k)
+			}
+			p.Value = binary.BigEndian.AppendUint16(p.Value, mk)
+		}
+	case SvcParamALPN:
+		for _, id := range strings.Split(value, ",") {
+			if id == "" || len(id) > 255 {
+				return p, fmt.Errorf("invalid alpn %q", value)
+			}
+			p.Value = append(p.Value, byte(len(id)))
+			p.Value = append(p.Value, id...)
+		}
+	case SvcParamNoDefaultALPN:
+		if value != "" {
+			return p, fmt.Errorf("no-default-alpn takes no value")
+		}
+	case SvcParamPort:
+		n, err := strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			return p, fmt.Errorf("invalid port %q", value)
+		}
+		p.Value = binary.BigEndian.AppendUint16(nil, uint16(n))
+	case SvcParamIPv4Hint, SvcParamIPv6Hint:
+		for _, addr := range strings.Split(value, ",") {
+			ip := net.ParseIP(addr)
+			if key == SvcParamIPv4Hint && (ip == nil || ip.To4() == nil) {
+				return p, fmt.Errorf("invalid ipv4hint %q", addr)
+			}
+			if key == SvcParamIPv6Hint && (ip == nil || ip.To4() != nil) {
+				return p, fmt.Errorf("invalid ipv6hint %q", addr)
+			}
+			if key == SvcParamIPv4Hint {
+				ip = ip.To4()
+			}
+			p.Value = append(p.Value, ip...)
+		}
+	case SvcParamECH:
+		data, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return p, fmt.Errorf("invalid ech %q", value)
+		}
+		p.Value = data
+	default:
+		p.Value = []byte(value)
+	}
+	return p, nil
+}
+
+// svcParamKey parses a parameter name such as "alpn" or the generic "key65".
+func svcParamKey(name string) (uint16, bool) {
+	for key, n := range svcParamKeyNames {
+		if n == name {
+			return key, true
+		}
+	}
+	if rest, ok := strings.CutPrefix(name, "key"); ok {
+		if n, err := strconv.ParseUint(rest, 10, 16); err == nil {
+			return uint16(n), true
+		}
+	}
+	return 0, false
+}
+
+func parseGenericRData(s string) (RData, error) {
+	fields := strings.Fields(s)
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("invalid generic rdata %q", s)
+	}
+	n, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid generic rdata length %q", fields[1])
+	}
+	data, err := hex.DecodeString(strings.Join(fields[2:], ""))
+	if err != nil || len(data) != n {
+		return nil, fmt.Errorf("invalid generic rdata %q", s)
+	}
+	return &UnknownRecord{Data: data}, nil
+}
+
+// parseCharacterStrings splits TXT rdata into its character-strings. Each is
+// either quoted or a bare word; \X and \DDD escapes are decoded.
+func parseCharacterStrings(s string) ([]string, error) {
+	var strs []string
+	for i := 0; i < len(s); {
+		if s[i] == ' ' || s[i] == '\t' {
+			i++
+			continue
+		}
+		quoted := s[i] == '"'
+		if quoted {
+			i++
+		}
+		var sb strings.Builder
+		closed := !quoted
+		for i < len(s) {
+			c := s[i]
+			if quoted && c == '"' {
+				i++
+				closed = true
+				break
+			}
+			if !quoted && (c == ' ' || c == '\t') {
+				break
+			}
+			if c == '\\' {
+				if i+3 < len(s) && isDigit(s[i+1]) && isDigit(s[i+2]) && isDigit(s[i+3]) {
+					n, _ := strconv.Atoi(s[i+1 : i+4])
+					if n > 255 {
+						return nil, fmt.Errorf("invalid escape %q", s[i:i+4])
+					}
+					sb.WriteByte(byte(n))
+					i += 4
+					continue
+				}
+				if i+1 < len(s) {
+					sb.WriteByte(s[i+1])
+					i += 2
+					continue
+				}
+			}
+			sb.WriteByte(c)
+			i++
+		}
+		if !closed {
+			return nil, fmt.Errorf("unterminated string in %q", s)
+		}
+		strs = append(strs, sb.String())
+	}
+	return strs, nil
+}
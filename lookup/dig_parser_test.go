@@ -0,0 +1,178 @@
+package lookup_test
+
+import (
+	"dlookup/lookup"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+var digGoldenCases = []struct {
+	file  string
+	short bool
+	name  string
+	qtype lookup.RRType
+}{
+	{file: "answer_any"},
+	{file: "answer_any_rfc8482"},
+	{file: "answer_soa"},
+	{file: "answer_txt"},
+	{file: "answer_txt_multistring"},
+	{file: "answer_txt_escapes"},
+	{file: "answer_with_warnings"},
+	{file: "answer_ns"},
+	{file: "answer_ptr"},
+	{file: "answer_srv"},
+	{file: "answer_caa"},
+	{file: "answer_ds"},
+	{file: "answer_dnskey"},
+	{file: "answer_https"},
+	{file: "answer_svcb"},
+	{file: "answer_tlsa"},
+	{file: "answer_sshfp"},
+	{file: "answer_naptr"},
+	{file: "answer_nsec"},
+	{file: "answer_nsec3"},
+	{file: "full_a"},
+	{file: "short_a", short: true, name: "www.github.com", qtype: lookup.TypeA},
+	{file: "short_a_empty", short: true, name: "nodata.example.com", qtype: lookup.TypeA},
+	{file: "short_aaaa", short: true, name: "google.com", qtype: lookup.TypeAAAA},
+	{file: "short_mx", short: true, name: "gmail.com", qtype: lookup.TypeMX},
+	{file: "short_cname", short: true, name: "www.github.com", qtype: lookup.TypeCNAME},
+}
+
+// goldenRecord is the golden-file form of a parsed record. RData is kept as
+// the typed struct so the golden files show every decoded field.
+type goldenRecord struct {
+	Name      string
+	TTL       uint32
+	Class     string
+	Type      string
+	RDataType string
+	RData     lookup.RData
+}
+
+func TestDigParser_Golden(t *testing.T) {
+	for _, tc := range digGoldenCases {
+		t.Run(tc.file, func(t *testing.T) {
+			input, err := os.ReadFile(filepath.Join("testdata", "dig", tc.file+".txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var records []lookup.RR
+			if tc.short {
+				records, err = lookup.ParseDigShort(tc.name, tc.qtype, string(input))
+			} else {
+				records, err = lookup.ParseDigAnswer(string(input))
+			}
+			if err != nil {
+				t.Fatalf("parse error = %v", err)
+			}
+
+			golden := make([]goldenRecord, 0, len(records))
+			for _, rr := range records {
+				golden = append(golden, goldenRecord{
+					Name: rr.Name, TTL: rr.TTL, Class: rr.Class.String(), Type: rr.Type.String(),
+					RDataType: fmt.Sprintf("%T", rr.Data), RData: rr.Data,
+				})
+			}
+			got, err := json.MarshalIndent(golden, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			goldenPath := filepath.Join("testdata", "dig", tc.file+".golden")
+			if *updateGolden {
+				if err := os.WriteFile(goldenPath, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("parsed records differ from %s.\nGot:\n%s\nWant:\n%s", goldenPath, got, want)
+			}
+		})
+	}
+}
+
+func TestParseDigAnswer_RoundTrip(t *testing.T) {
+	// Rendering parsed records must give back dig's own rdata text.
+	for _, file := range []string{"answer_any", "answer_srv", "answer_caa", "answer_https", "answer_svcb", "answer_naptr", "answer_nsec", "answer_nsec3"} {
+		input, err := os.ReadFile(filepath.Join("testdata", "dig", file+".txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := lookup.ParseDigAnswer(string(input))
+		if err != nil {
+			t.Fatalf("%s: ParseDigAnswer() error = %v", file, err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(input)), "\n")
+		for i, rr := range records {
+			fields := strings.Fields(lines[i])
+			want := strings.Join(fields[4:], " ")
+			if got := rr.Data.String(); got != want {
+				t.Errorf("%s: record %d rdata = %q, want %q", file, i, got, want)
+			}
+		}
+	}
+}
+
+func TestParseDigAnswer_Errors(t *testing.T) {
+	for _, input := range []string{
+		"example.com.\t300\tIN\tA\tnot-an-ip",
+		"example.com.\t300\tIN\tAAAA\t192.0.2.1",
+		"example.com.\t300\tIN\tMX\tmail.example.com.",
+		"example.com.\t300\tIN\tSOA\tns. host. 1 2 3",
+		"example.com.\t300\tIN\tTXT\t\"unterminated",
+		"example.com.\t300\tIN\tBOGUS\tdata",
+		"_sip._tcp.example.com.\t300\tIN\tSRV\t10 60 sip.example.com.",
+		"example.com.\t300\tIN\tCAA\t0 issue",
+		"example.com.\t300\tIN\tDS\t2371 13 2 XYZ",
+		"example.com.\t300\tIN\tDNSKEY\t257 3 13 not*base64",
+		"example.com.\t300\tIN\tHTTPS\t1 . bogus=1",
+		"example.com.\t300\tIN\tHTTPS\t1 . port=http",
+		"example.com.\t300\tIN\tNAPTR\t100 10 \"S\" \"SIP+D2U\"",
+		"example.com.\t300",
+	} {
+		if _, err := lookup.ParseDigAnswer(input); err == nil {
+			t.Errorf("ParseDigAnswer(%q) error = nil, want error", input)
+		}
+	}
+}
+
+func TestParseDigShort_AliasChain(t *testing.T) {
+	records, err := lookup.ParseDigShort("www.example.com", lookup.TypeMX, "mail.example.net.\n10 mx.example.net.\n")
+	if err != nil {
+		t.Fatalf("ParseDigShort() error = %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("ParseDigShort() = %d records, want 2", len(records))
+	}
+	if records[0].Type != lookup.TypeCNAME || records[0].Name != "www.example.com." {
+		t.Errorf("first record = %v, want CNAME owned by www.example.com.", records[0])
+	}
+	if records[1].Type != lookup.TypeMX || records[1].Name != "mail.example.net." {
+		t.Errorf("second record = %v, want MX owned by the alias target", records[1])
+	}
+}
+
+func TestTXTRecord_TextJoinsStrings(t *testing.T) {
+	data, err := lookup.ParseRData(lookup.TypeTXT, `"v=DKIM1; k=rsa; " "p=abc"`)
+	if err != nil {
+		t.Fatalf("ParseRData() error = %v", err)
+	}
+	txt := data.(*lookup.TXTRecord)
+	if len(txt.Strings) != 2 || txt.Text() != "v=DKIM1; k=rsa; p=abc" {
+		t.Errorf("TXT strings = %q, Text() = %q", txt.Strings, txt.Text())
+	}
+}
@@ -0,0 +1,564 @@
+package lookup
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// RRType is a DNS resource record type.
+type RRType uint16
+
+const (
+	TypeA          RRType = 1
+	TypeNS         RRType = 2
+	TypeCNAME      RRType = 5
+	TypeSOA        RRType = 6
+	TypePTR        RRType = 12
+	TypeHINFO      RRType = 13
+	TypeMX         RRType = 15
+	TypeTXT        RRType = 16
+	TypeAAAA       RRType = 28
+	TypeSRV        RRType = 33
+	TypeNAPTR      RRType = 35
+	TypeOPT        RRType = 41
+	TypeDS         RRType = 43
+	TypeSSHFP      RRType = 44
+	TypeRRSIG      RRType = 46
+	TypeNSEC       RRType = 47
+	TypeDNSKEY     RRType = 48
+	TypeNSEC3      RRType = 50
+	TypeNSEC3PARAM RRType = 51
+	TypeTLSA       RRType = 52
+	TypeSVCB       RRType = 64
+	TypeHTTPS      RRType = 65
+	TypeANY        RRType = 255
+	TypeCAA        RRType = 257
+)
+
+var rrTypeNames = map[RRType]string{
+	TypeA:          "A",
+	TypeNS:         "NS",
+	TypeCNAME:      "CNAME",
+	TypeSOA:        "SOA",
+	TypePTR:        "PTR",
+	TypeHINFO:      "HINFO",
+	TypeMX:         "MX",
+	TypeTXT:        "TXT",
+	TypeAAAA:       "AAAA",
+	TypeSRV:        "SRV",
+	TypeNAPTR:      "NAPTR",
+	TypeOPT:        "OPT",
+	TypeDS:         "DS",
+	TypeSSHFP:      "SSHFP",
+	TypeRRSIG:      "RRSIG",
+	TypeNSEC:       "NSEC",
+	TypeDNSKEY:     "DNSKEY",
+	TypeNSEC3:      "NSEC3",
+	TypeNSEC3PARAM: "NSEC3PARAM",
+	TypeTLSA:       "TLSA",
+	TypeSVCB:       "SVCB",
+	TypeHTTPS:      "HTTPS",
+	TypeANY:        "ANY",
+	TypeCAA:        "CAA",
+}
+
+func (t RRType) String() string {
+	if name, ok := rrTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("TYPE%d", uint16(t))
+}
+
+// ParseRRType parses a type mnemonic such as "MX" or the generic "TYPE65".
+func ParseRRType(s string) (RRType, error) {
+	s = strings.ToUpper(s)
+	for t, name := range rrTypeNames {
+		if name == s {
+			return t, nil
+		}
+	}
+	if rest, ok := strings.CutPrefix(s, "TYPE"); ok {
+		if n, err := strconv.ParseUint(rest, 10, 16); err == nil {
+			return RRType(n), nil
+		}
+	}
+	return 0, fmt.Errorf("dns: unknown record type %q", s)
+}
+
+// Class is a DNS class. Only IN is used in practice.
+type Class uint16
+
+const ClassINET Class = 1
+
+// ParseClass parses a class mnemonic such as "IN" or the generic "CLASS3".
+func ParseClass(s string) (Class, error) {
+	switch s = strings.ToUpper(s); s {
+	case "IN":
+		return ClassINET, nil
+	case "CH":
+		return 3, nil
+	case "HS":
+		return 4, nil
+	}
+	if rest, ok := strings.CutPrefix(s, "CLASS"); ok {
+		if n, err := strconv.ParseUint(rest, 10, 16); err == nil {
+			return Class(n), nil
+		}
+	}
+	return 0, fmt.Errorf("dns: unknown class %q", s)
+}
+
+func (c Class) String() string {
+	switch c {
+	case ClassINET:
+		return "IN"
+	case 3:
+		return "CH"
+	case 4:
+		return "HS"
+	}
+	return fmt.Sprintf("CLASS%d", uint16(c))
+}
+
+// Rcode is a DNS response code, including the extended bits carried in EDNS0.
+type Rcode int
+
+const (
+	RcodeSuccess        Rcode = 0
+	RcodeFormatError    Rcode = 1
+	RcodeServerFailure  Rcode = 2
+	RcodeNameError      Rcode = 3
+	RcodeNotImplemented Rcode = 4
+	RcodeRefused        Rcode = 5
+)
+
+var rcodeNames = map[Rcode]string{
+	RcodeSuccess:        "NOERROR",
+	RcodeFormatError:    "FORMERR",
+	RcodeServerFailure:  "SERVFAIL",
+	RcodeNameError:      "NXDOMAIN",
+	RcodeNotImplemented: "NOTIMP",
+	RcodeRefused:        "REFUSED",
+	6:                   "YXDOMAIN",
+	7:                   "YXRRSET",
+	8:                   "NXRRSET",
+	9:                   "NOTAUTH",
+	10:                  "NOTZONE",
+	16:                  "BADVERS",
+}
+
+func (r Rcode) String() string {
+	if name, ok := rcodeNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("RCODE%d", int(r))
+}
+
+// RcodeError is returned when a server answers with a response code that
+// indicates the query could not be answered (anything but NOERROR/NXDOMAIN).
+type RcodeError struct {
+	Name   string
+	Server string
+	Rcode  Rcode
+}
+
+func (e *RcodeError) Error() string {
+	return fmt.Sprintf("server %s returned %s for %s", e.Server, e.Rcode, e.Name)
+}
+
+// Header holds the fixed fields of a DNS message header.
+type Header struct {
+	ID                 uint16
+	Response           bool
+	Opcode             uint8
+	Authoritative      bool
+	Truncated          bool
+	RecursionDesired   bool
+	RecursionAvailable bool
+	AuthenticatedData  bool
+	CheckingDisabled   bool
+	Rcode              Rcode
+}
+
+// Question is an entry in the question section of a message.
+type Question struct {
+	Name  string
+	Type  RRType
+	Class Class
+}
+
+// RR is a resource record. Name is fully qualified (with a trailing dot).
+type RR struct {
+	Name  string
+	Type  RRType
+	Class Class
+	TTL   uint32
+	Data  RData
+}
+
+// String renders the record the way dig prints answer-section lines.
+func (rr RR) String() string {
+	data := ""
+	if rr.Data != nil {
+		data = rr.Data.String()
+	}
+	return fmt.Sprintf("%s\t%d\t%s\t%s\t%s", rr.Name, rr.TTL, rr.Class, rr.Type, data)
+}
+
+// Message is a DNS query or response.
+type Message struct {
+	Header
+	Question   []Question
+	Answer     []RR
+	Authority  []RR
+	Additional []RR
+}
+
+// NewQuery builds a recursive query for a single name and type.
+func NewQuery(name string, t RRType) *Message {
+	return &Message{
+		Header:   Header{RecursionDesired: true},
+		Question: []Question{{Name: Fqdn(name), Type: t, Class: ClassINET}},
+	}
+}
+
+// SetEDNS0 adds (or replaces) the OPT pseudo-record advertising udpSize and
+// the DNSSEC OK bit.
+func (m *Message) SetEDNS0(udpSize uint16, dnssecOK bool) {
+	var ttl uint32
+	if dnssecOK {
+		ttl |= 1 << 15
+	}
+	opt := RR{Name: ".", Type: TypeOPT, Class: Class(udpSize), TTL: ttl, Data: &OPTRecord{}}
+	for i, rr := range m.Additional {
+		if rr.Type == TypeOPT {
+			m.Additional[i] = opt
+			return
+		}
+	}
+	m.Additional = append(m.Additional, opt)
+}
+
+// EDNS0 returns the OPT pseudo-record of the message, if any.
+func (m *Message) EDNS0() *RR {
+	for i := range m.Additional {
+		if m.Additional[i].Type == TypeOPT {
+			return &m.Additional[i]
+		}
+	}
+	return nil
+}
+
+// Fqdn returns name with a trailing dot.
+func Fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// ReverseName returns the in-addr.arpa or ip6.arpa name that PTR records
+// for ip are published under.
+func ReverseName(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa.", ip4[3], ip4[2], ip4[1], ip4[0])
+	}
+	var sb strings.Builder
+	ip16 := ip.To16()
+	for i := len(ip16) - 1; i >= 0; i-- {
+		fmt.Fprintf(&sb, "%x.%x.", ip16[i]&0x0F, ip16[i]>>4)
+	}
+	sb.WriteString("ip6.arpa.")
+	return sb.String()
+}
+
+var (
+	errShortMessage = errors.New("dns: message too short")
+	errLabelTooLong = errors.New("dns: label longer than 63 octets")
+	errNameTooLong  = errors.New("dns: name longer than 255 octets")
+	errPointerLoop  = errors.New("dns: too many compression pointers")
+)
+
+// Pack encodes the message in wire format. Names are never compressed.
+func (m *Message) Pack() ([]byte, error) {
+	b := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(b[0:], m.ID)
+
+	var flags uint16
+	if m.Response {
+		flags |= 1 << 15
+	}
+	flags |= uint16(m.Opcode&0xF) << 11
+	if m.Authoritative {
+		flags |= 1 << 10
+	}
+	if m.Truncated {
+		flags |= 1 << 9
+	}
+	if m.RecursionDesired {
+		flags |= 1 << 8
+	}
+	if m.RecursionAvailable {
+		flags |= 1 << 7
+	}
+	if m.AuthenticatedData {
+		flags |= 1 << 5
+	}
+	if m.CheckingDisabled {
+		flags |= 1 << 4
+	}
+	flags |= uint16(m.Rcode & 0xF)
+	binary.BigEndian.PutUint16(b[2:], flags)
+	binary.BigEndian.PutUint16(b[4:], uint16(len(m.Question)))
+	binary.BigEndian.PutUint16(b[6:], uint16(len(m.Answer)))
+	binary.BigEndian.PutUint16(b[8:], uint16(len(m.Authority)))
+	binary.BigEndian.PutUint16(b[10:], uint16(len(m.Additional)))
+
+	var err error
+	for _, q := range m.Question {
+		if b, err = appendName(b, q.Name); err != nil {
+			return nil, err
+		}
+		b = binary.BigEndian.AppendUint16(b, uint16(q.Type))
+		b = binary.BigEndian.AppendUint16(b, uint16(q.Class))
+	}
+	for _, section := range [][]RR{m.Answer, m.Authority, m.Additional} {
+		for _, rr := range section {
+			if b, err = appendRR(b, rr); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b, nil
+}
+
+func appendRR(b []byte, rr RR) ([]byte, error) {
+	var err error
+	if b, err = appendName(b, rr.Name); err != nil {
+		return nil, err
+	}
+	b = binary.BigEndian.AppendUint16(b, uint16(rr.Type))
+	b = binary.BigEndian.AppendUint16(b, uint16(rr.Class))
+	b = binary.BigEndian.AppendUint32(b, rr.TTL)
+	lenOff := len(b)
+	b = append(b, 0, 0)
+	if rr.Data != nil {
+		if b, err = rr.Data.pack(b); err != nil {
+			return nil, err
+		}
+	}
+	rdlen := len(b) - lenOff - 2
+	if rdlen > 0xFFFF {
+		return nil, fmt.Errorf("dns: rdata of %s record too long", rr.Type)
+	}
+	binary.BigEndian.PutUint16(b[lenOff:], uint16(rdlen))
+	return b, nil
+}
+
+// UnpackMessage decodes a wire-format DNS message.
+func UnpackMessage(msg []byte) (*Message, error) {
+	if len(msg) < 12 {
+		return nil, errShortMessage
+	}
+	m := &Message{}
+	m.ID = binary.BigEndian.Uint16(msg[0:])
+	flags := binary.BigEndian.Uint16(msg[2:])
+	m.Response = flags&(1<<15) != 0
+	m.Opcode = uint8(flags>>11) & 0xF
+	m.Authoritative = flags&(1<<10) != 0
+	m.Truncated = flags&(1<<9) != 0
+	m.RecursionDesired = flags&(1<<8) != 0
+	m.RecursionAvailable = flags&(1<<7) != 0
+	m.AuthenticatedData = flags&(1<<5) != 0
+	m.CheckingDisabled = flags&(1<<4) != 0
+	m.Rcode = Rcode(flags & 0xF)
+
+	qdcount := int(binary.BigEndian.Uint16(msg[4:]))
+	counts := []int{
+		int(binary.BigEndian.Uint16(msg[6:])),
+		int(binary.BigEndian.Uint16(msg[8:])),
+		int(binary.BigEndian.Uint16(msg[10:])),
+	}
+
+	off := 12
+	for i := 0; i < qdcount; i++ {
+		name, next, err := unpackName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		if next+4 > len(msg) {
+			return nil, errShortMessage
+		}
+		m.Question = append(m.Question, Question{
+			Name:  name,
+			Type:  RRType(binary.BigEndian.Uint16(msg[next:])),
+			Class: Class(binary.BigEndian.Uint16(msg[next+2:])),
+		})
+		off = next + 4
+	}
+
+	sections := []*[]RR{&m.Answer, &m.Authority, &m.Additional}
+	for i, section := range sections {
+		for j := 0; j < counts[i]; j++ {
+			rr, next, err := unpackRR(msg, off)
+			if err != nil {
+				// A truncated response may legitimately stop mid-section.
+				if m.Truncated {
+					return m, nil
+				}
+				return nil, err
+			}
+			*section = append(*section, rr)
+			off = next
+		}
+	}
+
+	if opt := m.EDNS0(); opt != nil {
+		m.Rcode |= Rcode(opt.TTL>>24) << 4
+	}
+	return m, nil
+}
+
+func unpackRR(msg []byte, off int) (RR, int, error) {
+	name, off, err := unpackName(msg, off)
+	if err != nil {
+		return RR{}, 0, err
+	}
+	if off+10 > len(msg) {
+		return RR{}, 0, errShortMessage
+	}
+	rr := RR{
+		Name:  name,
+		Type:  RRType(binary.BigEndian.Uint16(msg[off:])),
+		Class: Class(binary.BigEndian.Uint16(msg[off+2:])),
+		TTL:   binary.BigEndian.Uint32(msg[off+4:]),
+	}
+	rdlen := int(binary.BigEndian.Uint16(msg[off+8:]))
+	off += 10
+	end := off + rdlen
+	if end > len(msg) {
+		return RR{}, 0, errShortMessage
+	}
+	rr.Data, err = unpackRData(rr.Type, msg, off, end)
+	if err != nil {
+		return RR{}, 0, fmt.Errorf("dns: bad %s record for %s: %w", rr.Type, rr.Name, err)
+	}
+	return rr, end, nil
+}
+
+// appendName encodes a presentation-format name (with \. and \DDD escapes)
+// as uncompressed wire-format labels.
+func appendName(b []byte, name string) ([]byte, error) {
+	if name == "." || name == "" {
+		return append(b, 0), nil
+	}
+	start := len(b)
+	label := make([]byte, 0, 63)
+	flush := func() error {
+		if len(label) == 0 {
+			return fmt.Errorf("dns: empty label in %q", name)
+		}
+		if len(label) > 63 {
+			return errLabelTooLong
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+		label = label[:0]
+		return nil
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '\\' && i+3 < len(name) && isDigit(name[i+1]) && isDigit(name[i+2]) && isDigit(name[i+3]):
+			v, _ := strconv.Atoi(name[i+1 : i+4])
+			label = append(label, byte(v))
+			i += 3
+		case c == '\\' && i+1 < len(name):
+			label = append(label, name[i+1])
+			i++
+		case c == '.':
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		default:
+			label = append(label, c)
+		}
+	}
+	if len(label) > 0 {
+		if err := flush(); err != nil {
+			return nil, err
+		}
+	}
+	b = append(b, 0)
+	if len(b)-start > 255 {
+		return nil, errNameTooLong
+	}
+	return b, nil
+}
+
+// unpackName decodes a possibly compressed name starting at off and returns
+// it in presentation format along with the offset just past it.
+func unpackName(msg []byte, off int) (string, int, error) {
+	var sb strings.Builder
+	next := -1
+	pointers := 0
+	for {
+		if off >= len(msg) {
+			return "", 0, errShortMessage
+		}
+		c := int(msg[off])
+		switch c & 0xC0 {
+		case 0x00:
+			if c == 0 {
+				off++
+				if next < 0 {
+					next = off
+				}
+				if sb.Len() == 0 {
+					return ".", next, nil
+				}
+				return sb.String(), next, nil
+			}
+			if off+1+c > len(msg) {
+				return "", 0, errShortMessage
+			}
+			writeLabel(&sb, msg[off+1:off+1+c])
+			sb.WriteByte('.')
+			off += 1 + c
+		case 0xC0:
+			if off+1 >= len(msg) {
+				return "", 0, errShortMessage
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			pointers++
+			if pointers > 64 {
+				return "", 0, errPointerLoop
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3FFF)
+		default:
+			return "", 0, fmt.Errorf("dns: unsupported label type 0x%x", c&0xC0)
+		}
+	}
+}
+
+func writeLabel(sb *strings.Builder, label []byte) {
+	for _, c := range label {
+		switch {
+		case c == '.' || c == '\\' || c == '"' || c == '(' || c == ')' || c == ';' || c == ' ' || c == '@' || c == '$':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c < 0x21 || c > 0x7E:
+			fmt.Fprintf(sb, "\\%03d", c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
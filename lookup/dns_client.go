@@ -0,0 +1,233 @@
+package lookup
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resolver sends queries straight to name servers without going through the
+// operating system's stub resolver or any external command.
+type Resolver struct {
+	// Servers are tried in order. Entries may omit the port (53 is assumed).
+	// When empty, the nameservers from /etc/resolv.conf are used.
+	Servers []string
+	// Timeout bounds a single exchange with one server.
+	Timeout time.Duration
+	// UDPSize is the EDNS0 buffer size advertised in queries. Zero disables EDNS0.
+	UDPSize uint16
+}
+
+// DefaultResolver is used by the native DNS providers.
+var DefaultResolver = &Resolver{
+	Timeout: 5 * time.Second,
+	UDPSize: 1232,
+}
+
+// DNSExchange performs one query/response exchange with a server. It sends
+// the query over UDP and retries over TCP when the answer is truncated.
+// Tests can replace this with a mock implementation.
+var DNSExchange = exchange
+
+// Query sends a recursive query for name and type t and returns the response
+// together with the server that produced it.
+func (r *Resolver) Query(ctx context.Context, name string, t RRType) (*Message, string, error) {
+	return r.Exchange(ctx, NewQuery(name, t))
+}
+
+// Exchange sends q to each configured server in turn until one answers.
+func (r *Resolver) Exchange(ctx context.Context, q *Message) (*Message, string, error) {
+	servers := r.Servers
+	if len(servers) == 0 {
+		servers = systemNameservers()
+	}
+	if r.UDPSize > 0 && q.EDNS0() == nil {
+		q.SetEDNS0(r.UDPSize, false)
+	}
+
+	var lastErr error
+	for _, server := range servers {
+		server = hostPort(server, "53")
+		if err := waitServer(ctx, server); err != nil {
+			return nil, "", err
+		}
+		exCtx := ctx
+		cancel := func() {}
+		if r.Timeout > 0 {
+			exCtx, cancel = context.WithTimeout(ctx, r.Timeout)
+		}
+		resp, err := DNSExchange(exCtx, server, q)
+		cancel()
+		if err == nil {
+			return resp, server, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if lastErr == nil {
+		lastErr = errors.New("dns: no name servers configured")
+	}
+	return nil, "", lastErr
+}
+
+func exchange(ctx context.Context, server string, q *Message) (*Message, error) {
+	q.ID = uint16(rand.UintN(1 << 16))
+	packed, err := q.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := exchangeUDP(ctx, server, q.ID, packed)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Truncated {
+		return exchangeTCP(ctx, server, q.ID, packed)
+	}
+	return resp, nil
+}
+
+func exchangeUDP(ctx context.Context, server string, id uint16, packed []byte) (*Message, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	stop := closeOnDone(ctx, conn)
+	defer stop()
+
+	if _, err := conn.Write(packed); err != nil {
+		return nil, ctxErr(ctx, err)
+	}
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, ctxErr(ctx, err)
+		}
+		resp, err := UnpackMessage(buf[:n])
+		if err != nil || resp.ID != id || !resp.Response {
+			// Ignore stray or malformed datagrams and keep waiting.
+			continue
+		}
+		return resp, nil
+	}
+}
+
+func exchangeTCP(ctx context.Context, server string, id uint16, packed []byte) (*Message, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	stop := closeOnDone(ctx, conn)
+	defer stop()
+
+	framed := binary.BigEndian.AppendUint16(make([]byte, 0, len(packed)+2), uint16(len(packed)))
+	framed = append(framed, packed...)
+	if _, err := conn.Write(framed); err != nil {
+		return nil, ctxErr(ctx, err)
+	}
+	r := bufio.NewReader(conn)
+	var lenBuf [2]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		return nil, ctxErr(ctx, err)
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(lenBuf[:]))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, ctxErr(ctx, err)
+	}
+	resp, err := UnpackMessage(buf)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ID != id {
+		return nil, fmt.Errorf("dns: response ID mismatch from %s", server)
+	}
+	return resp, nil
+}
+
+// closeOnDone closes conn when ctx is done so blocked reads return promptly.
+func closeOnDone(ctx context.Context, conn net.Conn) (stop func()) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// ctxErr prefers the context's error over the I/O error it caused. The
+// connection deadline is the context's own, so it can expire before ctx
+// reports it; that is reported as the context's deadline too.
+func ctxErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if _, ok := ctx.Deadline(); ok && errors.Is(err, os.ErrDeadlineExceeded) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// hostPort appends defaultPort to addr unless it already carries a port.
+func hostPort(addr, defaultPort string) string {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	return net.JoinHostPort(strings.Trim(addr, "[]"), defaultPort)
+}
+
+var (
+	sysNameservers     []string
+	sysNameserversOnce sync.Once
+)
+
+// systemNameservers returns the nameservers listed in /etc/resolv.conf,
+// falling back to a local resolver when none can be read.
+func systemNameservers() []string {
+	sysNameserversOnce.Do(func() {
+		if f, err := os.Open("/etc/resolv.conf"); err == nil {
+			sysNameservers = parseResolvConf(f)
+			f.Close()
+		}
+		if len(sysNameservers) == 0 {
+			sysNameservers = []string{"127.0.0.1:53", "[::1]:53"}
+		}
+	})
+	return sysNameservers
+}
+
+func parseResolvConf(r io.Reader) []string {
+	var servers []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			// Zone identifiers (fe80::1%eth0) are kept; only the address is validated.
+			if net.ParseIP(strings.SplitN(fields[1], "%", 2)[0]) != nil {
+				servers = append(servers, hostPort(fields[1], "53"))
+			}
+		}
+	}
+	return servers
+}
@@ -0,0 +1,218 @@
+package lookup_test
+
+import (
+	"context"
+	"dlookup/lookup"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// dnsHandler builds the response for a query. tcp reports which transport
+// the query arrived on.
+type dnsHandler func(q *lookup.Message, tcp bool) *lookup.Message
+
+// startDNSServer runs a stand-in name server on a loopback UDP and TCP port
+// and returns its address.
+func startDNSServer(t *testing.T, handler dnsHandler) string {
+	t.Helper()
+	var pc net.PacketConn
+	var ln net.Listener
+	for attempt := 0; ; attempt++ {
+		var err error
+		pc, err = net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("listen udp: %v", err)
+		}
+		ln, err = net.Listen("tcp", pc.LocalAddr().String())
+		if err == nil {
+			break
+		}
+		pc.Close()
+		if attempt > 10 {
+			t.Fatalf("listen tcp: %v", err)
+		}
+	}
+	t.Cleanup(func() {
+		pc.Close()
+		ln.Close()
+	})
+
+	reply := func(raw []byte, tcp bool) []byte {
+		q, err := lookup.UnpackMessage(raw)
+		if err != nil {
+			return nil
+		}
+		resp := handler(q, tcp)
+		if resp == nil {
+			return nil
+		}
+		resp.ID = q.ID
+		resp.Response = true
+		resp.Question = q.Question
+		packed, err := resp.Pack()
+		if err != nil {
+			t.Errorf("stand-in server: pack response: %v", err)
+			return nil
+		}
+		return packed
+	}
+
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if out := reply(buf[:n], false); out != nil {
+				pc.WriteTo(out, addr)
+			}
+		}
+	}()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				var lenBuf [2]byte
+				if _, err := io.ReadFull(c, lenBuf[:]); err != nil {
+					return
+				}
+				buf := make([]byte, binary.BigEndian.Uint16(lenBuf[:]))
+				if _, err := io.ReadFull(c, buf); err != nil {
+					return
+				}
+				if out := reply(buf, true); out != nil {
+					c.Write(binary.BigEndian.AppendUint16(nil, uint16(len(out))))
+					c.Write(out)
+				}
+			}(conn)
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+func aRecord(name, ip string, ttl uint32) lookup.RR {
+	return lookup.RR{Name: name, Type: lookup.TypeA, Class: lookup.ClassINET, TTL: ttl, Data: &lookup.ARecord{IP: net.ParseIP(ip).To4()}}
+}
+
+func TestResolver_QueryUDP(t *testing.T) {
+	addr := startDNSServer(t, func(q *lookup.Message, tcp bool) *lookup.Message {
+		if q.EDNS0() == nil {
+			t.Errorf("query did not carry an EDNS0 OPT record")
+		}
+		return &lookup.Message{Answer: []lookup.RR{aRecord(q.Question[0].Name, "192.0.2.1", 60)}}
+	})
+
+	r := &lookup.Resolver{Servers: []string{addr}, Timeout: 2 * time.Second, UDPSize: 1232}
+	resp, server, err := r.Query(context.Background(), "example.com", lookup.TypeA)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if server != addr {
+		t.Errorf("server = %q, want %q", server, addr)
+	}
+	if len(resp.Answer) != 1 || resp.Answer[0].Data.String() != "192.0.2.1" {
+		t.Errorf("unexpected answer: %+v", resp.Answer)
+	}
+}
+
+func TestResolver_TruncatedFallsBackToTCP(t *testing.T) {
+	var tcpQueries atomic.Int32
+	addr := startDNSServer(t, func(q *lookup.Message, tcp bool) *lookup.Message {
+		if !tcp {
+			return &lookup.Message{Header: lookup.Header{Truncated: true}}
+		}
+		tcpQueries.Add(1)
+		return &lookup.Message{Answer: []lookup.RR{aRecord(q.Question[0].Name, "192.0.2.2", 60)}}
+	})
+
+	r := &lookup.Resolver{Servers: []string{addr}, Timeout: 2 * time.Second, UDPSize: 1232}
+	resp, _, err := r.Query(context.Background(), "big.example.com", lookup.TypeA)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if tcpQueries.Load() != 1 {
+		t.Errorf("TCP queries = %d, want 1", tcpQueries.Load())
+	}
+	if resp.Truncated || len(resp.Answer) != 1 {
+		t.Errorf("expected full TCP answer, got %+v", resp)
+	}
+}
+
+func TestResolver_FallsThroughToNextServer(t *testing.T) {
+	origExchange := lookup.DNSExchange
+	defer func() { lookup.DNSExchange = origExchange }()
+
+	var tried []string
+	lookup.DNSExchange = func(ctx context.Context, server string, q *lookup.Message) (*lookup.Message, error) {
+		tried = append(tried, server)
+		if server == "192.0.2.53:53" {
+			return nil, errors.New("i/o timeout")
+		}
+		return &lookup.Message{Header: lookup.Header{Response: true}}, nil
+	}
+
+	r := &lookup.Resolver{Servers: []string{"192.0.2.53", "[2001:db8::53]:5353"}}
+	_, server, err := r.Query(context.Background(), "example.com", lookup.TypeA)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if server != "[2001:db8::53]:5353" {
+		t.Errorf("server = %q, want second server", server)
+	}
+	if len(tried) != 2 || tried[0] != "192.0.2.53:53" {
+		t.Errorf("tried = %v", tried)
+	}
+}
+
+func TestResolver_ContextCancel(t *testing.T) {
+	addr := startDNSServer(t, func(q *lookup.Message, tcp bool) *lookup.Message {
+		return nil // never answer
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	r := &lookup.Resolver{Servers: []string{addr}}
+	start := time.Now()
+	_, _, err := r.Query(ctx, "example.com", lookup.TypeA)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Query() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Query() took %v after context deadline", elapsed)
+	}
+}
+
+// useFakeDNS answers queries through DNSExchange without sockets, with the
+// handler of the server ("host:port") each query is sent to. Queries to
+// other servers fail. The system resolver is set to 192.0.2.1:53.
+func useFakeDNS(t *testing.T, servers map[string]dnsHandler) {
+	t.Helper()
+	origExchange := lookup.DNSExchange
+	lookup.DNSExchange = func(ctx context.Context, server string, q *lookup.Message) (*lookup.Message, error) {
+		handler, ok := servers[server]
+		if !ok {
+			return nil, errors.New("no route to " + server)
+		}
+		resp := handler(q, false)
+		resp.ID = q.ID
+		resp.Response = true
+		resp.Question = q.Question
+		return resp, nil
+	}
+	origResolver := lookup.DefaultResolver
+	lookup.DefaultResolver = &lookup.Resolver{Servers: []string{"192.0.2.1:53"}, Timeout: time.Second}
+	t.Cleanup(func() {
+		lookup.DNSExchange = origExchange
+		lookup.DefaultResolver = origResolver
+	})
+}
@@ -0,0 +1,845 @@
+package lookup
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+// RData is the type-specific payload of a resource record.
+type RData interface {
+	String() string
+	pack(b []byte) ([]byte, error)
+}
+
+type ARecord struct {
+	IP net.IP
+}
+
+func (r *ARecord) String() string { return r.IP.String() }
+
+func (r *ARecord) pack(b []byte) ([]byte, error) {
+	ip4 := r.IP.To4()
+	if ip4 == nil {
+		return nil, fmt.Errorf("dns: %q is not an IPv4 address", r.IP)
+	}
+	return append(b, ip4...), nil
+}
+
+type AAAARecord struct {
+	IP net.IP
+}
+
+func (r *AAAARecord) String() string { return r.IP.String() }
+
+func (r *AAAARecord) pack(b []byte) ([]byte, error) {
+	ip16 := r.IP.To16()
+	if ip16 == nil {
+		return nil, fmt.Errorf("dns: %q is not an IPv6 address", r.IP)
+	}
+	return append(b, ip16...), nil
+}
+
+type NSRecord struct {
+	Host string
+}
+
+func (r *NSRecord) String() string                { return r.Host }
+func (r *NSRecord) pack(b []byte) ([]byte, error) { return appendName(b, r.Host) }
+
+type CNAMERecord struct {
+	Target string
+}
+
+func (r *CNAMERecord) String() string                { return r.Target }
+func (r *CNAMERecord) pack(b []byte) ([]byte, error) { return appendName(b, r.Target) }
+
+type PTRRecord struct {
+	Ptr string
+}
+
+func (r *PTRRecord) String() string                { return r.Ptr }
+func (r *PTRRecord) pack(b []byte) ([]byte, error) { return appendName(b, r.Ptr) }
+
+type MXRecord struct {
+	Preference uint16
+	Exchange   string
+}
+
+func (r *MXRecord) String() string { return fmt.Sprintf("%d %s", r.Preference, r.Exchange) }
+
+func (r *MXRecord) pack(b []byte) ([]byte, error) {
+	b = binary.BigEndian.AppendUint16(b, r.Preference)
+	return appendName(b, r.Exchange)
+}
+
+type TXTRecord struct {
+	Strings []string
+}
+
+func (r *TXTRecord) String() string {
+	quoted := make([]string, len(r.Strings))
+	for i, s := range r.Strings {
+		quoted[i] = quoteTXT(s)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Text returns the character-strings joined without separators, which is how
+// SPF, DKIM and similar consumers interpret multi-string TXT records.
+func (r *TXTRecord) Text() string { return strings.Join(r.Strings, "") }
+
+func (r *TXTRecord) pack(b []byte) ([]byte, error) {
+	for _, s := range r.Strings {
+		if len(s) > 255 {
+			return nil, fmt.Errorf("dns: TXT character-string longer than 255 octets")
+		}
+		b = append(b, byte(len(s)))
+		b = append(b, s...)
+	}
+	return b, nil
+}
+
+func quoteTXT(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c < 0x20 || c > 0x7E:
+			fmt.Fprintf(&sb, "\\%03d", c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+type SOARecord struct {
+	MName   string
+	RName   string
+	Serial  uint32
+	Refresh uint32
+	Retry   uint32
+	Expire  uint32
+	Minimum uint32
+}
+
+func (r *SOARecord) String() string {
+	return fmt.Sprintf("%s %s %d %d %d %d %d", r.MName, r.RName, r.Serial, r.Refresh, r.Retry, r.Expire, r.Minimum)
+}
+
+func (r *SOARecord) pack(b []byte) ([]byte, error) {
+	var err error
+	if b, err = appendName(b, r.MName); err != nil {
+		return nil, err
+	}
+	if b, err = appendName(b, r.RName); err != nil {
+		return nil, err
+	}
+	for _, v := range []uint32{r.Serial, r.Refresh, r.Retry, r.Expire, r.Minimum} {
+		b = binary.BigEndian.AppendUint32(b, v)
+	}
+	return b, nil
+}
+
+type SRVRecord struct {
+	Priority uint16
+	Weight   uint16
+	Port     uint16
+	Target   string
+}
+
+func (r *SRVRecord) String() string {
+	return fmt.Sprintf("%d %d %d %s", r.Priority, r.Weight, r.Port, r.Target)
+}
+
+func (r *SRVRecord) pack(b []byte) ([]byte, error) {
+	b = binary.BigEndian.AppendUint16(b, r.Priority)
+	b = binary.BigEndian.AppendUint16(b, r.Weight)
+	b = binary.BigEndian.AppendUint16(b, r.Port)
+	return appendName(b, r.Target)
+}
+
+// CAARecord is a certification authority authorization (RFC 8659).
+type CAARecord struct {
+	Flags uint8
+	Tag   string
+	Value string
+}
+
+// Critical reports whether the issuer critical flag is set.
+func (r *CAARecord) Critical() bool { return r.Flags&0x80 != 0 }
+
+func (r *CAARecord) String() string {
+	return fmt.Sprintf("%d %s %s", r.Flags, r.Tag, quoteTXT(r.Value))
+}
+
+func (r *CAARecord) pack(b []byte) ([]byte, error) {
+	if len(r.Tag) == 0 || len(r.Tag) > 255 {
+		return nil, fmt.Errorf("dns: invalid CAA tag %q", r.Tag)
+	}
+	b = append(b, r.Flags, byte(len(r.Tag)))
+	b = append(b, r.Tag...)
+	return append(b, r.Value...), nil
+}
+
+// DSRecord is a delegation signer: the digest of a child zone's key.
+type DSRecord struct {
+	KeyTag     uint16
+	Algorithm  uint8
+	DigestType uint8
+	Digest     []byte
+}
+
+func (r *DSRecord) String() string {
+	return fmt.Sprintf("%d %d %d %X", r.KeyTag, r.Algorithm, r.DigestType, r.Digest)
+}
+
+func (r *DSRecord) pack(b []byte) ([]byte, error) {
+	b = binary.BigEndian.AppendUint16(b, r.KeyTag)
+	b = append(b, r.Algorithm, r.DigestType)
+	return append(b, r.Digest...), nil
+}
+
+type DNSKEYRecord struct {
+	Flags     uint16
+	Protocol  uint8
+	Algorithm uint8
+	PublicKey []byte
+}
+
+// IsSEP reports whether the secure entry point flag is set, which marks a
+// key signing key.
+func (r *DNSKEYRecord) IsSEP() bool { return r.Flags&0x0001 != 0 }
+
+// KeyTag computes the key tag DS and RRSIG records use to refer to the key
+// (RFC 4034, Appendix B).
+func (r *DNSKEYRecord) KeyTag() uint16 {
+	rdata, _ := r.pack(nil)
+	var ac uint32
+	for i, c := range rdata {
+		if i&1 == 0 {
+			ac += uint32(c) << 8
+		} else {
+			ac += uint32(c)
+		}
+	}
+	ac += ac >> 16 & 0xFFFF
+	return uint16(ac & 0xFFFF)
+}
+
+func (r *DNSKEYRecord) String() string {
+	return fmt.Sprintf("%d %d %d %s", r.Flags, r.Protocol, r.Algorithm, base64.StdEncoding.EncodeToString(r.PublicKey))
+}
+
+func (r *DNSKEYRecord) pack(b []byte) ([]byte, error) {
+	b = binary.BigEndian.AppendUint16(b, r.Flags)
+	b = append(b, r.Protocol, r.Algorithm)
+	return append(b, r.PublicKey...), nil
+}
+
+// RRSIGRecord is the signature over an RRset (RFC 4034, section 3).
+// Inception and Expiration are seconds since the epoch, modulo 2^32.
+type RRSIGRecord struct {
+	TypeCovered RRType
+	Algorithm   uint8
+	Labels      uint8
+	OrigTTL     uint32
+	Expiration  uint32
+	Inception   uint32
+	KeyTag      uint16
+	SignerName  string
+	Signature   []byte
+}
+
+// rrsigTimeFormat is the presentation format of RRSIG validity times.
+const rrsigTimeFormat = "20060102150405"
+
+func (r *RRSIGRecord) String() string {
+	return fmt.Sprintf("%s %d %d %d %s %s %d %s %s", r.TypeCovered, r.Algorithm, r.Labels, r.OrigTTL,
+		r.ExpirationTime().Format(rrsigTimeFormat), r.InceptionTime().Format(rrsigTimeFormat),
+		r.KeyTag, r.SignerName, base64.StdEncoding.EncodeToString(r.Signature))
+}
+
+// InceptionTime returns when the signature becomes valid.
+func (r *RRSIGRecord) InceptionTime() time.Time { return time.Unix(int64(r.Inception), 0).UTC() }
+
+// ExpirationTime returns when the signature expires.
+func (r *RRSIGRecord) ExpirationTime() time.Time { return time.Unix(int64(r.Expiration), 0).UTC() }
+
+// packNoSignature appends the rdata without the signature, with the signer
+// name uncompressed and lowercased, as it is signed.
+func (r *RRSIGRecord) packNoSignature(b []byte) ([]byte, error) {
+	b = binary.BigEndian.AppendUint16(b, uint16(r.TypeCovered))
+	b = append(b, r.Algorithm, r.Labels)
+	b = binary.BigEndian.AppendUint32(b, r.OrigTTL)
+	b = binary.BigEndian.AppendUint32(b, r.Expiration)
+	b = binary.BigEndian.AppendUint32(b, r.Inception)
+	b = binary.BigEndian.AppendUint16(b, r.KeyTag)
+	return appendName(b, strings.ToLower(r.SignerName))
+}
+
+func (r *RRSIGRecord) pack(b []byte) ([]byte, error) {
+	b, err := r.packNoSignature(b)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, r.Signature...), nil
+}
+
+// NSECRecord names the next owner name of a signed zone and lists the types
+// at its own name, proving that no name or type in between exists (RFC
+// 4034, section 4).
+type NSECRecord struct {
+	NextName string
+	Types    []RRType
+}
+
+func (r *NSECRecord) String() string {
+	return strings.TrimSpace(r.NextName + " " + typeList(r.Types))
+}
+
+// HasType reports whether the type bitmap lists t.
+func (r *NSECRecord) HasType(t RRType) bool { return slices.Contains(r.Types, t) }
+
+func (r *NSECRecord) pack(b []byte) ([]byte, error) {
+	b, err := appendName(b, r.NextName)
+	if err != nil {
+		return nil, err
+	}
+	return appendTypeBitmap(b, r.Types), nil
+}
+
+// NSEC3Record is NSEC with hashed owner names (RFC 5155, section 3). The
+// hash of the next owner name is kept raw; owner names carry it in base32hex.
+type NSEC3Record struct {
+	HashAlgorithm uint8
+	Flags         uint8
+	Iterations    uint16
+	Salt          []byte
+	NextHashed    []byte
+	Types         []RRType
+}
+
+// OptOut reports whether the record may cover unsigned delegations.
+func (r *NSEC3Record) OptOut() bool { return r.Flags&0x01 != 0 }
+
+// HasType reports whether the type bitmap lists t.
+func (r *NSEC3Record) HasType(t RRType) bool { return slices.Contains(r.Types, t) }
+
+func (r *NSEC3Record) String() string {
+	salt := "-"
+	if len(r.Salt) > 0 {
+		salt = fmt.Sprintf("%X", r.Salt)
+	}
+	return strings.TrimSpace(fmt.Sprintf("%d %d %d %s %s %s", r.HashAlgorithm, r.Flags, r.Iterations, salt,
+		nsec3Encoding.EncodeToString(r.NextHashed), typeList(r.Types)))
+}
+
+func (r *NSEC3Record) pack(b []byte) ([]byte, error) {
+	if len(r.Salt) > 255 || len(r.NextHashed) > 255 {
+		return nil, errors.New("dns: NSEC3 salt or hash too long")
+	}
+	b = append(b, r.HashAlgorithm, r.Flags)
+	b = binary.BigEndian.AppendUint16(b, r.Iterations)
+	b = append(b, byte(len(r.Salt)))
+	b = append(b, r.Salt...)
+	b = append(b, byte(len(r.NextHashed)))
+	b = append(b, r.NextHashed...)
+	return appendTypeBitmap(b, r.Types), nil
+}
+
+// nsec3Encoding is the base32hex alphabet of hashed owner names, without
+// padding.
+var nsec3Encoding = base32.HexEncoding.WithPadding(base32.NoPadding)
+
+func typeList(types []RRType) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = t.String()
+	}
+	return strings.Join(names, " ")
+}
+
+// appendTypeBitmap appends the type bitmap of NSEC and NSEC3 records: a
+// window of up to 32 bytes for each block of 256 types that has any (RFC
+// 4034, section 4.1.2).
+func appendTypeBitmap(b []byte, types []RRType) []byte {
+	sorted := slices.Clone(types)
+	slices.Sort(sorted)
+	for i := 0; i < len(sorted); {
+		window := byte(sorted[i] >> 8)
+		var bits [32]byte
+		n := 0
+		for ; i < len(sorted) && byte(sorted[i]>>8) == window; i++ {
+			low := byte(sorted[i])
+			bits[low/8] |= 0x80 >> (low % 8)
+			n = int(low/8) + 1
+		}
+		b = append(b, window, byte(n))
+		b = append(b, bits[:n]...)
+	}
+	return b
+}
+
+func unpackTypeBitmap(data []byte) ([]RRType, error) {
+	var types []RRType
+	for len(data) > 0 {
+		if len(data) < 2 || data[1] == 0 || data[1] > 32 || len(data) < 2+int(data[1]) {
+			return nil, errShortMessage
+		}
+		window, bits := data[0], data[2:2+int(data[1])]
+		for i, octet := range bits {
+			for bit := 0; bit < 8; bit++ {
+				if octet&(0x80>>bit) != 0 {
+					types = append(types, RRType(uint16(window)<<8|uint16(i*8+bit)))
+				}
+			}
+		}
+		data = data[2+len(bits):]
+	}
+	return types, nil
+}
+
+// TLSARecord associates a certificate or public key with a TLS service
+// (RFC 6698).
+type TLSARecord struct {
+	Usage        uint8
+	Selector     uint8
+	MatchingType uint8
+	Data         []byte
+}
+
+func (r *TLSARecord) String() string {
+	return fmt.Sprintf("%d %d %d %X", r.Usage, r.Selector, r.MatchingType, r.Data)
+}
+
+func (r *TLSARecord) pack(b []byte) ([]byte, error) {
+	b = append(b, r.Usage, r.Selector, r.MatchingType)
+	return append(b, r.Data...), nil
+}
+
+// SSHFPRecord is the fingerprint of an SSH host key (RFC 4255).
+type SSHFPRecord struct {
+	Algorithm   uint8
+	Type        uint8
+	Fingerprint []byte
+}
+
+func (r *SSHFPRecord) String() string {
+	return fmt.Sprintf("%d %d %X", r.Algorithm, r.Type, r.Fingerprint)
+}
+
+func (r *SSHFPRecord) pack(b []byte) ([]byte, error) {
+	b = append(b, r.Algorithm, r.Type)
+	return append(b, r.Fingerprint...), nil
+}
+
+// NAPTRRecord is a naming authority pointer (RFC 3403).
+type NAPTRRecord struct {
+	Order       uint16
+	Preference  uint16
+	Flags       string
+	Services    string
+	Regexp      string
+	Replacement string
+}
+
+func (r *NAPTRRecord) String() string {
+	return fmt.Sprintf("%d %d %s %s %s %s", r.Order, r.Preference, quoteTXT(r.Flags), quoteTXT(r.Services), quoteTXT(r.Regexp), r.Replacement)
+}
+
+func (r *NAPTRRecord) pack(b []byte) ([]byte, error) {
+	b = binary.BigEndian.AppendUint16(b, r.Order)
+	b = binary.BigEndian.AppendUint16(b, r.Preference)
+	for _, s := range []string{r.Flags, r.Services, r.Regexp} {
+		if len(s) > 255 {
+			return nil, fmt.Errorf("dns: NAPTR character-string longer than 255 octets")
+		}
+		b = append(b, byte(len(s)))
+		b = append(b, s...)
+	}
+	return appendName(b, r.Replacement)
+}
+
+// SVCB parameter keys (RFC 9460).
+const (
+	SvcParamMandatory     uint16 = 0
+	SvcParamALPN          uint16 = 1
+	SvcParamNoDefaultALPN uint16 = 2
+	SvcParamPort          uint16 = 3
+	SvcParamIPv4Hint      uint16 = 4
+	SvcParamECH           uint16 = 5
+	SvcParamIPv6Hint      uint16 = 6
+)
+
+var svcParamKeyNames = map[uint16]string{
+	SvcParamMandatory:     "mandatory",
+	SvcParamALPN:          "alpn",
+	SvcParamNoDefaultALPN: "no-default-alpn",
+	SvcParamPort:          "port",
+	SvcParamIPv4Hint:      "ipv4hint",
+	SvcParamECH:           "ech",
+	SvcParamIPv6Hint:      "ipv6hint",
+}
+
+func svcParamKeyName(key uint16) string {
+	if name, ok := svcParamKeyNames[key]; ok {
+		return name
+	}
+	return fmt.Sprintf("key%d", key)
+}
+
+// SvcParam is one key=value parameter of an SVCB or HTTPS record, with the
+// value in its wire format.
+type SvcParam struct {
+	Key   uint16
+	Value []byte
+}
+
+func (p SvcParam) String() string {
+	name := svcParamKeyName(p.Key)
+	v := p.Value
+	switch p.Key {
+	case SvcParamMandatory:
+		var keys []string
+		for i := 0; i+1 < len(v); i += 2 {
+			keys = append(keys, svcParamKeyName(binary.BigEndian.Uint16(v[i:])))
+		}
+		return name + "=" + strings.Join(keys, ",")
+	case SvcParamALPN:
+		var ids []string
+		for i := 0; i < len(v); {
+			n := int(v[i])
+			if i+1+n > len(v) {
+				break
+			}
+			ids = append(ids, string(v[i+1:i+1+n]))
+			i += 1 + n
+		}
+		return name + "=" + quoteTXT(strings.Join(ids, ","))
+	case SvcParamNoDefaultALPN:
+		return name
+	case SvcParamPort:
+		if len(v) == 2 {
+			return fmt.Sprintf("%s=%d", name, binary.BigEndian.Uint16(v))
+		}
+	case SvcParamIPv4Hint, SvcParamIPv6Hint:
+		size := net.IPv4len
+		if p.Key == SvcParamIPv6Hint {
+			size = net.IPv6len
+		}
+		if len(v)%size == 0 {
+			var ips []string
+			for i := 0; i < len(v); i += size {
+				ips = append(ips, net.IP(v[i:i+size]).String())
+			}
+			return name + "=" + strings.Join(ips, ",")
+		}
+	case SvcParamECH:
+		return name + "=" + base64.StdEncoding.EncodeToString(v)
+	}
+	return name + "=" + quoteTXT(string(v))
+}
+
+// SVCBRecord is a service binding (RFC 9460). HTTPS records have the same
+// format. Priority 0 makes the record an alias for Target.
+type SVCBRecord struct {
+	Priority uint16
+	Target   string
+	Params   []SvcParam
+}
+
+func (r *SVCBRecord) String() string {
+	parts := []string{fmt.Sprint(r.Priority), r.Target}
+	for _, p := range r.Params {
+		parts = append(parts, p.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+func (r *SVCBRecord) pack(b []byte) ([]byte, error) {
+	b = binary.BigEndian.AppendUint16(b, r.Priority)
+	b, err := appendName(b, r.Target)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range r.Params {
+		if len(p.Value) > 0xFFFF {
+			return nil, fmt.Errorf("dns: SVCB parameter %s too long", svcParamKeyName(p.Key))
+		}
+		b = binary.BigEndian.AppendUint16(b, p.Key)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(p.Value)))
+		b = append(b, p.Value...)
+	}
+	return b, nil
+}
+
+// EDNSOption is a single option carried in an OPT pseudo-record.
+type EDNSOption struct {
+	Code uint16
+	Data []byte
+}
+
+type OPTRecord struct {
+	Options []EDNSOption
+}
+
+func (r *OPTRecord) String() string {
+	parts := make([]string, len(r.Options))
+	for i, o := range r.Options {
+		parts[i] = fmt.Sprintf("%d:%x", o.Code, o.Data)
+	}
+	return strings.Join(parts, " ")
+}
+
+func (r *OPTRecord) pack(b []byte) ([]byte, error) {
+	for _, o := range r.Options {
+		b = binary.BigEndian.AppendUint16(b, o.Code)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(o.Data)))
+		b = append(b, o.Data...)
+	}
+	return b, nil
+}
+
+// UnknownRecord holds rdata of a type this package does not decode, rendered
+// in the RFC 3597 generic format.
+type UnknownRecord struct {
+	Data []byte
+}
+
+func (r *UnknownRecord) String() string {
+	if len(r.Data) == 0 {
+		return `\# 0`
+	}
+	return fmt.Sprintf(`\# %d %X`, len(r.Data), r.Data)
+}
+
+func (r *UnknownRecord) pack(b []byte) ([]byte, error) { return append(b, r.Data...), nil }
+
+// GenericRecord keeps rdata that was parsed from text, such as dig output,
+// for a type this package does not decode. It cannot be packed.
+type GenericRecord struct {
+	Text string
+}
+
+func (r *GenericRecord) String() string { return r.Text }
+
+func (r *GenericRecord) pack(b []byte) ([]byte, error) {
+	return nil, fmt.Errorf("dns: cannot pack rdata known only as text: %q", r.Text)
+}
+
+func unpackRData(t RRType, msg []byte, off, end int) (RData, error) {
+	rdata := msg[off:end]
+	switch t {
+	case TypeA:
+		if len(rdata) != net.IPv4len {
+			return nil, errShortMessage
+		}
+		return &ARecord{IP: net.IP(append([]byte(nil), rdata...))}, nil
+	case TypeAAAA:
+		if len(rdata) != net.IPv6len {
+			return nil, errShortMessage
+		}
+		return &AAAARecord{IP: net.IP(append([]byte(nil), rdata...))}, nil
+	case TypeNS:
+		name, _, err := unpackName(msg, off)
+		return &NSRecord{Host: name}, err
+	case TypeCNAME:
+		name, _, err := unpackName(msg, off)
+		return &CNAMERecord{Target: name}, err
+	case TypePTR:
+		name, _, err := unpackName(msg, off)
+		return &PTRRecord{Ptr: name}, err
+	case TypeMX:
+		if len(rdata) < 3 {
+			return nil, errShortMessage
+		}
+		name, _, err := unpackName(msg, off+2)
+		return &MXRecord{Preference: binary.BigEndian.Uint16(rdata), Exchange: name}, err
+	case TypeTXT:
+		r := &TXTRecord{}
+		for i := 0; i < len(rdata); {
+			n := int(rdata[i])
+			if i+1+n > len(rdata) {
+				return nil, errShortMessage
+			}
+			r.Strings = append(r.Strings, string(rdata[i+1:i+1+n]))
+			i += 1 + n
+		}
+		return r, nil
+	case TypeSOA:
+		mname, next, err := unpackName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		rname, next, err := unpackName(msg, next)
+		if err != nil {
+			return nil, err
+		}
+		if next+20 > end {
+			return nil, errShortMessage
+		}
+		return &SOARecord{
+			MName:   mname,
+			RName:   rname,
+			Serial:  binary.BigEndian.Uint32(msg[next:]),
+			Refresh: binary.BigEndian.Uint32(msg[next+4:]),
+			Retry:   binary.BigEndian.Uint32(msg[next+8:]),
+			Expire:  binary.BigEndian.Uint32(msg[next+12:]),
+			Minimum: binary.BigEndian.Uint32(msg[next+16:]),
+		}, nil
+	case TypeSRV:
+		if len(rdata) < 7 {
+			return nil, errShortMessage
+		}
+		name, _, err := unpackName(msg, off+6)
+		return &SRVRecord{
+			Priority: binary.BigEndian.Uint16(rdata),
+			Weight:   binary.BigEndian.Uint16(rdata[2:]),
+			Port:     binary.BigEndian.Uint16(rdata[4:]),
+			Target:   name,
+		}, err
+	case TypeCAA:
+		if len(rdata) < 2 || 2+int(rdata[1]) > len(rdata) {
+			return nil, errShortMessage
+		}
+		n := int(rdata[1])
+		return &CAARecord{Flags: rdata[0], Tag: string(rdata[2 : 2+n]), Value: string(rdata[2+n:])}, nil
+	case TypeDS:
+		if len(rdata) < 4 {
+			return nil, errShortMessage
+		}
+		return &DSRecord{
+			KeyTag:     binary.BigEndian.Uint16(rdata),
+			Algorithm:  rdata[2],
+			DigestType: rdata[3],
+			Digest:     append([]byte(nil), rdata[4:]...),
+		}, nil
+	case TypeDNSKEY:
+		if len(rdata) < 4 {
+			return nil, errShortMessage
+		}
+		return &DNSKEYRecord{
+			Flags:     binary.BigEndian.Uint16(rdata),
+			Protocol:  rdata[2],
+			Algorithm: rdata[3],
+			PublicKey: append([]byte(nil), rdata[4:]...),
+		}, nil
+	case TypeRRSIG:
+		if len(rdata) < 19 {
+			return nil, errShortMessage
+		}
+		name, next, err := unpackName(msg, off+18)
+		if err != nil {
+			return nil, err
+		}
+		return &RRSIGRecord{
+			TypeCovered: RRType(binary.BigEndian.Uint16(rdata)),
+			Algorithm:   rdata[2],
+			Labels:      rdata[3],
+			OrigTTL:     binary.BigEndian.Uint32(rdata[4:]),
+			Expiration:  binary.BigEndian.Uint32(rdata[8:]),
+			Inception:   binary.BigEndian.Uint32(rdata[12:]),
+			KeyTag:      binary.BigEndian.Uint16(rdata[16:]),
+			SignerName:  name,
+			Signature:   append([]byte(nil), msg[next:end]...),
+		}, nil
+	case TypeNSEC:
+		name, next, err := unpackName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		if next > end {
+			return nil, errShortMessage
+		}
+		types, err := unpackTypeBitmap(msg[next:end])
+		return &NSECRecord{NextName: name, Types: types}, err
+	case TypeNSEC3:
+		if len(rdata) < 5 || 5+int(rdata[4]) >= len(rdata) {
+			return nil, errShortMessage
+		}
+		r := &NSEC3Record{HashAlgorithm: rdata[0], Flags: rdata[1], Iterations: binary.BigEndian.Uint16(rdata[2:])}
+		i := 5 + int(rdata[4])
+		r.Salt = append([]byte(nil), rdata[5:i]...)
+		n := int(rdata[i])
+		if i+1+n > len(rdata) {
+			return nil, errShortMessage
+		}
+		r.NextHashed = append([]byte(nil), rdata[i+1:i+1+n]...)
+		var err error
+		r.Types, err = unpackTypeBitmap(rdata[i+1+n:])
+		return r, err
+	case TypeTLSA:
+		if len(rdata) < 3 {
+			return nil, errShortMessage
+		}
+		return &TLSARecord{Usage: rdata[0], Selector: rdata[1], MatchingType: rdata[2], Data: append([]byte(nil), rdata[3:]...)}, nil
+	case TypeSSHFP:
+		if len(rdata) < 2 {
+			return nil, errShortMessage
+		}
+		return &SSHFPRecord{Algorithm: rdata[0], Type: rdata[1], Fingerprint: append([]byte(nil), rdata[2:]...)}, nil
+	case TypeNAPTR:
+		if len(rdata) < 4 {
+			return nil, errShortMessage
+		}
+		r := &NAPTRRecord{Order: binary.BigEndian.Uint16(rdata), Preference: binary.BigEndian.Uint16(rdata[2:])}
+		i := 4
+		for _, field := range []*string{&r.Flags, &r.Services, &r.Regexp} {
+			if i >= len(rdata) || i+1+int(rdata[i]) > len(rdata) {
+				return nil, errShortMessage
+			}
+			*field = string(rdata[i+1 : i+1+int(rdata[i])])
+			i += 1 + int(rdata[i])
+		}
+		name, _, err := unpackName(msg, off+i)
+		r.Replacement = name
+		return r, err
+	case TypeSVCB, TypeHTTPS:
+		if len(rdata) < 3 {
+			return nil, errShortMessage
+		}
+		name, next, err := unpackName(msg, off+2)
+		if err != nil {
+			return nil, err
+		}
+		r := &SVCBRecord{Priority: binary.BigEndian.Uint16(rdata), Target: name}
+		for i := next; i < end; {
+			if i+4 > end {
+				return nil, errShortMessage
+			}
+			n := int(binary.BigEndian.Uint16(msg[i+2:]))
+			if i+4+n > end {
+				return nil, errShortMessage
+			}
+			r.Params = append(r.Params, SvcParam{Key: binary.BigEndian.Uint16(msg[i:]), Value: append([]byte(nil), msg[i+4:i+4+n]...)})
+			i += 4 + n
+		}
+		return r, nil
+	case TypeOPT:
+		r := &OPTRecord{}
+		for i := 0; i+4 <= len(rdata); {
+			code := binary.BigEndian.Uint16(rdata[i:])
+			n := int(binary.BigEndian.Uint16(rdata[i+2:]))
+			if i+4+n > len(rdata) {
+				return nil, errShortMessage
+			}
+			r.Options = append(r.Options, EDNSOption{Code: code, Data: append([]byte(nil), rdata[i+4:i+4+n]...)})
+			i += 4 + n
+		}
+		return r, nil
+	}
+	return &UnknownRecord{Data: append([]byte(nil), rdata...)}, nil
+}
@@ -0,0 +1,144 @@
+package lookup_test
+
+import (
+	"dlookup/lookup"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestMessage_PackUnpackRoundTrip(t *testing.T) {
+	msg := &lookup.Message{
+		Header: lookup.Header{ID: 0xBEEF, Response: true, Authoritative: true, RecursionDesired: true, Rcode: lookup.RcodeSuccess},
+		Question: []lookup.Question{
+			{Name: "example.com.", Type: lookup.TypeANY, Class: lookup.ClassINET},
+		},
+		Answer: []lookup.RR{
+			{Name: "example.com.", Type: lookup.TypeA, Class: lookup.ClassINET, TTL: 300, Data: &lookup.ARecord{IP: net.ParseIP("93.184.216.34").To4()}},
+			{Name: "example.com.", Type: lookup.TypeAAAA, Class: lookup.ClassINET, TTL: 300, Data: &lookup.AAAARecord{IP: net.ParseIP("2606:2800:220:1::248")}},
+			{Name: "example.com.", Type: lookup.TypeMX, Class: lookup.ClassINET, TTL: 3600, Data: &lookup.MXRecord{Preference: 10, Exchange: "mail.example.com."}},
+			{Name: "example.com.", Type: lookup.TypeTXT, Class: lookup.ClassINET, TTL: 60, Data: &lookup.TXTRecord{Strings: []string{"v=spf1 -all", "second \"quoted\""}}},
+			{Name: "example.com.", Type: lookup.TypeSOA, Class: lookup.ClassINET, TTL: 3600, Data: &lookup.SOARecord{
+				MName: "ns.icann.org.", RName: "noc.dns.icann.org.", Serial: 2024081401, Refresh: 7200, Retry: 3600, Expire: 1209600, Minimum: 3600,
+			}},
+			{Name: "www.example.com.", Type: lookup.TypeCNAME, Class: lookup.ClassINET, TTL: 120, Data: &lookup.CNAMERecord{Target: "example.com."}},
+			{Name: "_sip._tcp.example.com.", Type: lookup.TypeSRV, Class: lookup.ClassINET, TTL: 300, Data: &lookup.SRVRecord{Priority: 10, Weight: 60, Port: 5060, Target: "sip.example.com."}},
+			{Name: "example.com.", Type: lookup.TypeCAA, Class: lookup.ClassINET, TTL: 300, Data: &lookup.CAARecord{Flags: 128, Tag: "issue", Value: "letsencrypt.org"}},
+			{Name: "example.com.", Type: lookup.TypeDS, Class: lookup.ClassINET, TTL: 300, Data: &lookup.DSRecord{KeyTag: 2371, Algorithm: 13, DigestType: 2, Digest: []byte{0xDE, 0xAD}}},
+			{Name: "example.com.", Type: lookup.TypeDNSKEY, Class: lookup.ClassINET, TTL: 300, Data: &lookup.DNSKEYRecord{Flags: 257, Protocol: 3, Algorithm: 13, PublicKey: []byte{1, 2, 3, 4}}},
+			{Name: "example.com.", Type: lookup.TypeRRSIG, Class: lookup.ClassINET, TTL: 300, Data: &lookup.RRSIGRecord{
+				TypeCovered: lookup.TypeDNSKEY, Algorithm: 13, Labels: 2, OrigTTL: 300, Expiration: 1900000000, Inception: 1890000000, KeyTag: 2371, SignerName: "example.com.", Signature: []byte{0xAB, 0xCD},
+			}},
+			{Name: "_443._tcp.example.com.", Type: lookup.TypeTLSA, Class: lookup.ClassINET, TTL: 300, Data: &lookup.TLSARecord{Usage: 3, Selector: 1, MatchingType: 1, Data: []byte{0xBE, 0xEF}}},
+			{Name: "example.com.", Type: lookup.TypeSSHFP, Class: lookup.ClassINET, TTL: 300, Data: &lookup.SSHFPRecord{Algorithm: 4, Type: 2, Fingerprint: []byte{0xCA, 0xFE}}},
+			{Name: "example.com.", Type: lookup.TypeNAPTR, Class: lookup.ClassINET, TTL: 300, Data: &lookup.NAPTRRecord{Order: 100, Preference: 10, Flags: "S", Services: "SIP+D2U", Regexp: "", Replacement: "_sip._udp.example.com."}},
+			{Name: "example.com.", Type: lookup.TypeHTTPS, Class: lookup.ClassINET, TTL: 300, Data: &lookup.SVCBRecord{Priority: 1, Target: ".", Params: []lookup.SvcParam{
+				{Key: lookup.SvcParamALPN, Value: []byte("\x02h2")},
+				{Key: lookup.SvcParamPort, Value: []byte{0x01, 0xBB}},
+			}}},
+		},
+		Authority: []lookup.RR{
+			{Name: "example.com.", Type: lookup.TypeNS, Class: lookup.ClassINET, TTL: 86400, Data: &lookup.NSRecord{Host: "a.iana-servers.net."}},
+			{Name: "example.com.", Type: lookup.TypeNSEC, Class: lookup.ClassINET, TTL: 300, Data: &lookup.NSECRecord{
+				NextName: "www.example.com.", Types: []lookup.RRType{lookup.TypeA, lookup.TypeNS, lookup.TypeRRSIG, lookup.TypeNSEC, lookup.TypeCAA},
+			}},
+			{Name: "2t7b4g4vsa5smi47k61mv5bv1a22bojr.example.com.", Type: lookup.TypeNSEC3, Class: lookup.ClassINET, TTL: 300, Data: &lookup.NSEC3Record{
+				HashAlgorithm: 1, Flags: 1, Iterations: 0, Salt: []byte{0xAA, 0xBB}, NextHashed: []byte{0x01, 0x02, 0x03}, Types: []lookup.RRType{lookup.TypeNS, lookup.TypeDS},
+			}},
+		},
+		Additional: []lookup.RR{
+			{Name: "a.iana-servers.net.", Type: 99, Class: lookup.ClassINET, TTL: 5, Data: &lookup.UnknownRecord{Data: []byte{1, 2, 3}}},
+		},
+	}
+	msg.SetEDNS0(1232, true)
+
+	packed, err := msg.Pack()
+	if err != nil {
+		t.Fatalf("Pack() error = %v", err)
+	}
+	got, err := lookup.UnpackMessage(packed)
+	if err != nil {
+		t.Fatalf("UnpackMessage() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, msg) {
+		t.Errorf("round trip mismatch:\n got %+v\nwant %+v", got, msg)
+	}
+
+	opt := got.EDNS0()
+	if opt == nil || uint16(opt.Class) != 1232 || opt.TTL&(1<<15) == 0 {
+		t.Errorf("EDNS0() = %+v, want UDP size 1232 with DO bit", opt)
+	}
+}
+
+func TestUnpackMessage_CompressedNames(t *testing.T) {
+	// Response for example.com A with the answer owner compressed to the
+	// question name (pointer 0xC00C).
+	wire := []byte{
+		0x12, 0x34, 0x81, 0x80, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00,
+		7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0,
+		0x00, 0x01, 0x00, 0x01,
+		0xC0, 0x0C, 0x00, 0x05, 0x00, 0x01, 0x00, 0x00, 0x00, 0x3C, 0x00, 0x06,
+		3, 'w', 'w', 'w', 0xC0, 0x0C,
+	}
+	msg, err := lookup.UnpackMessage(wire)
+	if err != nil {
+		t.Fatalf("UnpackMessage() error = %v", err)
+	}
+	if len(msg.Answer) != 1 {
+		t.Fatalf("got %d answers, want 1", len(msg.Answer))
+	}
+	want := "example.com.\t60\tIN\tCNAME\twww.example.com."
+	if got := msg.Answer[0].String(); got != want {
+		t.Errorf("answer = %q, want %q", got, want)
+	}
+	if !msg.RecursionAvailable || msg.Rcode != lookup.RcodeSuccess {
+		t.Errorf("header flags not decoded: %+v", msg.Header)
+	}
+}
+
+func TestUnpackMessage_PointerLoop(t *testing.T) {
+	wire := []byte{
+		0, 0, 0x81, 0x80, 0x00, 0x01, 0, 0, 0, 0, 0, 0,
+		0xC0, 0x0C, 0x00, 0x01, 0x00, 0x01,
+	}
+	if _, err := lookup.UnpackMessage(wire); err == nil {
+		t.Errorf("UnpackMessage() error = nil, want error for self-referencing pointer")
+	}
+}
+
+func TestDNSKEYRecord_KeyTag(t *testing.T) {
+	data, err := lookup.ParseRData(lookup.TypeDNSKEY, "257 3 13 mdsswUyr3DPW132mOi8V9xESWE8jTo0dxCjjnopKl+GqJxpVXckHAeF+ KkxLbxILfDLUT0rAK9iUzy1L53eKGQ==")
+	if err != nil {
+		t.Fatalf("ParseRData() error = %v", err)
+	}
+	key := data.(*lookup.DNSKEYRecord)
+	if got := key.KeyTag(); got != 2371 {
+		t.Errorf("KeyTag() = %d, want 2371", got)
+	}
+	if !key.IsSEP() {
+		t.Error("IsSEP() = false for a key signing key")
+	}
+}
+
+func TestReverseName(t *testing.T) {
+	tests := map[string]string{
+		"192.0.2.1":   "1.2.0.192.in-addr.arpa.",
+		"2001:db8::1": "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.",
+	}
+	for ip, want := range tests {
+		if got := lookup.ReverseName(net.ParseIP(ip)); got != want {
+			t.Errorf("ReverseName(%s) = %q, want %q", ip, got, want)
+		}
+	}
+}
+
+func TestTXTRecord_String(t *testing.T) {
+	txt := &lookup.TXTRecord{Strings: []string{`a "b" \c`, "d"}}
+	want := `"a \"b\" \\c" "d"`
+	if got := txt.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if got := txt.Text(); got != `a "b" \cd` {
+		t.Errorf("Text() = %q", got)
+	}
+}
@@ -0,0 +1,301 @@
+package lookup
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RootTrustAnchors are the DS records of the root zone's key signing keys,
+// as published by IANA, where every chain of trust starts. Tests replace
+// them with the anchors of their own root zone.
+var RootTrustAnchors = []DSRecord{
+	{KeyTag: 20326, Algorithm: 8, DigestType: 2, Digest: mustDecodeHex("E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D")},
+	{KeyTag: 38696, Algorithm: 8, DigestType: 2, Digest: mustDecodeHex("683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16")},
+}
+
+// DNSSECClock returns the time signatures are checked against. Tests can
+// replace it to validate fixtures signed for a fixed period.
+var DNSSECClock = time.Now
+
+// SignatureExpiryWarning is how close to its expiration a valid signature
+// is reported as expiring.
+var SignatureExpiryWarning = 7 * 24 * time.Hour
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Algorithm and digest support. Algorithms RFC 8624 says must not be used
+// for signing are still validated, but reported.
+var (
+	supportedAlgorithms  = map[uint8]bool{5: true, 7: true, 8: true, 10: true, 13: true, 14: true, 15: true}
+	deprecatedAlgorithms = map[uint8]bool{1: true, 3: true, 5: true, 6: true, 7: true, 12: true}
+	supportedDigests     = map[uint8]bool{1: true, 2: true, 4: true}
+)
+
+// dsDigest computes the digest a DS record of the given type holds for key,
+// owned by owner (RFC 4034, section 5.1.4).
+func dsDigest(owner string, key *DNSKEYRecord, digestType uint8) ([]byte, error) {
+	data, err := appendName(nil, strings.ToLower(Fqdn(owner)))
+	if err != nil {
+		return nil, err
+	}
+	if data, err = key.pack(data); err != nil {
+		return nil, err
+	}
+	switch digestType {
+	case 1:
+		sum := sha1.Sum(data)
+		return sum[:], nil
+	case 2:
+		sum := sha256.Sum256(data)
+		return sum[:], nil
+	case 4:
+		sum := sha512.Sum384(data)
+		return sum[:], nil
+	}
+	return nil, fmt.Errorf("unsupported DS digest type %s", digestTypeName(digestType))
+}
+
+// dsMatches reports whether ds refers to key, owned by owner, and its digest
+// is right.
+func dsMatches(ds *DSRecord, owner string, key *DNSKEYRecord) bool {
+	if ds.KeyTag != key.KeyTag() || ds.Algorithm != key.Algorithm {
+		return false
+	}
+	digest, err := dsDigest(owner, key, ds.DigestType)
+	return err == nil && bytes.Equal(digest, ds.Digest)
+}
+
+// nsec3Hash returns the hash of name with the parameters of r (RFC 5155,
+// section 5).
+func nsec3Hash(name string, r *NSEC3Record) ([]byte, error) {
+	if r.HashAlgorithm != 1 {
+		return nil, fmt.Errorf("unsupported NSEC3 hash algorithm %d", r.HashAlgorithm)
+	}
+	data, err := appendName(nil, strings.ToLower(Fqdn(name)))
+	if err != nil {
+		return nil, err
+	}
+	sum := sha1.Sum(append(data, r.Salt...))
+	for i := 0; i < int(r.Iterations); i++ {
+		sum = sha1.Sum(append(sum[:], r.Salt...))
+	}
+	return sum[:], nil
+}
+
+// nsec3Covers reports whether hash falls strictly between owner, the hash
+// of r's owner name, and the next hash in the chain. The last record of the
+// chain wraps around to the first.
+func nsec3Covers(owner []byte, r *NSEC3Record, hash []byte) bool {
+	after, before := bytes.Compare(hash, owner) > 0, bytes.Compare(hash, r.NextHashed) < 0
+	if bytes.Compare(owner, r.NextHashed) < 0 {
+		return after && before
+	}
+	return after || before
+}
+
+// signedData builds the data sig signs over rrset: the RRSIG rdata without
+// the signature, then the records in canonical form and order (RFC 4034,
+// sections 3.1.8.1 and 6).
+func signedData(sig *RRSIGRecord, rrset []RR) ([]byte, error) {
+	data, err := sig.packNoSignature(nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(rrset) == 0 {
+		return nil, errors.New("empty RRset")
+	}
+	owner := strings.ToLower(Fqdn(rrset[0].Name))
+	// A record synthesized from a wildcard is signed as the wildcard.
+	labels := strings.Split(strings.TrimSuffix(owner, "."), ".")
+	if owner == "." {
+		labels = nil
+	} else if labels[0] == "*" {
+		labels = labels[1:]
+	}
+	if int(sig.Labels) < len(labels) {
+		owner = "*." + strings.Join(labels[len(labels)-int(sig.Labels):], ".") + "."
+	}
+	ownerWire, err := appendName(nil, owner)
+	if err != nil {
+		return nil, err
+	}
+	rdatas := make([][]byte, 0, len(rrset))
+	for _, rr := range rrset {
+		if rr.Data == nil {
+			return nil, fmt.Errorf("%s record for %s has no data", rr.Type, rr.Name)
+		}
+		rdata, err := canonicalRData(rr.Data).pack(nil)
+		if err != nil {
+			return nil, err
+		}
+		rdatas = append(rdatas, rdata)
+	}
+	sort.Slice(rdatas, func(i, j int) bool { return bytes.Compare(rdatas[i], rdatas[j]) < 0 })
+	for i, rdata := range rdatas {
+		if i > 0 && bytes.Equal(rdata, rdatas[i-1]) {
+			continue
+		}
+		data = append(data, ownerWire...)
+		data = binary.BigEndian.AppendUint16(data, uint16(rrset[0].Type))
+		data = binary.BigEndian.AppendUint16(data, uint16(rrset[0].Class))
+		data = binary.BigEndian.AppendUint32(data, sig.OrigTTL)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(rdata)))
+		data = append(data, rdata...)
+	}
+	return data, nil
+}
+
+// canonicalRData returns d with the domain names it embeds lowercased, for
+// the types whose names RFC 4034 (section 6.2) lowercases.
+func canonicalRData(d RData) RData {
+	switch d := d.(type) {
+	case *NSRecord:
+		return &NSRecord{Host: strings.ToLower(d.Host)}
+	case *CNAMERecord:
+		return &CNAMERecord{Target: strings.ToLower(d.Target)}
+	case *PTRRecord:
+		return &PTRRecord{Ptr: strings.ToLower(d.Ptr)}
+	case *MXRecord:
+		return &MXRecord{Preference: d.Preference, Exchange: strings.ToLower(d.Exchange)}
+	case *SOARecord:
+		c := *d
+		c.MName, c.RName = strings.ToLower(d.MName), strings.ToLower(d.RName)
+		return &c
+	case *SRVRecord:
+		c := *d
+		c.Target = strings.ToLower(d.Target)
+		return &c
+	case *NAPTRRecord:
+		c := *d
+		c.Replacement = strings.ToLower(d.Replacement)
+		return &c
+	}
+	return d
+}
+
+// verifySignature checks sig over rrset with key. It checks the signature
+// itself only, not its validity period.
+func verifySignature(sig *RRSIGRecord, key *DNSKEYRecord, rrset []RR) error {
+	if sig.Algorithm != key.Algorithm || sig.KeyTag != key.KeyTag() {
+		return errors.New("key does not match the signature")
+	}
+	data, err := signedData(sig, rrset)
+	if err != nil {
+		return err
+	}
+	var hash crypto.Hash
+	switch sig.Algorithm {
+	case 5, 7:
+		hash = crypto.SHA1
+	case 8, 13:
+		hash = crypto.SHA256
+	case 10:
+		hash = crypto.SHA512
+	case 14:
+		hash = crypto.SHA384
+	case 15:
+		if len(key.PublicKey) != ed25519.PublicKeySize {
+			return errors.New("malformed Ed25519 key")
+		}
+		if !ed25519.Verify(ed25519.PublicKey(key.PublicKey), data, sig.Signature) {
+			return errors.New("signature does not verify")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported algorithm %s", dnssecAlgorithmName(sig.Algorithm))
+	}
+	h := hash.New()
+	h.Write(data)
+	digest := h.Sum(nil)
+
+	switch sig.Algorithm {
+	case 13, 14:
+		curve := elliptic.P256()
+		if sig.Algorithm == 14 {
+			curve = elliptic.P384()
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(key.PublicKey) != 2*size || len(sig.Signature) != 2*size {
+			return errors.New("malformed ECDSA key or signature")
+		}
+		pub := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(key.PublicKey[:size]),
+			Y:     new(big.Int).SetBytes(key.PublicKey[size:]),
+		}
+		r := new(big.Int).SetBytes(sig.Signature[:size])
+		s := new(big.Int).SetBytes(sig.Signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("signature does not verify")
+		}
+		return nil
+	}
+	pub, err := rsaPublicKey(key.PublicKey)
+	if err != nil {
+		return err
+	}
+	if err := rsa.VerifyPKCS1v15(pub, hash, digest, sig.Signature); err != nil {
+		return errors.New("signature does not verify")
+	}
+	return nil
+}
+
+// rsaPublicKey decodes an RSA DNSKEY (RFC 3110, section 2): the exponent
+// length in one byte, or in three starting with zero, then the exponent and
+// the modulus.
+func rsaPublicKey(key []byte) (*rsa.PublicKey, error) {
+	if len(key) < 3 {
+		return nil, errors.New("malformed RSA key")
+	}
+	expLen, off := int(key[0]), 1
+	if expLen == 0 {
+		expLen, off = int(binary.BigEndian.Uint16(key[1:])), 3
+	}
+	if expLen == 0 || expLen > 4 || off+expLen >= len(key) {
+		return nil, errors.New("malformed RSA key")
+	}
+	exp := 0
+	for _, b := range key[off : off+expLen] {
+		exp = exp<<8 | int(b)
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(key[off+expLen:]), E: exp}, nil
+}
+
+// keyBits returns the size of key in bits: the modulus for RSA, the curve
+// for ECDSA and EdDSA.
+func keyBits(key *DNSKEYRecord) int {
+	switch key.Algorithm {
+	case 5, 7, 8, 10:
+		if pub, err := rsaPublicKey(key.PublicKey); err == nil {
+			return pub.N.BitLen()
+		}
+	case 13:
+		return 256
+	case 14:
+		return 384
+	case 15:
+		return 255
+	}
+	return len(key.PublicKey) * 8
+}
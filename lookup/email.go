@@ -0,0 +1,352 @@
+package lookup
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// SPFLookupLimit is how many DNS-querying terms an SPF evaluation may use,
+// counting those of included records (RFC 7208, section 4.6.4).
+const SPFLookupLimit = 10
+
+// spfMaxDepth stops the expansion of include chains that are deeper than
+// any sane configuration but are not loops, such as generated names.
+const spfMaxDepth = 10
+
+// DKIMSelectors are the selectors the email audit tries when looking for
+// DKIM keys. Selectors cannot be listed through DNS, so these are the ones
+// common mail services use; the config can replace them with the
+// selectors a domain is known to use.
+var DKIMSelectors = []string{
+	"default", "dkim", "mail", "selector1", "selector2", "google",
+	"k1", "k2", "s1", "s2", "mandrill", "smtp",
+}
+
+// SPFTerm is a mechanism or modifier of an SPF record.
+type SPFTerm struct {
+	// Qualifier is "+", "-", "~" or "?" for mechanisms, empty for
+	// modifiers.
+	Qualifier string `json:"qualifier,omitempty"`
+	// Name is the mechanism ("ip4", "include", "all", ...) or modifier
+	// ("redirect", "exp") in lowercase.
+	Name string `json:"name"`
+	// Value is what follows ":" or "=", such as a domain or network.
+	Value string `json:"value,omitempty"`
+	// Modifier is set for "name=value" terms.
+	Modifier bool `json:"modifier,omitempty"`
+	// Networks are the networks an ip4, ip6, a or mx term matches, once
+	// resolved by the SPF view; Error is why an a or mx term could not be.
+	Networks []string `json:"networks,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+func (t SPFTerm) String() string {
+	s := t.Name
+	if t.Qualifier != "" && t.Qualifier != "+" {
+		s = t.Qualifier + s
+	}
+	switch {
+	case t.Modifier:
+		s += "=" + t.Value
+	case t.Value != "" && strings.HasPrefix(t.Value, "/"):
+		s += t.Value
+	case t.Value != "":
+		s += ":" + t.Value
+	}
+	return s
+}
+
+// countsLookup reports whether evaluating the term queries DNS.
+func (t SPFTerm) countsLookup() bool {
+	switch t.Name {
+	case "include", "a", "mx", "ptr", "exists":
+		return !t.Modifier
+	case "redirect":
+		return t.Modifier
+	}
+	return false
+}
+
+// followed reports whether the term pulls in another record: an include
+// or redirect whose domain has no macros, which expand per message.
+func (t SPFTerm) followed() bool {
+	if strings.Contains(t.Value, "%") {
+		return false
+	}
+	return t.Modifier && t.Name == "redirect" || !t.Modifier && t.Name == "include"
+}
+
+// ParseSPF parses an SPF record into its terms. It fails on a record that
+// does not start with "v=spf1" or has a term it does not know.
+func ParseSPF(record string) ([]SPFTerm, error) {
+	fields := strings.Fields(record)
+	if len(fields) == 0 || !strings.EqualFold(fields[0], "v=spf1") {
+		return nil, fmt.Errorf("%q is not an SPF record", record)
+	}
+	var terms []SPFTerm
+	for _, field := range fields[1:] {
+		term, err := parseSPFTerm(field)
+		if err != nil {
+			return terms, err
+		}
+		terms = append(terms, term)
+	}
+	return terms, nil
+}
+
+func parseSPFTerm(field string) (SPFTerm, error) {
+	if name, value, ok := strings.Cut(field, "="); ok && !strings.ContainsAny(name, ":/") {
+		name = strings.ToLower(name)
+		if name == "" {
+			return SPFTerm{}, fmt.Errorf("malformed modifier %q", field)
+		}
+		return SPFTerm{Name: name, Value: value, Modifier: true}, nil
+	}
+	term := SPFTerm{Qualifier: "+"}
+	if strings.ContainsAny(field[:1], "+-~?") {
+		term.Qualifier, field = field[:1], field[1:]
+	}
+	name, value, hasValue := strings.Cut(field, ":")
+	if !hasValue {
+		if i := strings.IndexByte(field, '/'); i >= 0 {
+			name, value = field[:i], field[i:]
+		}
+	}
+	term.Name, term.Value = strings.ToLower(name), value
+	switch term.Name {
+	case "all":
+		if value != "" {
+			return term, fmt.Errorf("%q takes no argument", field)
+		}
+	case "include", "exists":
+		if value == "" {
+			return term, fmt.Errorf("%q needs a domain", field)
+		}
+	case "ip4", "ip6":
+		if !validSPFNetwork(term.Name, value) {
+			return term, fmt.Errorf("%q is not a valid %s network", value, term.Name)
+		}
+	case "a", "mx", "ptr":
+	default:
+		return term, fmt.Errorf("unknown mechanism %q", field)
+	}
+	return term, nil
+}
+
+func validSPFNetwork(mechanism, value string) bool {
+	addr, prefix, hasPrefix := strings.Cut(value, "/")
+	ip := net.ParseIP(addr)
+	if ip == nil || (ip.To4() != nil) != (mechanism == "ip4") {
+		return false
+	}
+	if !hasPrefix {
+		return true
+	}
+	bits, err := strconv.Atoi(prefix)
+	limit := 32
+	if mechanism == "ip6" {
+		limit = 128
+	}
+	return err == nil && bits >= 0 && bits <= limit
+}
+
+// SPFNode is an SPF record and, through its include and redirect terms,
+// the records it pulls in.
+type SPFNode struct {
+	Domain string    `json:"domain"`
+	Record string    `json:"record,omitempty"`
+	Terms  []SPFTerm `json:"terms,omitempty"`
+	// Children holds the records of the include and redirect terms, in
+	// the order of Terms.
+	Children []*SPFNode `json:"children,omitempty"`
+	// Via is the term of the parent record that pulled this one in.
+	Via string `json:"via,omitempty"`
+	// Error is why the record could not be fetched, parsed or expanded.
+	Error string `json:"error,omitempty"`
+}
+
+// Lookups counts the DNS-querying terms of the record and of every record
+// it includes, as checked against SPFLookupLimit.
+func (n *SPFNode) Lookups() int {
+	count := 0
+	for _, t := range n.Terms {
+		if t.countsLookup() {
+			count++
+		}
+	}
+	for _, c := range n.Children {
+		count += c.Lookups()
+	}
+	return count
+}
+
+// spfResolver builds SPF trees, fetching the records with txt.
+type spfResolver struct {
+	txt func(name string) ([]string, error)
+}
+
+// lookupRecords looks up the records of type t at host with the native
+// provider for t, such as `DNS (TXT)`, through RunLookup, so it keeps that
+// provider's timeout, retries and cache. A name that does not exist has
+// none.
+func lookupRecords(ctx context.Context, t RRType, host string) ([]RR, error) {
+	name := fmt.Sprintf("DNS (%s)", t)
+	provider, ok := GetProvider(name)
+	if !ok {
+		return nil, fmt.Errorf("%s is not available", name)
+	}
+	result, err := RunLookup(ctx, provider, host)
+	if err != nil {
+		return nil, err
+	}
+	return recordsOfType(result, t), nil
+}
+
+// recordsOfType returns the records of type t in result, leaving out the
+// aliases that led to them.
+func recordsOfType(result *Result, t RRType) []RR {
+	var rrs []RR
+	for _, rr := range result.Records {
+		if rr.Type == t && rr.Data != nil {
+			rrs = append(rrs, rr)
+		}
+	}
+	return rrs
+}
+
+// memoTXT returns the txt function of spfResolver for a run: it fetches
+// the TXT records at a name with records, joins the strings of each, and
+// keeps the answers for the run, since includes are often shared.
+func memoTXT(records func(name string) ([]RR, error)) func(name string) ([]string, error) {
+	answers := make(map[string][]string)
+	return func(name string) ([]string, error) {
+		key := strings.ToLower(name)
+		if texts, ok := answers[key]; ok {
+			return texts, nil
+		}
+		rrs, err := records(name)
+		if err != nil {
+			return nil, err
+		}
+		var texts []string
+		for _, rr := range rrs {
+			texts = append(texts, rr.Data.(*TXTRecord).Text())
+		}
+		answers[key] = texts
+		return texts, nil
+	}
+}
+
+// hasVersion reports whether a record starts with the version tag v, as in
+// "v=spf1 ..." or "v=DMARC1; ...".
+func hasVersion(record, v string) bool {
+	if len(record) < len(v) || !strings.EqualFold(record[:len(v)], v) {
+		return false
+	}
+	rest := record[len(v):]
+	return rest == "" || rest[0] == ' ' || rest[0] == ';'
+}
+
+// fetchSPF builds the SPF tree of domain. path holds the domains of the
+// records that included this one, to detect loops.
+func (s *spfResolver) fetchSPF(domain, via string, path []string) *SPFNode {
+	node := &SPFNode{Domain: strings.TrimSuffix(domain, "."), Via: via}
+	for _, p := range path {
+		if strings.EqualFold(p, node.Domain) {
+			node.Error = "include loop: " + strings.Join(append(path, node.Domain), " → ")
+			return node
+		}
+	}
+	if len(path) > spfMaxDepth {
+		node.Error = fmt.Sprintf("includes nested more than %d deep", spfMaxDepth)
+		return node
+	}
+	texts, err := s.txt(node.Domain)
+	if err != nil {
+		node.Error = err.Error()
+		return node
+	}
+	var records []string
+	for _, t := range texts {
+		if hasVersion(t, "v=spf1") {
+			records = append(records, t)
+		}
+	}
+	switch len(records) {
+	case 0:
+		node.Error = "no SPF record"
+		return node
+	case 1:
+	default:
+		node.Error = fmt.Sprintf("%d SPF records; there must be only one", len(records))
+		return node
+	}
+	node.Record = records[0]
+	node.Terms, err = ParseSPF(node.Record)
+	if err != nil {
+		node.Error = err.Error()
+	}
+	path = append(path, node.Domain)
+	for _, t := range node.Terms {
+		if !t.followed() {
+			continue
+		}
+		node.Children = append(node.Children, s.fetchSPF(t.Value, t.String(), append([]string(nil), path...)))
+	}
+	return node
+}
+
+// parseTags parses a tag list such as DMARC or DKIM records use:
+// "tag=value" pairs separated by semicolons. Tag names are lowercased; the
+// first occurrence of a tag wins.
+func parseTags(record string) (map[string]string, []string) {
+	tags := make(map[string]string)
+	var order []string
+	for _, part := range strings.Split(record, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !ok || name == "" {
+			continue
+		}
+		if _, seen := tags[name]; !seen {
+			order = append(order, name)
+			tags[name] = strings.TrimSpace(value)
+		}
+	}
+	return tags, order
+}
+
+// dkimKeyBits returns the size of a DKIM public key given as the base64 p=
+// value, for the key type k (RFC 6376 and RFC 8463).
+func dkimKeyBits(keyType, p string) (int, error) {
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(p), ""))
+	if err != nil {
+		return 0, fmt.Errorf("the key is not valid base64")
+	}
+	if strings.EqualFold(keyType, "ed25519") {
+		if len(der) != ed25519.PublicKeySize {
+			return 0, fmt.Errorf("the ed25519 key is %d bytes, not %d", len(der), ed25519.PublicKeySize)
+		}
+		return 256, nil
+	}
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		// Some signers publish the bare PKCS #1 key.
+		if rsaKey, err := x509.ParsePKCS1PublicKey(der); err == nil {
+			return rsaKey.N.BitLen(), nil
+		}
+		return 0, fmt.Errorf("the key cannot be parsed: %v", err)
+	}
+	rsaKey, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return 0, fmt.Errorf("k=rsa but the key is a %T", pub)
+	}
+	return rsaKey.N.BitLen(), nil
+}
@@ -0,0 +1,48 @@
+package lookup_test
+
+import (
+	"strings"
+	"testing"
+
+	"dlookup/lookup"
+)
+
+func TestParseSPF(t *testing.T) {
+	record := "v=spf1 ip4:192.0.2.0/24 ip6:2001:db8::/32 a mx:mail.example.com/28 -ptr ~include:_spf.example.net exists:%{i}.spf.example.net ?all redirect=_spf.example.com exp=explain.example.com"
+	terms, err := lookup.ParseSPF(record)
+	if err != nil {
+		t.Fatalf("ParseSPF() error = %v", err)
+	}
+	var got []string
+	for _, term := range terms {
+		got = append(got, term.String())
+	}
+	want := "ip4:192.0.2.0/24 ip6:2001:db8::/32 a mx:mail.example.com/28 -ptr ~include:_spf.example.net exists:%{i}.spf.example.net ?all redirect=_spf.example.com exp=explain.example.com"
+	if strings.Join(got, " ") != want {
+		t.Errorf("terms = %s\nwant    %s", strings.Join(got, " "), want)
+	}
+	if terms[4].Qualifier != "-" || terms[4].Name != "ptr" || terms[5].Value != "_spf.example.net" {
+		t.Errorf("terms[4:6] = %+v", terms[4:6])
+	}
+	if !terms[8].Modifier || terms[8].Name != "redirect" || terms[8].Value != "_spf.example.com" {
+		t.Errorf("redirect = %+v", terms[8])
+	}
+	if a, err := lookup.ParseSPF("v=spf1 a/24 -all"); err != nil || a[0].Name != "a" || a[0].Value != "/24" {
+		t.Errorf("ParseSPF(a/24) = %+v, %v", a, err)
+	}
+}
+
+func TestParseSPF_Errors(t *testing.T) {
+	for _, record := range []string{
+		"v=DMARC1; p=none",
+		"v=spf1 include:",
+		"v=spf1 ip4:2001:db8::1",
+		"v=spf1 ip4:192.0.2.0/33",
+		"v=spf1 all:example.com",
+		"v=spf1 foo:example.com -all",
+	} {
+		if terms, err := lookup.ParseSPF(record); err == nil {
+			t.Errorf("ParseSPF(%q) = %+v, want error", record, terms)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package lookup
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// OutputFormats lists the formats accepted by NewEncoder.
+var OutputFormats = []string{"text", "json", "ndjson", "csv"}
+
+// Encoder writes results to a stream in one of the OutputFormats.
+type Encoder interface {
+	Encode(r *Result) error
+	// Close flushes anything buffered. It does not close the writer.
+	Close() error
+}
+
+// NewEncoder returns an encoder for format writing to w.
+func NewEncoder(w io.Writer, format string) (Encoder, error) {
+	switch strings.ToLower(format) {
+	case "text", "":
+		return &textEncoder{w: w}, nil
+	case "json":
+		return &jsonEncoder{w: w}, nil
+	case "ndjson":
+		return &ndjsonEncoder{enc: json.NewEncoder(w)}, nil
+	case "csv":
+		return &csvEncoder{w: csv.NewWriter(w)}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q (want one of %s)", format, strings.Join(OutputFormats, ", "))
+}
+
+type textEncoder struct {
+	w     io.Writer
+	count int
+}
+
+func (e *textEncoder) Encode(r *Result) error {
+	sep := ""
+	if e.count > 0 {
+		sep = "\n"
+	}
+	e.count++
+	query := r.Query
+	if r.Server != "" {
+		query += " @" + r.Server
+	}
+	_, err := fmt.Fprintf(e.w, "%s--- %s: %s ---\n%s\n", sep, r.Provider, query, strings.TrimSpace(r.Text()))
+	return err
+}
+
+func (e *textEncoder) Close() error { return nil }
+
+// jsonEncoder writes a single JSON array, so it buffers until Close.
+type jsonEncoder struct {
+	w       io.Writer
+	results []*Result
+}
+
+func (e *jsonEncoder) Encode(r *Result) error {
+	e.results = append(e.results, r)
+	return nil
+}
+
+func (e *jsonEncoder) Close() error {
+	results := e.results
+	if results == nil {
+		results = []*Result{}
+	}
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(append(data, '\n'))
+	return err
+}
+
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) Encode(r *Result) error { return e.enc.Encode(r) }
+
+func (e *ndjsonEncoder) Close() error { return nil }
+
+// csvHeader is the column layout of the csv format: one row per record, or
+// a single row with empty record columns when a lookup has none.
+var csvHeader = []string{"provider", "query", "server", "status", "error", "exit_code", "duration_ms", "command", "name", "type", "class", "ttl", "data"}
+
+type csvEncoder struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+// Encode writes the rows for r and flushes them, so rows appear as lookups
+// finish.
+func (e *csvEncoder) Encode(r *Result) error {
+	if err := e.encode(r); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) encode(r *Result) error {
+	if !e.wroteHeader {
+		if err := e.w.Write(csvHeader); err != nil {
+			return err
+		}
+		e.wroteHeader = true
+	}
+	// A report is flattened into the rows of the lookups it ran.
+	if len(r.Results) > 0 {
+		for _, child := range r.Results {
+			if err := e.encode(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	status, errText := "ok", ""
+	if r.Err != nil {
+		status, errText = "error", r.Err.Error()
+	}
+	row := []string{
+		r.Provider, r.Query, r.Server, status, errText,
+		strconv.Itoa(r.ExitCode), strconv.FormatInt(r.Duration.Milliseconds(), 10), r.Command,
+	}
+	if len(r.Records) == 0 {
+		return e.w.Write(append(row, "", "", "", "", ""))
+	}
+	for _, rr := range r.Records {
+		data := ""
+		if rr.Data != nil {
+			data = rr.Data.String()
+		}
+		rec := append(append([]string(nil), row...), rr.Name, rr.Type.String(), rr.Class.String(), strconv.FormatUint(uint64(rr.TTL), 10), data)
+		if err := e.w.Write(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *csvEncoder) Close() error {
+	if !e.wroteHeader {
+		if err := e.w.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
@@ -0,0 +1,121 @@
+package lookup_test
+
+import (
+	"bytes"
+	"dlookup/lookup"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func exportTestResults() []*lookup.Result {
+	return []*lookup.Result{
+		{
+			Provider: "DNS (A)", Query: "example.com",
+			Records: []lookup.RR{
+				{Name: "example.com.", Type: lookup.TypeA, Class: lookup.ClassINET, TTL: 300, Data: &lookup.ARecord{IP: net.ParseIP("192.0.2.1").To4()}},
+				{Name: "example.com.", Type: lookup.TypeA, Class: lookup.ClassINET, TTL: 300, Data: &lookup.ARecord{IP: net.ParseIP("192.0.2.2").To4()}},
+			},
+			Stdout:   "example.com.\t300\tIN\tA\t192.0.2.1\nexample.com.\t300\tIN\tA\t192.0.2.2",
+			Duration: 12 * time.Millisecond,
+		},
+		{
+			Provider: "WHOIS", Query: "broken.test",
+			Stderr: "connect: connection refused", ExitCode: 2, Command: "whois broken.test",
+			Err: errors.New("command 'whois broken.test' failed: exit status 2"),
+		},
+	}
+}
+
+func encodeAll(t *testing.T, format string, results []*lookup.Result) string {
+	t.Helper()
+	var buf bytes.Buffer
+	enc, err := lookup.NewEncoder(&buf, format)
+	if err != nil {
+		t.Fatalf("NewEncoder(%q) error = %v", format, err)
+	}
+	for _, r := range results {
+		if err := enc.Encode(r); err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.String()
+}
+
+func TestEncoder_Text(t *testing.T) {
+	out := encodeAll(t, "text", exportTestResults())
+	want := "--- DNS (A): example.com ---\nexample.com.\t300\tIN\tA\t192.0.2.1\nexample.com.\t300\tIN\tA\t192.0.2.2\n" +
+		"\n--- WHOIS: broken.test ---\nError: command 'whois broken.test' failed: exit status 2\nStderr:\nconnect: connection refused\n"
+	if out != want {
+		t.Errorf("text output =\n%q\nwant\n%q", out, want)
+	}
+}
+
+func TestEncoder_JSON(t *testing.T) {
+	out := encodeAll(t, "json", exportTestResults())
+	var decoded []map[string]any
+	if err := json.Unmarshal([]byte(out), &decoded); err != nil {
+		t.Fatalf("output is not a JSON array: %v\n%s", err, out)
+	}
+	if len(decoded) != 2 || decoded[0]["provider"] != "DNS (A)" || decoded[1]["error"] == nil {
+		t.Errorf("decoded = %v", decoded)
+	}
+
+	if empty := encodeAll(t, "json", nil); strings.TrimSpace(empty) != "[]" {
+		t.Errorf("empty json output = %q, want []", empty)
+	}
+}
+
+func TestEncoder_NDJSON(t *testing.T) {
+	out := encodeAll(t, "ndjson", exportTestResults())
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("ndjson output has %d lines, want 2:\n%s", len(lines), out)
+	}
+	for _, line := range lines {
+		var v map[string]any
+		if err := json.Unmarshal([]byte(line), &v); err != nil {
+			t.Errorf("line %q is not JSON: %v", line, err)
+		}
+	}
+}
+
+func TestEncoder_CSV(t *testing.T) {
+	report := &lookup.Result{Provider: "Report", Query: "example.com", Results: exportTestResults()}
+	out := encodeAll(t, "csv", []*lookup.Result{report})
+	rows, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not CSV: %v", err)
+	}
+	want := [][]string{
+		{"provider", "query", "server", "status", "error", "exit_code", "duration_ms", "command", "name", "type", "class", "ttl", "data"},
+		{"DNS (A)", "example.com", "", "ok", "", "0", "12", "", "example.com.", "A", "IN", "300", "192.0.2.1"},
+		{"DNS (A)", "example.com", "", "ok", "", "0", "12", "", "example.com.", "A", "IN", "300", "192.0.2.2"},
+		{"WHOIS", "broken.test", "", "error", "command 'whois broken.test' failed: exit status 2", "2", "0", "whois broken.test", "", "", "", "", ""},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("csv has %d rows, want %d:\n%s", len(rows), len(want), out)
+	}
+	for i := range want {
+		if strings.Join(rows[i], "|") != strings.Join(want[i], "|") {
+			t.Errorf("row %d = %q, want %q", i, rows[i], want[i])
+		}
+	}
+
+	if empty := encodeAll(t, "csv", nil); !strings.HasPrefix(empty, "provider,query,") {
+		t.Errorf("empty csv output = %q, want header", empty)
+	}
+}
+
+func TestNewEncoder_UnknownFormat(t *testing.T) {
+	if _, err := lookup.NewEncoder(&bytes.Buffer{}, "yaml"); err == nil {
+		t.Errorf("NewEncoder(yaml) error = nil, want error")
+	}
+}
@@ -0,0 +1,69 @@
+package lookup
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+const acePrefix = "xn--"
+
+// idnDots are the characters IDNA treats as label separators besides ".".
+var idnDots = strings.NewReplacer("。", ".", "．", ".", "｡", ".")
+
+// ToASCII converts an internationalized domain name to the ASCII form that
+// is sent in queries: labels with non-ASCII characters are mapped and
+// Punycode-encoded by the IDNA lookup profile (UTS #46), other labels are
+// kept as they are. Labels are converted one at a time because the profile
+// rejects ASCII labels DNS allows, such as "_dmarc".
+func ToASCII(name string) (string, error) {
+	name = idnDots.Replace(name)
+	labels := strings.Split(name, ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+		encoded, err := idna.Lookup.ToASCII(label)
+		if err != nil {
+			return "", fmt.Errorf("cannot encode label %q: %w", label, err)
+		}
+		labels[i] = encoded
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// ToUnicode converts the "xn--" labels of name back to Unicode. Labels that
+// do not decode are kept as they are.
+func ToUnicode(name string) string {
+	labels := strings.Split(name, ".")
+	for i, label := range labels {
+		if len(label) <= len(acePrefix) || !strings.EqualFold(label[:len(acePrefix)], acePrefix) {
+			continue
+		}
+		if decoded, err := idna.Lookup.ToUnicode(label); err == nil {
+			labels[i] = decoded
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+// DisplayName shows a name in both forms, "bücher.example
+// (xn--bcher-kva.example)", when its Unicode form differs, and as it is
+// otherwise.
+func DisplayName(name string) string {
+	if u := ToUnicode(name); u != name {
+		return fmt.Sprintf("%s (%s)", u, name)
+	}
+	return name
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,50 @@
+package lookup_test
+
+import (
+	"testing"
+
+	"dlookup/lookup"
+)
+
+func TestToASCII(t *testing.T) {
+	// Examples from RFC 3492, section 7.1, and common test names.
+	tests := map[string]string{
+		"bücher.example":         "xn--bcher-kva.example",
+		"münchen.de":             "xn--mnchen-3ya.de",
+		"他们为什么不说中文":              "xn--ihqwcrb4cv8a8dqg056pqjye",
+		"Pročprostěnemluvíčesky": "xn--proprostnemluvesky-uyb24dma41a",
+		"ascii.example":          "ascii.example",
+		"Bücher.example":         "xn--bcher-kva.example",
+		"_dmarc.bücher.example":  "_dmarc.xn--bcher-kva.example",
+	}
+	for input, want := range tests {
+		got, err := lookup.ToASCII(input)
+		if err != nil {
+			t.Errorf("ToASCII(%q) error = %v", input, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ToASCII(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestToUnicode(t *testing.T) {
+	tests := map[string]string{
+		"xn--bcher-kva.example":        "bücher.example",
+		"XN--MNCHEN-3YA.de":            "münchen.de",
+		"xn--ihqwcrb4cv8a8dqg056pqjye": "他们为什么不说中文",
+		"xn--invalid-!.example":        "xn--invalid-!.example",
+	}
+	for input, want := range tests {
+		if got := lookup.ToUnicode(input); got != want {
+			t.Errorf("ToUnicode(%q) = %q, want %q", input, got, want)
+		}
+	}
+	if got, want := lookup.DisplayName("xn--bcher-kva.example"), "bücher.example (xn--bcher-kva.example)"; got != want {
+		t.Errorf("DisplayName() = %q, want %q", got, want)
+	}
+	if got := lookup.DisplayName("example.com"); got != "example.com" {
+		t.Errorf("DisplayName() = %q, want the name unchanged", got)
+	}
+}
@@ -0,0 +1,265 @@
+package lookup
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// InputOptions controls how DomainList.Read parses its input.
+type InputOptions struct {
+	// Column selects the CSV column holding the domains, either by 1-based
+	// index or by header name. When it is a name, the first row is the
+	// header. Empty means plain text with one domain per line.
+	Column string
+}
+
+// SkippedLine is an input line that did not yield a domain.
+type SkippedLine struct {
+	Source string // File name, "-" for stdin or "args"
+	Line   int    // 1-based line (or argument) number
+	Text   string
+	Reason string // "duplicate", "invalid" or "missing column"
+	Detail string // Why an invalid line is invalid
+}
+
+func (s SkippedLine) String() string {
+	if s.Detail != "" {
+		return fmt.Sprintf("%s:%d: %s %q: %s", s.Source, s.Line, s.Reason, s.Text, s.Detail)
+	}
+	return fmt.Sprintf("%s:%d: %s %q", s.Source, s.Line, s.Reason, s.Text)
+}
+
+// DomainList collects domains and IPs from files, stdin and arguments. It
+// keeps the first occurrence of each domain and records every line it
+// skipped.
+type DomainList struct {
+	Domains []string
+	Skipped []SkippedLine
+	seen    map[string]bool
+}
+
+// Add adds a single domain taken from line of source, normalized with
+// NormalizeInput. Text after '#' is a comment.
+func (l *DomainList) Add(source string, line int, text string) {
+	if i := strings.IndexByte(text, '#'); i >= 0 {
+		text = text[:i]
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	input, err := NormalizeInput(text)
+	if err != nil {
+		l.Skipped = append(l.Skipped, SkippedLine{Source: source, Line: line, Text: text, Reason: "invalid", Detail: err.Error()})
+		return
+	}
+	domain := input.Query
+	key := strings.ToLower(strings.TrimSuffix(domain, "."))
+	if l.seen[key] {
+		l.skip(source, line, text, "duplicate")
+		return
+	}
+	if l.seen == nil {
+		l.seen = make(map[string]bool)
+	}
+	l.seen[key] = true
+	l.Domains = append(l.Domains, domain)
+}
+
+func (l *DomainList) skip(source string, line int, text, reason string) {
+	l.Skipped = append(l.Skipped, SkippedLine{Source: source, Line: line, Text: text, Reason: reason})
+}
+
+// Read adds the domains in r, named source in the skipped-line report.
+// Blank lines and comments are ignored.
+func (l *DomainList) Read(r io.Reader, source string, opts InputOptions) error {
+	if opts.Column != "" {
+		return l.readCSV(r, source, opts.Column)
+	}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		l.Add(source, n, scanner.Text())
+	}
+	return scanner.Err()
+}
+
+func (l *DomainList) readCSV(r io.Reader, source, column string) error {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	index, byName := -1, false
+	if n, err := strconv.Atoi(column); err == nil {
+		if n < 1 {
+			return fmt.Errorf("invalid CSV column %d: columns are numbered from 1", n)
+		}
+		index = n - 1
+	} else {
+		byName = true
+	}
+
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", source, err)
+		}
+		line, _ := cr.FieldPos(0)
+		if byName {
+			for i, name := range record {
+				if strings.EqualFold(strings.TrimSpace(name), column) {
+					index = i
+				}
+			}
+			if index < 0 {
+				return fmt.Errorf("%s: no column named %q in header", source, column)
+			}
+			byName = false
+			continue
+		}
+		if index >= len(record) {
+			l.skip(source, line, strings.Join(record, ","), "missing column")
+			continue
+		}
+		l.Add(source, line, record[index])
+	}
+	return nil
+}
+
+// SkippedSummary describes the skipped lines in one line, such as
+// "3 lines skipped (2 duplicate, 1 invalid)", or returns "" if there are none.
+func (l *DomainList) SkippedSummary() string {
+	if len(l.Skipped) == 0 {
+		return ""
+	}
+	counts := make(map[string]int)
+	var reasons []string
+	for _, s := range l.Skipped {
+		if counts[s.Reason] == 0 {
+			reasons = append(reasons, s.Reason)
+		}
+		counts[s.Reason]++
+	}
+	parts := make([]string, len(reasons))
+	for i, reason := range reasons {
+		parts[i] = fmt.Sprintf("%d %s", counts[reason], reason)
+	}
+	noun := "lines"
+	if len(l.Skipped) == 1 {
+		noun = "line"
+	}
+	return fmt.Sprintf("%d %s skipped (%s)", len(l.Skipped), noun, strings.Join(parts, ", "))
+}
+
+// NormalizedInput is a lookup input after NormalizeInput.
+type NormalizedInput struct {
+	// Query is what providers are given: an ASCII domain name with any
+	// internationalized labels in Punycode, an IP address or an address
+	// range in the form ParseAddressRange accepts.
+	Query string
+	// Unicode is Query with Punycode labels decoded, for display. It equals
+	// Query for ASCII names and addresses.
+	Unicode string
+	Kind    InputKind
+}
+
+// NormalizeInput turns what a user typed or pasted into a lookup input. A
+// URL is stripped down to its host name, and a port or path after a host is
+// dropped. Unicode domain names are converted to Punycode. Anything that is
+// not an IP address or an address range of at most MaxRangeAddresses
+// addresses must be a valid host name: labels of letters, digits, hyphens
+// and underscores of at most 63 characters, and at most 253 characters in
+// all.
+func NormalizeInput(s string) (NormalizedInput, error) {
+	s = stripToHost(strings.TrimSpace(s))
+	if s == "" {
+		return NormalizedInput{}, errors.New("enter a domain name or IP address")
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		return NormalizedInput{Query: ip.String(), Unicode: ip.String(), Kind: DetectInputKind(s)}, nil
+	}
+	if r, err := ParseAddressRange(s); err == nil {
+		if err := r.CheckSize(MaxRangeAddresses); err != nil {
+			return NormalizedInput{}, err
+		}
+		return NormalizedInput{Query: r.String(), Unicode: r.String(), Kind: KindRange}, nil
+	}
+	ascii, err := ToASCII(s)
+	if err != nil {
+		return NormalizedInput{}, err
+	}
+	if err := validateName(ascii); err != nil {
+		return NormalizedInput{}, err
+	}
+	return NormalizedInput{Query: ascii, Unicode: ToUnicode(ascii), Kind: DetectInputKind(ascii)}, nil
+}
+
+// stripToHost returns the host of a URL, or of "host/path" or "host:port",
+// and s itself otherwise. CIDR blocks are left alone.
+func stripToHost(s string) string {
+	if strings.Contains(s, "://") {
+		if u, err := url.Parse(s); err == nil && u.Host != "" {
+			return u.Hostname()
+		}
+		return s
+	}
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		if _, _, err := net.ParseCIDR(s); err == nil {
+			return s
+		}
+		s = s[:i]
+	}
+	if host, port, err := net.SplitHostPort(s); err == nil {
+		if _, err := strconv.ParseUint(port, 10, 16); err == nil {
+			return host
+		}
+	}
+	return s
+}
+
+// validateName checks that name, in its ASCII form, is a valid host name.
+// Underscores are allowed for names such as _dmarc.example.com.
+func validateName(name string) error {
+	trimmed := strings.TrimSuffix(name, ".")
+	if trimmed == "" {
+		return fmt.Errorf("%q is not a domain name", name)
+	}
+	if len(trimmed) > 253 {
+		return fmt.Errorf("name is %d characters long, more than the 253 allowed", len(trimmed))
+	}
+	numeric := true
+	for _, label := range strings.Split(trimmed, ".") {
+		if label == "" {
+			return fmt.Errorf("empty label in %q", name)
+		}
+		if len(label) > 63 {
+			return fmt.Errorf("label %q is %d characters long, more than the 63 allowed", label, len(label))
+		}
+		for _, r := range label {
+			switch {
+			case r >= '0' && r <= '9':
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '-', r == '_':
+				numeric = false
+			default:
+				return fmt.Errorf("invalid character %q in %q", r, name)
+			}
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("label %q starts or ends with a hyphen", label)
+		}
+	}
+	if numeric {
+		return fmt.Errorf("%q is not a valid IP address", name)
+	}
+	return nil
+}
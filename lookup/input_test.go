@@ -0,0 +1,184 @@
+package lookup_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"dlookup/lookup"
+)
+
+func TestDomainList_ReadText(t *testing.T) {
+	input := `# hosts from inventory
+example.com
+example.org   # primary site
+
+EXAMPLE.com
+example.org.
+not a domain
+192.0.2.1
+2001:db8::1 # v6
+`
+	var list lookup.DomainList
+	if err := list.Read(strings.NewReader(input), "hosts.txt", lookup.InputOptions{}); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	wantDomains := []string{"example.com", "example.org", "192.0.2.1", "2001:db8::1"}
+	if !reflect.DeepEqual(list.Domains, wantDomains) {
+		t.Errorf("Domains = %q, want %q", list.Domains, wantDomains)
+	}
+	wantSkipped := []lookup.SkippedLine{
+		{Source: "hosts.txt", Line: 5, Text: "EXAMPLE.com", Reason: "duplicate"},
+		{Source: "hosts.txt", Line: 6, Text: "example.org.", Reason: "duplicate"},
+		{Source: "hosts.txt", Line: 7, Text: "not a domain", Reason: "invalid", Detail: `invalid character ' ' in "not a domain"`},
+	}
+	if !reflect.DeepEqual(list.Skipped, wantSkipped) {
+		t.Errorf("Skipped = %+v, want %+v", list.Skipped, wantSkipped)
+	}
+	if got, want := list.SkippedSummary(), "3 lines skipped (2 duplicate, 1 invalid)"; got != want {
+		t.Errorf("SkippedSummary() = %q, want %q", got, want)
+	}
+	if got, want := list.Skipped[2].String(), `hosts.txt:7: invalid "not a domain": invalid character ' ' in "not a domain"`; got != want {
+		t.Errorf("SkippedLine.String() = %q, want %q", got, want)
+	}
+}
+
+func TestDomainList_ReadCSV(t *testing.T) {
+	input := `id,Domain,owner
+1,example.com,alice
+# retired
+2,"example.net",bob
+3
+4,example.com,carol
+`
+	tests := []struct {
+		name        string
+		column      string
+		wantDomains []string
+		wantSkipped []lookup.SkippedLine
+		wantErr     string
+	}{
+		{
+			name:        "by header name",
+			column:      "domain",
+			wantDomains: []string{"example.com", "example.net"},
+			wantSkipped: []lookup.SkippedLine{
+				{Source: "in.csv", Line: 5, Text: "3", Reason: "missing column"},
+				{Source: "in.csv", Line: 6, Text: "example.com", Reason: "duplicate"},
+			},
+		},
+		{
+			name:        "by index",
+			column:      "3",
+			wantDomains: []string{"owner", "alice", "bob", "carol"},
+			wantSkipped: []lookup.SkippedLine{
+				{Source: "in.csv", Line: 5, Text: "3", Reason: "missing column"},
+			},
+		},
+		{name: "unknown header", column: "hostname", wantErr: `no column named "hostname"`},
+		{name: "zero index", column: "0", wantErr: "numbered from 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var list lookup.DomainList
+			err := list.Read(strings.NewReader(input), "in.csv", lookup.InputOptions{Column: tt.column})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Read() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if !reflect.DeepEqual(list.Domains, tt.wantDomains) {
+				t.Errorf("Domains = %q, want %q", list.Domains, tt.wantDomains)
+			}
+			if !reflect.DeepEqual(list.Skipped, tt.wantSkipped) {
+				t.Errorf("Skipped = %+v, want %+v", list.Skipped, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestDomainList_AddAcrossSources(t *testing.T) {
+	var list lookup.DomainList
+	list.Add("args", 1, "example.com")
+	if err := list.Read(strings.NewReader("example.org\nexample.com\n"), "-", lookup.InputOptions{}); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	list.Add("args", 2, "example.org")
+
+	if want := []string{"example.com", "example.org"}; !reflect.DeepEqual(list.Domains, want) {
+		t.Errorf("Domains = %q, want %q", list.Domains, want)
+	}
+	if got, want := list.SkippedSummary(), "2 lines skipped (2 duplicate)"; got != want {
+		t.Errorf("SkippedSummary() = %q, want %q", got, want)
+	}
+
+	var empty lookup.DomainList
+	if got := empty.SkippedSummary(); got != "" {
+		t.Errorf("SkippedSummary() on empty list = %q, want empty", got)
+	}
+}
+
+func TestNormalizeInput(t *testing.T) {
+	tests := []struct {
+		input   string
+		query   string
+		unicode string
+		kind    lookup.InputKind
+	}{
+		{"example.com", "example.com", "example.com", lookup.KindDomain},
+		{"  Example.COM. ", "Example.COM.", "Example.COM.", lookup.KindDomain},
+		{"https://foo.example.com/path?q=1", "foo.example.com", "foo.example.com", lookup.KindDomain},
+		{"http://user@foo.example.com:8080/", "foo.example.com", "foo.example.com", lookup.KindDomain},
+		{"foo.example.com/path", "foo.example.com", "foo.example.com", lookup.KindDomain},
+		{"foo.example.com:443", "foo.example.com", "foo.example.com", lookup.KindDomain},
+		{"_dmarc.example.com", "_dmarc.example.com", "_dmarc.example.com", lookup.KindDomain},
+		{"bücher.example", "xn--bcher-kva.example", "bücher.example", lookup.KindDomain},
+		{"https://Bücher.example/", "xn--bcher-kva.example", "bücher.example", lookup.KindDomain},
+		{"xn--bcher-kva.example", "xn--bcher-kva.example", "bücher.example", lookup.KindDomain},
+		{"例え。テスト", "xn--r8jz45g.xn--zckzah", "例え.テスト", lookup.KindDomain},
+		{"192.0.2.1", "192.0.2.1", "192.0.2.1", lookup.KindIPv4},
+		{"[2001:DB8::1]:53", "2001:db8::1", "2001:db8::1", lookup.KindIPv6},
+		{"http://[2001:db8::1]/", "2001:db8::1", "2001:db8::1", lookup.KindIPv6},
+		{"192.0.2.0/24", "192.0.2.0/24", "192.0.2.0/24", lookup.KindRange},
+		{"192.0.2.77/24", "192.0.2.0/24", "192.0.2.0/24", lookup.KindRange},
+		{"192.0.2.10 - 20", "192.0.2.10-20", "192.0.2.10-20", lookup.KindRange},
+		{"1.2.0.192.in-addr.arpa", "1.2.0.192.in-addr.arpa", "1.2.0.192.in-addr.arpa", lookup.KindReverse},
+	}
+	for _, tt := range tests {
+		got, err := lookup.NormalizeInput(tt.input)
+		if err != nil {
+			t.Errorf("NormalizeInput(%q) error = %v", tt.input, err)
+			continue
+		}
+		want := lookup.NormalizedInput{Query: tt.query, Unicode: tt.unicode, Kind: tt.kind}
+		if got != want {
+			t.Errorf("NormalizeInput(%q) = %+v, want %+v", tt.input, got, want)
+		}
+	}
+}
+
+func TestNormalizeInput_Errors(t *testing.T) {
+	tests := map[string]string{
+		"":                               "enter a domain",
+		"-x.example.com":                 "starts or ends with a hyphen",
+		"+trace":                         "invalid character '+'",
+		"exa mple.com":                   "invalid character ' '",
+		"a..example.com":                 "empty label",
+		strings.Repeat("a", 64) + ".com": "more than the 63 allowed",
+		strings.Repeat("abcdefghi.", 26): "more than the 253 allowed",
+		"300.1.1.1":                      "not a valid IP address",
+		"10.0.0.0/8":                     "16777216 addresses, more than the 1024 allowed",
+		"2001:db8::/64":                  "more than the 1024 allowed",
+	}
+	for input, want := range tests {
+		_, err := lookup.NormalizeInput(input)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("NormalizeInput(%q) error = %v, want containing %q", input, err, want)
+		}
+	}
+}
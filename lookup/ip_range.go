@@ -0,0 +1,353 @@
+package lookup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// MaxRangeAddresses caps how many addresses a CIDR block or address range
+// may expand to, so a mistyped /8 does not start millions of lookups.
+var MaxRangeAddresses = 1024
+
+// AddressRange is a block of consecutive IP addresses, entered as a CIDR
+// block such as 192.0.2.0/24 or as a range such as 192.0.2.10-20 or
+// 2001:db8::1-2001:db8::ff.
+type AddressRange struct {
+	First, Last net.IP
+	text        string
+}
+
+// ParseAddressRange parses a CIDR block, "first-last" with both ends full
+// addresses of the same family, or the IPv4 shorthand "a.b.c.d-e" where e
+// replaces the last octet.
+func ParseAddressRange(s string) (*AddressRange, error) {
+	s = strings.TrimSpace(s)
+	if ip, ipnet, err := net.ParseCIDR(s); err == nil {
+		first := ip.Mask(ipnet.Mask)
+		last := make(net.IP, len(first))
+		for i := range first {
+			last[i] = first[i] | ^ipnet.Mask[i]
+		}
+		return &AddressRange{First: first, Last: last, text: ipnet.String()}, nil
+	}
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return nil, fmt.Errorf("%q is not a CIDR block or address range", s)
+	}
+	first := net.ParseIP(strings.TrimSpace(from))
+	if first == nil {
+		return nil, fmt.Errorf("%q is not an IP address", from)
+	}
+	to = strings.TrimSpace(to)
+	var last net.IP
+	if first4 := first.To4(); first4 != nil {
+		first = first4
+		if n, err := strconv.ParseUint(to, 10, 8); err == nil {
+			last = append(net.IP{}, first...)
+			last[3] = byte(n)
+		} else if ip := net.ParseIP(to); ip != nil {
+			last = ip.To4()
+		}
+	} else if ip := net.ParseIP(to); ip != nil && ip.To4() == nil {
+		last = ip
+	}
+	if last == nil {
+		return nil, fmt.Errorf("%q is not the last address of the range from %s", to, first)
+	}
+	if bytes.Compare(first, last) > 0 {
+		return nil, fmt.Errorf("range %s-%s ends before it starts", first, last)
+	}
+	text := first.String() + "-" + last.String()
+	if len(first) == net.IPv4len && bytes.Equal(first[:3], last[:3]) {
+		text = fmt.Sprintf("%s-%d", first, last[3])
+	}
+	return &AddressRange{First: first, Last: last, text: text}, nil
+}
+
+// String returns the range in canonical form: a CIDR block with its host
+// bits cleared, or "first-last".
+func (r *AddressRange) String() string {
+	return r.text
+}
+
+// Size returns the number of addresses in the range, which for IPv6 may not
+// fit in an int.
+func (r *AddressRange) Size() *big.Int {
+	n := new(big.Int).Sub(new(big.Int).SetBytes(r.Last), new(big.Int).SetBytes(r.First))
+	return n.Add(n, big.NewInt(1))
+}
+
+// CheckSize returns an error if the range has more than limit addresses.
+func (r *AddressRange) CheckSize(limit int) error {
+	if size := r.Size(); size.Cmp(big.NewInt(int64(limit))) > 0 {
+		return fmt.Errorf("%s has %s addresses, more than the %d allowed (lookup.max_range_addresses in config.yaml)", r, size, limit)
+	}
+	return nil
+}
+
+// Addresses lists the addresses of the range in order, or returns an error
+// if there are more than limit.
+func (r *AddressRange) Addresses(limit int) ([]string, error) {
+	if err := r.CheckSize(limit); err != nil {
+		return nil, err
+	}
+	var addrs []string
+	for ip := append(net.IP{}, r.First...); ; {
+		addrs = append(addrs, ip.String())
+		if ip.Equal(r.Last) {
+			return addrs, nil
+		}
+		for i := len(ip) - 1; i >= 0; i-- {
+			ip[i]++
+			if ip[i] != 0 {
+				break
+			}
+		}
+	}
+}
+
+// RangeJob is a lookup of every address of a range, each scheduled as a
+// Job of its own.
+type RangeJob struct {
+	provider LookupProvider
+	rng      *AddressRange
+	ctx      context.Context
+	jobs     []*Job
+	start    time.Time
+}
+
+// ScheduleRange expands rng and schedules a lookup of each of its addresses
+// with provider, returning at once. It fails if rng is not a range or has
+// more than MaxRangeAddresses addresses.
+func (s *Scheduler) ScheduleRange(ctx context.Context, provider LookupProvider, rng string) (*RangeJob, error) {
+	r, err := ParseAddressRange(rng)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := r.Addresses(MaxRangeAddresses)
+	if err != nil {
+		return nil, err
+	}
+	j := &RangeJob{provider: provider, rng: r, ctx: ctx, start: time.Now()}
+	for _, addr := range addrs {
+		j.jobs = append(j.jobs, s.Schedule(ctx, provider, addr))
+	}
+	return j, nil
+}
+
+// Progress returns how many of the range's lookups have finished, and how
+// many there are.
+func (j *RangeJob) Progress() (done, total int) {
+	for _, job := range j.jobs {
+		select {
+		case <-job.done:
+			done++
+		default:
+		}
+	}
+	return done, len(j.jobs)
+}
+
+// Wait blocks until every lookup has finished and returns one Result for
+// the range: the per-address results in Results, in address order, and a
+// *RangeReport in Details. The error is set only if the lookups were
+// cancelled or all of them failed.
+func (j *RangeJob) Wait() (*Result, error) {
+	report := &RangeReport{Range: j.rng.String()}
+	result := &Result{Provider: j.provider.Name(), Query: j.rng.String(), Details: report, Server: ServerFromContext(j.ctx)}
+	failed := 0
+	for _, job := range j.jobs {
+		r, _ := job.Wait()
+		result.Results = append(result.Results, r)
+		row := newRangeRow(r)
+		if row.Status == RangeFailed {
+			failed++
+		}
+		report.Rows = append(report.Rows, row)
+	}
+	result.Duration = time.Since(j.start)
+	result.Stdout = strings.Join(report.Table(), "\n")
+	if err := j.ctx.Err(); err != nil {
+		result.Err = err
+	} else if failed == len(j.jobs) {
+		result.Err = fmt.Errorf("all %d lookups of %s failed", failed, j.rng)
+	}
+	return result, result.Err
+}
+
+// RangeStatus is the outcome of the lookup of one address of a range.
+type RangeStatus string
+
+const (
+	RangeAnswered RangeStatus = "ok"
+	RangeEmpty    RangeStatus = "empty"
+	RangeFailed   RangeStatus = "error"
+)
+
+// RangeRow is one address of a range lookup.
+type RangeRow struct {
+	Address    string        `json:"address"`
+	Status     RangeStatus   `json:"status"`
+	Answer     string        `json:"answer"`
+	Duration   time.Duration `json:"-"`
+	DurationMS int64         `json:"duration_ms"`
+}
+
+// RangeColumn is a column of a range lookup's table to sort by.
+type RangeColumn int
+
+const (
+	ByAddress RangeColumn = iota
+	ByAnswer
+	ByStatus
+	ByDuration
+)
+
+// RangeColumns lists the columns in table order.
+var RangeColumns = []RangeColumn{ByAddress, ByAnswer, ByStatus, ByDuration}
+
+func (c RangeColumn) String() string {
+	switch c {
+	case ByAnswer:
+		return "answer"
+	case ByStatus:
+		return "status"
+	case ByDuration:
+		return "time"
+	}
+	return "address"
+}
+
+// RangeReport is the Details of a range lookup: one row per address.
+type RangeReport struct {
+	Range string     `json:"range"`
+	Rows  []RangeRow `json:"rows"`
+	// SortedBy and Descending describe the current order of Rows.
+	SortedBy   RangeColumn `json:"-"`
+	Descending bool        `json:"-"`
+}
+
+// Sort orders the rows by column, breaking ties by address.
+func (r *RangeReport) Sort(column RangeColumn, descending bool) {
+	r.SortedBy, r.Descending = column, descending
+	sort.SliceStable(r.Rows, func(i, j int) bool {
+		a, b := r.Rows[i], r.Rows[j]
+		if descending {
+			a, b = b, a
+		}
+		switch column {
+		case ByAnswer:
+			if a.Answer != b.Answer {
+				return a.Answer < b.Answer
+			}
+		case ByStatus:
+			if a.Status != b.Status {
+				return a.Status < b.Status
+			}
+		case ByDuration:
+			if a.Duration != b.Duration {
+				return a.Duration < b.Duration
+			}
+		}
+		return compareAddresses(a.Address, b.Address) < 0
+	})
+}
+
+// compareAddresses orders addresses numerically, IPv4 before IPv6.
+func compareAddresses(a, b string) int {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	if (ipA.To4() == nil) != (ipB.To4() == nil) {
+		if ipA.To4() != nil {
+			return -1
+		}
+		return 1
+	}
+	return bytes.Compare(ipA.To16(), ipB.To16())
+}
+
+// Summary counts the addresses by outcome and tells how the table is
+// sorted.
+func (r *RangeReport) Summary() string {
+	counts := make(map[RangeStatus]int)
+	for _, row := range r.Rows {
+		counts[row.Status]++
+	}
+	order := "ascending"
+	if r.Descending {
+		order = "descending"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-14s %s (%d addresses)\n", "Range:", r.Range, len(r.Rows))
+	fmt.Fprintf(&b, "%-14s %d answered, %d empty, %d failed\n", "Results:", counts[RangeAnswered], counts[RangeEmpty], counts[RangeFailed])
+	fmt.Fprintf(&b, "%-14s %s, %s", "Sorted by:", r.SortedBy, order)
+	return b.String()
+}
+
+// Table renders a header line followed by one line per row, in the order
+// of Rows.
+func (r *RangeReport) Table() []string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ADDRESS\tANSWER\tSTATUS\tTIME")
+	for _, row := range r.Rows {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", row.Address, row.Answer, row.Status, row.Duration.Round(time.Millisecond))
+	}
+	w.Flush()
+	return strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+}
+
+// briefDetails is implemented by Details that can describe their result
+// in one line, for a row of a range lookup.
+type briefDetails interface {
+	Brief() string
+}
+
+// newRangeRow condenses the result of one address's lookup into a row: the
+// data of its records, the brief form of its Details, or else the first
+// line of its output that is not a comment. A lookup without records, or
+// whose output says it found none, is empty.
+func newRangeRow(r *Result) RangeRow {
+	row := RangeRow{Address: r.Query, Status: RangeAnswered, Duration: r.Duration, DurationMS: r.Duration.Milliseconds()}
+	if r.Err != nil {
+		row.Status, row.Answer = RangeFailed, r.Err.Error()
+		if errors.Is(r.Err, context.Canceled) {
+			row.Answer = "cancelled"
+		}
+		return row
+	}
+	if d, ok := r.Details.(briefDetails); ok {
+		row.Answer = d.Brief()
+	} else if len(r.Records) > 0 {
+		var data []string
+		for _, rr := range r.Records {
+			if rr.Data != nil {
+				data = append(data, rr.Data.String())
+			}
+		}
+		row.Answer = strings.Join(data, ", ")
+	} else {
+		for _, line := range strings.Split(r.Stdout, "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "%") && !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, ";") {
+				row.Answer = line
+				break
+			}
+		}
+	}
+	if row.Answer == "" {
+		row.Answer = "(no records)"
+	}
+	if strings.HasPrefix(row.Answer, "(No results found") || row.Answer == "(no records)" {
+		row.Status = RangeEmpty
+	}
+	return row
+}
@@ -0,0 +1,144 @@
+package lookup_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"dlookup/lookup"
+)
+
+func TestParseAddressRange(t *testing.T) {
+	tests := []struct {
+		input       string
+		text        string
+		first, last string
+		size        int64
+	}{
+		{"192.0.2.0/24", "192.0.2.0/24", "192.0.2.0", "192.0.2.255", 256},
+		{"192.0.2.77/30", "192.0.2.76/30", "192.0.2.76", "192.0.2.79", 4},
+		{"192.0.2.10-20", "192.0.2.10-20", "192.0.2.10", "192.0.2.20", 11},
+		{"192.0.2.250-192.0.3.5", "192.0.2.250-192.0.3.5", "192.0.2.250", "192.0.3.5", 12},
+		{"192.0.2.7-192.0.2.7", "192.0.2.7-7", "192.0.2.7", "192.0.2.7", 1},
+		{"2001:db8::/126", "2001:db8::/126", "2001:db8::", "2001:db8::3", 4},
+		{"2001:db8::fe-2001:db8::101", "2001:db8::fe-2001:db8::101", "2001:db8::fe", "2001:db8::101", 4},
+	}
+	for _, tt := range tests {
+		r, err := lookup.ParseAddressRange(tt.input)
+		if err != nil {
+			t.Errorf("ParseAddressRange(%q) error = %v", tt.input, err)
+			continue
+		}
+		if r.String() != tt.text || r.First.String() != tt.first || r.Last.String() != tt.last || r.Size().Int64() != tt.size {
+			t.Errorf("ParseAddressRange(%q) = %s (%s to %s, %s addresses), want %s (%s to %s, %d addresses)",
+				tt.input, r, r.First, r.Last, r.Size(), tt.text, tt.first, tt.last, tt.size)
+		}
+	}
+}
+
+func TestParseAddressRange_Errors(t *testing.T) {
+	for _, input := range []string{
+		"192.0.2.1",
+		"example.com",
+		"foo-bar.example",
+		"192.0.2.20-10",
+		"192.0.2.1-256",
+		"192.0.2.1-2001:db8::1",
+		"2001:db8::1-ff",
+	} {
+		if r, err := lookup.ParseAddressRange(input); err == nil {
+			t.Errorf("ParseAddressRange(%q) = %s, want error", input, r)
+		}
+	}
+}
+
+func TestAddressRange_Addresses(t *testing.T) {
+	r, _ := lookup.ParseAddressRange("192.0.2.254-192.0.3.1")
+	addrs, err := r.Addresses(4)
+	if err != nil {
+		t.Fatalf("Addresses() error = %v", err)
+	}
+	want := "192.0.2.254 192.0.2.255 192.0.3.0 192.0.3.1"
+	if got := strings.Join(addrs, " "); got != want {
+		t.Errorf("Addresses() = %s, want %s", got, want)
+	}
+	if _, err := r.Addresses(3); err == nil || !strings.Contains(err.Error(), "more than the 3 allowed") {
+		t.Errorf("Addresses(3) error = %v, want the cap exceeded", err)
+	}
+}
+
+func TestScheduleRange(t *testing.T) {
+	useFakeDNS(t, map[string]dnsHandler{
+		"192.0.2.1:53": func(q *lookup.Message, tcp bool) *lookup.Message {
+			resp := &lookup.Message{}
+			switch name := q.Question[0].Name; name {
+			case "1.2.0.192.in-addr.arpa.":
+				resp.Answer = append(resp.Answer, ptrRecord(name, "zeta.example.com."))
+			case "2.2.0.192.in-addr.arpa.":
+				resp.Answer = append(resp.Answer, ptrRecord(name, "alpha.example.com."))
+			case "3.2.0.192.in-addr.arpa.":
+				resp.Rcode = lookup.RcodeServerFailure
+			default:
+				resp.Rcode = lookup.RcodeNameError
+			}
+			return resp
+		},
+	})
+	provider, ok := lookup.GetProvider("DNS (PTR)")
+	if !ok {
+		t.Fatal("DNS (PTR) provider not registered")
+	}
+	job, err := lookup.NewScheduler(2, nil).ScheduleRange(context.Background(), provider, "192.0.2.0/30")
+	if err != nil {
+		t.Fatalf("ScheduleRange() error = %v", err)
+	}
+	result, err := job.Wait()
+	if err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if done, total := job.Progress(); done != 4 || total != 4 {
+		t.Errorf("Progress() = %d, %d, want 4, 4", done, total)
+	}
+	if result.Query != "192.0.2.0/30" || len(result.Results) != 4 {
+		t.Fatalf("result = %s with %d results, want 192.0.2.0/30 with 4", result.Query, len(result.Results))
+	}
+	report := result.Details.(*lookup.RangeReport)
+	rows := func() string {
+		var s []string
+		for _, row := range report.Rows {
+			s = append(s, row.Address+"="+string(row.Status))
+		}
+		return strings.Join(s, " ")
+	}
+	if got, want := rows(), "192.0.2.0=empty 192.0.2.1=ok 192.0.2.2=ok 192.0.2.3=error"; got != want {
+		t.Errorf("rows = %s, want %s", got, want)
+	}
+	if report.Rows[1].Answer != "zeta.example.com." {
+		t.Errorf("answer of 192.0.2.1 = %q, want zeta.example.com.", report.Rows[1].Answer)
+	}
+	if !strings.Contains(report.Summary(), "2 answered, 1 empty, 1 failed") {
+		t.Errorf("Summary() =\n%s", report.Summary())
+	}
+
+	report.Sort(lookup.ByAnswer, false)
+	if got := report.Rows[0].Address + " " + report.Rows[1].Address; got != "192.0.2.0 192.0.2.2" {
+		t.Errorf("sorted by answer, first rows = %s, want 192.0.2.0 (no records) then 192.0.2.2 (alpha)", got)
+	}
+	report.Sort(lookup.ByAddress, true)
+	if got, want := rows(), "192.0.2.3=error 192.0.2.2=ok 192.0.2.1=ok 192.0.2.0=empty"; got != want {
+		t.Errorf("sorted by address descending, rows = %s, want %s", got, want)
+	}
+	if lines := report.Table(); len(lines) != 5 || !strings.HasPrefix(lines[0], "ADDRESS") {
+		t.Errorf("Table() = %q", lines)
+	}
+}
+
+func TestScheduleRange_OverCap(t *testing.T) {
+	orig := lookup.MaxRangeAddresses
+	lookup.MaxRangeAddresses = 16
+	t.Cleanup(func() { lookup.MaxRangeAddresses = orig })
+	provider, _ := lookup.GetProvider("DNS (PTR)")
+	if _, err := lookup.NewScheduler(2, nil).ScheduleRange(context.Background(), provider, "192.0.2.0/24"); err == nil {
+		t.Error("ScheduleRange() of 256 addresses with a cap of 16 succeeded")
+	}
+}
@@ -0,0 +1,95 @@
+package lookup
+
+import (
+	"net"
+	"strings"
+)
+
+// InputKind is what a lookup input names: a domain, an IP address, the
+// reverse DNS name of one or a range of them.
+type InputKind int
+
+const (
+	KindDomain InputKind = iota
+	KindIPv4
+	KindIPv6
+	// KindReverse is a name under in-addr.arpa or ip6.arpa.
+	KindReverse
+	// KindRange is a CIDR block such as 192.0.2.0/24 or an address range
+	// such as 192.0.2.10-20; see ParseAddressRange.
+	KindRange
+)
+
+func (k InputKind) String() string {
+	switch k {
+	case KindIPv4:
+		return "IPv4 address"
+	case KindIPv6:
+		return "IPv6 address"
+	case KindReverse:
+		return "reverse DNS name"
+	case KindRange:
+		return "address range"
+	}
+	return "domain"
+}
+
+// IsIP reports whether k is an IPv4 or IPv6 address.
+func (k InputKind) IsIP() bool {
+	return k == KindIPv4 || k == KindIPv6
+}
+
+// DetectInputKind tells what s names. Anything that is not an IP address,
+// an address range or a reverse DNS name is taken to be a domain.
+func DetectInputKind(s string) InputKind {
+	s = strings.TrimSpace(s)
+	if ip := net.ParseIP(s); ip != nil {
+		if ip.To4() != nil {
+			return KindIPv4
+		}
+		return KindIPv6
+	}
+	if _, err := ParseAddressRange(s); err == nil {
+		return KindRange
+	}
+	name := strings.ToLower(Fqdn(s))
+	if strings.HasSuffix(name, ".in-addr.arpa.") || strings.HasSuffix(name, ".ip6.arpa.") {
+		return KindReverse
+	}
+	return KindDomain
+}
+
+// InputKindProvider is implemented by providers that take other input than
+// domain names, such as IP addresses.
+type InputKindProvider interface {
+	Accepts(kind InputKind) bool
+}
+
+// Accepts reports whether provider makes sense for input of the given kind.
+// Providers that do not implement InputKindProvider take domains only. A
+// range is looked up address by address (see Scheduler.ScheduleRange), so
+// it takes the providers that accept addresses, except reports and live
+// checks.
+func Accepts(provider LookupProvider, kind InputKind) bool {
+	if kind == KindRange {
+		return provider.Name() != ComprehensiveReportName && !isLive(provider) &&
+			(Accepts(provider, KindIPv4) || Accepts(provider, KindIPv6))
+	}
+	if p, ok := provider.(InputKindProvider); ok {
+		return p.Accepts(kind)
+	}
+	return kind == KindDomain
+}
+
+// acceptsRecordType reports whether a query for records of type t makes
+// sense for input of the given kind: PTR lookups are for addresses and their
+// reverse names, ANY for any name, the rest for domains.
+func acceptsRecordType(t RRType, kind InputKind) bool {
+	switch t {
+	case TypePTR:
+		return kind != KindDomain
+	case TypeANY:
+		return !kind.IsIP()
+	}
+	return kind == KindDomain
+}
@@ -0,0 +1,58 @@
+package lookup_test
+
+import (
+	"testing"
+
+	"dlookup/lookup"
+)
+
+func TestDetectInputKind(t *testing.T) {
+	tests := map[string]lookup.InputKind{
+		"example.com":                   lookup.KindDomain,
+		"192.0.2.1":                     lookup.KindIPv4,
+		" 2001:db8::1 ":                 lookup.KindIPv6,
+		"1.2.0.192.in-addr.arpa":        lookup.KindReverse,
+		"1.0.8.b.d.0.1.0.0.2.ip6.arpa.": lookup.KindReverse,
+		"192.0.2.0/24":                  lookup.KindRange,
+		"192.0.2.10-20":                 lookup.KindRange,
+		"2001:db8::1-2001:db8::ff":      lookup.KindRange,
+		"foo-bar.example":               lookup.KindDomain,
+	}
+	for input, want := range tests {
+		if got := lookup.DetectInputKind(input); got != want {
+			t.Errorf("DetectInputKind(%q) = %s, want %s", input, got, want)
+		}
+	}
+}
+
+func TestAccepts(t *testing.T) {
+	tests := []struct {
+		provider string
+		kind     lookup.InputKind
+		want     bool
+	}{
+		{"DIG (A)", lookup.KindDomain, true},
+		{"DIG (A)", lookup.KindIPv4, false},
+		{"DIG (PTR)", lookup.KindIPv6, true},
+		{"DNS (PTR)", lookup.KindDomain, false},
+		{"DNS (PTR)", lookup.KindReverse, true},
+		{"WHOIS", lookup.KindIPv4, true},
+		{"FCRDNS", lookup.KindIPv4, true},
+		{"FCRDNS", lookup.KindDomain, false},
+		{"PROPAGATION (A)", lookup.KindIPv4, false},
+		{lookup.ComprehensiveReportName, lookup.KindIPv6, true},
+		{"DIG (PTR)", lookup.KindRange, true},
+		{"FCRDNS", lookup.KindRange, true},
+		{"DIG (A)", lookup.KindRange, false},
+		{lookup.ComprehensiveReportName, lookup.KindRange, false},
+	}
+	for _, tt := range tests {
+		provider, ok := lookup.GetProvider(tt.provider)
+		if !ok {
+			t.Fatalf("provider %q not registered", tt.provider)
+		}
+		if got := lookup.Accepts(provider, tt.kind); got != tt.want {
+			t.Errorf("Accepts(%s, %s) = %v, want %v", tt.provider, tt.kind, got, tt.want)
+		}
+	}
+}
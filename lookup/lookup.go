@@ -2,15 +2,21 @@ package lookup
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"os/exec"
 	"strings"
 	"sync"
+	"time"
 )
 
 type LookupProvider interface {
 	Name() string
-	Execute(domain string) (output string, err error)
+	// Execute runs the lookup. Implementations must stop and return promptly
+	// once ctx is done. The result may be non-nil alongside an error to carry
+	// partial output such as stderr.
+	Execute(ctx context.Context, domain string) (*Result, error)
 	CheckAvailability() bool
 	FlagName() string
 	Usage() string
@@ -24,6 +30,19 @@ var (
 	initialCmdCheck   sync.Once
 )
 
+// LiveProvider is implemented by providers whose results are only useful
+// fresh, such as propagation checks. Their results are never cached, and
+// the comprehensive report leaves them out.
+type LiveProvider interface {
+	Live() bool
+}
+
+// isLive reports whether provider is a LiveProvider that asks to be live.
+func isLive(provider LookupProvider) bool {
+	live, ok := provider.(LiveProvider)
+	return ok && live.Live()
+}
+
 func RegisterProvider(provider LookupProvider) {
 	registryMutex.Lock()
 	defer registryMutex.Unlock()
@@ -62,6 +81,36 @@ func GetProviderByFlagName(flagName string) (LookupProvider, bool) {
 	return nil, false
 }
 
+// ProvidersForTypes resolves a comma-separated list of lookup types, such as
+// "a,mx,whois", to providers. Each entry is a provider flag name, or a record
+// type for the native DNS resolver ("mx" selects "dns-mx"). Duplicates are
+// dropped and the order is kept.
+func ProvidersForTypes(types string) ([]LookupProvider, error) {
+	var providers []LookupProvider
+	seen := make(map[string]bool)
+	for _, t := range strings.Split(types, ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" {
+			continue
+		}
+		provider, found := GetProviderByFlagName(t)
+		if !found {
+			provider, found = GetProviderByFlagName("dns-" + t)
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown lookup type %q", t)
+		}
+		if !seen[provider.Name()] {
+			seen[provider.Name()] = true
+			providers = append(providers, provider)
+		}
+	}
+	if len(providers) == 0 {
+		return nil, errors.New("no lookup types given")
+	}
+	return providers, nil
+}
+
 func AvailableProviders() []LookupProvider {
 
 	checkAllCommandsOnce()
@@ -131,53 +180,158 @@ func checkAllCommandsOnce() {
 }
 
 // osRunCommandInternal is the actual implementation that executes a command.
-// It returns the raw output (potentially combined stdout/stderr) and raw error.
-func osRunCommandInternal(cmdName string, args ...string) (string, error) {
-	cmd := exec.Command(cmdName, args...)
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	err := cmd.Run() // This is the raw error from the command execution
-
-	output := strings.TrimSpace(stdout.String())
-	errMsg := strings.TrimSpace(stderr.String())
-
-	// Combine stdout and stderr into a single output string for simplicity if needed by caller
-	// Or, could return them separately if RunCommand is made to handle it.
-	// For now, stick to current combined behavior if errMsg is present.
-	finalOutput := output
-	if errMsg != "" {
-		if output != "" {
-			finalOutput = fmt.Sprintf("STDOUT:\n%s\nSTDERR:\n%s", output, errMsg) // Adjusted format slightly for clarity
-		} else {
-			finalOutput = fmt.Sprintf("STDERR:\n%s", errMsg)
-		}
-	}
-	return finalOutput, err // Return raw output and raw error
+// It returns the trimmed stdout and stderr and the raw error.
+// The process is killed when ctx is done.
+func osRunCommandInternal(ctx context.Context, cmdName string, args ...string) (stdout, stderr string, err error) {
+	cmd := exec.CommandContext(ctx, cmdName, args...)
+	var outBuf, errBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+	err = cmd.Run() // This is the raw error from the command execution
+	return strings.TrimSpace(outBuf.String()), strings.TrimSpace(errBuf.String()), err
 }
 
 // OsRunCommand is a variable that holds the function to execute commands.
 // Tests can replace this with a mock implementation. It should adhere to returning raw output and raw error.
 var OsRunCommand = osRunCommandInternal
 
-// RunCommand executes a command using the function assigned to OsRunCommand,
-// then applies transformations (error wrapping, "No results found").
-func RunCommand(cmdName string, args ...string) (string, error) {
-	rawOutput, rawErr := OsRunCommand(cmdName, args...)
+// RunCommand executes a command using the function assigned to OsRunCommand
+// and records the command line, output and exit code in a Result.
+func RunCommand(ctx context.Context, cmdName string, args ...string) (*Result, error) {
+	// dig and nslookup runs against a chosen server share its bucket with the
+	// native clients; otherwise the command picks its server itself, so all
+	// its runs share one. whois never talks to the DNS server.
+	key := cmdName
+	if server := ServerFromContext(ctx); server != "" && (cmdName == "dig" || cmdName == "nslookup") {
+		key = hostPort(server, "53")
+	}
+	if err := waitServer(ctx, key); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	stdout, stderr, rawErr := OsRunCommand(ctx, cmdName, args...)
+	result := &Result{
+		Stdout:   stdout,
+		Stderr:   stderr,
+		ExitCode: exitCode(rawErr),
+		Duration: time.Since(start),
+		Command:  commandLine(cmdName, args),
+	}
 
 	if rawErr != nil {
-		// Error wrapping logic, potentially including rawOutput if it's stderr
-		// Based on previous osRunCommandInternal, rawOutput might already contain stderr.
-		// Let's assume rawOutput is the string to return alongside the error.
-		detail := fmt.Errorf("command '%s %s' failed: %w", cmdName, strings.Join(args, " "), rawErr)
-		// If rawOutput contains actual stderr info (as per osRunCommandInternal's old logic),
-		// it might need to be appended to 'detail' or handled.
-		// For now, the tests set rawOutput to "error output" in failure cases.
-		return rawOutput, detail
+		// A killed process reports "signal: killed"; say why it was killed.
+		if ctx.Err() != nil {
+			rawErr = ctx.Err()
+		}
+		return result, fmt.Errorf("command '%s %s' failed: %w", cmdName, strings.Join(args, " "), rawErr)
+	}
+	return result, nil
+}
+
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
 	}
+	return -1
+}
+
+// commandLine renders a command the way it would be typed into a shell.
+func commandLine(cmdName string, args []string) string {
+	parts := make([]string, 0, len(args)+1)
+	for _, a := range append([]string{cmdName}, args...) {
+		if a == "" || strings.ContainsAny(a, " \t\n'\"\\$`;&|<>()*?[]#~") {
+			a = "'" + strings.ReplaceAll(a, "'", `'\''`) + "'"
+		}
+		parts = append(parts, a)
+	}
+	return strings.Join(parts, " ")
+}
+
+// DefaultTimeout bounds a lookup whose provider has no timeout of its own.
+var DefaultTimeout = 30 * time.Second
+
+var (
+	providerTimeouts      = make(map[string]time.Duration)
+	providerTimeoutsMutex sync.RWMutex
+)
+
+// SetProviderTimeout sets the timeout used by RunLookup for the provider with
+// the given flag name. A zero duration restores DefaultTimeout.
+func SetProviderTimeout(flagName string, d time.Duration) {
+	providerTimeoutsMutex.Lock()
+	defer providerTimeoutsMutex.Unlock()
+	if d <= 0 {
+		delete(providerTimeouts, flagName)
+		return
+	}
+	providerTimeouts[flagName] = d
+}
+
+// ProviderTimeout returns the timeout RunLookup applies to provider.
+func ProviderTimeout(provider LookupProvider) time.Duration {
+	providerTimeoutsMutex.RLock()
+	defer providerTimeoutsMutex.RUnlock()
+	if d, ok := providerTimeouts[provider.FlagName()]; ok {
+		return d
+	}
+	return DefaultTimeout
+}
 
-	if rawOutput == "" {
-		return "(No results found)", nil
+// RunLookup executes provider with its configured timeout applied to ctx,
+// retrying transient failures as its Policy allows. The timeout covers all
+// attempts. Results are served from and stored in DefaultCache unless ctx
+// comes from WithRefresh. Callers should use it instead of calling Execute
+// directly. The returned Result is never nil; its Provider, Query, Duration,
+// Attempts and Err are filled in.
+func RunLookup(ctx context.Context, provider LookupProvider, domain string) (*Result, error) {
+	if cached, ok := cachedResult(ctx, provider, domain); ok {
+		return cached, nil
+	}
+	if d := ProviderTimeout(provider); d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	policy := ProviderPolicy(provider)
+	stats := &attemptStats{policy: policy}
+	ctx = context.WithValue(ctx, attemptStatsKey{}, stats)
+
+	start := time.Now()
+	var result *Result
+	var err error
+	var retries []string
+	attempts := 0
+	for {
+		attempts++
+		result, err = provider.Execute(ctx, domain)
+		reason, transient := Transient(result, err)
+		if !transient || attempts >= policy.MaxAttempts || ctx.Err() != nil {
+			break
+		}
+		retries = append(retries, reason)
+		if sleepCtx(ctx, policy.Backoff(attempts)) != nil {
+			break
+		}
+	}
+	if result == nil {
+		result = &Result{}
+	}
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("%s timed out after %s: %w", provider.Name(), ProviderTimeout(provider), err)
 	}
-	return rawOutput, nil
+	result.Provider = provider.Name()
+	result.Query = domain
+	result.Duration = time.Since(start)
+	result.Attempts = attempts
+	result.Retries = retries
+	stats.mu.Lock()
+	result.Throttled = stats.throttled
+	stats.mu.Unlock()
+	result.Err = err
+	DefaultCache.Put(provider, ServerFromContext(ctx), domain, result)
+	return result, err
 }
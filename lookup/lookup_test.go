@@ -1,9 +1,15 @@
 package lookup_test
 
 import (
+	"context"
 	"dlookup/lookup" // Assuming dlookup is the module name
+	"errors"
 	"fmt"
+	"os/exec"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 // mockProvider is a simple implementation of lookup.LookupProvider for testing.
@@ -14,11 +20,21 @@ type mockProvider struct {
 	available bool
 }
 
-func (m *mockProvider) Name() string                                  { return m.name }
-func (m *mockProvider) Execute(domain string) (string, error)         { return fmt.Sprintf("executed %s for %s", m.name, domain), nil }
-func (m *mockProvider) CheckAvailability() bool                       { return m.available }
-func (m *mockProvider) FlagName() string                              { return m.flagName }
-func (m *mockProvider) Usage() string                                 { return m.usage }
+func (m *mockProvider) Name() string { return m.name }
+func (m *mockProvider) Execute(ctx context.Context, domain string) (*lookup.Result, error) {
+	return &lookup.Result{Stdout: fmt.Sprintf("executed %s for %s", m.name, domain)}, nil
+}
+func (m *mockProvider) CheckAvailability() bool { return m.available }
+func (m *mockProvider) FlagName() string        { return m.flagName }
+func (m *mockProvider) Usage() string           { return m.usage }
+
+// outputOf returns the displayed output of a provider result, or "" if there is none.
+func outputOf(r *lookup.Result) string {
+	if r == nil {
+		return ""
+	}
+	return r.Output()
+}
 
 func assertPanics(t *testing.T, fn func(), expectedPanicValue interface{}) {
 	t.Helper()
@@ -115,6 +131,41 @@ func TestProviderRegistrationAndRetrieval(t *testing.T) {
 	// Similar to GetProvider, no explicit error object is returned.
 }
 
+func TestProvidersForTypes(t *testing.T) {
+	tests := []struct {
+		types   string
+		want    []string
+		wantErr string
+	}{
+		{types: "a,mx,txt", want: []string{"DNS (A)", "DNS (MX)", "DNS (TXT)"}},
+		{types: " MX , whois-native,dig-dig-a", want: []string{"DNS (MX)", "WHOIS (NATIVE)", "DIG (A)"}},
+		{types: "a,dns-a,A", want: []string{"DNS (A)"}},
+		{types: "a,bogus", wantErr: `unknown lookup type "bogus"`},
+		{types: " , ", wantErr: "no lookup types given"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.types, func(t *testing.T) {
+			providers, err := lookup.ProvidersForTypes(tt.types)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ProvidersForTypes(%q) error = %v, want containing %q", tt.types, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ProvidersForTypes(%q) error = %v", tt.types, err)
+			}
+			var got []string
+			for _, p := range providers {
+				got = append(got, p.Name())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ProvidersForTypes(%q) = %q, want %q", tt.types, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestProviderAvailability(t *testing.T) {
 	// This test indirectly checks the command availability logic by using a mock provider
 	// whose CheckAvailability() method can be controlled.
@@ -160,3 +211,84 @@ func TestProviderAvailability(t *testing.T) {
 	// set it back to a known state if necessary, though for this test, it's the last action.
 	mockP.available = true // Reset for potential other tests if any depended on this exact provider instance
 }
+
+// blockingProvider never finishes on its own; it only returns when its
+// context is done.
+type blockingProvider struct {
+	mockProvider
+}
+
+func (b *blockingProvider) Execute(ctx context.Context, domain string) (*lookup.Result, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestRunLookup_AppliesProviderTimeout(t *testing.T) {
+	p := &blockingProvider{mockProvider{name: "timeout-test-provider", flagName: "timeout-test-flag", available: true}}
+	lookup.SetProviderTimeout("timeout-test-flag", 50*time.Millisecond)
+	defer lookup.SetProviderTimeout("timeout-test-flag", 0)
+
+	if got := lookup.ProviderTimeout(p); got != 50*time.Millisecond {
+		t.Errorf("ProviderTimeout() = %v, want 50ms", got)
+	}
+
+	start := time.Now()
+	_, err := lookup.RunLookup(context.Background(), p, "example.com")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("RunLookup() error = %v, want context.DeadlineExceeded", err)
+	}
+	if !strings.Contains(err.Error(), "timed out after 50ms") {
+		t.Errorf("RunLookup() error = %q, want timeout explanation", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("RunLookup() returned after %v, want about 50ms", elapsed)
+	}
+
+	lookup.SetProviderTimeout("timeout-test-flag", 0)
+	if got := lookup.ProviderTimeout(p); got != lookup.DefaultTimeout {
+		t.Errorf("ProviderTimeout() after reset = %v, want DefaultTimeout", got)
+	}
+}
+
+func TestRunLookup_Cancel(t *testing.T) {
+	p := &blockingProvider{mockProvider{name: "cancel-test-provider", flagName: "cancel-test-flag", available: true}}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	if _, err := lookup.RunLookup(ctx, p, "example.com"); !errors.Is(err, context.Canceled) {
+		t.Errorf("RunLookup() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestRunCommand_ReportsContextError(t *testing.T) {
+	origRunCommand := lookup.OsRunCommand
+	defer func() { lookup.OsRunCommand = origRunCommand }()
+	lookup.OsRunCommand = func(ctx context.Context, cmdName string, args ...string) (string, string, error) {
+		return "", "", errors.New("signal: killed")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := lookup.RunCommand(ctx, "whois", "example.com")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("RunCommand() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestOsRunCommand_KillsProcessWhenContextDone(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep command not available")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, _, err := lookup.OsRunCommand(ctx, "sleep", "5"); err == nil {
+		t.Errorf("OsRunCommand() error = nil, want error for killed process")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("OsRunCommand() returned after %v, want the process killed at the deadline", elapsed)
+	}
+}
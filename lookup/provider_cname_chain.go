@@ -0,0 +1,205 @@
+package lookup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// CNAMEMaxDepth is how many aliases a chain may pass through before the
+// check gives up on it. Resolvers stop following chains at about this
+// length, and every hop costs a lookup.
+var CNAMEMaxDepth = 8
+
+// Outcomes of a CNAME chain check.
+const (
+	CNAMEResolved    = "resolved"
+	CNAMENoAddresses = "no addresses"
+	CNAMENXDomain    = "NXDOMAIN"
+	CNAMEDangling    = "dangling"
+	CNAMELoop        = "loop"
+	CNAMETooDeep     = "too deep"
+)
+
+// CNAMEChainProvider follows a name's CNAME records one hop at a time to the
+// A and AAAA records at the end, unlike `DIG (CNAME)`, which only shows the
+// first hop.
+type CNAMEChainProvider struct{}
+
+func (p *CNAMEChainProvider) Name() string {
+	return "CNAME CHAIN"
+}
+
+func (p *CNAMEChainProvider) FlagName() string {
+	return "cname-chain"
+}
+
+func (p *CNAMEChainProvider) Usage() string {
+	return fmt.Sprintf("Run %s (every alias down to the addresses) on domains from <filename>", p.Name())
+}
+
+func (p *CNAMEChainProvider) CheckAvailability() bool {
+	return true
+}
+
+// Accepts reports that the check takes domains only.
+func (p *CNAMEChainProvider) Accepts(kind InputKind) bool {
+	return kind == KindDomain
+}
+
+// CNAMEChainReport is the Details of a CNAME chain check.
+type CNAMEChainReport struct {
+	Name string     `json:"name"`
+	Hops []CNAMEHop `json:"hops"`
+	// Target is the name at the end of the chain, Name itself if it is not
+	// an alias.
+	Target    string `json:"target"`
+	Addresses []RR   `json:"addresses,omitempty"`
+	Status    string `json:"status"`
+	// Problem explains a Status other than CNAMEResolved.
+	Problem string `json:"problem,omitempty"`
+}
+
+// CNAMEHop is one alias of a chain.
+type CNAMEHop struct {
+	Name   string `json:"name"`
+	Target string `json:"target"`
+	TTL    uint32 `json:"ttl"`
+}
+
+// OK reports whether the chain ends in addresses.
+func (r *CNAMEChainReport) OK() bool {
+	return r.Status == CNAMEResolved
+}
+
+// Summary shows the length of the chain, where it ends and how.
+func (r *CNAMEChainReport) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-14s %s\n", "Query:", r.Name)
+	fmt.Fprintf(&b, "%-14s %d\n", "Hops:", len(r.Hops))
+	fmt.Fprintf(&b, "%-14s %s\n", "Target:", r.Target)
+	if r.OK() {
+		var addrs []string
+		for _, rr := range r.Addresses {
+			addrs = append(addrs, rr.Data.String())
+		}
+		fmt.Fprintf(&b, "%-14s %s\n", "Addresses:", strings.Join(addrs, ", "))
+		fmt.Fprintf(&b, "%-14s %s", "Status:", r.Status)
+	} else {
+		fmt.Fprintf(&b, "%-14s %s: %s", "Status:", r.Status, r.Problem)
+	}
+	return b.String()
+}
+
+// Chain renders the chain one hop per line, each indented below the one
+// that points to it, with the addresses at the end. When the chain does not
+// end in addresses, the last line explains why and starts with "✗".
+func (r *CNAMEChainReport) Chain() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", r.Name)
+	depth := 1
+	for _, hop := range r.Hops {
+		fmt.Fprintf(&b, "%s→ CNAME %s  (TTL %d)\n", strings.Repeat("  ", depth), hop.Target, hop.TTL)
+		depth++
+	}
+	indent := strings.Repeat("  ", depth)
+	for _, rr := range r.Addresses {
+		fmt.Fprintf(&b, "%s%s %s  (TTL %d)\n", indent, rr.Type, rr.Data, rr.TTL)
+	}
+	if !r.OK() {
+		fmt.Fprintf(&b, "✗ %s: %s\n", r.Status, r.Problem)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Execute asks for the CNAME record of the domain, then of each target in
+// turn, until a name is not an alias; then asks for its A and AAAA records.
+func (p *CNAMEChainProvider) Execute(ctx context.Context, domain string) (*Result, error) {
+	chosen := ServerFromContext(ctx)
+	r := resolverFor(chosen)
+	report := &CNAMEChainReport{Name: Fqdn(domain)}
+	result := &Result{Details: report, Server: chosen}
+
+	query := func(name string, t RRType) (*Message, error) {
+		resp, server, err := r.Query(ctx, name, t)
+		if err != nil {
+			return nil, fmt.Errorf("%s query for %s failed: %w", t, name, err)
+		}
+		if resp.Rcode != RcodeSuccess && resp.Rcode != RcodeNameError {
+			return nil, &RcodeError{Name: name, Server: server, Rcode: resp.Rcode}
+		}
+		return resp, nil
+	}
+
+	name := report.Name
+	seen := map[string]bool{strings.ToLower(name): true}
+	for report.Status == "" {
+		resp, err := query(name, TypeCNAME)
+		if err != nil {
+			return result, err
+		}
+		if resp.Rcode == RcodeNameError {
+			report.Status = CNAMENXDomain
+			if len(report.Hops) > 0 {
+				report.Status = CNAMEDangling
+				report.Problem = fmt.Sprintf("%s points at %s, which does not exist", report.Hops[len(report.Hops)-1].Name, name)
+			} else {
+				report.Problem = fmt.Sprintf("%s does not exist", name)
+			}
+			break
+		}
+		var hop *CNAMEHop
+		for _, rr := range resp.Answer {
+			if cname, ok := rr.Data.(*CNAMERecord); ok && strings.EqualFold(rr.Name, name) {
+				hop = &CNAMEHop{Name: name, Target: Fqdn(cname.Target), TTL: rr.TTL}
+				result.Records = append(result.Records, rr)
+				break
+			}
+		}
+		if hop == nil {
+			break
+		}
+		report.Hops = append(report.Hops, *hop)
+		name = hop.Target
+		switch {
+		case seen[strings.ToLower(name)]:
+			var names []string
+			for _, h := range report.Hops {
+				names = append(names, h.Name)
+			}
+			report.Status = CNAMELoop
+			report.Problem = strings.Join(append(names, name), " → ")
+		case len(report.Hops) >= CNAMEMaxDepth:
+			report.Status = CNAMETooDeep
+			report.Problem = fmt.Sprintf("gave up after %d aliases; resolvers may give up sooner", CNAMEMaxDepth)
+		}
+		seen[strings.ToLower(name)] = true
+	}
+	report.Target = name
+
+	if report.Status == "" {
+		for _, t := range []RRType{TypeA, TypeAAAA} {
+			resp, err := query(name, t)
+			if err != nil {
+				return result, err
+			}
+			for _, rr := range resp.Answer {
+				if rr.Type == t && strings.EqualFold(rr.Name, name) {
+					report.Addresses = append(report.Addresses, rr)
+				}
+			}
+		}
+		result.Records = append(result.Records, report.Addresses...)
+		report.Status = CNAMEResolved
+		if len(report.Addresses) == 0 {
+			report.Status = CNAMENoAddresses
+			report.Problem = fmt.Sprintf("%s has no A or AAAA records", name)
+		}
+	}
+	result.Stdout = report.Chain()
+	return result, nil
+}
+
+func init() {
+	RegisterProvider(&CNAMEChainProvider{})
+}
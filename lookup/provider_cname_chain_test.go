@@ -0,0 +1,143 @@
+package lookup_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"dlookup/lookup"
+)
+
+// aliasZone answers CNAME queries from aliases and A and AAAA queries from
+// addrs, keyed by fully qualified name. Names in neither do not exist.
+func aliasZone(aliases map[string]string, addrs map[string]string) dnsHandler {
+	return func(q *lookup.Message, tcp bool) *lookup.Message {
+		qq := q.Question[0]
+		resp := &lookup.Message{}
+		if target, ok := aliases[qq.Name]; ok {
+			if qq.Type == lookup.TypeCNAME {
+				resp.Answer = []lookup.RR{{Name: qq.Name, Type: lookup.TypeCNAME, Class: lookup.ClassINET, TTL: 600, Data: &lookup.CNAMERecord{Target: target}}}
+			}
+			return resp
+		}
+		ip, ok := addrs[qq.Name]
+		if !ok {
+			resp.Rcode = lookup.RcodeNameError
+			return resp
+		}
+		switch {
+		case qq.Type == lookup.TypeA && net.ParseIP(ip).To4() != nil:
+			resp.Answer = []lookup.RR{aRecord(qq.Name, ip, 60)}
+		case qq.Type == lookup.TypeAAAA && net.ParseIP(ip).To4() == nil && ip != "":
+			resp.Answer = []lookup.RR{{Name: qq.Name, Type: lookup.TypeAAAA, Class: lookup.ClassINET, TTL: 60, Data: &lookup.AAAARecord{IP: net.ParseIP(ip)}}}
+		}
+		return resp
+	}
+}
+
+func followChain(t *testing.T, domain string) (*lookup.Result, *lookup.CNAMEChainReport) {
+	t.Helper()
+	provider, ok := lookup.GetProviderByFlagName("cname-chain")
+	if !ok {
+		t.Fatal("cname-chain provider not registered")
+	}
+	result, err := provider.Execute(context.Background(), domain)
+	if err != nil {
+		t.Fatalf("Execute(%s) error = %v", domain, err)
+	}
+	return result, result.Details.(*lookup.CNAMEChainReport)
+}
+
+func TestCNAMEChainProvider(t *testing.T) {
+	aliases := map[string]string{
+		"www.example.com.":   "shop.example.net.",
+		"shop.example.net.":  "edge.cdn.test.",
+		"loop1.example.com.": "loop2.example.com.",
+		"loop2.example.com.": "loop1.example.com.",
+		"old.example.com.":   "gone.saas.test.",
+		"empty.example.com.": "nodata.saas.test.",
+		"deep0.example.com.": "deep1.example.com.",
+	}
+	for i := 1; i <= lookup.CNAMEMaxDepth; i++ {
+		aliases[fmt.Sprintf("deep%d.example.com.", i)] = fmt.Sprintf("deep%d.example.com.", i+1)
+	}
+	useFakeDNS(t, map[string]dnsHandler{"192.0.2.1:53": aliasZone(aliases, map[string]string{
+		"edge.cdn.test.":      "192.0.2.80",
+		"direct.example.com.": "192.0.2.81",
+		"nodata.saas.test.":   "",
+	})})
+
+	result, report := followChain(t, "www.example.com")
+	if !report.OK() || len(report.Hops) != 2 || report.Target != "edge.cdn.test." || len(report.Addresses) != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	want := "www.example.com.\n  → CNAME shop.example.net.  (TTL 600)\n    → CNAME edge.cdn.test.  (TTL 600)\n      A 192.0.2.80  (TTL 60)"
+	if result.Stdout != want {
+		t.Errorf("Stdout =\n%s\nwant\n%s", result.Stdout, want)
+	}
+	if len(result.Records) != 3 {
+		t.Errorf("Records = %v, want both CNAMEs and the address", result.Records)
+	}
+	if !strings.Contains(report.Summary(), "Addresses:     192.0.2.80") {
+		t.Errorf("Summary() =\n%s", report.Summary())
+	}
+
+	if _, report := followChain(t, "direct.example.com"); !report.OK() || len(report.Hops) != 0 || report.Target != "direct.example.com." {
+		t.Errorf("direct: report = %+v, want the addresses of the name itself", report)
+	}
+
+	tests := []struct {
+		domain, status, problem string
+	}{
+		{"loop1.example.com", lookup.CNAMELoop, "loop1.example.com. → loop2.example.com. → loop1.example.com."},
+		{"old.example.com", lookup.CNAMEDangling, "old.example.com. points at gone.saas.test., which does not exist"},
+		{"empty.example.com", lookup.CNAMENoAddresses, "nodata.saas.test. has no A or AAAA records"},
+		{"missing.example.com", lookup.CNAMENXDomain, "missing.example.com. does not exist"},
+		{"deep0.example.com", lookup.CNAMETooDeep, fmt.Sprintf("gave up after %d aliases", lookup.CNAMEMaxDepth)},
+	}
+	for _, tt := range tests {
+		result, report := followChain(t, tt.domain)
+		if report.Status != tt.status || !strings.HasPrefix(report.Problem, tt.problem) {
+			t.Errorf("%s: status %q, problem %q; want %q, %q", tt.domain, report.Status, report.Problem, tt.status, tt.problem)
+		}
+		if !strings.HasSuffix(result.Stdout, "\n✗ "+report.Status+": "+report.Problem) {
+			t.Errorf("%s: Stdout =\n%s\nwant the problem last", tt.domain, result.Stdout)
+		}
+	}
+}
+
+func TestCNAMEChainProvider_MaxDepth(t *testing.T) {
+	// limitN passes through CNAMEMaxDepth aliases, nearN through one fewer.
+	aliases := map[string]string{}
+	for i := 0; i < lookup.CNAMEMaxDepth; i++ {
+		aliases[fmt.Sprintf("limit%d.example.com.", i)] = fmt.Sprintf("limit%d.example.com.", i+1)
+		aliases[fmt.Sprintf("near%d.example.com.", i)] = fmt.Sprintf("near%d.example.com.", i+1)
+	}
+	aliases[fmt.Sprintf("limit%d.example.com.", lookup.CNAMEMaxDepth-1)] = "edge.cdn.test."
+	aliases[fmt.Sprintf("near%d.example.com.", lookup.CNAMEMaxDepth-2)] = "edge.cdn.test."
+	useFakeDNS(t, map[string]dnsHandler{"192.0.2.1:53": aliasZone(aliases, map[string]string{
+		"edge.cdn.test.": "192.0.2.80",
+	})})
+
+	if _, report := followChain(t, "near0.example.com"); !report.OK() || len(report.Hops) != lookup.CNAMEMaxDepth-1 {
+		t.Errorf("near0: status %q after %d aliases, want OK after %d", report.Status, len(report.Hops), lookup.CNAMEMaxDepth-1)
+	}
+	_, report := followChain(t, "limit0.example.com")
+	if report.Status != lookup.CNAMETooDeep || len(report.Hops) != lookup.CNAMEMaxDepth {
+		t.Errorf("limit0: status %q after %d aliases, want %q after %d", report.Status, len(report.Hops), lookup.CNAMETooDeep, lookup.CNAMEMaxDepth)
+	}
+}
+
+func TestCNAMEChainProvider_ServerFailure(t *testing.T) {
+	useFakeDNS(t, map[string]dnsHandler{
+		"192.0.2.1:53": func(q *lookup.Message, tcp bool) *lookup.Message {
+			return &lookup.Message{Header: lookup.Header{Rcode: lookup.RcodeServerFailure}}
+		},
+	})
+	provider, _ := lookup.GetProviderByFlagName("cname-chain")
+	if _, err := provider.Execute(context.Background(), "www.example.com"); err == nil || !strings.Contains(err.Error(), "SERVFAIL") {
+		t.Errorf("Execute() error = %v, want SERVFAIL", err)
+	}
+}
@@ -1,6 +1,7 @@
 package lookup
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strings"
@@ -26,45 +27,96 @@ func (p *ComprehensiveProvider) CheckAvailability() bool {
 	return true
 }
 
-func (p *ComprehensiveProvider) Execute(domain string) (string, error) {
-	results := make(map[string]string)
-	providers := AvailableProviders() // Assuming AvailableProviders() is a function in the lookup package
+// Accepts reports that a report can be run on any input; it only includes
+// the providers that accept it.
+func (p *ComprehensiveProvider) Accepts(kind InputKind) bool {
+	return true
+}
 
-	for _, provider := range providers {
-		if provider.Name() == ComprehensiveReportName {
-			continue // Skip self
-		}
-		if !provider.CheckAvailability() {
-			// Optionally, decide if you want to report unavailable providers
-			// results[provider.Name()] = "Error: Provider not available"
+// Execute schedules every other available provider that accepts domain on
+// the scheduler the report runs on, so the lookups keep to its limits, and
+// collects their results, in report order, in Result.Results. Live checks
+// are left out. A `DNS (...)` provider runs only when the matching
+// `DIG (...)` provider is unavailable, and `WHOIS` only when
+// `WHOIS (NATIVE)` is unavailable.
+func (p *ComprehensiveProvider) Execute(ctx context.Context, domain string) (*Result, error) {
+	s := schedulerFromContext(ctx)
+	jobs := make(map[string]*Job)
+	kind := DetectInputKind(domain)
+	for _, provider := range AvailableProviders() {
+		if !inReport(provider) || !provider.CheckAvailability() || !Accepts(provider, kind) {
 			continue
 		}
-
-		output, err := provider.Execute(domain)
-		if err != nil {
-			results[provider.Name()] = fmt.Sprintf("Error: %v\nOutput:\n%s", err, output)
-		} else {
-			results[provider.Name()] = output
-		}
+		jobs[provider.Name()] = s.Schedule(ctx, provider, domain)
+	}
+	results := make(map[string]*Result, len(jobs))
+	for name, job := range jobs {
+		results[name], _ = job.Wait()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
 	order := GetComprehensiveReportOrder()
-	return FormatComprehensiveReport(domain, results, order), nil
+	report := &Result{Stdout: FormatComprehensiveReport(domain, results, order), Server: ServerFromContext(ctx)}
+	for _, name := range reportOrder(results, order) {
+		report.Results = append(report.Results, results[name])
+	}
+	return report, nil
 }
 
-func FormatComprehensiveReport(domain string, results map[string]string, order []string) string {
+// inReport reports whether the comprehensive report runs provider at all,
+// leaving out the report itself, live checks and the second provider of a
+// pair that would send the same queries.
+func inReport(provider LookupProvider) bool {
+	if provider.Name() == ComprehensiveReportName || isLive(provider) {
+		return false
+	}
+	switch p := provider.(type) {
+	case *NativeDNSProvider:
+		return !available(fmt.Sprintf("DIG (%s)", p.qtype))
+	case *WhoisProvider:
+		return !available("WHOIS (NATIVE)")
+	}
+	return true
+}
+
+// available reports whether the provider called name is registered and
+// available.
+func available(name string) bool {
+	provider, ok := GetProvider(name)
+	return ok && provider.CheckAvailability()
+}
+
+// FormatComprehensiveReport renders results as one section per provider,
+// following order and then the remaining providers alphabetically.
+func FormatComprehensiveReport(domain string, results map[string]*Result, order []string) string {
 	var b strings.Builder
 	b.WriteString(fmt.Sprintf("Comprehensive Report for: %s\n", domain))
 	b.WriteString(strings.Repeat("=", 40+len(domain)))
 	b.WriteString("\n")
 
-	processed := make(map[string]bool)
+	for _, name := range reportOrder(results, order) {
+		heading := name
+		if results[name].Cached {
+			heading += " (cached)"
+		}
+		b.WriteString(fmt.Sprintf("\n--- %s ---\n", heading))
+		b.WriteString(strings.TrimSpace(results[name].Text()))
+		b.WriteString("\n")
+	}
 
+	return b.String()
+}
+
+// reportOrder lists the providers in results: those named in order first,
+// then the rest sorted by name.
+func reportOrder(results map[string]*Result, order []string) []string {
+	names := make([]string, 0, len(results))
+	processed := make(map[string]bool)
 	for _, name := range order {
-		if result, ok := results[name]; ok {
-			b.WriteString(fmt.Sprintf("\n--- %s ---\n", name))
-			b.WriteString(strings.TrimSpace(result))
-			b.WriteString("\n")
+		if _, ok := results[name]; ok && !processed[name] {
+			names = append(names, name)
 			processed[name] = true
 		}
 	}
@@ -76,20 +128,16 @@ func FormatComprehensiveReport(domain string, results map[string]string, order [
 		}
 	}
 	sort.Strings(remainingNames)
-	for _, name := range remainingNames {
-		result := results[name]
-		b.WriteString(fmt.Sprintf("\n--- %s ---\n", name))
-		b.WriteString(strings.TrimSpace(result))
-		b.WriteString("\n")
-	}
-
-	return b.String()
+	return append(names, remainingNames...)
 }
 
 func GetComprehensiveReportOrder() []string {
 	return []string{
 		"NSLOOKUP", "DIG (A)", "DIG (AAAA)", "DIG (MX)", "DIG (CNAME)",
-		"DIG (TXT)", "DIG (SOA)", "DIG (ANY)", "WHOIS",
+		"DIG (TXT)", "DIG (SOA)", "DIG (NS)", "DIG (CAA)", "DIG (HTTPS)",
+		"DIG (SVCB)", "DIG (SRV)", "DIG (NAPTR)", "DIG (TLSA)", "DIG (SSHFP)",
+		"DIG (DS)", "DIG (DNSKEY)", "DIG (PTR)", "DIG (ANY)", "EMAIL",
+		"WHOIS (NATIVE)", "WHOIS",
 	}
 }
 
@@ -1,8 +1,12 @@
 package lookup_test
 
 import (
+	"context"
 	"dlookup/lookup"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"reflect" // For DeepEqual
 	"strings"
 	"testing"
@@ -11,7 +15,10 @@ import (
 func TestGetComprehensiveReportOrder(t *testing.T) {
 	expectedOrder := []string{
 		"NSLOOKUP", "DIG (A)", "DIG (AAAA)", "DIG (MX)", "DIG (CNAME)",
-		"DIG (TXT)", "DIG (SOA)", "DIG (ANY)", "WHOIS",
+		"DIG (TXT)", "DIG (SOA)", "DIG (NS)", "DIG (CAA)", "DIG (HTTPS)",
+		"DIG (SVCB)", "DIG (SRV)", "DIG (NAPTR)", "DIG (TLSA)", "DIG (SSHFP)",
+		"DIG (DS)", "DIG (DNSKEY)", "DIG (PTR)", "DIG (ANY)", "EMAIL",
+		"WHOIS (NATIVE)", "WHOIS",
 	}
 	actualOrder := lookup.GetComprehensiveReportOrder()
 	if !reflect.DeepEqual(actualOrder, expectedOrder) {
@@ -23,9 +30,9 @@ func TestFormatComprehensiveReport(t *testing.T) {
 	domain := "example.com"
 
 	t.Run("BasicFormatting", func(t *testing.T) {
-		results := map[string]string{
-			"DIG (A)": "1.2.3.4",
-			"WHOIS":   "Whois data for example.com",
+		results := map[string]*lookup.Result{
+			"DIG (A)": {Stdout: "1.2.3.4"},
+			"WHOIS":   {Stdout: "Whois data for example.com"},
 		}
 		providerOrder := []string{"DIG (A)", "WHOIS"}
 		output := lookup.FormatComprehensiveReport(domain, results, providerOrder)
@@ -54,7 +61,7 @@ func TestFormatComprehensiveReport(t *testing.T) {
 	})
 
 	t.Run("EmptyResults", func(t *testing.T) {
-		results := make(map[string]string)
+		results := make(map[string]*lookup.Result)
 		providerOrder := []string{"DIG (A)", "WHOIS"}
 		output := lookup.FormatComprehensiveReport(domain, results, providerOrder)
 
@@ -77,10 +84,10 @@ func TestFormatComprehensiveReport(t *testing.T) {
 	})
 
 	t.Run("Ordering", func(t *testing.T) {
-		results := map[string]string{
-			"ProviderA": "Result A",
-			"ProviderB": "Result B",
-			"ProviderC": "Result C",
+		results := map[string]*lookup.Result{
+			"ProviderA": {Stdout: "Result A"},
+			"ProviderB": {Stdout: "Result B"},
+			"ProviderC": {Stdout: "Result C"},
 		}
 		providerOrder := []string{"ProviderC", "ProviderA", "ProviderB"}
 		output := lookup.FormatComprehensiveReport(domain, results, providerOrder)
@@ -98,10 +105,10 @@ func TestFormatComprehensiveReport(t *testing.T) {
 	})
 
 	t.Run("ProviderMismatch", func(t *testing.T) {
-		results := map[string]string{
-			"InOrderAndResults":    "Data 1", // In order, in results
-			"InResultsOnly":        "Data 2", // In results, not in order
-			"InResultsOnlySortedB": "Data B", // Another one for sorting
+		results := map[string]*lookup.Result{
+			"InOrderAndResults":    {Stdout: "Data 1"}, // In order, in results
+			"InResultsOnly":        {Stdout: "Data 2"}, // In results, not in order
+			"InResultsOnlySortedB": {Stdout: "Data B"}, // Another one for sorting
 		}
 		providerOrder := []string{"InOrderAndResults", "InOrderOnly"} // In order, not in results
 		output := lookup.FormatComprehensiveReport(domain, results, providerOrder)
@@ -145,6 +152,79 @@ func TestFormatComprehensiveReport(t *testing.T) {
 	})
 }
 
+// disableNetwork keeps the report's built-in clients off the network: the
+// WHOIS and RDAP lookups and the checks that query with the native resolver
+// (EMAIL, DNSSEC, CNAME CHAIN, FCRDNS).
+func disableNetwork(t *testing.T) {
+	t.Helper()
+	errDisabled := errors.New("network disabled in tests")
+
+	origExchange := lookup.DNSExchange
+	lookup.DNSExchange = func(ctx context.Context, server string, q *lookup.Message) (*lookup.Message, error) {
+		return nil, errDisabled
+	}
+	origWhoisClient := lookup.DefaultWhoisClient
+	lookup.DefaultWhoisClient = &lookup.WhoisClient{
+		Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, errDisabled
+		},
+	}
+	origRDAPClient := lookup.DefaultRDAPClient
+	lookup.DefaultRDAPClient = &lookup.RDAPClient{
+		BootstrapURL: "https://rdap.invalid/",
+		HTTPClient: &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+			return nil, errDisabled
+		})},
+	}
+
+	t.Cleanup(func() {
+		lookup.DNSExchange = origExchange
+		lookup.DefaultWhoisClient = origWhoisClient
+		lookup.DefaultRDAPClient = origRDAPClient
+	})
+}
+
+// TestComprehensiveReportProvider_NativeDNSFallback checks that the report
+// runs DIG (A) when dig is installed and DNS (A) only when it is not.
+func TestComprehensiveReportProvider_NativeDNSFallback(t *testing.T) {
+	report, _ := lookup.GetProvider(lookup.ComprehensiveReportName)
+	disableNetwork(t)
+	origRunCommand := lookup.OsRunCommand
+	origCheckCommandFunc := lookup.LookupCheckCommandFunc
+	t.Cleanup(func() {
+		lookup.OsRunCommand = origRunCommand
+		lookup.LookupCheckCommandFunc = origCheckCommandFunc
+	})
+	lookup.OsRunCommand = func(ctx context.Context, cmdName string, args ...string) (string, string, error) {
+		return "", "", nil
+	}
+
+	for _, tt := range []struct {
+		dig        bool
+		want, skip string
+	}{
+		{dig: true, want: "DIG (A)", skip: "DNS (A)"},
+		{dig: false, want: "DNS (A)", skip: "DIG (A)"},
+	} {
+		lookup.LookupCheckCommandFunc = func(cmd string) bool { return tt.dig && cmd == "dig" }
+		result, err := report.Execute(context.Background(), "example.com")
+		if err != nil {
+			t.Fatalf("dig installed %v: Execute() error = %v", tt.dig, err)
+		}
+		names := make(map[string]bool)
+		for _, child := range result.Results {
+			names[child.Provider] = true
+		}
+		if !names[tt.want] || names[tt.skip] {
+			t.Errorf("dig installed %v: report ran %v, want %s and not %s", tt.dig, names, tt.want, tt.skip)
+		}
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
 // simpleMockProvider is a mock implementation of lookup.LookupProvider for testing comprehensive reports.
 type simpleMockProvider struct {
 	name              string
@@ -155,12 +235,13 @@ type simpleMockProvider struct {
 }
 
 func (m *simpleMockProvider) Name() string { return m.name }
-func (m *simpleMockProvider) Execute(domain string) (string, error) {
+func (m *simpleMockProvider) Execute(ctx context.Context, domain string) (*lookup.Result, error) {
 	if m.executeFunc != nil {
-		return m.executeFunc(domain)
+		output, err := m.executeFunc(domain)
+		return &lookup.Result{Stdout: output}, err
 	}
 	// Default behavior if executeFunc is not set
-	return fmt.Sprintf("Default mock output for %s on domain %s", m.name, domain), nil
+	return &lookup.Result{Stdout: fmt.Sprintf("Default mock output for %s on domain %s", m.name, domain)}, nil
 }
 func (m *simpleMockProvider) CheckAvailability() bool { return m.checkAvailability }
 func (m *simpleMockProvider) FlagName() string        { return m.flagName } // Not strictly needed by ComprehensiveReportProvider
@@ -173,6 +254,8 @@ func TestComprehensiveReportProvider_Execute(t *testing.T) {
 		t.Fatalf("ComprehensiveReportProvider with name %q not found.", lookup.ComprehensiveReportName)
 	}
 
+	disableNetwork(t)
+
 	// Note: Providers registered here are added to the global registry and will persist
 	// for the duration of the test suite. Use unique names to avoid conflicts.
 
@@ -221,15 +304,16 @@ func TestComprehensiveReportProvider_Execute(t *testing.T) {
 
 	domainToTest := "example.com"
 
-	// The comprehensiveProvider.Execute() will use the GetComprehensiveReportOrder()
+	// Execute will use the GetComprehensiveReportOrder()
 	// and then append any other available providers not in that order.
 	// Our mock providers will fall into the "appended" category.
 
 	t.Run("SuccessfulAndPartialFailureAggregation", func(t *testing.T) {
-		output, err := comprehensiveProvider.Execute(domainToTest)
+		result, err := comprehensiveProvider.Execute(context.Background(), domainToTest)
 		if err != nil {
 			t.Fatalf("ComprehensiveProvider.Execute() returned an unexpected error: %v", err)
 		}
+		output := result.Stdout
 
 		// Check for domain header
 		if !strings.Contains(output, fmt.Sprintf("Comprehensive Report for: %s", domainToTest)) {
@@ -279,6 +363,30 @@ func TestComprehensiveReportProvider_Execute(t *testing.T) {
 		if !(idxAnotherSuccess < idxFailure && idxFailure < idxSuccess) {
 			t.Errorf("Ad-hoc providers not in expected alphabetical order. AnotherSuccess: %d, Failure: %d, Success: %d. Output:\n%s", idxAnotherSuccess, idxFailure, idxSuccess, output)
 		}
+
+		// The per-provider results are kept alongside the rendered report.
+		children := make(map[string]*lookup.Result)
+		for _, child := range result.Results {
+			children[child.Provider] = child
+		}
+		if child := children["CompTestMock-Success"]; child == nil || child.Query != domainToTest || child.Err != nil {
+			t.Errorf("Results missing successful child result for CompTestMock-Success: %+v", child)
+		}
+		if child := children["CompTestMock-Failure"]; child == nil || child.Err == nil || child.Stdout != "Failure output from CompTestMock-Failure" {
+			t.Errorf("Results missing failed child result for CompTestMock-Failure: %+v", child)
+		}
+		if _, ok := children["CompTestMock-Unavailable"]; ok {
+			t.Errorf("Results unexpectedly contain the unavailable provider")
+		}
+		if _, ok := children["PROPAGATION (A)"]; ok {
+			t.Errorf("Results unexpectedly contain a live propagation check")
+		}
+		if _, ok := children["WHOIS (NATIVE)"]; !ok {
+			t.Errorf("Results missing the native WHOIS lookup")
+		}
+		if _, ok := children["WHOIS"]; ok {
+			t.Errorf("Results unexpectedly contain a second WHOIS lookup")
+		}
 	})
 
 	t.Run("AllMockProvidersFail", func(t *testing.T) {
@@ -309,10 +417,11 @@ func TestComprehensiveReportProvider_Execute(t *testing.T) {
 		lookup.RegisterProvider(failingMock1)
 		lookup.RegisterProvider(failingMock2)
 
-		output, err := comprehensiveProvider.Execute(domainToTest)
+		result, err := comprehensiveProvider.Execute(context.Background(), domainToTest)
 		if err != nil {
 			t.Fatalf("ComprehensiveProvider.Execute() returned an unexpected error: %v", err)
 		}
+		output := result.Stdout
 
 		expectedError1Str := "Error: FailingMock1 error\nOutput:\nOutput from FailingMock1"
 		if !strings.Contains(output, "--- CompTestFailingMock1 ---") || !strings.Contains(output, expectedError1Str) {
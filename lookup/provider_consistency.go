@@ -0,0 +1,540 @@
+package lookup
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// ConsistencyTypes are the record types the name server consistency check
+// compares across a zone's servers, besides the SOA serial and the NS set.
+var ConsistencyTypes = []RRType{TypeA, TypeAAAA, TypeMX}
+
+// Statuses of a server in a consistency check.
+const (
+	ConsistencyOK          = "ok"
+	ConsistencyLame        = "lame"
+	ConsistencyUnreachable = "unreachable"
+	ConsistencyNoAddress   = "no address"
+)
+
+// ConsistencyProvider finds every name server of a zone, from both the
+// parent's delegation and the zone's own NS records, and asks each one
+// directly for the SOA serial, the NS set and the records of
+// ConsistencyTypes, to show which servers are out of step.
+type ConsistencyProvider struct{}
+
+func (p *ConsistencyProvider) Name() string {
+	return "NS CONSISTENCY"
+}
+
+func (p *ConsistencyProvider) FlagName() string {
+	return "ns-consistency"
+}
+
+func (p *ConsistencyProvider) Usage() string {
+	return fmt.Sprintf("Run %s (compare every authoritative server) on domains from <filename>", p.Name())
+}
+
+func (p *ConsistencyProvider) CheckAvailability() bool {
+	return true
+}
+
+// Accepts reports that the check takes domains and reverse DNS names.
+func (p *ConsistencyProvider) Accepts(kind InputKind) bool {
+	return kind == KindDomain || kind == KindReverse
+}
+
+// Live reports that consistency checks are never cached or run as part of a
+// report: like propagation checks, they are repeated to watch servers catch
+// up.
+func (p *ConsistencyProvider) Live() bool {
+	return true
+}
+
+// ConsistencyReport is the Details of a name server consistency check.
+type ConsistencyReport struct {
+	Name string `json:"name"`
+	Zone string `json:"zone"`
+	// Columns are the queries compared, in table order: "NS" for the zone's
+	// NS set, then the types of ConsistencyTypes.
+	Columns []string `json:"columns"`
+	// ParentNS is the delegation in the parent zone; nil if the parent
+	// could not be asked. ChildNS is the NS set most of the zone's servers
+	// answer with.
+	ParentNS []string `json:"parent_ns"`
+	ChildNS  []string `json:"child_ns"`
+	// Serial is the newest SOA serial any server answered with, in serial
+	// number arithmetic.
+	Serial  uint32              `json:"serial"`
+	Servers []ConsistencyServer `json:"servers"`
+	// Problems lists what differs or failed, one sentence each.
+	Problems []string `json:"problems,omitempty"`
+}
+
+// ConsistencyServer is what one name server answered.
+type ConsistencyServer struct {
+	NameServer
+	// InParent and InChild say whether the parent's delegation and the
+	// zone's NS set list the server.
+	InParent bool `json:"in_parent"`
+	InChild  bool `json:"in_child"`
+	// Glue holds the addresses the parent supplied for the server.
+	Glue      []string            `json:"glue,omitempty"`
+	Status    string              `json:"status"`
+	Error     string              `json:"error,omitempty"`
+	Serial    uint32              `json:"serial"`
+	Answers   []ConsistencyAnswer `json:"answers,omitempty"`
+	Latency   time.Duration       `json:"-"`
+	LatencyMS int64               `json:"latency_ms"`
+}
+
+// ConsistencyAnswer is a server's answer to one of the report's columns.
+type ConsistencyAnswer struct {
+	Type   string   `json:"type"`
+	Answer []string `json:"answer"`
+	Error  string   `json:"error,omitempty"`
+	// Differs is set when the answer is not the one most servers gave.
+	Differs bool `json:"differs"`
+}
+
+// Consistent reports whether s answered, is listed by both the parent and
+// the zone, and agrees with the other servers in every column.
+func (r *ConsistencyReport) Consistent(s *ConsistencyServer) bool {
+	if s.Status != ConsistencyOK || s.Serial != r.Serial || !s.InChild || (!s.InParent && r.ParentNS != nil) {
+		return false
+	}
+	for _, a := range s.Answers {
+		if a.Differs {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *ConsistencyServer) label() string {
+	if s.Addr == "" {
+		return strings.TrimSuffix(s.Host, ".")
+	}
+	return s.NameServer.String()
+}
+
+// Summary names the zone, how many servers answered, the serials they
+// answered with, and whether they agree.
+func (r *ConsistencyReport) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-14s %s\n", "Query:", r.Name)
+	fmt.Fprintf(&b, "%-14s %s\n", "Zone:", r.Zone)
+	answered := 0
+	serials := make(map[uint32]int)
+	var order []uint32
+	for _, s := range r.Servers {
+		if s.Status != ConsistencyOK {
+			continue
+		}
+		answered++
+		if serials[s.Serial]++; serials[s.Serial] == 1 {
+			order = append(order, s.Serial)
+		}
+	}
+	fmt.Fprintf(&b, "%-14s %d (%d answered)\n", "Servers:", len(r.Servers), answered)
+	switch {
+	case len(order) == 1:
+		fmt.Fprintf(&b, "%-14s %d\n", "Serial:", r.Serial)
+	case len(order) > 1:
+		sort.Slice(order, func(i, j int) bool { return serialNewer(order[i], order[j]) })
+		var parts []string
+		for _, serial := range order {
+			parts = append(parts, fmt.Sprintf("%d on %d", serial, serials[serial]))
+		}
+		fmt.Fprintf(&b, "%-14s %s\n", "Serials:", strings.Join(parts, ", "))
+	}
+	switch n := len(r.Problems); n {
+	case 0:
+		fmt.Fprintf(&b, "%-14s %s", "Status:", "consistent")
+	case 1:
+		fmt.Fprintf(&b, "%-14s %s", "Status:", "1 problem")
+	default:
+		fmt.Fprintf(&b, "%-14s %d problems", "Status:", n)
+	}
+	return b.String()
+}
+
+// Table renders the servers as a header line followed by one line per
+// entry of Servers, in the same order. The first column marks servers that
+// agree with the others "✓" and the rest "✗"; cells that differ from what
+// most servers answered end in "*".
+func (r *ConsistencyReport) Table() []string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "\tSERVER\tLISTED BY\tSTATUS\tSERIAL\t%s\tLATENCY\n", strings.Join(r.Columns, "\t"))
+	for _, s := range r.Servers {
+		mark := "✓"
+		if !r.Consistent(&s) {
+			mark = "✗"
+		}
+		var listed []string
+		if s.InParent {
+			listed = append(listed, "parent")
+		}
+		if s.InChild {
+			listed = append(listed, "zone")
+		}
+		if len(listed) == 0 {
+			listed = []string{"some servers"}
+		}
+		cells := make([]string, len(r.Columns))
+		serial, latency := "-", "-"
+		if s.Status == ConsistencyOK {
+			serial = fmt.Sprint(s.Serial)
+			if s.Serial != r.Serial {
+				serial += " *"
+			}
+			latency = s.Latency.Round(time.Millisecond).String()
+		}
+		for i := range cells {
+			cells[i] = "-"
+			if i >= len(s.Answers) {
+				continue
+			}
+			a := s.Answers[i]
+			cells[i] = formatPropagationAnswer(a.Answer)
+			if a.Error != "" {
+				cells[i] = "error"
+			}
+			if a.Differs {
+				cells[i] += " *"
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", mark, s.label(), strings.Join(listed, ", "), s.Status, serial, strings.Join(cells, "\t"), latency)
+	}
+	w.Flush()
+	return strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+}
+
+// Listing renders the table followed by the problems, each marked "✗".
+func (r *ConsistencyReport) Listing() string {
+	text := strings.Join(r.Table(), "\n")
+	if len(r.Problems) > 0 {
+		text += "\n\nProblems:"
+		for _, p := range r.Problems {
+			text += "\n✗ " + p
+		}
+	}
+	return text
+}
+
+// Execute finds the zone of domain and its servers, asks the parent zone
+// for the delegation, then queries every server at once.
+func (p *ConsistencyProvider) Execute(ctx context.Context, domain string) (*Result, error) {
+	name := Fqdn(domain)
+	chosen := ServerFromContext(ctx)
+	r := resolverFor(chosen)
+	zone, found, err := findNameServers(ctx, r, name)
+	if zone == "" {
+		return nil, err
+	}
+	report := &ConsistencyReport{Name: name, Zone: zone, Columns: []string{"NS"}}
+	for _, t := range ConsistencyTypes {
+		if t == TypeNS || t == TypeSOA {
+			continue
+		}
+		report.Columns = append(report.Columns, t.String())
+	}
+
+	delegation, parentErr := parentDelegation(ctx, r, zone)
+	if parentErr != nil {
+		report.Problems = append(report.Problems, fmt.Sprintf("the delegation of %s could not be fetched from the parent zone: %v", zone, parentErr))
+	}
+	// The parent's servers come first, in the parent's order, then those
+	// only the zone lists.
+	addrs := make(map[string]string)
+	for _, ns := range found {
+		addrs[strings.ToLower(ns.Host)] = ns.Addr
+	}
+	var servers []*ConsistencyServer
+	add := func(host string) (s *ConsistencyServer, added bool) {
+		for _, s := range servers {
+			if strings.EqualFold(s.Host, Fqdn(host)) {
+				return s, false
+			}
+		}
+		s = &ConsistencyServer{NameServer: NameServer{Host: Fqdn(host)}}
+		servers = append(servers, s)
+		return s, true
+	}
+	if delegation != nil {
+		report.ParentNS = []string{}
+		for _, host := range delegation.NameServers {
+			s, _ := add(host)
+			s.InParent = true
+			s.Glue = glueFor(delegation.Glue, host)
+			report.ParentNS = append(report.ParentNS, strings.TrimSuffix(host, "."))
+		}
+	}
+	// Until the servers answer, the resolver's NS set stands for the
+	// zone's.
+	for _, ns := range found {
+		s, _ := add(ns.Host)
+		s.InChild = true
+	}
+
+	p.query(ctx, r, servers, addrs, report)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// Servers that only some servers' NS sets list are checked too.
+	var extra []*ConsistencyServer
+	for _, s := range servers {
+		if len(s.Answers) == 0 || s.Answers[0].Error != "" {
+			continue
+		}
+		for _, host := range s.Answers[0].Answer {
+			if s, added := add(host); added {
+				extra = append(extra, s)
+			}
+		}
+	}
+	p.query(ctx, r, extra, addrs, report)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	answered := 0
+	for _, s := range servers {
+		report.Servers = append(report.Servers, *s)
+		if s.Status == ConsistencyOK {
+			answered++
+		}
+	}
+	report.compare()
+	result := &Result{Stdout: report.Listing(), Details: report, Server: chosen}
+	if answered == 0 {
+		return result, fmt.Errorf("no name server of %s answered", zone)
+	}
+	return result, nil
+}
+
+// query finds the addresses of servers that have none yet and checks them
+// all at once.
+func (p *ConsistencyProvider) query(ctx context.Context, r *Resolver, servers []*ConsistencyServer, addrs map[string]string, report *ConsistencyReport) {
+	var wg sync.WaitGroup
+	for _, s := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Glue comes first: it is the address resolvers use.
+			switch {
+			case len(s.Glue) > 0:
+				s.Addr = s.Glue[0]
+			case addrs[strings.ToLower(s.Host)] != "":
+				s.Addr = addrs[strings.ToLower(s.Host)]
+			default:
+				s.Addr = lookupAddr(ctx, r, s.Host)
+			}
+			if s.Addr == "" {
+				s.Status = ConsistencyNoAddress
+				return
+			}
+			checkServer(ctx, s, report)
+		}()
+	}
+	wg.Wait()
+}
+
+// checkServer asks s for the SOA of the zone, then for each column of the
+// report.
+func checkServer(ctx context.Context, s *ConsistencyServer, report *ConsistencyReport) {
+	start := time.Now()
+	resp, err := queryAuthoritative(ctx, s.Addr, report.Zone, TypeSOA)
+	s.Latency = time.Since(start)
+	s.LatencyMS = s.Latency.Milliseconds()
+	if err != nil {
+		// A server that responds without serving the zone is lame; one
+		// that does not respond at all is unreachable.
+		s.Status = ConsistencyLame
+		if resp == nil {
+			s.Status = ConsistencyUnreachable
+		}
+		s.Error = err.Error()
+		return
+	}
+	found := false
+	for _, rr := range resp.Answer {
+		if soa, ok := rr.Data.(*SOARecord); ok && strings.EqualFold(rr.Name, report.Zone) {
+			s.Serial, found = soa.Serial, true
+		}
+	}
+	if !found {
+		s.Status, s.Error = ConsistencyLame, fmt.Sprintf("no SOA record for %s", report.Zone)
+		return
+	}
+	s.Status = ConsistencyOK
+	for _, column := range report.Columns {
+		t, _ := ParseRRType(column)
+		qname := report.Name
+		if t == TypeNS {
+			qname = report.Zone
+		}
+		a := ConsistencyAnswer{Type: column}
+		if resp, err := queryAuthoritative(ctx, s.Addr, qname, t); err != nil {
+			a.Error = err.Error()
+		} else {
+			answer, _ := propagationAnswer(resp, qname)
+			for _, rr := range answer {
+				a.Answer = append(a.Answer, strings.TrimSuffix(strings.TrimPrefix(rr, column+" "), "."))
+			}
+		}
+		s.Answers = append(s.Answers, a)
+	}
+}
+
+// parentDelegation asks the servers of the zone above zone for its NS
+// records, and returns them with their glue.
+func parentDelegation(ctx context.Context, r *Resolver, zone string) (*TraceReferral, error) {
+	if zone == "." {
+		return nil, fmt.Errorf("the root zone has no parent")
+	}
+	parent, servers, err := findNameServers(ctx, r, parentZone(zone))
+	if err != nil {
+		return nil, err
+	}
+	for i, s := range servers {
+		if i == traceServersPerZone {
+			break
+		}
+		resp, qerr := traceQuery(ctx, s.Addr, parent, zone, TypeNS)
+		if qerr != nil {
+			err = fmt.Errorf("%s: %w", s, qerr)
+			continue
+		}
+		if ref := referral(resp, parent, zone); ref != nil && strings.EqualFold(ref.Zone, zone) {
+			return ref, nil
+		}
+		// The parent's servers serve the zone too and answer for it.
+		ref := &TraceReferral{Zone: zone}
+		for _, rr := range resp.Answer {
+			if ns, ok := rr.Data.(*NSRecord); ok && strings.EqualFold(rr.Name, zone) {
+				ref.NameServers = append(ref.NameServers, ns.Host)
+			}
+		}
+		if len(ref.NameServers) > 0 {
+			for _, rr := range resp.Additional {
+				if rr.Type == TypeA || rr.Type == TypeAAAA {
+					ref.Glue = append(ref.Glue, rr)
+				}
+			}
+			return ref, nil
+		}
+		err = fmt.Errorf("%s returned no delegation for %s", s, zone)
+	}
+	return nil, err
+}
+
+// compare marks the answers that differ from what most servers answered,
+// settles the NS set and the serial, and lists the problems.
+func (r *ConsistencyReport) compare() {
+	answered := false
+	for _, s := range r.Servers {
+		if s.Status == ConsistencyOK && (!answered || serialNewer(s.Serial, r.Serial)) {
+			r.Serial, answered = s.Serial, true
+		}
+	}
+	common := make([][]string, len(r.Columns))
+	for i := range r.Columns {
+		counts := make(map[string]int)
+		most := 0
+		for _, s := range r.Servers {
+			if i >= len(s.Answers) || s.Answers[i].Error != "" {
+				continue
+			}
+			key := strings.ToLower(strings.Join(s.Answers[i].Answer, "\n"))
+			if counts[key]++; counts[key] > most {
+				most, common[i] = counts[key], s.Answers[i].Answer
+				if common[i] == nil {
+					common[i] = []string{}
+				}
+			}
+		}
+		for j := range r.Servers {
+			if s := &r.Servers[j]; i < len(s.Answers) {
+				s.Answers[i].Differs = s.Answers[i].Error != "" || common[i] == nil || !equalAnswers(s.Answers[i].Answer, common[i])
+			}
+		}
+	}
+	// Without an answer from any server, InChild keeps the resolver's NS
+	// set.
+	if r.ChildNS = common[0]; r.ChildNS != nil {
+		for i := range r.Servers {
+			s := &r.Servers[i]
+			s.InChild = false
+			for _, host := range r.ChildNS {
+				if strings.EqualFold(Fqdn(host), s.Host) {
+					s.InChild = true
+				}
+			}
+		}
+	}
+
+	if r.ParentNS != nil && r.ChildNS != nil {
+		var parentOnly, childOnly []string
+		for _, s := range r.Servers {
+			label := strings.TrimSuffix(s.Host, ".")
+			switch {
+			case s.InParent && !s.InChild:
+				parentOnly = append(parentOnly, label)
+			case s.InChild && !s.InParent:
+				childOnly = append(childOnly, label)
+			}
+		}
+		if len(parentOnly) > 0 {
+			r.Problems = append(r.Problems, fmt.Sprintf("NS sets differ: only the parent lists %s", strings.Join(parentOnly, ", ")))
+		}
+		if len(childOnly) > 0 {
+			r.Problems = append(r.Problems, fmt.Sprintf("NS sets differ: only the zone lists %s", strings.Join(childOnly, ", ")))
+		}
+	}
+	for _, s := range r.Servers {
+		if s.InParent && len(s.Glue) == 0 && isSubdomain(s.Host, r.Zone) {
+			r.Problems = append(r.Problems, fmt.Sprintf("%s is inside %s but the parent gives no glue for it", strings.TrimSuffix(s.Host, "."), r.Zone))
+		}
+	}
+	for _, s := range r.Servers {
+		switch s.Status {
+		case ConsistencyNoAddress:
+			r.Problems = append(r.Problems, fmt.Sprintf("%s has no address", s.label()))
+		case ConsistencyUnreachable:
+			r.Problems = append(r.Problems, fmt.Sprintf("%s is unreachable: %s", s.label(), s.Error))
+		case ConsistencyLame:
+			r.Problems = append(r.Problems, fmt.Sprintf("%s is lame: %s", s.label(), s.Error))
+		case ConsistencyOK:
+			if s.Serial != r.Serial {
+				r.Problems = append(r.Problems, fmt.Sprintf("serial mismatch: %s has %d, behind %d", s.label(), s.Serial, r.Serial))
+			}
+			for i, a := range s.Answers {
+				switch {
+				case a.Error != "":
+					r.Problems = append(r.Problems, fmt.Sprintf("%s failed the %s query: %s", s.label(), a.Type, a.Error))
+				case a.Differs:
+					r.Problems = append(r.Problems, fmt.Sprintf("%s answers %s with %s, most servers with %s", s.label(), a.Type, formatPropagationAnswer(a.Answer), formatPropagationAnswer(common[i])))
+				}
+			}
+		}
+	}
+}
+
+// serialNewer reports whether SOA serial a is newer than b in the serial
+// number arithmetic of RFC 1982, under which serials wrap around: 1 is newer
+// than 4294967295. Serials exactly 2^31 apart are not ordered.
+func serialNewer(a, b uint32) bool {
+	d := a - b
+	return d != 0 && d < 1<<31
+}
+
+func init() {
+	RegisterProvider(&ConsistencyProvider{})
+}
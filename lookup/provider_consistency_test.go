@@ -0,0 +1,213 @@
+package lookup_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"dlookup/lookup"
+)
+
+// nsHosts are the addresses of the name servers the consistency tests use.
+var nsHosts = map[string]string{
+	"ns1.example.com.": "192.0.2.11",
+	"ns2.example.com.": "192.0.2.12",
+	"ns3.example.com.": "192.0.2.13",
+	"ns4.example.net.": "192.0.2.14",
+}
+
+// zoneServer is how one server of example.com answers: with serial and
+// an A record of ip, or not authoritatively if lame.
+type zoneServer struct {
+	serial uint32
+	ip     string
+	lame   bool
+}
+
+// consistencyZone serves example.com: com. delegates it to parentNS, with
+// glue for ns1 and ns2 only, and servers, keyed by address, list childNS.
+// Servers missing from servers do not respond.
+func consistencyZone(parentNS, childNS []string, servers map[string]zoneServer) map[string]dnsHandler {
+	nsSet := func(hosts []string) []lookup.RR {
+		var rrs []lookup.RR
+		for _, host := range hosts {
+			rrs = append(rrs, nsRecord("example.com.", host))
+		}
+		return rrs
+	}
+	glue := []lookup.RR{aRecord("ns1.example.com.", "192.0.2.11", 3600), aRecord("ns2.example.com.", "192.0.2.12", 3600)}
+	handlers := map[string]dnsHandler{
+		"192.0.2.1:53": func(q *lookup.Message, tcp bool) *lookup.Message {
+			qq := q.Question[0]
+			switch {
+			case qq.Type == lookup.TypeNS && qq.Name == "example.com.":
+				return &lookup.Message{Answer: nsSet(childNS), Additional: glue}
+			case qq.Type == lookup.TypeNS && qq.Name == "com.":
+				return &lookup.Message{
+					Answer:     []lookup.RR{nsRecord("com.", "a.gtld.test.")},
+					Additional: []lookup.RR{aRecord("a.gtld.test.", "192.0.2.53", 3600)},
+				}
+			case qq.Type == lookup.TypeA && nsHosts[qq.Name] != "":
+				return &lookup.Message{Answer: []lookup.RR{aRecord(qq.Name, nsHosts[qq.Name], 3600)}}
+			}
+			return &lookup.Message{}
+		},
+		"192.0.2.53:53": func(q *lookup.Message, tcp bool) *lookup.Message {
+			return &lookup.Message{Authority: nsSet(parentNS), Additional: glue}
+		},
+	}
+	for addr, server := range servers {
+		handlers[addr+":53"] = func(q *lookup.Message, tcp bool) *lookup.Message {
+			if server.lame {
+				return &lookup.Message{}
+			}
+			qq := q.Question[0]
+			resp := &lookup.Message{Header: lookup.Header{Authoritative: true}}
+			switch qq.Type {
+			case lookup.TypeSOA:
+				soa := soaRecord("example.com.")
+				soa.Data.(*lookup.SOARecord).Serial = server.serial
+				resp.Answer = []lookup.RR{soa}
+			case lookup.TypeNS:
+				resp.Answer = nsSet(childNS)
+			case lookup.TypeA:
+				resp.Answer = []lookup.RR{aRecord(qq.Name, server.ip, 300)}
+			}
+			return resp
+		}
+	}
+	return handlers
+}
+
+func checkConsistency(t *testing.T, domain string) (*lookup.Result, *lookup.ConsistencyReport) {
+	t.Helper()
+	provider, ok := lookup.GetProviderByFlagName("ns-consistency")
+	if !ok {
+		t.Fatal("ns-consistency provider not registered")
+	}
+	result, err := provider.Execute(context.Background(), domain)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	return result, result.Details.(*lookup.ConsistencyReport)
+}
+
+func TestConsistencyProvider_Consistent(t *testing.T) {
+	both := []string{"ns1.example.com.", "ns2.example.com."}
+	useFakeDNS(t, consistencyZone(both, both, map[string]zoneServer{
+		"192.0.2.11": {serial: 7, ip: "203.0.113.2"},
+		"192.0.2.12": {serial: 7, ip: "203.0.113.2"},
+	}))
+
+	result, report := checkConsistency(t, "www.example.com")
+	if len(report.Problems) != 0 {
+		t.Errorf("Problems = %q, want none", report.Problems)
+	}
+	if report.Zone != "example.com." || report.Serial != 7 || strings.Join(report.ChildNS, ",") != "ns1.example.com,ns2.example.com" {
+		t.Errorf("report = %+v", report)
+	}
+	summary := report.Summary()
+	for _, line := range []string{"Servers:       2 (2 answered)", "Serial:        7", "Status:        consistent"} {
+		if !strings.Contains(summary, line) {
+			t.Errorf("Summary() =\n%s\nwant a line %q", summary, line)
+		}
+	}
+	lines := strings.Split(result.Stdout, "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], "SERIAL  NS") || !strings.HasPrefix(lines[1], "✓  ns1.example.com (192.0.2.11)  parent, zone  ok") {
+		t.Errorf("table =\n%s", result.Stdout)
+	}
+	if !strings.Contains(lines[2], "203.0.113.2") || strings.Contains(result.Stdout, "*") {
+		t.Errorf("table =\n%s\nwant the A records and no differences", result.Stdout)
+	}
+}
+
+func TestConsistencyProvider_Problems(t *testing.T) {
+	useFakeDNS(t, consistencyZone(
+		[]string{"ns1.example.com.", "ns2.example.com.", "ns3.example.com."},
+		[]string{"ns1.example.com.", "ns2.example.com.", "ns4.example.net."},
+		map[string]zoneServer{
+			"192.0.2.11": {serial: 7, ip: "203.0.113.2"},
+			"192.0.2.12": {serial: 6, ip: "203.0.113.1"},
+			"192.0.2.13": {lame: true},
+		}))
+
+	result, report := checkConsistency(t, "example.com")
+	var hosts, statuses []string
+	for _, s := range report.Servers {
+		hosts = append(hosts, s.Host)
+		statuses = append(statuses, s.Status)
+	}
+	if got := strings.Join(hosts, " "); got != "ns1.example.com. ns2.example.com. ns3.example.com. ns4.example.net." {
+		t.Errorf("servers = %s, want the parent's first, then the zone's", got)
+	}
+	if got := strings.Join(statuses, ","); got != "ok,ok,lame,unreachable" {
+		t.Errorf("statuses = %s", got)
+	}
+	problems := strings.Join(report.Problems, "\n")
+	for _, want := range []string{
+		"NS sets differ: only the parent lists ns3.example.com",
+		"NS sets differ: only the zone lists ns4.example.net",
+		"ns3.example.com is inside example.com. but the parent gives no glue for it",
+		"ns3.example.com (192.0.2.13) is lame: 192.0.2.13 is not authoritative for example.com.",
+		"ns4.example.net (192.0.2.14) is unreachable: ",
+		"serial mismatch: ns2.example.com (192.0.2.12) has 6, behind 7",
+		"ns2.example.com (192.0.2.12) answers A with 203.0.113.1, most servers with 203.0.113.2",
+	} {
+		if !strings.Contains(problems, want) {
+			t.Errorf("Problems =\n%s\nwant %q", problems, want)
+		}
+	}
+	if !strings.Contains(report.Summary(), "Serials:       7 on 1, 6 on 1") {
+		t.Errorf("Summary() =\n%s\nwant both serials", report.Summary())
+	}
+
+	lines := strings.Split(result.Stdout, "\n")
+	for i, want := range []string{"✓  ns1", "✗  ns2", "✗  ns3", "✗  ns4"} {
+		if !strings.HasPrefix(lines[i+1], want) {
+			t.Errorf("table line %d = %q, want prefix %q", i+1, lines[i+1], want)
+		}
+	}
+	if !strings.Contains(lines[2], "6 *") || !strings.Contains(lines[2], "203.0.113.1 *") || !strings.Contains(lines[3], "parent  ") {
+		t.Errorf("table =\n%s\nwant the differing cells starred", result.Stdout)
+	}
+	if !strings.Contains(result.Stdout, "\n\nProblems:\n✗ NS sets differ") {
+		t.Errorf("Stdout =\n%s\nwant the problems after the table", result.Stdout)
+	}
+	if _, err := json.Marshal(result); err != nil {
+		t.Errorf("json.Marshal() error = %v", err)
+	}
+}
+
+func TestConsistencyProvider_SerialWraps(t *testing.T) {
+	both := []string{"ns1.example.com.", "ns2.example.com."}
+	useFakeDNS(t, consistencyZone(both, both, map[string]zoneServer{
+		"192.0.2.11": {serial: 4294967295, ip: "203.0.113.2"},
+		"192.0.2.12": {serial: 0, ip: "203.0.113.2"},
+	}))
+
+	_, report := checkConsistency(t, "example.com")
+	if report.Servers[1].Status != lookup.ConsistencyOK {
+		t.Errorf("server with serial 0: status %q (%s), want ok", report.Servers[1].Status, report.Servers[1].Error)
+	}
+	want := "serial mismatch: ns1.example.com (192.0.2.11) has 4294967295, behind 0"
+	if report.Serial != 0 || strings.Join(report.Problems, "\n") != want {
+		t.Errorf("Serial = %d, Problems = %q; want 0 and %q", report.Serial, report.Problems, want)
+	}
+	if !strings.Contains(report.Summary(), "Serials:       0 on 1, 4294967295 on 1") {
+		t.Errorf("Summary() =\n%s\nwant the wrapped serial first", report.Summary())
+	}
+}
+
+func TestConsistencyProvider_NoServerAnswers(t *testing.T) {
+	both := []string{"ns1.example.com.", "ns2.example.com."}
+	useFakeDNS(t, consistencyZone(both, both, nil))
+	provider, _ := lookup.GetProviderByFlagName("ns-consistency")
+	result, err := provider.Execute(context.Background(), "example.com")
+	if err == nil || !strings.Contains(err.Error(), "no name server of example.com. answered") {
+		t.Errorf("Execute() error = %v", err)
+	}
+	if result == nil || len(result.Details.(*lookup.ConsistencyReport).Servers) != 2 {
+		t.Errorf("Execute() result = %+v, want the unreachable servers", result)
+	}
+}
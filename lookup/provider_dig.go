@@ -1,6 +1,7 @@
 package lookup
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
@@ -10,20 +11,30 @@ type DigProvider struct {
 	name     string
 	flagName string
 	args     []string
+	qtype    RRType
 }
 
 var nonAlphanumericRegex = regexp.MustCompile(`[^a-zA-Z0-9]+`)
 
-func newDigProvider(name string, digArgs ...string) {
+// slugify turns a provider name such as "DIG (A)" into "dig-a".
+func slugify(name string) string {
 	s := strings.ToLower(name)
 	s = nonAlphanumericRegex.ReplaceAllString(s, "-")
-	s = strings.Trim(s, "-")
-	flagName := "dig-" + s
+	return strings.Trim(s, "-")
+}
 
+func newDigProvider(name string, digArgs ...string) {
+	flagName := "dig-" + slugify(name)
+
+	qtype, err := ParseRRType(digArgs[0])
+	if err != nil {
+		panic(fmt.Sprintf("dig provider %q: %v", name, err))
+	}
 	provider := &DigProvider{
 		name:     name,
 		flagName: flagName,
 		args:     digArgs,
+		qtype:    qtype,
 	}
 	RegisterProvider(provider)
 }
@@ -40,13 +51,62 @@ func (p *DigProvider) Usage() string {
 	return fmt.Sprintf("Run %s lookup on domains from <filename>", p.Name())
 }
 
-func (p *DigProvider) Execute(domain string) (string, error) {
+func (p *DigProvider) Execute(ctx context.Context, domain string) (*Result, error) {
 	if !p.CheckAvailability() {
-		return "", fmt.Errorf("command not found: dig")
+		return nil, fmt.Errorf("command not found: dig")
+	}
+	fullArgs := append([]string{queryName(p.qtype, domain)}, p.args...)
+	// dig's own tries (3 of 5s each) would use up the lookup's timeout
+	// before RunLookup could retry a timed-out query.
+	if retriedByRunLookup(ctx) {
+		fullArgs = append(fullArgs, "+tries=1")
+	}
+	server := ServerFromContext(ctx)
+	if server != "" {
+		host, port := splitServer(server)
+		if port != "" {
+			fullArgs = append([]string{"-p", port}, fullArgs...)
+		}
+		fullArgs = append([]string{"@" + host}, fullArgs...)
 	}
-	fullArgs := append([]string{domain}, p.args...)
 	// Use the new exported RunCommand which allows mocking
-	return RunCommand("dig", fullArgs...)
+	result, err := RunCommand(ctx, "dig", fullArgs...)
+	if result != nil {
+		result.Server = server
+	}
+	if err != nil {
+		return result, err
+	}
+	// Records are best effort: output dig formats in a way the parser does
+	// not understand is still shown as text.
+	result.Records, _ = p.parse(domain, result.Stdout)
+	result.NoTTLs = p.short()
+	if view := newRecordView(p.qtype, result.Records); view != nil {
+		result.Details = view
+	}
+	return result, nil
+}
+
+// Accepts reports whether p's record type makes sense for kind.
+func (p *DigProvider) Accepts(kind InputKind) bool {
+	return acceptsRecordType(p.qtype, kind)
+}
+
+func (p *DigProvider) parse(domain, output string) ([]RR, error) {
+	if p.short() {
+		return ParseDigShort(domain, p.qtype, output)
+	}
+	return ParseDigAnswer(output)
+}
+
+// short reports whether p runs `dig +short`, whose output has no TTLs.
+func (p *DigProvider) short() bool {
+	for _, arg := range p.args {
+		if arg == "+short" {
+			return true
+		}
+	}
+	return false
 }
 
 func (p *DigProvider) CheckAvailability() bool {
@@ -61,4 +121,15 @@ func init() {
 	newDigProvider("DIG (TXT)", "TXT", "+noall", "+answer")
 	newDigProvider("DIG (SOA)", "SOA", "+noall", "+answer")
 	newDigProvider("DIG (CNAME)", "CNAME", "+short")
+	newDigProvider("DIG (NS)", "NS", "+noall", "+answer")
+	newDigProvider("DIG (PTR)", "PTR", "+noall", "+answer")
+	newDigProvider("DIG (SRV)", "SRV", "+noall", "+answer")
+	newDigProvider("DIG (CAA)", "CAA", "+noall", "+answer")
+	newDigProvider("DIG (DS)", "DS", "+noall", "+answer")
+	newDigProvider("DIG (DNSKEY)", "DNSKEY", "+noall", "+answer")
+	newDigProvider("DIG (HTTPS)", "HTTPS", "+noall", "+answer")
+	newDigProvider("DIG (SVCB)", "SVCB", "+noall", "+answer")
+	newDigProvider("DIG (TLSA)", "TLSA", "+noall", "+answer")
+	newDigProvider("DIG (SSHFP)", "SSHFP", "+noall", "+answer")
+	newDigProvider("DIG (NAPTR)", "NAPTR", "+noall", "+answer")
 }
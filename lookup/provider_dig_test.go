@@ -1,6 +1,7 @@
 package lookup_test
 
 import (
+	"context"
 	"dlookup/lookup"
 	"fmt"
 	"strings"
@@ -21,6 +22,17 @@ var digProviderTestCases = []struct {
 	{"DIG (TXT)", "DIG (TXT)", "dig-dig-txt", []string{"TXT", "+noall", "+answer"}},
 	{"DIG (SOA)", "DIG (SOA)", "dig-dig-soa", []string{"SOA", "+noall", "+answer"}},
 	{"DIG (CNAME)", "DIG (CNAME)", "dig-dig-cname", []string{"CNAME", "+short"}},
+	{"DIG (NS)", "DIG (NS)", "dig-dig-ns", []string{"NS", "+noall", "+answer"}},
+	{"DIG (PTR)", "DIG (PTR)", "dig-dig-ptr", []string{"PTR", "+noall", "+answer"}},
+	{"DIG (SRV)", "DIG (SRV)", "dig-dig-srv", []string{"SRV", "+noall", "+answer"}},
+	{"DIG (CAA)", "DIG (CAA)", "dig-dig-caa", []string{"CAA", "+noall", "+answer"}},
+	{"DIG (DS)", "DIG (DS)", "dig-dig-ds", []string{"DS", "+noall", "+answer"}},
+	{"DIG (DNSKEY)", "DIG (DNSKEY)", "dig-dig-dnskey", []string{"DNSKEY", "+noall", "+answer"}},
+	{"DIG (HTTPS)", "DIG (HTTPS)", "dig-dig-https", []string{"HTTPS", "+noall", "+answer"}},
+	{"DIG (SVCB)", "DIG (SVCB)", "dig-dig-svcb", []string{"SVCB", "+noall", "+answer"}},
+	{"DIG (TLSA)", "DIG (TLSA)", "dig-dig-tlsa", []string{"TLSA", "+noall", "+answer"}},
+	{"DIG (SSHFP)", "DIG (SSHFP)", "dig-dig-sshfp", []string{"SSHFP", "+noall", "+answer"}},
+	{"DIG (NAPTR)", "DIG (NAPTR)", "dig-dig-naptr", []string{"NAPTR", "+noall", "+answer"}},
 }
 
 func TestDigProviders_StaticMethods(t *testing.T) {
@@ -109,13 +121,14 @@ func TestDigProviders_Execute(t *testing.T) {
 			// Test Case 1: Successful execution
 			t.Run("Success", func(t *testing.T) {
 				expectedOutput := "mocked_dig_output_for_" + tc.name
-				lookup.OsRunCommand = func(cmdName string, args ...string) (string, error) {
+				lookup.OsRunCommand = func(ctx context.Context, cmdName string, args ...string) (string, string, error) {
 					capturedCmdName = cmdName
 					capturedArgs = args
-					return expectedOutput, nil
+					return expectedOutput, "", nil
 				}
 
-				output, err := provider.Execute("testdomain.com")
+				result, err := provider.Execute(context.Background(), "testdomain.com")
+				output := outputOf(result)
 				if err != nil {
 					t.Errorf("Execute() error = %v, want nil", err)
 				}
@@ -137,16 +150,17 @@ func TestDigProviders_Execute(t *testing.T) {
 				// This is the error our mock OsRunCommand will return
 				mockError := fmt.Errorf("mocked dig error for %s", tc.name)
 
-				lookup.OsRunCommand = func(cmdName string, args ...string) (string, error) {
+				lookup.OsRunCommand = func(ctx context.Context, cmdName string, args ...string) (string, string, error) {
 					capturedCmdName = cmdName
 					capturedArgs = args
 					// lookup.RunCommand wraps the error from OsRunCommand.
 					// It returns: fmt.Errorf("command '%s %s' failed: %w", cmdName, strings.Join(args, " "), err)
-					// So, the error we get from provider.Execute() will be this wrapped error.
-					return "error output", mockError // "error output" could be potential stderr
+					// So, the error we get from Execute will be this wrapped error.
+					return "error output", "", mockError
 				}
 
-				output, err := provider.Execute("testdomain.com")
+				result, err := provider.Execute(context.Background(), "testdomain.com")
+				output := outputOf(result)
 				if err == nil {
 					t.Fatalf("Execute() error = nil, want non-nil")
 				}
@@ -160,8 +174,8 @@ func TestDigProviders_Execute(t *testing.T) {
 					t.Errorf("Execute() error = %q, want %q", err.Error(), expectedWrappedErrStr)
 				}
 
-				// Also check the output, which might contain stderr if RunCommand populates it.
-				// The mock OsRunCommand returns "error output" as the string part.
+				// Also check that stdout is kept alongside the error.
+				// The mock OsRunCommand returns "error output" as stdout.
 				if output != "error output" {
 					t.Errorf("Execute() output on error = %q, want %q", output, "error output")
 				}
@@ -169,15 +183,16 @@ func TestDigProviders_Execute(t *testing.T) {
 
 			// Test Case 3: Command produces no output (but no error)
 			t.Run("NoOutput", func(t *testing.T) {
-				// RunCommand should convert empty raw output to "(No results found)"
+				// Result.Output should turn empty stdout into "(No results found)"
 				expectedOutput := "(No results found)"
-				lookup.OsRunCommand = func(cmdName string, args ...string) (string, error) {
+				lookup.OsRunCommand = func(ctx context.Context, cmdName string, args ...string) (string, string, error) {
 					capturedCmdName = cmdName
 					capturedArgs = args
-					return "", nil // Mock OsRunCommand returns empty string and nil error
+					return "", "", nil // Mock OsRunCommand returns empty output and nil error
 				}
 
-				output, err := provider.Execute("nodata.example.com")
+				result, err := provider.Execute(context.Background(), "nodata.example.com")
+				output := outputOf(result)
 				if err != nil {
 					t.Errorf("Execute() error = %v, want nil", err)
 				}
@@ -188,3 +203,128 @@ func TestDigProviders_Execute(t *testing.T) {
 		})
 	}
 }
+
+func TestDigProviders_ParseRecords(t *testing.T) {
+	origRunCommand := lookup.OsRunCommand
+	defer func() { lookup.OsRunCommand = origRunCommand }()
+	origCheckCommandFunc := lookup.LookupCheckCommandFunc
+	defer func() { lookup.LookupCheckCommandFunc = origCheckCommandFunc }()
+	lookup.LookupCheckCommandFunc = func(cmd string) bool { return cmd == "dig" }
+
+	tests := []struct {
+		provider string
+		output   string
+		want     string
+	}{
+		{"DIG (MX)", "10 mx1.example.com.\n20 mx2.example.com.", "example.com.\t0\tIN\tMX\t20 mx2.example.com."},
+		{"DIG (SOA)", "example.com.\t\t3600\tIN\tSOA\tns1.example.com. admin.example.com. 7 3600 600 86400 300", "example.com.\t3600\tIN\tSOA\tns1.example.com. admin.example.com. 7 3600 600 86400 300"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.provider, func(t *testing.T) {
+			lookup.OsRunCommand = func(ctx context.Context, cmdName string, args ...string) (string, string, error) {
+				return tc.output, "", nil
+			}
+			provider, _ := lookup.GetProvider(tc.provider)
+			result, err := provider.Execute(context.Background(), "example.com")
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if len(result.Records) == 0 || result.Records[len(result.Records)-1].String() != tc.want {
+				t.Errorf("Execute() records = %v, want last record %q", result.Records, tc.want)
+			}
+		})
+	}
+}
+
+func TestDigProvider_Server(t *testing.T) {
+	origRunCommand := lookup.OsRunCommand
+	defer func() { lookup.OsRunCommand = origRunCommand }()
+	origCheckCommandFunc := lookup.LookupCheckCommandFunc
+	defer func() { lookup.LookupCheckCommandFunc = origCheckCommandFunc }()
+	lookup.LookupCheckCommandFunc = func(cmd string) bool { return cmd == "dig" }
+
+	var capturedArgs []string
+	lookup.OsRunCommand = func(ctx context.Context, cmdName string, args ...string) (string, string, error) {
+		capturedArgs = args
+		return "192.0.2.1", "", nil
+	}
+	provider, _ := lookup.GetProvider("DIG (A)")
+
+	tests := []struct {
+		server string
+		want   []string
+	}{
+		{"8.8.8.8", []string{"@8.8.8.8", "example.com", "A", "+short"}},
+		{"[2001:db8::53]:5353", []string{"@2001:db8::53", "-p", "5353", "example.com", "A", "+short"}},
+	}
+	for _, tt := range tests {
+		result, err := provider.Execute(lookup.WithServer(context.Background(), tt.server), "example.com")
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if !equalSlices(capturedArgs, tt.want) {
+			t.Errorf("server %s: args = %v, want %v", tt.server, capturedArgs, tt.want)
+		}
+		if result.Server != tt.server {
+			t.Errorf("Result.Server = %q, want %q", result.Server, tt.server)
+		}
+	}
+}
+
+func TestDigProvider_PTRReversesAddresses(t *testing.T) {
+	origRunCommand := lookup.OsRunCommand
+	defer func() { lookup.OsRunCommand = origRunCommand }()
+	origCheckCommandFunc := lookup.LookupCheckCommandFunc
+	defer func() { lookup.LookupCheckCommandFunc = origCheckCommandFunc }()
+	lookup.LookupCheckCommandFunc = func(cmd string) bool { return cmd == "dig" }
+
+	var capturedArgs []string
+	lookup.OsRunCommand = func(ctx context.Context, cmdName string, args ...string) (string, string, error) {
+		capturedArgs = args
+		return "8.8.8.8.in-addr.arpa.\t16900\tIN\tPTR\tdns.google.", "", nil
+	}
+	provider, _ := lookup.GetProvider("DIG (PTR)")
+	result, err := provider.Execute(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if want := []string{"8.8.8.8.in-addr.arpa.", "PTR", "+noall", "+answer"}; !equalSlices(capturedArgs, want) {
+		t.Errorf("args = %v, want %v", capturedArgs, want)
+	}
+	if len(result.Records) != 1 || result.Records[0].Data.String() != "dns.google." {
+		t.Errorf("Records = %v, want the PTR record", result.Records)
+	}
+}
+
+func TestDigProvider_TriesOnceWhenRetried(t *testing.T) {
+	origRunCommand := lookup.OsRunCommand
+	defer func() { lookup.OsRunCommand = origRunCommand }()
+	origCheckCommandFunc := lookup.LookupCheckCommandFunc
+	defer func() { lookup.LookupCheckCommandFunc = origCheckCommandFunc }()
+	lookup.LookupCheckCommandFunc = func(cmd string) bool { return cmd == "dig" }
+	defer lookup.SetProviderPolicy("dig-dig-a", lookup.DefaultPolicy)
+
+	var capturedArgs []string
+	lookup.OsRunCommand = func(ctx context.Context, cmdName string, args ...string) (string, string, error) {
+		capturedArgs = args
+		return "192.0.2.1", "", nil
+	}
+	provider, _ := lookup.GetProvider("DIG (A)")
+
+	tests := []struct {
+		attempts int
+		want     []string
+	}{
+		{3, []string{"example.com", "A", "+short", "+tries=1"}},
+		{1, []string{"example.com", "A", "+short"}},
+	}
+	for _, tt := range tests {
+		lookup.SetProviderPolicy("dig-dig-a", lookup.Policy{MaxAttempts: tt.attempts})
+		if _, err := lookup.RunLookup(context.Background(), provider, "example.com"); err != nil {
+			t.Fatalf("RunLookup() error = %v", err)
+		}
+		if !equalSlices(capturedArgs, tt.want) {
+			t.Errorf("%d attempts: args = %v, want %v", tt.attempts, capturedArgs, tt.want)
+		}
+	}
+}
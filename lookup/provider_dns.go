@@ -0,0 +1,132 @@
+package lookup
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// NativeDNSProvider performs DNS queries with the built-in resolver instead
+// of shelling out to dig, so it works on hosts without bind-utils.
+type NativeDNSProvider struct {
+	name     string
+	flagName string
+	qtype    RRType
+}
+
+func newNativeDNSProvider(name string, qtype RRType) {
+	RegisterProvider(&NativeDNSProvider{
+		name:     name,
+		flagName: slugify(name),
+		qtype:    qtype,
+	})
+}
+
+func (p *NativeDNSProvider) Name() string {
+	return p.name
+}
+
+func (p *NativeDNSProvider) FlagName() string {
+	return p.flagName
+}
+
+func (p *NativeDNSProvider) Usage() string {
+	return fmt.Sprintf("Run %s lookup on domains from <filename>", p.Name())
+}
+
+func (p *NativeDNSProvider) CheckAvailability() bool {
+	return true
+}
+
+// Accepts reports whether p's record type makes sense for kind.
+func (p *NativeDNSProvider) Accepts(kind InputKind) bool {
+	return acceptsRecordType(p.qtype, kind)
+}
+
+func (p *NativeDNSProvider) Execute(ctx context.Context, domain string) (*Result, error) {
+	chosen := ServerFromContext(ctx)
+	resp, server, err := resolverFor(chosen).Query(ctx, queryName(p.qtype, domain), p.qtype)
+	if err != nil {
+		return &Result{Server: chosen}, fmt.Errorf("%s query for %s failed: %w", p.qtype, domain, err)
+	}
+	if resp.Rcode != RcodeSuccess && resp.Rcode != RcodeNameError {
+		return &Result{Server: chosen}, &RcodeError{Name: domain, Server: server, Rcode: resp.Rcode}
+	}
+	result := &Result{Records: resp.Answer, NegativeTTL: negativeTTL(resp), Stdout: formatAnswer(resp), Server: chosen}
+	if view := newRecordView(p.qtype, resp.Answer); view != nil {
+		result.Details = view
+	}
+	return result, nil
+}
+
+// negativeTTL returns how long resp may be cached if it has no answers: the
+// smaller of the TTL and minimum of the SOA in its authority section, or 0
+// without one.
+func negativeTTL(resp *Message) uint32 {
+	for _, rr := range resp.Authority {
+		if soa, ok := rr.Data.(*SOARecord); ok {
+			return min(rr.TTL, soa.Minimum)
+		}
+	}
+	return 0
+}
+
+// queryName returns the name to query for domain: for PTR lookups an IP
+// address is turned into its reverse name, anything else is used as is.
+func queryName(t RRType, domain string) string {
+	if t == TypePTR {
+		if ip := net.ParseIP(domain); ip != nil {
+			return ReverseName(ip)
+		}
+	}
+	return domain
+}
+
+// resolverFor returns DefaultResolver, or a copy of it that queries only
+// server when one is given.
+func resolverFor(server string) *Resolver {
+	if server == "" {
+		return DefaultResolver
+	}
+	r := *DefaultResolver
+	r.Servers = []string{server}
+	return &r
+}
+
+// formatAnswer renders the answer section one record per line, the same way
+// `dig +noall +answer` does.
+func formatAnswer(resp *Message) string {
+	if len(resp.Answer) == 0 {
+		if resp.Rcode == RcodeNameError {
+			return "(No results found: NXDOMAIN)"
+		}
+		return "(No results found)"
+	}
+	lines := make([]string, 0, len(resp.Answer))
+	for _, rr := range resp.Answer {
+		lines = append(lines, rr.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func init() {
+	newNativeDNSProvider("DNS (ANY)", TypeANY)
+	newNativeDNSProvider("DNS (A)", TypeA)
+	newNativeDNSProvider("DNS (AAAA)", TypeAAAA)
+	newNativeDNSProvider("DNS (MX)", TypeMX)
+	newNativeDNSProvider("DNS (TXT)", TypeTXT)
+	newNativeDNSProvider("DNS (SOA)", TypeSOA)
+	newNativeDNSProvider("DNS (CNAME)", TypeCNAME)
+	newNativeDNSProvider("DNS (NS)", TypeNS)
+	newNativeDNSProvider("DNS (PTR)", TypePTR)
+	newNativeDNSProvider("DNS (SRV)", TypeSRV)
+	newNativeDNSProvider("DNS (CAA)", TypeCAA)
+	newNativeDNSProvider("DNS (DS)", TypeDS)
+	newNativeDNSProvider("DNS (DNSKEY)", TypeDNSKEY)
+	newNativeDNSProvider("DNS (HTTPS)", TypeHTTPS)
+	newNativeDNSProvider("DNS (SVCB)", TypeSVCB)
+	newNativeDNSProvider("DNS (TLSA)", TypeTLSA)
+	newNativeDNSProvider("DNS (SSHFP)", TypeSSHFP)
+	newNativeDNSProvider("DNS (NAPTR)", TypeNAPTR)
+}
@@ -0,0 +1,217 @@
+package lookup_test
+
+import (
+	"context"
+	"dlookup/lookup"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var nativeDNSProviderTestCases = []struct {
+	name         string
+	expectedFlag string
+	qtype        lookup.RRType
+}{
+	{"DNS (ANY)", "dns-any", lookup.TypeANY},
+	{"DNS (A)", "dns-a", lookup.TypeA},
+	{"DNS (AAAA)", "dns-aaaa", lookup.TypeAAAA},
+	{"DNS (MX)", "dns-mx", lookup.TypeMX},
+	{"DNS (TXT)", "dns-txt", lookup.TypeTXT},
+	{"DNS (SOA)", "dns-soa", lookup.TypeSOA},
+	{"DNS (CNAME)", "dns-cname", lookup.TypeCNAME},
+	{"DNS (NS)", "dns-ns", lookup.TypeNS},
+	{"DNS (PTR)", "dns-ptr", lookup.TypePTR},
+	{"DNS (SRV)", "dns-srv", lookup.TypeSRV},
+	{"DNS (CAA)", "dns-caa", lookup.TypeCAA},
+	{"DNS (DS)", "dns-ds", lookup.TypeDS},
+	{"DNS (DNSKEY)", "dns-dnskey", lookup.TypeDNSKEY},
+	{"DNS (HTTPS)", "dns-https", lookup.TypeHTTPS},
+	{"DNS (SVCB)", "dns-svcb", lookup.TypeSVCB},
+	{"DNS (TLSA)", "dns-tlsa", lookup.TypeTLSA},
+	{"DNS (SSHFP)", "dns-sshfp", lookup.TypeSSHFP},
+	{"DNS (NAPTR)", "dns-naptr", lookup.TypeNAPTR},
+}
+
+// useResolver points the native DNS providers at addr for the duration of a test.
+func useResolver(t *testing.T, addr string) {
+	t.Helper()
+	orig := lookup.DefaultResolver
+	lookup.DefaultResolver = &lookup.Resolver{Servers: []string{addr}, Timeout: 2 * time.Second, UDPSize: 1232}
+	t.Cleanup(func() { lookup.DefaultResolver = orig })
+}
+
+func TestNativeDNSProviders_StaticMethods(t *testing.T) {
+	for _, tc := range nativeDNSProviderTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider, ok := lookup.GetProvider(tc.name)
+			if !ok {
+				t.Fatalf("Expected provider %q not found.", tc.name)
+			}
+			if flagName := provider.FlagName(); flagName != tc.expectedFlag {
+				t.Errorf("FlagName() = %q, want %q", flagName, tc.expectedFlag)
+			}
+			if !provider.CheckAvailability() {
+				t.Errorf("CheckAvailability() = false, want true for a native provider")
+			}
+			expectedUsage := fmt.Sprintf("Run %s lookup on domains from <filename>", tc.name)
+			if usage := provider.Usage(); usage != expectedUsage {
+				t.Errorf("Usage() = %q, want %q", usage, expectedUsage)
+			}
+		})
+	}
+}
+
+func TestNativeDNSProviders_Execute(t *testing.T) {
+	var mu sync.Mutex
+	var gotTypes []lookup.RRType
+	addr := startDNSServer(t, func(q *lookup.Message, tcp bool) *lookup.Message {
+		mu.Lock()
+		defer mu.Unlock()
+		gotTypes = append(gotTypes, q.Question[0].Type)
+		return &lookup.Message{Answer: []lookup.RR{aRecord(q.Question[0].Name, "192.0.2.10", 300)}}
+	})
+	useResolver(t, addr)
+
+	for _, tc := range nativeDNSProviderTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mu.Lock()
+			gotTypes = nil
+			mu.Unlock()
+			provider, _ := lookup.GetProvider(tc.name)
+			result, err := provider.Execute(context.Background(), "example.com")
+			output := outputOf(result)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			mu.Lock()
+			seen := append([]lookup.RRType(nil), gotTypes...)
+			mu.Unlock()
+			if len(seen) != 1 || seen[0] != tc.qtype {
+				t.Errorf("server saw query types %v, want [%s]", seen, tc.qtype)
+			}
+			want := "example.com.\t300\tIN\tA\t192.0.2.10"
+			if output != want {
+				t.Errorf("Execute() output = %q, want %q", output, want)
+			}
+		})
+	}
+}
+
+func TestNativeDNSProvider_NoAnswer(t *testing.T) {
+	addr := startDNSServer(t, func(q *lookup.Message, tcp bool) *lookup.Message {
+		if strings.HasPrefix(q.Question[0].Name, "missing.") {
+			soa := lookup.RR{Name: "example.com.", Type: lookup.TypeSOA, Class: lookup.ClassINET, TTL: 3600,
+				Data: &lookup.SOARecord{MName: "ns1.example.com.", RName: "admin.example.com.", Serial: 7, Refresh: 3600, Retry: 600, Expire: 86400, Minimum: 300}}
+			return &lookup.Message{Header: lookup.Header{Rcode: lookup.RcodeNameError}, Authority: []lookup.RR{soa}}
+		}
+		return &lookup.Message{}
+	})
+	useResolver(t, addr)
+	provider, _ := lookup.GetProvider("DNS (MX)")
+
+	result, err := provider.Execute(context.Background(), "nodata.example.com")
+	output := outputOf(result)
+	if err != nil || output != "(No results found)" {
+		t.Errorf("Execute() = %q, %v; want (No results found)", output, err)
+	}
+	if result.NegativeTTL != 0 {
+		t.Errorf("NegativeTTL = %d without an SOA, want 0", result.NegativeTTL)
+	}
+	result, err = provider.Execute(context.Background(), "missing.example.com")
+	output = outputOf(result)
+	if err != nil || output != "(No results found: NXDOMAIN)" {
+		t.Errorf("Execute() = %q, %v; want NXDOMAIN notice", output, err)
+	}
+	if result.NegativeTTL != 300 {
+		t.Errorf("NegativeTTL = %d, want the SOA minimum of 300", result.NegativeTTL)
+	}
+}
+
+func TestNativeDNSProvider_ServerFailure(t *testing.T) {
+	addr := startDNSServer(t, func(q *lookup.Message, tcp bool) *lookup.Message {
+		return &lookup.Message{Header: lookup.Header{Rcode: lookup.RcodeServerFailure}}
+	})
+	useResolver(t, addr)
+	provider, _ := lookup.GetProvider("DNS (A)")
+
+	_, err := provider.Execute(context.Background(), "broken.example.com")
+	if err == nil || !strings.Contains(err.Error(), "SERVFAIL") {
+		t.Errorf("Execute() error = %v, want SERVFAIL error", err)
+	}
+}
+
+func TestNativeDNSProvider_Server(t *testing.T) {
+	chosen := startDNSServer(t, func(q *lookup.Message, tcp bool) *lookup.Message {
+		return &lookup.Message{Answer: []lookup.RR{aRecord(q.Question[0].Name, "192.0.2.53", 300)}}
+	})
+	useResolver(t, "192.0.2.1:1") // Must not be queried
+	provider, _ := lookup.GetProvider("DNS (A)")
+
+	result, err := provider.Execute(lookup.WithServer(context.Background(), chosen), "example.com")
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if !strings.Contains(result.Stdout, "192.0.2.53") || result.Server != chosen {
+		t.Errorf("Execute() = (%q, server %q), want the answer from %s", result.Stdout, result.Server, chosen)
+	}
+}
+
+func TestNativeDNSProvider_PTRReversesAddresses(t *testing.T) {
+	var mu sync.Mutex
+	var gotName string
+	addr := startDNSServer(t, func(q *lookup.Message, tcp bool) *lookup.Message {
+		mu.Lock()
+		gotName = q.Question[0].Name
+		mu.Unlock()
+		return &lookup.Message{Answer: []lookup.RR{{
+			Name: q.Question[0].Name, Type: lookup.TypePTR, Class: lookup.ClassINET, TTL: 300,
+			Data: &lookup.PTRRecord{Ptr: "host.example.com."},
+		}}}
+	})
+	useResolver(t, addr)
+	provider, _ := lookup.GetProvider("DNS (PTR)")
+
+	result, err := provider.Execute(context.Background(), "2001:db8::1")
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !strings.HasSuffix(gotName, ".8.b.d.0.1.0.0.2.ip6.arpa.") {
+		t.Errorf("queried %q, want the ip6.arpa name", gotName)
+	}
+	if !strings.Contains(result.Stdout, "host.example.com.") {
+		t.Errorf("Execute() output = %q, want the PTR target", result.Stdout)
+	}
+}
+
+func TestNativeDNSProvider_RecordView(t *testing.T) {
+	addr := startDNSServer(t, func(q *lookup.Message, tcp bool) *lookup.Message {
+		srv := func(prio, weight uint16, target string) lookup.RR {
+			return lookup.RR{Name: q.Question[0].Name, Type: lookup.TypeSRV, Class: lookup.ClassINET, TTL: 300,
+				Data: &lookup.SRVRecord{Priority: prio, Weight: weight, Port: 443, Target: target}}
+		}
+		return &lookup.Message{Answer: []lookup.RR{srv(20, 0, "c.example.com."), srv(10, 5, "b.example.com."), srv(10, 50, "a.example.com.")}}
+	})
+	useResolver(t, addr)
+	provider, _ := lookup.GetProvider("DNS (SRV)")
+
+	result, err := provider.Execute(context.Background(), "_https._tcp.example.com")
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	view, ok := result.Details.(*lookup.RecordView)
+	if !ok {
+		t.Fatalf("Details = %T, want *lookup.RecordView", result.Details)
+	}
+	var targets []string
+	for _, rr := range view.Records {
+		targets = append(targets, rr.Data.(*lookup.SRVRecord).Target)
+	}
+	if got := strings.Join(targets, " "); got != "a.example.com. b.example.com. c.example.com." {
+		t.Errorf("sorted targets = %s, want a, b, c", got)
+	}
+}
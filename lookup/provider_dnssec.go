@@ -0,0 +1,667 @@
+package lookup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DNSSECProvider validates the chain of trust of a name: from the root
+// trust anchors, through the DS and DNSKEY records of every zone on the way
+// down, to the signature over the name's own records. It asks the resolver
+// with checking disabled, so it sees bogus data a validating resolver would
+// hide behind SERVFAIL, and checks every signature itself.
+type DNSSECProvider struct{}
+
+func (p *DNSSECProvider) Name() string {
+	return "DNSSEC"
+}
+
+func (p *DNSSECProvider) FlagName() string {
+	return "dnssec"
+}
+
+func (p *DNSSECProvider) Usage() string {
+	return fmt.Sprintf("Run %s (chain of trust validation) on domains from <filename>", p.Name())
+}
+
+func (p *DNSSECProvider) CheckAvailability() bool {
+	return true
+}
+
+// Accepts reports that validation takes domains and reverse DNS names.
+func (p *DNSSECProvider) Accepts(kind InputKind) bool {
+	return kind == KindDomain || kind == KindReverse
+}
+
+// DNSSECStatus is the validation state of a link, as defined in RFC 4033
+// (section 5).
+type DNSSECStatus string
+
+const (
+	// DNSSECSecure means the link is signed and validates from the anchor.
+	DNSSECSecure DNSSECStatus = "secure"
+	// DNSSECInsecure means the parent proves nothing: it proves with signed
+	// NSEC or NSEC3 records that there is no DS record for the zone, or has
+	// none with an algorithm this package supports.
+	DNSSECInsecure DNSSECStatus = "insecure"
+	// DNSSECBogus means the link should validate but does not.
+	DNSSECBogus DNSSECStatus = "bogus"
+)
+
+// DNSSECReport is the Details of a DNSSEC validation.
+type DNSSECReport struct {
+	Name string `json:"name"`
+	// Status is that of the first link that is not secure, or secure if all
+	// of them are.
+	Status DNSSECStatus `json:"status"`
+	// Links holds the root zone first, then each zone on the way down, and
+	// last the RRset of the name itself if the chain got that far.
+	Links []DNSSECLink `json:"links"`
+}
+
+// DNSSECLink is one zone of the chain, or the name's own RRset.
+type DNSSECLink struct {
+	Zone string `json:"zone"`
+	// RRType is the type of the records validated by the final link; it is
+	// empty for zones.
+	RRType     string            `json:"rr_type,omitempty"`
+	Status     DNSSECStatus      `json:"status"`
+	DS         []DNSSECDS        `json:"ds,omitempty"`
+	Keys       []DNSSECKey       `json:"keys,omitempty"`
+	Signatures []DNSSECSignature `json:"signatures,omitempty"`
+	// Problems explain a bogus link; Notes explain an insecure one.
+	Problems []string `json:"problems,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	Notes    []string `json:"notes,omitempty"`
+}
+
+// DNSSECDS is a DS record of a zone, or a trust anchor for the root.
+type DNSSECDS struct {
+	KeyTag     uint16 `json:"key_tag"`
+	Algorithm  string `json:"algorithm"`
+	DigestType string `json:"digest_type"`
+	// Matches is set when a DNSKEY of the zone has this DS's digest.
+	Matches bool `json:"matches"`
+}
+
+// DNSSECKey is a DNSKEY of a zone.
+type DNSSECKey struct {
+	KeyTag    uint16 `json:"key_tag"`
+	Role      string `json:"role"`
+	Algorithm string `json:"algorithm"`
+	Bits      int    `json:"bits"`
+}
+
+// DNSSECSignature is an RRSIG over one of the link's RRsets.
+type DNSSECSignature struct {
+	Covers    string    `json:"covers"`
+	KeyTag    uint16    `json:"key_tag"`
+	Algorithm string    `json:"algorithm"`
+	Inception time.Time `json:"inception"`
+	Expires   time.Time `json:"expires"`
+	Valid     bool      `json:"valid"`
+}
+
+// Summary gives the overall status and the path of the chain.
+func (r *DNSSECReport) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-14s %s\n", "Name:", r.Name)
+	zones := make([]string, len(r.Links))
+	warnings := 0
+	for i, l := range r.Links {
+		zones[i] = l.Zone
+		if l.RRType != "" {
+			zones[i] += " " + l.RRType
+		}
+		warnings += len(l.Warnings)
+	}
+	fmt.Fprintf(&b, "%-14s %s\n", "Chain:", strings.Join(zones, " → "))
+	if warnings > 0 {
+		fmt.Fprintf(&b, "%-14s %d\n", "Warnings:", warnings)
+	}
+	status := string(r.Status)
+	for _, l := range r.Links {
+		if l.Status == r.Status && r.Status != DNSSECSecure {
+			reasons := l.Problems
+			if r.Status == DNSSECInsecure {
+				reasons = l.Notes
+			}
+			if len(reasons) > 0 {
+				status += " at " + l.Zone + ": " + reasons[0]
+			}
+			break
+		}
+	}
+	fmt.Fprintf(&b, "%-14s %s", "Status:", status)
+	return b.String()
+}
+
+// Tree renders the chain one link per level, each with its DS records,
+// keys and signatures, then its problems ("✗"), warnings ("!") and notes.
+func (r *DNSSECReport) Tree() string {
+	var b strings.Builder
+	for depth, l := range r.Links {
+		indent := strings.Repeat("  ", depth)
+		mark := "✓"
+		switch l.Status {
+		case DNSSECInsecure:
+			mark = "-"
+		case DNSSECBogus:
+			mark = "✗"
+		}
+		title := l.Zone
+		if l.RRType != "" {
+			title += " " + l.RRType
+		}
+		fmt.Fprintf(&b, "%s%s %s  %s\n", indent, mark, title, l.Status)
+		detail := indent + "    "
+		for _, ds := range l.DS {
+			source := ""
+			if depth == 0 {
+				source = " (trust anchor)"
+			}
+			fmt.Fprintf(&b, "%sDS      %d %s %s %s%s\n", detail, ds.KeyTag, ds.Algorithm, ds.DigestType, checkMark(ds.Matches), source)
+		}
+		for _, k := range l.Keys {
+			fmt.Fprintf(&b, "%sDNSKEY  %s %d %s %d bits\n", detail, k.Role, k.KeyTag, k.Algorithm, k.Bits)
+		}
+		for _, s := range l.Signatures {
+			fmt.Fprintf(&b, "%sRRSIG   %s by %d %s, valid until %s %s\n", detail, s.Covers, s.KeyTag, s.Algorithm, s.Expires.Format("2006-01-02 15:04"), checkMark(s.Valid))
+		}
+		for _, p := range l.Problems {
+			fmt.Fprintf(&b, "%s✗ %s\n", detail, p)
+		}
+		for _, w := range l.Warnings {
+			fmt.Fprintf(&b, "%s! %s\n", detail, w)
+		}
+		for _, n := range l.Notes {
+			fmt.Fprintf(&b, "%s- %s\n", detail, n)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func checkMark(ok bool) string {
+	if ok {
+		return "✓"
+	}
+	return "✗"
+}
+
+// Execute validates the chain of trust from the root down to domain. If a
+// query fails, the links validated so far are returned with the error.
+func (p *DNSSECProvider) Execute(ctx context.Context, domain string) (*Result, error) {
+	chosen := ServerFromContext(ctx)
+	v := &dnssecValidator{ctx: ctx, r: resolverFor(chosen), now: DNSSECClock()}
+	report := &DNSSECReport{Name: Fqdn(domain), Status: DNSSECSecure}
+	err := v.validate(report)
+	return &Result{Details: report, Records: v.records, Stdout: report.Tree(), Server: chosen}, err
+}
+
+// validate adds the links of report.Name's chain to report, stopping at the
+// first link that is not secure.
+func (v *dnssecValidator) validate(report *DNSSECReport) error {
+	zones, err := v.zoneCuts(report.Name)
+	if err != nil {
+		return err
+	}
+	var keys []*DNSKEYRecord
+	for i, zone := range zones {
+		var link DNSSECLink
+		if i == 0 {
+			link, keys, err = v.validateZone(zone, "", RootTrustAnchors, nil)
+		} else {
+			link, keys, err = v.validateZone(zone, zones[i-1], nil, keys)
+		}
+		if err != nil {
+			return err
+		}
+		report.add(link)
+		if link.Status != DNSSECSecure {
+			return nil
+		}
+	}
+	link, err := v.validateAnswer(report.Name, zones[len(zones)-1], keys)
+	if err != nil {
+		return err
+	}
+	if link != nil {
+		report.add(*link)
+	} else {
+		last := &report.Links[len(report.Links)-1]
+		last.Notes = append(last.Notes, fmt.Sprintf("%s has no A records to validate", report.Name))
+	}
+	return nil
+}
+
+// add appends link, which sets the report's status if it is the first link
+// that is not secure.
+func (r *DNSSECReport) add(link DNSSECLink) {
+	if r.Status == DNSSECSecure {
+		r.Status = link.Status
+	}
+	r.Links = append(r.Links, link)
+}
+
+// dnssecValidator holds the state of one validation.
+type dnssecValidator struct {
+	ctx context.Context
+	r   *Resolver
+	now time.Time
+	// records collects the DS, DNSKEY, RRSIG and final records seen.
+	records []RR
+}
+
+// query asks for name and t with the DNSSEC OK bit, so signatures are
+// returned, and checking disabled, so bogus data is too.
+func (v *dnssecValidator) query(name string, t RRType) (*Message, error) {
+	q := NewQuery(name, t)
+	q.CheckingDisabled = true
+	q.SetEDNS0(max(v.r.UDPSize, 1232), true)
+	resp, server, err := v.r.Exchange(v.ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("%s query for %s failed: %w", t, name, err)
+	}
+	if resp.Rcode != RcodeSuccess && resp.Rcode != RcodeNameError {
+		return resp, &RcodeError{Name: Fqdn(name), Server: server, Rcode: resp.Rcode}
+	}
+	return resp, nil
+}
+
+// rrset returns the records of type t owned by name in resp's answer and
+// the signatures over them.
+func (v *dnssecValidator) rrset(resp *Message, name string, t RRType) ([]RR, []*RRSIGRecord) {
+	var rrs []RR
+	var sigs []*RRSIGRecord
+	for _, rr := range resp.Answer {
+		if !strings.EqualFold(rr.Name, name) {
+			continue
+		}
+		if rr.Type == t {
+			rrs = append(rrs, rr)
+			v.records = append(v.records, rr)
+		} else if sig, ok := rr.Data.(*RRSIGRecord); ok && sig.TypeCovered == t {
+			sigs = append(sigs, sig)
+			v.records = append(v.records, rr)
+		}
+	}
+	return rrs, sigs
+}
+
+// zoneCuts lists the root and every zone apex on the way down to name,
+// found by asking each ancestor of name for its SOA record.
+func (v *dnssecValidator) zoneCuts(name string) ([]string, error) {
+	zones := []string{"."}
+	labels := strings.Split(strings.TrimSuffix(name, "."), ".")
+	if name == "." {
+		return zones, nil
+	}
+	for i := len(labels) - 1; i >= 0; i-- {
+		ancestor := strings.Join(labels[i:], ".") + "."
+		resp, err := v.query(ancestor, TypeSOA)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Rcode == RcodeNameError {
+			break
+		}
+		if hasOwnRecords(resp.Answer, ancestor, TypeSOA) {
+			zones = append(zones, ancestor)
+		}
+	}
+	return zones, nil
+}
+
+// validateZone validates one link: the DS RRset of zone, signed by the keys
+// of parent (or the trust anchors for the root), and the zone's DNSKEY
+// RRset, signed by a key the DS records vouch for. It returns the zone's
+// keys when the link is secure.
+func (v *dnssecValidator) validateZone(zone, parent string, anchors []DSRecord, parentKeys []*DNSKEYRecord) (DNSSECLink, []*DNSKEYRecord, error) {
+	link := DNSSECLink{Zone: zone, Status: DNSSECSecure}
+	dsSet := anchors
+	if parent != "" {
+		resp, err := v.query(zone, TypeDS)
+		if err != nil {
+			return link, nil, err
+		}
+		rrs, sigs := v.rrset(resp, zone, TypeDS)
+		if len(rrs) == 0 {
+			// The missing DS records must be proven missing, or anyone on
+			// the path could strip them to make a signed zone insecure.
+			proof, ok := v.proveNoDS(&link, resp, zone, parent, parentKeys)
+			if !ok {
+				link.Status = DNSSECBogus
+				return link, nil, nil
+			}
+			link.Status = DNSSECInsecure
+			link.Notes = append(link.Notes, fmt.Sprintf("no DS record for %s in %s, as %s proves: the zone is not signed, or its delegation is not secured", zone, parent, proof))
+			return link, nil, nil
+		}
+		if !v.checkSignatures(&link, rrs, sigs, parent, parentKeys) {
+			link.Status = DNSSECBogus
+			return link, nil, nil
+		}
+		for _, rr := range rrs {
+			dsSet = append(dsSet, *rr.Data.(*DSRecord))
+		}
+	}
+
+	resp, err := v.query(zone, TypeDNSKEY)
+	if err != nil {
+		return link, nil, err
+	}
+	rrs, sigs := v.rrset(resp, zone, TypeDNSKEY)
+	var keys []*DNSKEYRecord
+	for _, rr := range rrs {
+		key := rr.Data.(*DNSKEYRecord)
+		keys = append(keys, key)
+		role := "ZSK"
+		if key.IsSEP() {
+			role = "KSK"
+		}
+		link.Keys = append(link.Keys, DNSSECKey{KeyTag: key.KeyTag(), Role: role, Algorithm: dnssecAlgorithmName(key.Algorithm), Bits: keyBits(key)})
+		if deprecatedAlgorithms[key.Algorithm] {
+			link.Warnings = append(link.Warnings, fmt.Sprintf("key %d uses %s, which RFC 8624 says must not be used for signing", key.KeyTag(), dnssecAlgorithmName(key.Algorithm)))
+		}
+		if bits := keyBits(key); key.Algorithm != 13 && key.Algorithm != 14 && key.Algorithm != 15 && bits < 2048 {
+			link.Warnings = append(link.Warnings, fmt.Sprintf("key %d is a %d-bit RSA key; 2048 bits or more are recommended", key.KeyTag(), bits))
+		}
+	}
+
+	// The DS records (or anchors) select the keys allowed to sign the
+	// DNSKEY RRset.
+	var trusted []*DNSKEYRecord
+	supported := false
+	for _, ds := range dsSet {
+		entry := DNSSECDS{KeyTag: ds.KeyTag, Algorithm: dnssecAlgorithmName(ds.Algorithm), DigestType: digestTypeName(ds.DigestType)}
+		if supportedAlgorithms[ds.Algorithm] && supportedDigests[ds.DigestType] {
+			supported = true
+		}
+		tagFound := false
+		for _, key := range keys {
+			if key.KeyTag() != ds.KeyTag || key.Algorithm != ds.Algorithm {
+				continue
+			}
+			tagFound = true
+			if dsMatches(&ds, zone, key) {
+				entry.Matches = true
+				trusted = append(trusted, key)
+			}
+		}
+		switch {
+		case entry.Matches:
+		case tagFound && supportedDigests[ds.DigestType]:
+			link.Problems = append(link.Problems, fmt.Sprintf("DS %d: the digest does not match DNSKEY %d", ds.KeyTag, ds.KeyTag))
+		case !tagFound:
+			link.Warnings = append(link.Warnings, fmt.Sprintf("DS %d: no DNSKEY with this key tag and algorithm", ds.KeyTag))
+		}
+		if ds.DigestType == 1 {
+			link.Warnings = append(link.Warnings, fmt.Sprintf("DS %d uses a SHA-1 digest; SHA-256 is recommended", ds.KeyTag))
+		}
+		link.DS = append(link.DS, entry)
+	}
+	switch {
+	case !supported:
+		link.Status = DNSSECInsecure
+		link.Notes = append(link.Notes, "no DS record uses an algorithm and digest this validator supports")
+		return link, nil, nil
+	case len(keys) == 0:
+		link.Status = DNSSECBogus
+		link.Problems = append(link.Problems, "the parent has DS records but the zone serves no DNSKEY records")
+		return link, nil, nil
+	case len(trusted) == 0:
+		link.Status = DNSSECBogus
+		link.Problems = append(link.Problems, "no DNSKEY matches a DS record")
+		return link, nil, nil
+	}
+	if !v.checkSignatures(&link, rrs, sigs, zone, trusted) {
+		link.Status = DNSSECBogus
+		return link, nil, nil
+	}
+	// A digest that does not match is a problem even when another DS vouches
+	// for the zone, but it does not make the link bogus.
+	if len(link.Problems) > 0 {
+		link.Warnings = append(link.Warnings, link.Problems...)
+		link.Problems = nil
+	}
+	return link, keys, nil
+}
+
+// denialSet is an NSEC or NSEC3 RRset of an authority section, with the
+// signatures over it.
+type denialSet struct {
+	rrs  []RR
+	sigs []*RRSIGRecord
+}
+
+// hashedDenial is an NSEC3 record with the hash its owner name holds.
+type hashedDenial struct {
+	owner []byte
+	rec   *NSEC3Record
+	set   *denialSet
+}
+
+// proveNoDS looks in the authority section of resp, the parent's answer
+// without DS records for zone, for NSEC or NSEC3 records signed with
+// parentKeys that prove zone is a delegation without any (RFC 4035, section
+// 5.2, and RFC 5155, sections 8.6 and 8.9). It returns what proves it, or
+// adds to link's problems why nothing does.
+func (v *dnssecValidator) proveNoDS(link *DNSSECLink, resp *Message, zone, parent string, parentKeys []*DNSKEYRecord) (string, bool) {
+	sets := make(map[string]*denialSet)
+	var keys []string
+	for _, rr := range resp.Authority {
+		t := rr.Type
+		sig, isSig := rr.Data.(*RRSIGRecord)
+		if isSig {
+			t = sig.TypeCovered
+		}
+		if t != TypeNSEC && t != TypeNSEC3 {
+			continue
+		}
+		key := strings.ToLower(Fqdn(rr.Name)) + " " + t.String()
+		if sets[key] == nil {
+			sets[key] = &denialSet{}
+			keys = append(keys, key)
+		}
+		if isSig {
+			sets[key].sigs = append(sets[key].sigs, sig)
+		} else {
+			sets[key].rrs = append(sets[key].rrs, rr)
+		}
+		v.records = append(v.records, rr)
+	}
+	signed := func(set *denialSet) bool {
+		return v.checkSignatures(link, set.rrs, set.sigs, parent, parentKeys)
+	}
+	// The record at zone's own name must show a delegation: NS records,
+	// but neither DS records nor the SOA record of the zone's apex.
+	delegation := func(kind string, hasType func(RRType) bool) bool {
+		switch {
+		case hasType(TypeDS):
+			link.Problems = append(link.Problems, fmt.Sprintf("the %s record for %s lists DS records, but none were returned", kind, zone))
+		case hasType(TypeSOA) || !hasType(TypeNS):
+			link.Problems = append(link.Problems, fmt.Sprintf("the %s record for %s does not show a delegation from %s", kind, zone, parent))
+		default:
+			return true
+		}
+		return false
+	}
+
+	if set := sets[strings.ToLower(zone)+" NSEC"]; set != nil && len(set.rrs) > 0 {
+		nsec, ok := set.rrs[0].Data.(*NSECRecord)
+		if !ok {
+			link.Problems = append(link.Problems, fmt.Sprintf("the NSEC record for %s is malformed", zone))
+			return "", false
+		}
+		if !signed(set) || !delegation("NSEC", nsec.HasType) {
+			return "", false
+		}
+		return "its NSEC record", true
+	}
+
+	var chain []hashedDenial
+	for _, key := range keys {
+		if !strings.HasSuffix(key, " NSEC3") {
+			continue
+		}
+		for _, rr := range sets[key].rrs {
+			label, rest, _ := strings.Cut(strings.ToLower(Fqdn(rr.Name)), ".")
+			owner, err := nsec3Encoding.DecodeString(strings.ToUpper(label))
+			if rec, ok := rr.Data.(*NSEC3Record); ok && err == nil && strings.EqualFold(Fqdn(rest), parent) {
+				chain = append(chain, hashedDenial{owner: owner, rec: rec, set: sets[key]})
+			}
+		}
+	}
+	// find returns the record whose owner is the hash of name or, without
+	// match, whose span covers it.
+	find := func(name string, match bool) *hashedDenial {
+		for i, d := range chain {
+			hash, err := nsec3Hash(name, d.rec)
+			if err != nil {
+				continue
+			}
+			if match && bytes.Equal(hash, d.owner) || !match && nsec3Covers(d.owner, d.rec, hash) {
+				return &chain[i]
+			}
+		}
+		return nil
+	}
+	if d := find(zone, true); d != nil {
+		if !signed(d.set) || !delegation("NSEC3", d.rec.HasType) {
+			return "", false
+		}
+		return "its NSEC3 record", true
+	}
+	// An unsigned delegation in an opt-out span has no record of its own.
+	// The proof is the record of its closest encloser, the nearest ancestor
+	// that exists, and an opt-out record covering the next closer name, the
+	// encloser's child on the way down to zone.
+	for next := zone; !strings.EqualFold(next, parent); {
+		_, encloser, _ := strings.Cut(next, ".")
+		encloser = Fqdn(encloser)
+		ce := find(encloser, true)
+		if ce == nil {
+			next = encloser
+			continue
+		}
+		cover := find(next, false)
+		if cover == nil || !cover.rec.OptOut() || ce.rec.HasType(TypeNS) && !ce.rec.HasType(TypeSOA) {
+			break
+		}
+		if !signed(ce.set) || cover.set != ce.set && !signed(cover.set) {
+			return "", false
+		}
+		return "the NSEC3 opt-out record covering it", true
+	}
+	link.Problems = append(link.Problems, fmt.Sprintf("no DS record for %s in %s, and no NSEC or NSEC3 record proves there is none: the DS records may have been stripped", zone, parent))
+	return "", false
+}
+
+// validateAnswer validates the records of name itself with the keys of
+// zone: its A records, or its SOA record if it is the zone apex and has no
+// A records. It returns nil if there are no such records to validate.
+func (v *dnssecValidator) validateAnswer(name, zone string, keys []*DNSKEYRecord) (*DNSSECLink, error) {
+	types := []RRType{TypeA}
+	if strings.EqualFold(name, zone) {
+		types = append(types, TypeSOA)
+	}
+	for _, t := range types {
+		resp, err := v.query(name, t)
+		if err != nil {
+			return nil, err
+		}
+		rrs, sigs := v.rrset(resp, name, t)
+		if len(rrs) == 0 {
+			continue
+		}
+		link := &DNSSECLink{Zone: name, RRType: t.String(), Status: DNSSECSecure}
+		if !v.checkSignatures(link, rrs, sigs, zone, keys) {
+			link.Status = DNSSECBogus
+		}
+		return link, nil
+	}
+	return nil, nil
+}
+
+// checkSignatures records the signatures in sigs over rrs made by signer and
+// reports whether one of them verifies with one of keys and is within its
+// validity period. Failures are added to link's problems, signatures about
+// to expire to its warnings.
+func (v *dnssecValidator) checkSignatures(link *DNSSECLink, rrs []RR, sigs []*RRSIGRecord, signer string, keys []*DNSKEYRecord) bool {
+	t := rrs[0].Type
+	valid := false
+	for _, sig := range sigs {
+		entry := DNSSECSignature{
+			Covers:    t.String(),
+			KeyTag:    sig.KeyTag,
+			Algorithm: dnssecAlgorithmName(sig.Algorithm),
+			Inception: sig.InceptionTime(),
+			Expires:   sig.ExpirationTime(),
+		}
+		var verifyErr error
+		switch {
+		case !strings.EqualFold(Fqdn(sig.SignerName), signer):
+			verifyErr = fmt.Errorf("signed by %s instead of %s", sig.SignerName, signer)
+		case !supportedAlgorithms[sig.Algorithm]:
+			verifyErr = fmt.Errorf("unsupported algorithm %s", dnssecAlgorithmName(sig.Algorithm))
+		default:
+			verifyErr = fmt.Errorf("no trusted key with tag %d", sig.KeyTag)
+			for _, key := range keys {
+				if key.KeyTag() == sig.KeyTag && key.Algorithm == sig.Algorithm {
+					if verifyErr = verifySignature(sig, key, rrs); verifyErr == nil {
+						break
+					}
+				}
+			}
+		}
+		switch {
+		case verifyErr != nil:
+			link.Problems = append(link.Problems, fmt.Sprintf("RRSIG %s by %d: %v", t, sig.KeyTag, verifyErr))
+		case v.now.Before(entry.Inception):
+			link.Problems = append(link.Problems, fmt.Sprintf("RRSIG %s by %d is not valid before %s", t, sig.KeyTag, entry.Inception.Format(time.RFC3339)))
+		case !v.now.Before(entry.Expires):
+			link.Problems = append(link.Problems, fmt.Sprintf("RRSIG %s by %d expired at %s", t, sig.KeyTag, entry.Expires.Format(time.RFC3339)))
+		default:
+			entry.Valid = true
+			valid = true
+			if left := entry.Expires.Sub(v.now); left < SignatureExpiryWarning {
+				link.Warnings = append(link.Warnings, fmt.Sprintf("RRSIG %s by %d expires in %s, at %s", t, sig.KeyTag, left.Round(time.Hour), entry.Expires.Format(time.RFC3339)))
+			}
+		}
+		link.Signatures = append(link.Signatures, entry)
+	}
+	if len(sigs) == 0 {
+		link.Problems = append(link.Problems, fmt.Sprintf("the %s RRset is not signed", t))
+	}
+	if valid {
+		// Other signatures that fail, such as those of a retired key, do not
+		// matter once one validates.
+		for _, s := range link.Signatures {
+			if !s.Valid && s.Covers == t.String() {
+				link.Warnings = append(link.Warnings, fmt.Sprintf("RRSIG %s by %d does not validate", t, s.KeyTag))
+			}
+		}
+		link.Problems = dropSignatureProblems(link.Problems, t)
+	}
+	return valid
+}
+
+// dropSignatureProblems removes the problems about signatures over RRsets
+// of type t.
+func dropSignatureProblems(problems []string, t RRType) []string {
+	var kept []string
+	prefix := "RRSIG " + t.String() + " "
+	for _, p := range problems {
+		if !strings.HasPrefix(p, prefix) {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+func init() {
+	RegisterProvider(&DNSSECProvider{})
+}
@@ -0,0 +1,298 @@
+package lookup_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"dlookup/lookup"
+)
+
+// readDNSSECFixture parses a file of testdata/dnssec.
+func readDNSSECFixture(t *testing.T, file string) []lookup.RR {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "dnssec", file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rrs, err := lookup.ParseDigAnswer(string(data))
+	if err != nil {
+		t.Fatalf("%s: %v", file, err)
+	}
+	return rrs
+}
+
+// useSignedZones serves the signed fixture zones from the system resolver,
+// trusts the fixture root key and sets the clock inside the signatures'
+// validity period.
+func useSignedZones(t *testing.T, now time.Time) {
+	t.Helper()
+	serveSignedZones(t, now, readDNSSECFixture(t, "zones.txt"), "anchor.txt")
+}
+
+// serveSignedZones serves zones from the system resolver, trusts the root
+// key of anchorFile and sets the clock to now. Answers without records carry
+// the NSEC and NSEC3 records of the parent of the name in their authority
+// section, as a signed zone's denial of existence does.
+func serveSignedZones(t *testing.T, now time.Time, zones []lookup.RR, anchorFile string) {
+	t.Helper()
+	useFakeDNS(t, map[string]dnsHandler{
+		"192.0.2.1:53": func(q *lookup.Message, tcp bool) *lookup.Message {
+			name, qtype := q.Question[0].Name, q.Question[0].Type
+			resp := &lookup.Message{Header: lookup.Header{Rcode: lookup.RcodeNameError}}
+			for _, rr := range zones {
+				if !strings.EqualFold(rr.Name, name) && !strings.HasSuffix(strings.ToLower(rr.Name), "."+strings.ToLower(name)) && name != "." {
+					continue
+				}
+				resp.Rcode = lookup.RcodeSuccess
+				if !strings.EqualFold(rr.Name, name) {
+					continue
+				}
+				if sig, ok := rr.Data.(*lookup.RRSIGRecord); rr.Type == qtype || ok && sig.TypeCovered == qtype {
+					resp.Answer = append(resp.Answer, rr)
+				}
+			}
+			if resp.Rcode != lookup.RcodeSuccess || len(resp.Answer) > 0 {
+				return resp
+			}
+			_, parent, _ := strings.Cut(strings.ToLower(name), ".")
+			for _, rr := range zones {
+				t := rr.Type
+				if sig, ok := rr.Data.(*lookup.RRSIGRecord); ok {
+					t = sig.TypeCovered
+				}
+				owner := strings.ToLower(rr.Name)
+				if (t == lookup.TypeNSEC || t == lookup.TypeNSEC3) && (parent == "" || owner == parent || strings.HasSuffix(owner, "."+parent)) {
+					resp.Authority = append(resp.Authority, rr)
+				}
+			}
+			return resp
+		},
+	})
+
+	var anchors []lookup.DSRecord
+	for _, rr := range readDNSSECFixture(t, anchorFile) {
+		anchors = append(anchors, *rr.Data.(*lookup.DSRecord))
+	}
+	origAnchors, origClock := lookup.RootTrustAnchors, lookup.DNSSECClock
+	lookup.RootTrustAnchors = anchors
+	lookup.DNSSECClock = func() time.Time { return now }
+	t.Cleanup(func() {
+		lookup.RootTrustAnchors = origAnchors
+		lookup.DNSSECClock = origClock
+	})
+}
+
+// fixtureNow is inside the validity period of every fixture signature.
+var fixtureNow = time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func validateDNSSEC(t *testing.T, name string) *lookup.DNSSECReport {
+	t.Helper()
+	provider, ok := lookup.GetProviderByFlagName("dnssec")
+	if !ok {
+		t.Fatal("dnssec provider not registered")
+	}
+	result, err := provider.Execute(context.Background(), name)
+	if err != nil {
+		t.Fatalf("Execute(%s) error = %v", name, err)
+	}
+	return result.Details.(*lookup.DNSSECReport)
+}
+
+func chain(report *lookup.DNSSECReport) string {
+	var links []string
+	for _, l := range report.Links {
+		links = append(links, strings.TrimSpace(l.Zone+" "+l.RRType)+"="+string(l.Status))
+	}
+	return strings.Join(links, " ")
+}
+
+func warnings(report *lookup.DNSSECReport) string {
+	var all []string
+	for _, l := range report.Links {
+		all = append(all, l.Warnings...)
+	}
+	return strings.Join(all, "\n")
+}
+
+func TestDNSSECProvider_Secure(t *testing.T) {
+	useSignedZones(t, fixtureNow)
+
+	report := validateDNSSEC(t, "www.secure.test")
+	want := ".=secure test.=secure secure.test.=secure www.secure.test. A=secure"
+	if report.Status != lookup.DNSSECSecure || chain(report) != want {
+		t.Fatalf("status = %s, chain = %s, want secure, %s", report.Status, chain(report), want)
+	}
+	if w := warnings(report); w != "" {
+		t.Errorf("warnings = %s, want none", w)
+	}
+	root := report.Links[0]
+	if len(root.DS) != 1 || !root.DS[0].Matches || len(root.Keys) != 1 || root.Keys[0].Role != "KSK" || root.Keys[0].Bits != 2048 {
+		t.Errorf("root link = %+v, want the anchor matching one 2048-bit KSK", root)
+	}
+	if zone := report.Links[2]; len(zone.Keys) != 1 || zone.Keys[0].Algorithm != "ED25519 (15)" {
+		t.Errorf("secure.test. keys = %+v, want one ED25519 key", zone.Keys)
+	}
+	if !strings.Contains(report.Summary(), "secure.test. → www.secure.test. A") {
+		t.Errorf("Summary() =\n%s", report.Summary())
+	}
+
+	apex := validateDNSSEC(t, "secure.test")
+	if last := apex.Links[len(apex.Links)-1]; apex.Status != lookup.DNSSECSecure || last.RRType != "A" {
+		t.Errorf("secure.test status = %s, last link = %+v", apex.Status, last)
+	}
+}
+
+func TestDNSSECProvider_Insecure(t *testing.T) {
+	serveSignedZones(t, fixtureNow, readDNSSECFixture(t, "denial.txt"), "denial_anchor.txt")
+
+	tests := []struct {
+		name   string
+		chain  string
+		covers string
+		proof  string
+	}{
+		{"unsigned.nsec", ".=secure nsec.=secure unsigned.nsec.=insecure", "NSEC", "as its NSEC record proves"},
+		{"unsigned.nsec3", ".=secure nsec3.=secure unsigned.nsec3.=insecure", "NSEC3", "as its NSEC3 record proves"},
+		{"optout.nsec3", ".=secure nsec3.=secure optout.nsec3.=insecure", "NSEC3", "as the NSEC3 opt-out record covering it proves"},
+	}
+	for _, tt := range tests {
+		report := validateDNSSEC(t, tt.name)
+		if report.Status != lookup.DNSSECInsecure || chain(report) != tt.chain {
+			t.Errorf("%s: status = %s, chain = %s, want insecure, %s", tt.name, report.Status, chain(report), tt.chain)
+			continue
+		}
+		if want := "insecure at " + tt.name + ".: no DS record"; !strings.Contains(report.Summary(), want) || !strings.Contains(report.Summary(), tt.proof) {
+			t.Errorf("%s: Summary() =\n%s\nwant %q and %q", tt.name, report.Summary(), want, tt.proof)
+		}
+		last := report.Links[len(report.Links)-1]
+		if len(last.Signatures) == 0 || last.Signatures[0].Covers != tt.covers || !last.Signatures[0].Valid {
+			t.Errorf("%s: signatures = %+v, want a valid %s signature by the parent", tt.name, last.Signatures, tt.covers)
+		}
+	}
+}
+
+func TestDNSSECProvider_UnprovenDenial(t *testing.T) {
+	t.Run("stripped DS", func(t *testing.T) {
+		// An attacker removing the DS records of a signed zone must not make
+		// it insecure.
+		var zones []lookup.RR
+		for _, rr := range readDNSSECFixture(t, "zones.txt") {
+			sig, isSig := rr.Data.(*lookup.RRSIGRecord)
+			if rr.Name == "secure.test." && (rr.Type == lookup.TypeDS || isSig && sig.TypeCovered == lookup.TypeDS) {
+				continue
+			}
+			zones = append(zones, rr)
+		}
+		serveSignedZones(t, fixtureNow, zones, "anchor.txt")
+
+		for _, name := range []string{"secure.test", "insecure.test"} {
+			report := validateDNSSEC(t, name)
+			want := ".=secure test.=secure " + name + ".=bogus"
+			if report.Status != lookup.DNSSECBogus || chain(report) != want {
+				t.Errorf("%s: status = %s, chain = %s, want bogus, %s", name, report.Status, chain(report), want)
+				continue
+			}
+			if !strings.Contains(report.Summary(), "no NSEC or NSEC3 record proves there is none") {
+				t.Errorf("%s: Summary() =\n%s", name, report.Summary())
+			}
+		}
+	})
+
+	t.Run("bad proof", func(t *testing.T) {
+		serveSignedZones(t, fixtureNow, readDNSSECFixture(t, "denial.txt"), "denial_anchor.txt")
+
+		tests := []struct {
+			name    string
+			problem string
+		}{
+			{"forged.nsec", "RRSIG NSEC by"},
+			{"hasds.nsec", "the NSEC record for hasds.nsec. lists DS records"},
+		}
+		for _, tt := range tests {
+			report := validateDNSSEC(t, tt.name)
+			if want := ".=secure nsec.=secure " + tt.name + ".=bogus"; report.Status != lookup.DNSSECBogus || chain(report) != want {
+				t.Errorf("%s: status = %s, chain = %s, want bogus, %s", tt.name, report.Status, chain(report), want)
+				continue
+			}
+			last := report.Links[len(report.Links)-1]
+			if len(last.Problems) == 0 || !strings.Contains(last.Problems[0], tt.problem) {
+				t.Errorf("%s: problems = %q, want %q", tt.name, last.Problems, tt.problem)
+			}
+		}
+	})
+}
+
+func TestDNSSECProvider_Bogus(t *testing.T) {
+	useSignedZones(t, fixtureNow)
+
+	tests := []struct {
+		name    string
+		chain   string
+		problem string
+	}{
+		{"bogus.test", ".=secure test.=secure bogus.test.=secure bogus.test. A=bogus", "does not verify"},
+		{"mismatch.test", ".=secure test.=secure mismatch.test.=bogus", "the digest does not match"},
+	}
+	for _, tt := range tests {
+		report := validateDNSSEC(t, tt.name)
+		if report.Status != lookup.DNSSECBogus || chain(report) != tt.chain {
+			t.Errorf("%s: status = %s, chain = %s, want bogus, %s", tt.name, report.Status, chain(report), tt.chain)
+			continue
+		}
+		last := report.Links[len(report.Links)-1]
+		if len(last.Problems) == 0 || !strings.Contains(last.Problems[0], tt.problem) {
+			t.Errorf("%s: problems = %q, want %q", tt.name, last.Problems, tt.problem)
+		}
+		if !strings.Contains(report.Tree(), "✗ "+last.Problems[0]) {
+			t.Errorf("%s: Tree() =\n%s", tt.name, report.Tree())
+		}
+	}
+}
+
+func TestDNSSECProvider_Warnings(t *testing.T) {
+	useSignedZones(t, fixtureNow)
+
+	report := validateDNSSEC(t, "expiring.test")
+	if report.Status != lookup.DNSSECSecure || !strings.Contains(warnings(report), "expires in 72h0m0s") {
+		t.Errorf("expiring.test: status = %s, warnings = %q, want secure with the A signature expiring in 72h", report.Status, warnings(report))
+	}
+
+	report = validateDNSSEC(t, "legacy.test")
+	w := warnings(report)
+	for _, want := range []string{"uses RSASHA1", "1024-bit RSA key", "SHA-1 digest"} {
+		if !strings.Contains(w, want) {
+			t.Errorf("legacy.test warnings = %q, want %q", w, want)
+		}
+	}
+	if report.Status != lookup.DNSSECSecure {
+		t.Errorf("legacy.test status = %s, want secure", report.Status)
+	}
+}
+
+func TestDNSSECProvider_Expired(t *testing.T) {
+	useSignedZones(t, time.Date(2031, 2, 1, 0, 0, 0, 0, time.UTC))
+
+	report := validateDNSSEC(t, "secure.test")
+	if report.Status != lookup.DNSSECBogus || chain(report) != ".=bogus" {
+		t.Fatalf("status = %s, chain = %s, want bogus at the root", report.Status, chain(report))
+	}
+	if p := report.Links[0].Problems; len(p) == 0 || !strings.Contains(p[0], "expired at 2031-01-01") {
+		t.Errorf("root problems = %q, want an expired signature", p)
+	}
+}
+
+func TestDNSSECProvider_WrongTrustAnchor(t *testing.T) {
+	useSignedZones(t, fixtureNow)
+	anchor := lookup.RootTrustAnchors[0]
+	anchor.Digest = append([]byte{anchor.Digest[0] ^ 0xFF}, anchor.Digest[1:]...)
+	lookup.RootTrustAnchors = []lookup.DSRecord{anchor}
+
+	report := validateDNSSEC(t, "secure.test")
+	if report.Status != lookup.DNSSECBogus || len(report.Links) != 1 || report.Links[0].DS[0].Matches {
+		t.Errorf("status = %s, chain = %s, want bogus at the root", report.Status, chain(report))
+	}
+}
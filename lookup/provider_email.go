@@ -0,0 +1,497 @@
+package lookup
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// EmailProvider audits the DNS records that secure a domain's email: SPF
+// with its includes expanded, DMARC, DKIM keys of the selectors in
+// DKIMSelectors, MTA-STS, SMTP TLS reporting and BIMI.
+type EmailProvider struct{}
+
+func (p *EmailProvider) Name() string {
+	return "EMAIL"
+}
+
+func (p *EmailProvider) FlagName() string {
+	return "email"
+}
+
+func (p *EmailProvider) Usage() string {
+	return fmt.Sprintf("Run %s (SPF, DMARC, DKIM, MTA-STS, TLS-RPT and BIMI audit) on domains from <filename>", p.Name())
+}
+
+func (p *EmailProvider) CheckAvailability() bool {
+	return true
+}
+
+// Accepts reports that the audit takes domains only.
+func (p *EmailProvider) Accepts(kind InputKind) bool {
+	return kind == KindDomain
+}
+
+// EmailSeverity ranks the findings of an email audit.
+type EmailSeverity string
+
+const (
+	// EmailError is a misconfiguration that makes receivers reject or
+	// ignore the policy.
+	EmailError EmailSeverity = "error"
+	// EmailWarning is a weakness worth fixing.
+	EmailWarning EmailSeverity = "warning"
+	// EmailInfo notes what is not deployed or could not be checked.
+	EmailInfo EmailSeverity = "info"
+)
+
+// EmailFinding is a problem found by an email audit.
+type EmailFinding struct {
+	// Check is "SPF", "DMARC", "DKIM", "MTA-STS", "TLS-RPT" or "BIMI".
+	Check    string        `json:"check"`
+	Severity EmailSeverity `json:"severity"`
+	Message  string        `json:"message"`
+}
+
+// EmailReport is the Details of an email audit.
+type EmailReport struct {
+	Domain string `json:"domain"`
+	// SPF is the domain's SPF record with its includes, or nil if the TXT
+	// query failed.
+	SPF    *SPFNode   `json:"spf,omitempty"`
+	DMARC  *TagRecord `json:"dmarc,omitempty"`
+	DKIM   []DKIMKey  `json:"dkim,omitempty"`
+	MTASTS *TagRecord `json:"mta_sts,omitempty"`
+	TLSRPT *TagRecord `json:"tls_rpt,omitempty"`
+	BIMI   *TagRecord `json:"bimi,omitempty"`
+	// Selectors are the DKIM selectors that were tried.
+	Selectors []string       `json:"selectors"`
+	Findings  []EmailFinding `json:"findings"`
+}
+
+// TagRecord is a "tag=value; ..." record such as DMARC, MTA-STS, TLS-RPT
+// and BIMI publish.
+type TagRecord struct {
+	Name   string            `json:"name"`
+	Record string            `json:"record"`
+	Tags   map[string]string `json:"tags"`
+}
+
+// DKIMKey is the key published for a DKIM selector.
+type DKIMKey struct {
+	Selector string `json:"selector"`
+	Name     string `json:"name"`
+	Record   string `json:"record"`
+	KeyType  string `json:"key_type"`
+	Bits     int    `json:"bits,omitempty"`
+	// Revoked is set when the key is empty, which withdraws it.
+	Revoked bool `json:"revoked,omitempty"`
+}
+
+// Count returns how many findings have severity s.
+func (r *EmailReport) Count(s EmailSeverity) int {
+	n := 0
+	for _, f := range r.Findings {
+		if f.Severity == s {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *EmailReport) add(check string, severity EmailSeverity, format string, args ...any) {
+	r.Findings = append(r.Findings, EmailFinding{Check: check, Severity: severity, Message: fmt.Sprintf(format, args...)})
+}
+
+// Summary shows one line per check and counts the findings.
+func (r *EmailReport) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-14s %s\n", "Domain:", r.Domain)
+	spf := "(none)"
+	if r.SPF != nil && r.SPF.Record != "" {
+		spf = fmt.Sprintf("%s (%d of %d DNS lookups)", r.SPF.Record, r.SPF.Lookups(), SPFLookupLimit)
+	}
+	fmt.Fprintf(&b, "%-14s %s\n", "SPF:", spf)
+	fmt.Fprintf(&b, "%-14s %s\n", "DMARC:", tagSummary(r.DMARC, "p", "sp", "pct", "rua"))
+	var keys []string
+	for _, k := range r.DKIM {
+		switch {
+		case k.Revoked:
+			keys = append(keys, k.Selector+" (revoked)")
+		case k.Bits > 0:
+			keys = append(keys, fmt.Sprintf("%s (%s, %d bits)", k.Selector, k.KeyType, k.Bits))
+		default:
+			keys = append(keys, fmt.Sprintf("%s (%s)", k.Selector, k.KeyType))
+		}
+	}
+	dkim := strings.Join(keys, ", ")
+	if dkim == "" {
+		dkim = fmt.Sprintf("(none of %d selectors)", len(r.Selectors))
+	}
+	fmt.Fprintf(&b, "%-14s %s\n", "DKIM:", dkim)
+	fmt.Fprintf(&b, "%-14s %s\n", "MTA-STS:", tagSummary(r.MTASTS, "id"))
+	fmt.Fprintf(&b, "%-14s %s\n", "TLS-RPT:", tagSummary(r.TLSRPT, "rua"))
+	fmt.Fprintf(&b, "%-14s %s\n", "BIMI:", tagSummary(r.BIMI, "l", "a"))
+	fmt.Fprintf(&b, "%-14s errors: %d, warnings: %d, notes: %d", "Findings:", r.Count(EmailError), r.Count(EmailWarning), r.Count(EmailInfo))
+	return b.String()
+}
+
+func tagSummary(rec *TagRecord, tags ...string) string {
+	if rec == nil {
+		return "(none)"
+	}
+	var parts []string
+	for _, t := range tags {
+		if v, ok := rec.Tags[t]; ok {
+			parts = append(parts, t+"="+v)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Listing lists the records found, with the SPF includes indented under the
+// record that includes them, then the findings marked by severity: "✗"
+// for errors, "!" for warnings and "-" for notes.
+func (r *EmailReport) Listing() string {
+	var b strings.Builder
+	if r.SPF != nil {
+		writeSPFNode(&b, r.SPF, 0)
+	}
+	for _, rec := range []*TagRecord{r.DMARC, r.MTASTS, r.TLSRPT, r.BIMI} {
+		if rec != nil {
+			fmt.Fprintf(&b, "%s  %s\n", rec.Name, rec.Record)
+		}
+	}
+	for _, k := range r.DKIM {
+		fmt.Fprintf(&b, "%s  %s\n", k.Name, k.Record)
+	}
+	if len(r.Findings) > 0 {
+		b.WriteString("\n")
+	}
+	for _, f := range r.Findings {
+		mark := "-"
+		switch f.Severity {
+		case EmailError:
+			mark = "✗"
+		case EmailWarning:
+			mark = "!"
+		}
+		fmt.Fprintf(&b, "%s %s: %s\n", mark, f.Check, f.Message)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func writeSPFNode(b *strings.Builder, n *SPFNode, depth int) {
+	indent := strings.Repeat("  ", depth)
+	record := n.Record
+	if record == "" {
+		record = "(" + n.Error + ")"
+	}
+	if n.Via != "" {
+		fmt.Fprintf(b, "%s%s  %s\n", indent, n.Via, record)
+	} else {
+		fmt.Fprintf(b, "%s%s  %s\n", indent, n.Domain, record)
+	}
+	for _, c := range n.Children {
+		writeSPFNode(b, c, depth+1)
+	}
+}
+
+// Execute fetches the email records of domain with `DNS (TXT)` and checks
+// them. Only a failure of the first query, for the domain's own TXT
+// records, is an error; later failures are reported as findings.
+func (p *EmailProvider) Execute(ctx context.Context, domain string) (*Result, error) {
+	domain = strings.TrimSuffix(strings.TrimSpace(domain), ".")
+	txt := memoTXT(func(name string) ([]RR, error) {
+		return lookupRecords(ctx, TypeTXT, name)
+	})
+	report := &EmailReport{Domain: domain, Selectors: DKIMSelectors}
+	result := &Result{Details: report, Server: ServerFromContext(ctx)}
+
+	if _, err := txt(domain); err != nil {
+		return result, err
+	}
+	report.SPF = (&spfResolver{txt: txt}).fetchSPF(domain, "", nil)
+	checkSPF(report)
+
+	report.DMARC = fetchTagRecord(report, txt, "DMARC", "_dmarc."+domain, "v=DMARC1")
+	checkDMARC(report)
+	for _, selector := range report.Selectors {
+		fetchDKIM(report, txt, selector)
+	}
+	if len(report.DKIM) == 0 {
+		report.add("DKIM", EmailInfo, "no key found for the selectors tried (%s); keys under other selectors cannot be discovered", strings.Join(report.Selectors, ", "))
+	}
+	report.MTASTS = fetchTagRecord(report, txt, "MTA-STS", "_mta-sts."+domain, "v=STSv1")
+	report.TLSRPT = fetchTagRecord(report, txt, "TLS-RPT", "_smtp._tls."+domain, "v=TLSRPTv1")
+	checkMTASTS(report)
+	report.BIMI = fetchTagRecord(report, txt, "BIMI", "default._bimi."+domain, "v=BIMI1")
+	checkBIMI(report)
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	result.Stdout = report.Listing()
+	return result, nil
+}
+
+// fetchTagRecord returns the record at name that starts with version, or
+// nil if there is none. A failed query or more than one such record is
+// reported as an error of check.
+func fetchTagRecord(report *EmailReport, txt func(string) ([]string, error), check, name, version string) *TagRecord {
+	texts, err := txt(name)
+	if err != nil {
+		report.add(check, EmailError, "lookup of %s failed: %v", name, err)
+		return nil
+	}
+	var records []string
+	for _, t := range texts {
+		if hasVersion(t, version) {
+			records = append(records, t)
+		}
+	}
+	if len(records) > 1 {
+		report.add(check, EmailError, "%s has %d %s records; receivers ignore them all", name, len(records), version)
+		return nil
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	tags, _ := parseTags(records[0])
+	return &TagRecord{Name: name, Record: records[0], Tags: tags}
+}
+
+func checkSPF(report *EmailReport) {
+	spf := report.SPF
+	if spf.Record == "" {
+		if spf.Error == "no SPF record" {
+			report.add("SPF", EmailError, "%s has no SPF record, so any server may send as it", spf.Domain)
+		} else {
+			report.add("SPF", EmailError, "%s: %s", spf.Domain, spf.Error)
+		}
+		return
+	}
+	if n := spf.Lookups(); n > SPFLookupLimit {
+		report.add("SPF", EmailError, "%d DNS lookups, more than the %d allowed; receivers fail the check (permerror)", n, SPFLookupLimit)
+	} else if n >= SPFLookupLimit-2 {
+		report.add("SPF", EmailWarning, "%d of the %d allowed DNS lookups used", n, SPFLookupLimit)
+	}
+	var walk func(n *SPFNode)
+	walk = func(n *SPFNode) {
+		for _, c := range n.Children {
+			if c.Error != "" {
+				report.add("SPF", EmailError, "%s: %s", c.Via, c.Error)
+			}
+			walk(c)
+		}
+		for _, t := range n.Terms {
+			switch {
+			case t.Name == "ptr" && !t.Modifier:
+				report.add("SPF", EmailWarning, "%s uses the ptr mechanism, which RFC 7208 says not to use", n.Domain)
+			case strings.Contains(t.Value, "%"):
+				report.add("SPF", EmailInfo, "%s uses the macro %s, which is not expanded here", n.Domain, t)
+			}
+		}
+	}
+	if spf.Error != "" {
+		report.add("SPF", EmailError, "%s", spf.Error)
+	}
+	walk(spf)
+
+	var all *SPFTerm
+	redirect := false
+	for i, t := range spf.Terms {
+		switch {
+		case t.Modifier:
+			redirect = redirect || t.Name == "redirect"
+		case all != nil:
+			report.add("SPF", EmailWarning, "%s comes after %s and is never evaluated", t, all)
+		case t.Name == "all":
+			all = &spf.Terms[i]
+		}
+	}
+	switch {
+	case all == nil && !redirect:
+		report.add("SPF", EmailWarning, "no \"all\" mechanism; mail from other servers gets a neutral result")
+	case all == nil:
+	case all.Qualifier == "+":
+		report.add("SPF", EmailError, "\"+all\" lets any server send as %s", spf.Domain)
+	case all.Qualifier == "?":
+		report.add("SPF", EmailWarning, "\"?all\" gives mail from other servers a neutral result")
+	}
+}
+
+var dmarcPolicies = map[string]bool{"none": true, "quarantine": true, "reject": true}
+
+func checkDMARC(report *EmailReport) {
+	d := report.DMARC
+	if d == nil {
+		if !hasFinding(report, "DMARC") {
+			report.add("DMARC", EmailError, "_dmarc.%s has no DMARC record; receivers apply their own policy", report.Domain)
+		}
+		return
+	}
+	p, ok := d.Tags["p"]
+	switch {
+	case !ok:
+		report.add("DMARC", EmailError, "no p= tag; the record is invalid")
+	case !dmarcPolicies[strings.ToLower(p)]:
+		report.add("DMARC", EmailError, "p=%s is not none, quarantine or reject", p)
+	case strings.EqualFold(p, "none"):
+		report.add("DMARC", EmailWarning, "p=none only monitors; failing mail is still delivered")
+	}
+	if sp, ok := d.Tags["sp"]; ok && !dmarcPolicies[strings.ToLower(sp)] {
+		report.add("DMARC", EmailError, "sp=%s is not none, quarantine or reject", sp)
+	}
+	if pct, ok := d.Tags["pct"]; ok {
+		if n, err := strconv.Atoi(pct); err != nil || n < 0 || n > 100 {
+			report.add("DMARC", EmailError, "pct=%s is not a percentage", pct)
+		} else if n < 100 {
+			report.add("DMARC", EmailWarning, "pct=%d applies the policy to only part of the failing mail", n)
+		}
+	}
+	for _, tag := range []string{"adkim", "aspf"} {
+		if v, ok := d.Tags[tag]; ok && v != "r" && v != "s" {
+			report.add("DMARC", EmailError, "%s=%s is not r or s", tag, v)
+		}
+	}
+	if _, ok := d.Tags["rua"]; !ok {
+		report.add("DMARC", EmailWarning, "no rua= tag, so no aggregate reports are sent")
+	}
+	for _, tag := range []string{"rua", "ruf"} {
+		for _, uri := range splitList(d.Tags[tag]) {
+			if !strings.HasPrefix(strings.ToLower(uri), "mailto:") {
+				report.add("DMARC", EmailWarning, "%s=%s is not a mailto: URI", tag, uri)
+			}
+		}
+	}
+	if report.SPF != nil {
+		for _, t := range report.SPF.Terms {
+			if t.Name == "all" && t.Qualifier == "~" && strings.EqualFold(p, "none") {
+				report.add("SPF", EmailInfo, "\"~all\" with DMARC p=none lets spoofed mail through")
+			}
+		}
+	}
+}
+
+func hasFinding(report *EmailReport, check string) bool {
+	for _, f := range report.Findings {
+		if f.Check == check {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// fetchDKIM looks up the key of selector and checks it.
+func fetchDKIM(report *EmailReport, txt func(string) ([]string, error), selector string) {
+	name := selector + "._domainkey." + report.Domain
+	texts, err := txt(name)
+	if err != nil {
+		report.add("DKIM", EmailError, "lookup of %s failed: %v", name, err)
+		return
+	}
+	for _, t := range texts {
+		tags, _ := parseTags(t)
+		p, hasKey := tags["p"]
+		if v, ok := tags["v"]; (ok && v != "DKIM1") || !hasKey {
+			continue
+		}
+		key := DKIMKey{Selector: selector, Name: name, Record: t, KeyType: strings.ToLower(tags["k"])}
+		if key.KeyType == "" {
+			key.KeyType = "rsa"
+		}
+		switch {
+		case p == "":
+			key.Revoked = true
+			report.add("DKIM", EmailInfo, "the key of selector %s is revoked (empty p=)", selector)
+		case key.KeyType != "rsa" && key.KeyType != "ed25519":
+			report.add("DKIM", EmailError, "selector %s: unknown key type k=%s", selector, key.KeyType)
+		default:
+			bits, err := dkimKeyBits(key.KeyType, p)
+			if err != nil {
+				report.add("DKIM", EmailError, "selector %s: %v", selector, err)
+				break
+			}
+			key.Bits = bits
+			if key.KeyType == "rsa" && bits < 1024 {
+				report.add("DKIM", EmailError, "selector %s has a %d-bit RSA key; receivers must reject keys under 1024 bits", selector, bits)
+			} else if key.KeyType == "rsa" && bits < 2048 {
+				report.add("DKIM", EmailWarning, "selector %s has a %d-bit RSA key; 2048 bits are recommended", selector, bits)
+			}
+		}
+		if strings.Contains(tags["t"], "y") {
+			report.add("DKIM", EmailInfo, "selector %s is in test mode (t=y)", selector)
+		}
+		report.DKIM = append(report.DKIM, key)
+		return
+	}
+}
+
+// stsID is the form of an MTA-STS policy id (RFC 8461, section 3.1).
+var stsID = regexp.MustCompile(`^[A-Za-z0-9]{1,32}$`)
+
+func checkMTASTS(report *EmailReport) {
+	sts, rpt := report.MTASTS, report.TLSRPT
+	switch {
+	case sts == nil && !hasFinding(report, "MTA-STS"):
+		report.add("MTA-STS", EmailInfo, "not deployed; mail to %s can be downgraded to plaintext", report.Domain)
+	case sts != nil && !stsID.MatchString(sts.Tags["id"]):
+		report.add("MTA-STS", EmailError, "id=%q must be 1 to 32 letters and digits; senders ignore the policy", sts.Tags["id"])
+	}
+	switch {
+	case rpt == nil && sts != nil && !hasFinding(report, "TLS-RPT"):
+		report.add("TLS-RPT", EmailWarning, "MTA-STS is deployed without TLS reporting, so delivery failures go unnoticed")
+	case rpt == nil && !hasFinding(report, "TLS-RPT"):
+		report.add("TLS-RPT", EmailInfo, "not deployed")
+	case rpt != nil:
+		uris := splitList(rpt.Tags["rua"])
+		if len(uris) == 0 {
+			report.add("TLS-RPT", EmailError, "no rua= tag; the record is invalid")
+		}
+		for _, uri := range uris {
+			if u := strings.ToLower(uri); !strings.HasPrefix(u, "mailto:") && !strings.HasPrefix(u, "https:") {
+				report.add("TLS-RPT", EmailError, "rua=%s is not a mailto: or https: URI", uri)
+			}
+		}
+	}
+}
+
+func checkBIMI(report *EmailReport) {
+	bimi := report.BIMI
+	if bimi == nil {
+		if !hasFinding(report, "BIMI") {
+			report.add("BIMI", EmailInfo, "not deployed")
+		}
+		return
+	}
+	if l := bimi.Tags["l"]; l == "" {
+		report.add("BIMI", EmailWarning, "no logo (empty l=); the record declines BIMI")
+	} else if !strings.HasPrefix(strings.ToLower(l), "https://") {
+		report.add("BIMI", EmailError, "the logo l=%s must be an https: URL", l)
+	}
+	if a := bimi.Tags["a"]; a == "" {
+		report.add("BIMI", EmailInfo, "no certificate (a=); many mailbox providers only show logos with a VMC")
+	} else if !strings.HasPrefix(strings.ToLower(a), "https://") {
+		report.add("BIMI", EmailError, "the certificate a=%s must be an https: URL", a)
+	}
+	if d := report.DMARC; d == nil || strings.EqualFold(d.Tags["p"], "none") || (d.Tags["pct"] != "" && d.Tags["pct"] != "100") {
+		report.add("BIMI", EmailWarning, "logos are only shown with a DMARC policy of quarantine or reject at pct=100")
+	}
+}
+
+func init() {
+	RegisterProvider(&EmailProvider{})
+}
@@ -0,0 +1,201 @@
+package lookup_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"dlookup/lookup"
+)
+
+// txtZone answers TXT queries from records, keyed by name without the
+// trailing dot. Other names do not exist.
+func txtZone(records map[string][]string) dnsHandler {
+	return func(q *lookup.Message, tcp bool) *lookup.Message {
+		name := q.Question[0].Name
+		texts, ok := records[strings.TrimSuffix(name, ".")]
+		if !ok {
+			return &lookup.Message{Header: lookup.Header{Rcode: lookup.RcodeNameError}}
+		}
+		resp := &lookup.Message{}
+		for _, text := range texts {
+			// Long records are split into strings of 255 bytes.
+			var strs []string
+			for len(text) > 255 {
+				strs, text = append(strs, text[:255]), text[255:]
+			}
+			resp.Answer = append(resp.Answer, lookup.RR{Name: name, Type: lookup.TypeTXT, Class: lookup.ClassINET, TTL: 300,
+				Data: &lookup.TXTRecord{Strings: append(strs, text)}})
+		}
+		return resp
+	}
+}
+
+func useDKIMSelectors(t *testing.T, selectors ...string) {
+	t.Helper()
+	orig := lookup.DKIMSelectors
+	lookup.DKIMSelectors = selectors
+	t.Cleanup(func() { lookup.DKIMSelectors = orig })
+}
+
+func auditEmail(t *testing.T, domain string) *lookup.EmailReport {
+	t.Helper()
+	provider, ok := lookup.GetProviderByFlagName("email")
+	if !ok {
+		t.Fatal("email provider not registered")
+	}
+	result, err := provider.Execute(context.Background(), domain)
+	if err != nil {
+		t.Fatalf("Execute(%s) error = %v", domain, err)
+	}
+	return result.Details.(*lookup.EmailReport)
+}
+
+func findings(report *lookup.EmailReport) string {
+	var lines []string
+	for _, f := range report.Findings {
+		lines = append(lines, string(f.Severity)+" "+f.Check+": "+f.Message)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestEmailProvider_WellConfigured(t *testing.T) {
+	edKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	useFakeDNS(t, map[string]dnsHandler{
+		"192.0.2.1:53": txtZone(map[string][]string{
+			"example.com":                      {"google-site-verification=abc", "v=spf1 include:_spf.mail.test ip4:192.0.2.0/24 -all"},
+			"_spf.mail.test":                   {"v=spf1 ip4:198.51.100.0/24 include:_spf2.mail.test ~all"},
+			"_spf2.mail.test":                  {"v=spf1 a mx -all"},
+			"_dmarc.example.com":               {"v=DMARC1; p=reject; rua=mailto:dmarc@example.com"},
+			"selector1._domainkey.example.com": {"v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(edKey)},
+			"s1._domainkey.example.com":        {"v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(der)},
+			"_mta-sts.example.com":             {"v=STSv1; id=20240101T000000"},
+			"_smtp._tls.example.com":           {"v=TLSRPTv1; rua=mailto:tls@example.com"},
+			"default._bimi.example.com":        {"v=BIMI1; l=https://example.com/logo.svg; a=https://example.com/vmc.pem"},
+			"old._domainkey.example.com":       {"v=DKIM1; p="},
+		}),
+	})
+	useDKIMSelectors(t, "selector1", "s1", "old", "missing")
+
+	report := auditEmail(t, "example.com")
+	if report.SPF == nil || report.SPF.Lookups() != 4 || len(report.SPF.Children) != 1 || len(report.SPF.Children[0].Children) != 1 {
+		t.Fatalf("SPF = %+v, want 4 lookups through two nested includes", report.SPF)
+	}
+	want := "warning DKIM: selector s1 has a 1024-bit RSA key; 2048 bits are recommended\ninfo DKIM: the key of selector old is revoked (empty p=)"
+	if got := findings(report); got != want {
+		t.Errorf("findings =\n%s\nwant\n%s", got, want)
+	}
+	if len(report.DKIM) != 3 || report.DKIM[0].Bits != 256 || report.DKIM[1].Bits != 1024 || !report.DKIM[2].Revoked {
+		t.Errorf("DKIM = %+v", report.DKIM)
+	}
+	summary := report.Summary()
+	for _, line := range []string{
+		"SPF:           v=spf1 include:_spf.mail.test ip4:192.0.2.0/24 -all (4 of 10 DNS lookups)",
+		"DMARC:         p=reject, rua=mailto:dmarc@example.com",
+		"MTA-STS:       id=20240101T000000",
+		"Findings:      errors: 0, warnings: 1, notes: 1",
+	} {
+		if !strings.Contains(summary, line) {
+			t.Errorf("Summary() =\n%s\nwant a line %q", summary, line)
+		}
+	}
+	if listing := report.Listing(); !strings.Contains(listing, "\n    include:_spf2.mail.test  v=spf1 a mx -all\n") {
+		t.Errorf("Listing() =\n%s", listing)
+	}
+}
+
+func TestEmailProvider_Problems(t *testing.T) {
+	var includes []string
+	zone := map[string][]string{
+		"_dmarc.bad.test":         {"v=DMARC1; p=none; pct=50"},
+		"_mta-sts.bad.test":       {"v=STSv1; id=not-valid!"},
+		"default._bimi.bad.test":  {"v=BIMI1; l=http://bad.test/logo.svg"},
+		"loop.test":               {"v=spf1 include:loop2.test -all"},
+		"loop2.test":              {"v=spf1 include:loop.test -all"},
+		"twice.test":              {"v=spf1 -all", "v=spf1 ~all"},
+		"_dmarc.twice.test":       {"v=DMARC1; p=reject", "v=DMARC1; p=none"},
+		"nodmarc.test":            {"v=spf1 mx"},
+		"_smtp._tls.nodmarc.test": {"v=TLSRPTv1"},
+	}
+	for _, n := range []string{"a", "b", "c", "d", "e", "f"} {
+		includes = append(includes, "include:"+n+".spf.test")
+		zone[n+".spf.test"] = []string{"v=spf1 a mx -all"}
+	}
+	zone["bad.test"] = []string{"v=spf1 " + strings.Join(includes, " ") + " include:missing.test +all ip4:192.0.2.1"}
+	useFakeDNS(t, map[string]dnsHandler{"192.0.2.1:53": txtZone(zone)})
+	useDKIMSelectors(t, "default")
+
+	got := findings(auditEmail(t, "bad.test"))
+	for _, want := range []string{
+		"error SPF: 19 DNS lookups, more than the 10 allowed",
+		"error SPF: include:missing.test: no SPF record",
+		"error SPF: \"+all\" lets any server send as bad.test",
+		"warning SPF: ip4:192.0.2.1 comes after all and is never evaluated",
+		"warning DMARC: p=none only monitors",
+		"warning DMARC: pct=50 applies the policy to only part",
+		"warning DMARC: no rua= tag",
+		"info DKIM: no key found for the selectors tried (default)",
+		"error MTA-STS: id=\"not-valid!\" must be 1 to 32 letters and digits",
+		"warning TLS-RPT: MTA-STS is deployed without TLS reporting",
+		"error BIMI: the logo l=http://bad.test/logo.svg must be an https: URL",
+		"warning BIMI: logos are only shown with a DMARC policy of quarantine or reject",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("findings of bad.test =\n%s\nwant %q", got, want)
+		}
+	}
+
+	if got := findings(auditEmail(t, "loop.test")); !strings.Contains(got, "include loop: loop.test → loop2.test → loop.test") {
+		t.Errorf("findings of loop.test =\n%s\nwant an include loop", got)
+	}
+
+	got = findings(auditEmail(t, "twice.test"))
+	for _, want := range []string{
+		"error SPF: twice.test: 2 SPF records; there must be only one",
+		"error DMARC: _dmarc.twice.test has 2 v=DMARC1 records",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("findings of twice.test =\n%s\nwant %q", got, want)
+		}
+	}
+	if strings.Contains(got, "has no DMARC record") {
+		t.Errorf("findings of twice.test =\n%s\nreport a missing DMARC record as well as duplicates", got)
+	}
+
+	got = findings(auditEmail(t, "nodmarc.test"))
+	for _, want := range []string{
+		"warning SPF: no \"all\" mechanism",
+		"error DMARC: _dmarc.nodmarc.test has no DMARC record",
+		"error TLS-RPT: no rua= tag",
+		"info MTA-STS: not deployed",
+		"info BIMI: not deployed",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("findings of nodmarc.test =\n%s\nwant %q", got, want)
+		}
+	}
+}
+
+func TestEmailProvider_QueryFails(t *testing.T) {
+	lookup.SetProviderPolicy("dns-txt", lookup.Policy{MaxAttempts: 1})
+	defer lookup.SetProviderPolicy("dns-txt", lookup.DefaultPolicy)
+	useFakeDNS(t, map[string]dnsHandler{
+		"192.0.2.1:53": func(q *lookup.Message, tcp bool) *lookup.Message {
+			return &lookup.Message{Header: lookup.Header{Rcode: lookup.RcodeServerFailure}}
+		},
+	})
+	provider, _ := lookup.GetProviderByFlagName("email")
+	if _, err := provider.Execute(context.Background(), "example.com"); err == nil || !strings.Contains(err.Error(), "SERVFAIL") {
+		t.Errorf("Execute() error = %v, want SERVFAIL", err)
+	}
+}
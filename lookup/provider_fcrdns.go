@@ -0,0 +1,175 @@
+package lookup
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// FCrDNSProvider checks forward-confirmed reverse DNS: it looks up the PTR
+// records of an IP address and then whether any of the names they point to
+// resolves back to the same address, as mail servers require of senders.
+type FCrDNSProvider struct{}
+
+func (p *FCrDNSProvider) Name() string {
+	return "FCRDNS"
+}
+
+func (p *FCrDNSProvider) FlagName() string {
+	return "fcrdns"
+}
+
+func (p *FCrDNSProvider) Usage() string {
+	return fmt.Sprintf("Run %s (PTR, then forward confirmation of the names) on IP addresses from <filename>", p.Name())
+}
+
+func (p *FCrDNSProvider) CheckAvailability() bool {
+	return true
+}
+
+// Accepts reports that the check takes IP addresses only.
+func (p *FCrDNSProvider) Accepts(kind InputKind) bool {
+	return kind.IsIP()
+}
+
+// FCrDNSReport is the Details of a forward-confirmed reverse DNS check.
+type FCrDNSReport struct {
+	IP          string       `json:"ip"`
+	ReverseName string       `json:"reverse_name"`
+	Names       []FCrDNSName `json:"names"`
+	// Confirmed is set when at least one PTR name resolves back to IP.
+	Confirmed bool `json:"confirmed"`
+}
+
+// FCrDNSName is one PTR name and the addresses it resolves to.
+type FCrDNSName struct {
+	Name      string   `json:"name"`
+	Addresses []string `json:"addresses"`
+	Matches   bool     `json:"matches"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// Summary shows each PTR name with its addresses and whether the check
+// passed.
+func (r *FCrDNSReport) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-14s %s\n", "IP:", r.IP)
+	fmt.Fprintf(&b, "%-14s %s\n", "Reverse name:", r.ReverseName)
+	for i, n := range r.Names {
+		label := "PTR:"
+		if i > 0 {
+			label = ""
+		}
+		forward := strings.Join(n.Addresses, ", ")
+		switch {
+		case n.Error != "":
+			forward = "error: " + n.Error
+		case forward == "":
+			forward = "(no addresses)"
+		}
+		mark := "✗"
+		if n.Matches {
+			mark = "✓"
+		}
+		fmt.Fprintf(&b, "%-14s %s → %s %s\n", label, n.Name, forward, mark)
+	}
+	switch {
+	case len(r.Names) == 0:
+		fmt.Fprintf(&b, "%-14s %s", "Status:", "not confirmed: no PTR record")
+	case r.Confirmed:
+		fmt.Fprintf(&b, "%-14s %s", "Status:", "confirmed: the PTR name resolves back to the address")
+	default:
+		fmt.Fprintf(&b, "%-14s %s", "Status:", "not confirmed: no PTR name resolves back to the address")
+	}
+	return b.String()
+}
+
+// Brief lists the PTR names and whether the check passed, for a row of a
+// range lookup.
+func (r *FCrDNSReport) Brief() string {
+	if len(r.Names) == 0 {
+		return ""
+	}
+	names := make([]string, len(r.Names))
+	for i, n := range r.Names {
+		names[i] = n.Name
+	}
+	status := "not confirmed"
+	if r.Confirmed {
+		status = "confirmed"
+	}
+	return fmt.Sprintf("%s (%s)", strings.Join(names, ", "), status)
+}
+
+// Execute looks up the PTR records of the address in ip, then the A or AAAA
+// records of every name they point to.
+func (p *FCrDNSProvider) Execute(ctx context.Context, ip string) (*Result, error) {
+	addr := net.ParseIP(strings.TrimSpace(ip))
+	if addr == nil {
+		return nil, fmt.Errorf("%s needs an IP address, got %q", p.Name(), ip)
+	}
+	forwardType := TypeAAAA
+	if addr.To4() != nil {
+		forwardType = TypeA
+	}
+	chosen := ServerFromContext(ctx)
+	r := resolverFor(chosen)
+	report := &FCrDNSReport{IP: addr.String(), ReverseName: ReverseName(addr)}
+	result := &Result{Details: report, Server: chosen}
+
+	resp, server, err := r.Query(ctx, report.ReverseName, TypePTR)
+	if err != nil {
+		return result, fmt.Errorf("PTR query for %s failed: %w", report.ReverseName, err)
+	}
+	if resp.Rcode != RcodeSuccess && resp.Rcode != RcodeNameError {
+		return result, &RcodeError{Name: report.ReverseName, Server: server, Rcode: resp.Rcode}
+	}
+	var lines []string
+	for _, rr := range resp.Answer {
+		lines = append(lines, rr.String())
+		ptr, ok := rr.Data.(*PTRRecord)
+		if !ok {
+			continue
+		}
+		result.Records = append(result.Records, rr)
+		name := FCrDNSName{Name: ptr.Ptr}
+		fwd, _, err := r.Query(ctx, ptr.Ptr, forwardType)
+		if err != nil {
+			name.Error = err.Error()
+			report.Names = append(report.Names, name)
+			continue
+		}
+		for _, frr := range fwd.Answer {
+			lines = append(lines, frr.String())
+			var fip net.IP
+			switch d := frr.Data.(type) {
+			case *ARecord:
+				fip = d.IP
+			case *AAAARecord:
+				fip = d.IP
+			default:
+				continue
+			}
+			result.Records = append(result.Records, frr)
+			name.Addresses = append(name.Addresses, fip.String())
+			if fip.Equal(addr) {
+				name.Matches = true
+			}
+		}
+		if fwd.Rcode != RcodeSuccess && len(fwd.Answer) == 0 {
+			name.Error = fwd.Rcode.String()
+		}
+		report.Confirmed = report.Confirmed || name.Matches
+		report.Names = append(report.Names, name)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	result.Stdout = strings.Join(lines, "\n")
+	return result, nil
+}
+
+func init() {
+	RegisterProvider(&FCrDNSProvider{})
+}
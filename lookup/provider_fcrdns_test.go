@@ -0,0 +1,83 @@
+package lookup_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"dlookup/lookup"
+)
+
+func ptrRecord(name, target string) lookup.RR {
+	return lookup.RR{Name: name, Type: lookup.TypePTR, Class: lookup.ClassINET, TTL: 3600, Data: &lookup.PTRRecord{Ptr: target}}
+}
+
+// useReverseZone serves PTR records for 192.0.2.25 and A records for the
+// names in forward.
+func useReverseZone(t *testing.T, ptrs []string, forward map[string]string) {
+	useFakeDNS(t, map[string]dnsHandler{
+		"192.0.2.1:53": func(q *lookup.Message, tcp bool) *lookup.Message {
+			name := q.Question[0].Name
+			resp := &lookup.Message{}
+			switch q.Question[0].Type {
+			case lookup.TypePTR:
+				if name != "25.2.0.192.in-addr.arpa." || len(ptrs) == 0 {
+					resp.Rcode = lookup.RcodeNameError
+				}
+				for _, ptr := range ptrs {
+					resp.Answer = append(resp.Answer, ptrRecord(name, ptr))
+				}
+			case lookup.TypeA:
+				ip, ok := forward[name]
+				if !ok {
+					resp.Rcode = lookup.RcodeNameError
+				} else {
+					resp.Answer = append(resp.Answer, aRecord(name, ip, 300))
+				}
+			}
+			return resp
+		},
+	})
+}
+
+func TestFCrDNSProvider_Execute(t *testing.T) {
+	provider, ok := lookup.GetProviderByFlagName("fcrdns")
+	if !ok {
+		t.Fatal("fcrdns provider not registered")
+	}
+	tests := []struct {
+		name      string
+		ptrs      []string
+		forward   map[string]string
+		confirmed bool
+		status    string
+	}{
+		{"confirmed", []string{"mail.example.com."}, map[string]string{"mail.example.com.": "192.0.2.25"}, true, "Status:        confirmed"},
+		{"other address", []string{"mail.example.com."}, map[string]string{"mail.example.com.": "192.0.2.99"}, false, "no PTR name resolves back"},
+		{"second name matches", []string{"gone.example.com.", "mx.example.com."}, map[string]string{"mx.example.com.": "192.0.2.25"}, true, "Status:        confirmed"},
+		{"no PTR", nil, nil, false, "no PTR record"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useReverseZone(t, tt.ptrs, tt.forward)
+			result, err := provider.Execute(context.Background(), "192.0.2.25")
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			report := result.Details.(*lookup.FCrDNSReport)
+			if report.Confirmed != tt.confirmed || len(report.Names) != len(tt.ptrs) {
+				t.Errorf("report = %+v, want confirmed %v with %d names", report, tt.confirmed, len(tt.ptrs))
+			}
+			if summary := result.Summary(); !strings.Contains(summary, tt.status) {
+				t.Errorf("Summary() =\n%s\nwant %q", summary, tt.status)
+			}
+		})
+	}
+}
+
+func TestFCrDNSProvider_RejectsDomains(t *testing.T) {
+	provider, _ := lookup.GetProviderByFlagName("fcrdns")
+	if _, err := provider.Execute(context.Background(), "example.com"); err == nil {
+		t.Error("Execute() on a domain error = nil, want error")
+	}
+}
@@ -1,6 +1,9 @@
 package lookup
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+)
 
 type NslookupProvider struct{}
 
@@ -16,11 +19,30 @@ func (p *NslookupProvider) Usage() string {
 	return fmt.Sprintf("Run %s lookup on domains from <filename>", p.Name())
 }
 
-func (p *NslookupProvider) Execute(domain string) (string, error) {
+func (p *NslookupProvider) Execute(ctx context.Context, domain string) (*Result, error) {
 	if !p.CheckAvailability() {
-		return "", fmt.Errorf("command not found: nslookup")
+		return nil, fmt.Errorf("command not found: nslookup")
 	}
-	return RunCommand("nslookup", domain) // Use exported RunCommand
+	args := []string{domain}
+	server := ServerFromContext(ctx)
+	if server != "" {
+		host, port := splitServer(server)
+		args = append(args, host)
+		if port != "" {
+			args = append([]string{"-port=" + port}, args...)
+		}
+	}
+	result, err := RunCommand(ctx, "nslookup", args...) // Use exported RunCommand
+	if result != nil {
+		result.Server = server
+	}
+	return result, err
+}
+
+// Accepts reports that nslookup takes any input: it reverses IP addresses
+// itself.
+func (p *NslookupProvider) Accepts(kind InputKind) bool {
+	return true
 }
 
 func (p *NslookupProvider) CheckAvailability() bool {
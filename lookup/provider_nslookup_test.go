@@ -1,6 +1,7 @@
 package lookup_test
 
 import (
+	"context"
 	"dlookup/lookup"
 	"fmt"
 	"strings"
@@ -73,13 +74,14 @@ func TestNslookupProvider_Execute(t *testing.T) {
 	// Test Case 1: Successful execution
 	t.Run("Success", func(t *testing.T) {
 		expectedOutput := "Mocked nslookup output for " + domainToTest
-		lookup.OsRunCommand = func(cmdName string, args ...string) (string, error) {
+		lookup.OsRunCommand = func(ctx context.Context, cmdName string, args ...string) (string, string, error) {
 			capturedCmdName = cmdName
 			capturedArgs = args
-			return expectedOutput, nil
+			return expectedOutput, "", nil
 		}
 
-		output, err := provider.Execute(domainToTest)
+		result, err := provider.Execute(context.Background(), domainToTest)
+		output := outputOf(result)
 		if err != nil {
 			t.Errorf("Execute() error = %v, want nil", err)
 		}
@@ -98,13 +100,14 @@ func TestNslookupProvider_Execute(t *testing.T) {
 	// Test Case 2: Command execution failure
 	t.Run("CommandFailure", func(t *testing.T) {
 		mockError := fmt.Errorf("mocked nslookup error")
-		lookup.OsRunCommand = func(cmdName string, args ...string) (string, error) {
+		lookup.OsRunCommand = func(ctx context.Context, cmdName string, args ...string) (string, string, error) {
 			capturedCmdName = cmdName
 			capturedArgs = args
-			return "error nslookup output", mockError
+			return "error nslookup output", "", mockError
 		}
 
-		output, err := provider.Execute(domainToTest)
+		result, err := provider.Execute(context.Background(), domainToTest)
+		output := outputOf(result)
 		if err == nil {
 			t.Fatalf("Execute() error = nil, want non-nil")
 		}
@@ -116,7 +119,7 @@ func TestNslookupProvider_Execute(t *testing.T) {
 			t.Errorf("Execute() error = %q, want %q", err.Error(), expectedWrappedErrStr)
 		}
 
-		// The mock OsRunCommand returns "error nslookup output" as the string part.
+		// The mock OsRunCommand returns "error nslookup output" as stdout.
 		if output != "error nslookup output" {
 			t.Errorf("Execute() output on error = %q, want %q", output, "error nslookup output")
 		}
@@ -124,15 +127,16 @@ func TestNslookupProvider_Execute(t *testing.T) {
 
 	// Test Case 3: Command produces no output (but no error)
 	t.Run("NoOutput", func(t *testing.T) {
-		// RunCommand should convert empty raw output to "(No results found)"
+		// Result.Output should turn empty stdout into "(No results found)"
 		expectedOutput := "(No results found)"
-		lookup.OsRunCommand = func(cmdName string, args ...string) (string, error) {
+		lookup.OsRunCommand = func(ctx context.Context, cmdName string, args ...string) (string, string, error) {
 			capturedCmdName = cmdName
 			capturedArgs = args
-			return "", nil // Mock OsRunCommand returns empty string and nil error
+			return "", "", nil // Mock OsRunCommand returns empty output and nil error
 		}
 
-		output, err := provider.Execute("nodata.example.com")
+		result, err := provider.Execute(context.Background(), "nodata.example.com")
+		output := outputOf(result)
 		if err != nil {
 			t.Errorf("Execute() error = %v, want nil", err)
 		}
@@ -141,3 +145,34 @@ func TestNslookupProvider_Execute(t *testing.T) {
 		}
 	})
 }
+
+func TestNslookupProvider_Server(t *testing.T) {
+	origRunCommand := lookup.OsRunCommand
+	defer func() { lookup.OsRunCommand = origRunCommand }()
+	origCheckCommandFunc := lookup.LookupCheckCommandFunc
+	defer func() { lookup.LookupCheckCommandFunc = origCheckCommandFunc }()
+	lookup.LookupCheckCommandFunc = func(cmd string) bool { return cmd == "nslookup" }
+
+	var capturedArgs []string
+	lookup.OsRunCommand = func(ctx context.Context, cmdName string, args ...string) (string, string, error) {
+		capturedArgs = args
+		return "Address: 192.0.2.1", "", nil
+	}
+	provider, _ := lookup.GetProvider("NSLOOKUP")
+
+	tests := []struct {
+		server string
+		want   []string
+	}{
+		{"1.1.1.1", []string{"example.com", "1.1.1.1"}},
+		{"192.0.2.53:5353", []string{"-port=5353", "example.com", "192.0.2.53"}},
+	}
+	for _, tt := range tests {
+		if _, err := provider.Execute(lookup.WithServer(context.Background(), tt.server), "example.com"); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if !equalSlices(capturedArgs, tt.want) {
+			t.Errorf("server %s: args = %v, want %v", tt.server, capturedArgs, tt.want)
+		}
+	}
+}
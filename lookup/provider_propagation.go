@@ -0,0 +1,272 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// PropagationProvider asks every named resolver and every authoritative
+// name server of the zone for the same record, to show whether a change has
+// reached the resolvers yet.
+type PropagationProvider struct {
+	name     string
+	flagName string
+	qtype    RRType
+}
+
+func newPropagationProvider(name string, qtype RRType) {
+	RegisterProvider(&PropagationProvider{
+		name:     name,
+		flagName: slugify(name),
+		qtype:    qtype,
+	})
+}
+
+func (p *PropagationProvider) Name() string {
+	return p.name
+}
+
+func (p *PropagationProvider) FlagName() string {
+	return p.flagName
+}
+
+func (p *PropagationProvider) Usage() string {
+	return fmt.Sprintf("Run %s check against the configured resolvers on domains from <filename>", p.Name())
+}
+
+func (p *PropagationProvider) CheckAvailability() bool {
+	return true
+}
+
+// Live reports that propagation checks are never cached or run as part of a
+// report: their point is to see the answers change.
+func (p *PropagationProvider) Live() bool {
+	return true
+}
+
+// PropagationReport is the Details of a propagation check.
+type PropagationReport struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Zone string `json:"zone,omitempty"`
+	// Expected is the answer of the authoritative servers that every resolver
+	// is compared with; nil if none of them answered.
+	Expected []string `json:"expected"`
+	// Answers holds the authoritative servers first, then the resolvers in
+	// the order they are configured.
+	Answers []PropagationAnswer `json:"answers"`
+}
+
+// PropagationAnswer is what one server answered.
+type PropagationAnswer struct {
+	Server        string        `json:"server"`
+	Label         string        `json:"label"`
+	Authoritative bool          `json:"authoritative"`
+	Answer        []string      `json:"answer"`
+	TTL           uint32        `json:"ttl"`
+	Latency       time.Duration `json:"-"`
+	LatencyMS     int64         `json:"latency_ms"`
+	Error         string        `json:"error,omitempty"`
+	// Agrees is set when the answer matches the authoritative answer.
+	Agrees bool `json:"agrees"`
+}
+
+// Disagreeing returns how many resolvers (not authoritative servers) failed
+// or answered differently from the authoritative servers, and how many were
+// asked.
+func (r *PropagationReport) Disagreeing() (n, total int) {
+	for _, a := range r.Answers {
+		if a.Authoritative {
+			continue
+		}
+		total++
+		if !a.Agrees {
+			n++
+		}
+	}
+	return n, total
+}
+
+// Summary reports whether the resolvers have converged on the authoritative
+// answer.
+func (r *PropagationReport) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-14s %s %s\n", "Query:", r.Name, r.Type)
+	if r.Zone != "" {
+		fmt.Fprintf(&b, "%-14s %s\n", "Zone:", r.Zone)
+	}
+	if r.Expected == nil {
+		fmt.Fprintf(&b, "%-14s %s\n", "Authoritative:", "no answer from the authoritative servers")
+	} else {
+		fmt.Fprintf(&b, "%-14s %s\n", "Authoritative:", formatPropagationAnswer(r.Expected))
+	}
+	n, total := r.Disagreeing()
+	switch {
+	case r.Expected == nil:
+		fmt.Fprintf(&b, "%-14s %s", "Status:", "unknown")
+	case n == 0:
+		fmt.Fprintf(&b, "%-14s all %d resolvers agree (converged)", "Status:", total)
+	default:
+		fmt.Fprintf(&b, "%-14s %d of %d resolvers differ or failed", "Status:", n, total)
+	}
+	return b.String()
+}
+
+// Table renders the answers as a header line followed by one line per entry
+// of Answers, in the same order. The first column marks authoritative
+// servers with "A" and answers that differ from the expected one with "✗".
+func (r *PropagationReport) Table() []string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\tSERVER\tANSWER\tTTL\tLATENCY")
+	for _, a := range r.Answers {
+		mark := "✓"
+		if !a.Agrees {
+			mark = "✗"
+		}
+		if a.Authoritative {
+			mark = "A" + strings.TrimPrefix(mark, "✓")
+		}
+		answer := formatPropagationAnswer(a.Answer)
+		ttl := fmt.Sprint(a.TTL)
+		if a.Error != "" {
+			answer, ttl = "error: "+a.Error, "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", mark, a.Label, answer, ttl, a.Latency.Round(time.Millisecond))
+	}
+	w.Flush()
+	return strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+}
+
+func formatPropagationAnswer(answer []string) string {
+	if len(answer) == 0 {
+		return "(no records)"
+	}
+	return strings.Join(answer, ", ")
+}
+
+// Execute finds the authoritative servers of domain's zone and queries them
+// and every named resolver at once.
+func (p *PropagationProvider) Execute(ctx context.Context, domain string) (*Result, error) {
+	resolvers := NamedResolvers()
+	if len(resolvers) == 0 {
+		return nil, errors.New("no resolvers configured for the propagation check")
+	}
+	report := &PropagationReport{Name: Fqdn(domain), Type: p.qtype.String()}
+
+	zone, nameServers, nsErr := findNameServers(ctx, DefaultResolver, domain)
+	report.Zone = zone
+	report.Answers = make([]PropagationAnswer, len(nameServers)+len(resolvers))
+	var wg sync.WaitGroup
+	query := func(i int, a PropagationAnswer, exchange func() (*Message, error)) {
+		defer wg.Done()
+		start := time.Now()
+		resp, err := exchange()
+		a.Latency = time.Since(start)
+		a.LatencyMS = a.Latency.Milliseconds()
+		if err != nil {
+			a.Error = err.Error()
+		} else {
+			a.Answer, a.TTL = propagationAnswer(resp, report.Name)
+		}
+		report.Answers[i] = a
+	}
+	for i, ns := range nameServers {
+		wg.Add(1)
+		go query(i, PropagationAnswer{Server: ns.Addr, Label: ns.String(), Authoritative: true}, func() (*Message, error) {
+			return queryAuthoritative(ctx, ns.Addr, domain, p.qtype)
+		})
+	}
+	for i, r := range resolvers {
+		wg.Add(1)
+		go query(len(nameServers)+i, PropagationAnswer{Server: r.Address, Label: ServerLabel(r.Address)}, func() (*Message, error) {
+			resp, server, err := resolverFor(r.Address).Query(ctx, domain, p.qtype)
+			if err == nil && resp.Rcode != RcodeSuccess && resp.Rcode != RcodeNameError {
+				err = &RcodeError{Name: report.Name, Server: server, Rcode: resp.Rcode}
+			}
+			return resp, err
+		})
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// The first authoritative server to answer sets the expected answer;
+	// authoritative servers are compared with it too.
+	for _, a := range report.Answers {
+		if a.Authoritative && a.Error == "" {
+			report.Expected = a.Answer
+			if report.Expected == nil {
+				report.Expected = []string{}
+			}
+			break
+		}
+	}
+	failed := 0
+	for i := range report.Answers {
+		a := &report.Answers[i]
+		a.Agrees = report.Expected != nil && a.Error == "" && equalAnswers(a.Answer, report.Expected)
+		if a.Error != "" {
+			failed++
+		}
+	}
+
+	result := &Result{Stdout: strings.Join(report.Table(), "\n"), Details: report}
+	if nsErr != nil {
+		result.Stderr = nsErr.Error()
+	}
+	if failed == len(report.Answers) {
+		return result, fmt.Errorf("no server answered the %s query for %s", p.qtype, domain)
+	}
+	return result, nil
+}
+
+// propagationAnswer returns the records of resp owned by name, rendered as
+// "TYPE data" and sorted so answers can be compared, and their smallest TTL.
+// Only records owned by name are kept: a resolver also returns the target of
+// a CNAME, which another zone's servers answer for.
+func propagationAnswer(resp *Message, name string) ([]string, uint32) {
+	if resp.Rcode == RcodeNameError {
+		return []string{"NXDOMAIN"}, 0
+	}
+	var answer []string
+	var ttl uint32
+	for _, rr := range resp.Answer {
+		if !strings.EqualFold(rr.Name, name) || rr.Data == nil {
+			continue
+		}
+		answer = append(answer, rr.Type.String()+" "+rr.Data.String())
+		if ttl == 0 || rr.TTL < ttl {
+			ttl = rr.TTL
+		}
+	}
+	sort.Strings(answer)
+	return answer, ttl
+}
+
+func equalAnswers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func init() {
+	newPropagationProvider("PROPAGATION (A)", TypeA)
+	newPropagationProvider("PROPAGATION (AAAA)", TypeAAAA)
+	newPropagationProvider("PROPAGATION (MX)", TypeMX)
+	newPropagationProvider("PROPAGATION (TXT)", TypeTXT)
+	newPropagationProvider("PROPAGATION (CNAME)", TypeCNAME)
+}
@@ -0,0 +1,151 @@
+package lookup_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"dlookup/lookup"
+)
+
+func nsRecord(zone, host string) lookup.RR {
+	return lookup.RR{Name: zone, Type: lookup.TypeNS, Class: lookup.ClassINET, TTL: 3600, Data: &lookup.NSRecord{Host: host}}
+}
+
+func soaRecord(zone string) lookup.RR {
+	return lookup.RR{Name: zone, Type: lookup.TypeSOA, Class: lookup.ClassINET, TTL: 3600, Data: &lookup.SOARecord{MName: "ns1." + zone, RName: "hostmaster." + zone, Serial: 1}}
+}
+
+// exampleZone serves example.com from ns1 (192.0.2.11) and ns2 (192.0.2.12)
+// through a system resolver at 192.0.2.1, and answers A queries for names
+// in it with ip.
+func exampleZone(ip string) map[string]dnsHandler {
+	authoritative := func(q *lookup.Message, tcp bool) *lookup.Message {
+		qq := q.Question[0]
+		resp := &lookup.Message{Header: lookup.Header{Authoritative: true}}
+		switch qq.Type {
+		case lookup.TypeA:
+			resp.Answer = []lookup.RR{aRecord(qq.Name, ip, 300)}
+		case lookup.TypeNS:
+			resp.Answer = []lookup.RR{nsRecord("example.com.", "ns1.example.com."), nsRecord("example.com.", "ns2.example.com.")}
+		default:
+			resp.Authority = []lookup.RR{soaRecord("example.com.")}
+		}
+		return resp
+	}
+	return map[string]dnsHandler{
+		"192.0.2.1:53": func(q *lookup.Message, tcp bool) *lookup.Message {
+			qq := q.Question[0]
+			switch {
+			case qq.Type == lookup.TypeNS && qq.Name == "example.com.":
+				return &lookup.Message{
+					Answer:     []lookup.RR{nsRecord("example.com.", "ns1.example.com."), nsRecord("example.com.", "ns2.example.com.")},
+					Additional: []lookup.RR{aRecord("ns1.example.com.", "192.0.2.11", 3600), aRecord("ns2.example.com.", "192.0.2.12", 3600)},
+				}
+			case qq.Type == lookup.TypeNS:
+				return &lookup.Message{Authority: []lookup.RR{soaRecord("example.com.")}}
+			}
+			return &lookup.Message{Answer: []lookup.RR{aRecord(qq.Name, ip, 300)}}
+		},
+		"192.0.2.11:53": authoritative,
+		"192.0.2.12:53": authoritative,
+	}
+}
+
+func TestPropagationProvider_Execute(t *testing.T) {
+	servers := exampleZone("203.0.113.2")
+	answer := func(ip string, ttl uint32) dnsHandler {
+		return func(q *lookup.Message, tcp bool) *lookup.Message {
+			return &lookup.Message{Answer: []lookup.RR{aRecord(q.Question[0].Name, ip, ttl)}}
+		}
+	}
+	servers["8.8.8.8:53"] = answer("203.0.113.2", 120)
+	servers["9.9.9.9:53"] = answer("203.0.113.1", 40)
+	servers["1.1.1.1:53"] = func(q *lookup.Message, tcp bool) *lookup.Message {
+		return &lookup.Message{Header: lookup.Header{Rcode: lookup.RcodeServerFailure}}
+	}
+	useFakeDNS(t, servers)
+	useNamedResolvers(t,
+		lookup.NamedResolver{Name: "google", Address: "8.8.8.8"},
+		lookup.NamedResolver{Name: "quad9", Address: "9.9.9.9"},
+		lookup.NamedResolver{Name: "cloudflare", Address: "1.1.1.1"},
+	)
+
+	provider, ok := lookup.GetProviderByFlagName("propagation-a")
+	if !ok {
+		t.Fatal("propagation-a provider not registered")
+	}
+	result, err := provider.Execute(context.Background(), "www.example.com")
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	report, ok := result.Details.(*lookup.PropagationReport)
+	if !ok {
+		t.Fatalf("Details = %T, want *lookup.PropagationReport", result.Details)
+	}
+	if report.Zone != "example.com." || strings.Join(report.Expected, ",") != "A 203.0.113.2" {
+		t.Errorf("zone %q, expected %q; want example.com. and A 203.0.113.2", report.Zone, report.Expected)
+	}
+
+	want := []struct {
+		label  string
+		auth   bool
+		agrees bool
+		ttl    uint32
+	}{
+		{"ns1.example.com (192.0.2.11)", true, true, 300},
+		{"ns2.example.com (192.0.2.12)", true, true, 300},
+		{"google (8.8.8.8)", false, true, 120},
+		{"quad9 (9.9.9.9)", false, false, 40},
+		{"cloudflare (1.1.1.1)", false, false, 0},
+	}
+	if len(report.Answers) != len(want) {
+		t.Fatalf("got %d answers, want %d: %+v", len(report.Answers), len(want), report.Answers)
+	}
+	for i, w := range want {
+		a := report.Answers[i]
+		if a.Label != w.label || a.Authoritative != w.auth || a.Agrees != w.agrees || a.TTL != w.ttl {
+			t.Errorf("answer %d = %+v, want %+v", i, a, w)
+		}
+	}
+	if report.Answers[4].Error == "" {
+		t.Error("SERVFAIL from cloudflare not reported as an error")
+	}
+	if n, total := report.Disagreeing(); n != 2 || total != 3 {
+		t.Errorf("Disagreeing() = %d, %d; want 2, 3", n, total)
+	}
+	if !strings.Contains(report.Summary(), "2 of 3 resolvers differ") {
+		t.Errorf("Summary() = %q, want the disagreement count", report.Summary())
+	}
+
+	lines := strings.Split(result.Stdout, "\n")
+	if len(lines) != 1+len(want) || !strings.HasPrefix(lines[4], "✗") || !strings.HasPrefix(lines[3], "✓") || !strings.HasPrefix(lines[1], "A ") {
+		t.Errorf("table =\n%s\nwant a header and one marked line per server", result.Stdout)
+	}
+	if _, err := json.Marshal(result); err != nil {
+		t.Errorf("json.Marshal() error = %v", err)
+	}
+}
+
+func TestPropagationProvider_Converged(t *testing.T) {
+	servers := exampleZone("203.0.113.2")
+	servers["8.8.8.8:53"] = servers["192.0.2.1:53"]
+	useFakeDNS(t, servers)
+	useNamedResolvers(t, lookup.NamedResolver{Name: "google", Address: "8.8.8.8"})
+	enableCache(t)
+
+	provider, _ := lookup.GetProviderByFlagName("propagation-a")
+	for i := 0; i < 2; i++ {
+		result, err := lookup.RunLookup(context.Background(), provider, "example.com")
+		if err != nil {
+			t.Fatalf("RunLookup() error = %v", err)
+		}
+		if result.Cached {
+			t.Fatal("propagation check served from the cache")
+		}
+		if !strings.Contains(result.Summary(), "all 1 resolvers agree (converged)") {
+			t.Errorf("Summary() = %q, want converged", result.Summary())
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package lookup
+
+import (
+	"context"
+	"fmt"
+)
+
+// RDAPProvider fetches structured registration data over RDAP, which many
+// registries now serve in place of (or in addition to) WHOIS.
+type RDAPProvider struct{}
+
+func (p *RDAPProvider) Name() string {
+	return "RDAP"
+}
+
+func (p *RDAPProvider) FlagName() string {
+	return "rdap"
+}
+
+func (p *RDAPProvider) Usage() string {
+	return fmt.Sprintf("Run %s lookup on domains from <filename>", p.Name())
+}
+
+// Accepts reports that RDAP takes domains and IP addresses.
+func (p *RDAPProvider) Accepts(kind InputKind) bool {
+	return kind == KindDomain || kind.IsIP()
+}
+
+func (p *RDAPProvider) CheckAvailability() bool {
+	return true
+}
+
+func (p *RDAPProvider) Execute(ctx context.Context, domain string) (*Result, error) {
+	resp, err := DefaultRDAPClient.Lookup(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Stdout: resp.Format(), Details: resp.Info()}, nil
+}
+
+func init() {
+	RegisterProvider(&RDAPProvider{})
+}
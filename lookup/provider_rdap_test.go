@@ -0,0 +1,53 @@
+package lookup_test
+
+import (
+	"context"
+	"dlookup/lookup"
+	"strings"
+	"testing"
+)
+
+func TestRDAPProvider_StaticMethods(t *testing.T) {
+	provider, ok := lookup.GetProvider("RDAP")
+	if !ok {
+		t.Fatalf("Expected provider 'RDAP' not found.")
+	}
+	if flagName := provider.FlagName(); flagName != "rdap" {
+		t.Errorf("FlagName() = %q, want %q", flagName, "rdap")
+	}
+	if !provider.CheckAvailability() {
+		t.Errorf("CheckAvailability() = false, want true")
+	}
+}
+
+func TestRDAPProvider_Execute(t *testing.T) {
+	client, _ := startRDAPServer(t)
+	orig := lookup.DefaultRDAPClient
+	lookup.DefaultRDAPClient = client
+	defer func() { lookup.DefaultRDAPClient = orig }()
+
+	provider, _ := lookup.GetProvider("RDAP")
+	result, err := provider.Execute(context.Background(), "8.8.8.8")
+	output := outputOf(result)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	for _, want := range []string{"Network:     GOGL", "Range:       8.8.8.0 - 8.8.8.255", "registrant: Google LLC"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Execute() output missing %q. Got:\n%s", want, output)
+		}
+	}
+	info, ok := result.Details.(*lookup.RDAPInfo)
+	if !ok {
+		t.Fatalf("Details = %T, want *lookup.RDAPInfo", result.Details)
+	}
+	if info.Object != "ip network" || info.Name != "GOGL" || info.Range != "8.8.8.0 - 8.8.8.255" || len(info.Status) != 1 || info.Status[0] != "active" {
+		t.Errorf("Details = %+v", info)
+	}
+	if len(info.Events) != 1 || info.Events[0].Action != "registration" {
+		t.Errorf("Events = %+v, want the registration", info.Events)
+	}
+	if len(info.Entities) != 1 || info.Entities[0].Name != "Google LLC" || info.Entities[0].Roles[0] != "registrant" {
+		t.Errorf("Entities = %+v, want the Google LLC registrant", info.Entities)
+	}
+}
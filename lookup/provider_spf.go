@@ -0,0 +1,243 @@
+package lookup
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// SPFProvider shows the include tree of a domain's SPF record with the
+// DNS lookups of every branch and the networks its a, mx, ip4 and ip6
+// terms resolve to, and suggests a flattened record that lists those
+// networks directly. Records are fetched with the dig providers, so the
+// tree shows what `DIG (TXT)` shows, followed through every include.
+type SPFProvider struct{}
+
+func (p *SPFProvider) Name() string {
+	return "SPF"
+}
+
+func (p *SPFProvider) FlagName() string {
+	return "spf"
+}
+
+func (p *SPFProvider) Usage() string {
+	return fmt.Sprintf("Run %s (include tree and flattened record) on domains from <filename>", p.Name())
+}
+
+func (p *SPFProvider) CheckAvailability() bool {
+	return LookupCheckCommandFunc("dig")
+}
+
+// Accepts reports that the view takes domains only.
+func (p *SPFProvider) Accepts(kind InputKind) bool {
+	return kind == KindDomain
+}
+
+// SPFReport is the Details of the SPF view.
+type SPFReport struct {
+	Domain     string         `json:"domain"`
+	Root       *SPFNode       `json:"root"`
+	Flattening *SPFFlattening `json:"flattening,omitempty"`
+	// VoidLookups counts the a and mx terms that resolved to nothing.
+	VoidLookups int `json:"void_lookups"`
+}
+
+// Summary shows the record, its lookup count against the limit, and the
+// size of the flattened suggestion.
+func (r *SPFReport) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-14s %s\n", "Domain:", r.Domain)
+	if r.Root.Record == "" {
+		fmt.Fprintf(&b, "%-14s %s", "Record:", r.Root.Error)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "%-14s %s\n", "Record:", r.Root.Record)
+	lookups := fmt.Sprintf("%d of %d", r.Root.Lookups(), SPFLookupLimit)
+	if r.Root.Lookups() > SPFLookupLimit {
+		lookups += " (over the limit: receivers fail the check)"
+	}
+	fmt.Fprintf(&b, "%-14s %s\n", "DNS lookups:", lookups)
+	if r.VoidLookups > 0 {
+		fmt.Fprintf(&b, "%-14s %d of %d\n", "Void lookups:", r.VoidLookups, spfVoidLookupLimit)
+	}
+	f := r.Flattening
+	fmt.Fprintf(&b, "%-14s %d (%d duplicates, %d covered by larger networks)\n", "Networks:", len(f.Networks), len(f.Duplicates), len(f.Overlaps))
+	size := 0
+	for _, rec := range f.Records {
+		size += len(rec.Record)
+	}
+	fmt.Fprintf(&b, "%-14s %d records, %d characters, %d DNS lookups", "Flattened:", len(f.Records), size, f.Lookups)
+	return b.String()
+}
+
+// Tree renders the include tree: each record with the lookups of its
+// branch (the include or redirect itself and everything below it), then
+// its terms, with what a and mx terms resolve to. Failures are marked "✗"
+// and void lookups "!".
+func (r *SPFReport) Tree() string {
+	var b strings.Builder
+	var write func(n *SPFNode, label string, lookups, depth int)
+	write = func(n *SPFNode, label string, lookups, depth int) {
+		indent := strings.Repeat("  ", depth)
+		if n.Record == "" {
+			fmt.Fprintf(&b, "%s✗ %s  %s\n", indent, label, n.Error)
+			return
+		}
+		fmt.Fprintf(&b, "%s%s  (%s)\n", indent, label, pluralLookups(lookups))
+		if n.Error != "" {
+			fmt.Fprintf(&b, "%s  ✗ %s\n", indent, n.Error)
+		}
+		n.forEachTerm(func(t *SPFTerm, child *SPFNode) {
+			switch {
+			case child != nil:
+				write(child, t.String(), 1+child.Lookups(), depth+1)
+			case t.Error != "":
+				fmt.Fprintf(&b, "%s  ✗ %s  %s\n", indent, t, t.Error)
+			case (t.Name == "a" || t.Name == "mx") && !t.Modifier && !strings.Contains(t.Value, "%"):
+				if len(t.Networks) == 0 {
+					fmt.Fprintf(&b, "%s  ! %s → (no addresses)\n", indent, t)
+				} else {
+					fmt.Fprintf(&b, "%s  %s → %s\n", indent, t, strings.Join(t.Networks, ", "))
+				}
+			default:
+				fmt.Fprintf(&b, "%s  %s\n", indent, t)
+			}
+		})
+	}
+	write(r.Root, r.Root.Domain, r.Root.Lookups(), 0)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func pluralLookups(n int) string {
+	if n == 1 {
+		return "1 lookup"
+	}
+	return fmt.Sprintf("%d lookups", n)
+}
+
+// Listing renders the tree, the duplicate and overlapping networks, and
+// the flattened records.
+func (r *SPFReport) Listing() string {
+	var b strings.Builder
+	b.WriteString(r.Tree())
+	f := r.Flattening
+	if f == nil {
+		return b.String()
+	}
+	if len(f.Duplicates) > 0 {
+		b.WriteString("\n\nDuplicates:\n")
+		for _, d := range f.Duplicates {
+			fmt.Fprintf(&b, "! %s from %s, already listed by %s\n", d.Network, d.Source, d.CoveredBy.Source)
+		}
+	}
+	if len(f.Overlaps) > 0 {
+		b.WriteString("\n\nOverlaps:\n")
+		for _, o := range f.Overlaps {
+			fmt.Fprintf(&b, "! %s from %s is inside %s from %s\n", o.Network, o.Source, o.CoveredBy.Network, o.CoveredBy.Source)
+		}
+	}
+	if len(f.Notes) > 0 {
+		b.WriteString("\n\nNotes:\n")
+		for _, n := range f.Notes {
+			fmt.Fprintf(&b, "- %s\n", n)
+		}
+	}
+	fmt.Fprintf(&b, "\n\nFlattened (%s instead of %d):\n", pluralLookups(f.Lookups), r.Root.Lookups())
+	b.WriteString(r.CopyText())
+	b.WriteString("\n- The networks of included records change without notice; regenerate the flattened records regularly.")
+	return strings.TrimRight(strings.ReplaceAll(b.String(), "\n\n\n", "\n\n"), "\n")
+}
+
+// CopyText returns the flattened records: the record itself when it fits
+// in one, otherwise one `name TXT "record"` line per record.
+func (r *SPFReport) CopyText() string {
+	if r.Flattening == nil || len(r.Flattening.Records) == 0 {
+		return ""
+	}
+	if recs := r.Flattening.Records; len(recs) == 1 {
+		return recs[0].Record
+	}
+	var lines []string
+	for _, rec := range r.Flattening.Records {
+		lines = append(lines, fmt.Sprintf("%s. TXT %q", rec.Name, rec.Record))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// digRecords runs the dig provider called name, such as "DIG (TXT)", for
+// host with RunLookup, so it keeps that provider's timeout, retries and
+// cache, and returns the records of type t it found.
+func digRecords(ctx context.Context, name, host string, t RRType) ([]RR, error) {
+	provider, ok := GetProvider(name)
+	if !ok {
+		return nil, fmt.Errorf("%s is not available", name)
+	}
+	result, err := RunLookup(ctx, provider, host)
+	if err != nil {
+		return nil, err
+	}
+	return recordsOfType(result, t), nil
+}
+
+// Execute fetches the SPF record of domain and everything it includes with
+// `DIG (TXT)`, resolves the a and mx terms with `DIG (A)`, `DIG (AAAA)`
+// and `DIG (MX)`, and flattens the result.
+func (p *SPFProvider) Execute(ctx context.Context, domain string) (*Result, error) {
+	domain = strings.TrimSuffix(strings.TrimSpace(domain), ".")
+	txt := memoTXT(func(name string) ([]RR, error) {
+		return digRecords(ctx, "DIG (TXT)", name, TypeTXT)
+	})
+	lookup := &spfAddressLookup{
+		addrs: func(name string) ([]net.IP, error) {
+			var ips []net.IP
+			for _, q := range []struct {
+				provider string
+				t        RRType
+			}{{"DIG (A)", TypeA}, {"DIG (AAAA)", TypeAAAA}} {
+				rrs, err := digRecords(ctx, q.provider, name, q.t)
+				if err != nil {
+					return nil, err
+				}
+				for _, rr := range rrs {
+					switch d := rr.Data.(type) {
+					case *ARecord:
+						ips = append(ips, d.IP)
+					case *AAAARecord:
+						ips = append(ips, d.IP)
+					}
+				}
+			}
+			return ips, nil
+		},
+		mx: func(name string) ([]string, error) {
+			rrs, err := digRecords(ctx, "DIG (MX)", name, TypeMX)
+			var hosts []string
+			for _, rr := range rrs {
+				hosts = append(hosts, strings.TrimSuffix(rr.Data.(*MXRecord).Exchange, "."))
+			}
+			return hosts, err
+		},
+	}
+
+	if _, err := txt(domain); err != nil {
+		return nil, err
+	}
+	report := &SPFReport{Domain: domain, Root: (&spfResolver{txt: txt}).fetchSPF(domain, "", nil)}
+	result := &Result{Details: report, Server: ServerFromContext(ctx)}
+	if report.Root.Record != "" {
+		lookup.resolve(report.Root)
+		report.VoidLookups = lookup.voids
+		report.Flattening = FlattenSPF(report.Root)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	result.Stdout = report.Listing()
+	return result, nil
+}
+
+func init() {
+	RegisterProvider(&SPFProvider{})
+}
@@ -0,0 +1,152 @@
+package lookup_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"dlookup/lookup"
+)
+
+// useFakeDig answers dig commands from records, keyed by "name TYPE", in
+// the format of the dig providers' arguments: +short for A, AAAA and MX,
+// the answer section for TXT.
+func useFakeDig(t *testing.T, records map[string][]string) *[]string {
+	t.Helper()
+	origRunCommand := lookup.OsRunCommand
+	origCheckCommandFunc := lookup.LookupCheckCommandFunc
+	t.Cleanup(func() {
+		lookup.OsRunCommand = origRunCommand
+		lookup.LookupCheckCommandFunc = origCheckCommandFunc
+	})
+	lookup.LookupCheckCommandFunc = func(cmd string) bool { return cmd == "dig" }
+	var queries []string
+	lookup.OsRunCommand = func(ctx context.Context, cmdName string, args ...string) (string, string, error) {
+		key := args[0] + " " + args[1]
+		queries = append(queries, key)
+		var lines []string
+		for _, data := range records[key] {
+			if args[1] == "TXT" {
+				data = fmt.Sprintf("%s.\t300\tIN\tTXT\t%q", args[0], data)
+			}
+			lines = append(lines, data)
+		}
+		return strings.Join(lines, "\n"), "", nil
+	}
+	return &queries
+}
+
+func TestSPFProvider(t *testing.T) {
+	queries := useFakeDig(t, map[string][]string{
+		"example.com TXT":         {"v=spf1 mx include:_spf.mail.test include:_spf.mail.test ip4:192.0.2.10 -all"},
+		"example.com MX":          {"10 mx1.example.com.", "20 mx2.example.com."},
+		"mx1.example.com A":       {"192.0.2.10"},
+		"mx1.example.com AAAA":    {"2001:db8::10"},
+		"mx2.example.com A":       {"192.0.2.11"},
+		"_spf.mail.test TXT":      {"v=spf1 a:out.mail.test/24 include:_spf2.mail.test ~all"},
+		"out.mail.test A":         {"198.51.100.7"},
+		"_spf2.mail.test TXT":     {"v=spf1 ip4:198.51.100.0/25 a:gone.mail.test -all"},
+		"unrelated.example.com A": {"203.0.113.1"},
+	})
+	provider, ok := lookup.GetProviderByFlagName("spf")
+	if !ok {
+		t.Fatal("spf provider not registered")
+	}
+	result, err := provider.Execute(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	report := result.Details.(*lookup.SPFReport)
+
+	if report.Root.Lookups() != 9 || report.VoidLookups != 2 {
+		t.Errorf("Lookups() = %d, VoidLookups = %d, want 9 and 2", report.Root.Lookups(), report.VoidLookups)
+	}
+	fetched := 0
+	for _, q := range *queries {
+		if q == "_spf.mail.test TXT" {
+			fetched++
+		}
+	}
+	if fetched != 1 {
+		t.Errorf("_spf.mail.test TXT fetched %d times, want once", fetched)
+	}
+
+	tree := report.Tree()
+	for _, line := range []string{
+		"example.com  (9 lookups)",
+		"  mx → 192.0.2.10/32, 2001:db8::10/128, 192.0.2.11/32",
+		"  include:_spf.mail.test  (4 lookups)",
+		"    a:out.mail.test/24 → 198.51.100.0/24",
+		"    include:_spf2.mail.test  (2 lookups)",
+		"      ! a:gone.mail.test → (no addresses)",
+		"  ip4:192.0.2.10",
+	} {
+		if !strings.Contains(tree, line+"\n") {
+			t.Errorf("Tree() =\n%s\nwant a line %q", tree, line)
+		}
+	}
+
+	want := "v=spf1 ip4:192.0.2.10 ip4:192.0.2.11 ip4:198.51.100.0/24 ip6:2001:db8::10 -all"
+	if got := report.CopyText(); got != want {
+		t.Errorf("CopyText() = %q, want %q", got, want)
+	}
+	listing := report.Listing()
+	for _, line := range []string{
+		"! 192.0.2.10/32 from ip4:192.0.2.10, already listed by mx",
+		"! 198.51.100.0/25 from include:_spf.mail.test include:_spf2.mail.test ip4:198.51.100.0/25 is inside 198.51.100.0/24 from include:_spf.mail.test a:out.mail.test/24",
+		"Flattened (0 lookups instead of 9):\n" + want,
+	} {
+		if !strings.Contains(listing, line) {
+			t.Errorf("Listing() =\n%s\nwant %q", listing, line)
+		}
+	}
+	summary := report.Summary()
+	for _, line := range []string{
+		"DNS lookups:   9 of 10",
+		"Void lookups:  2 of 2",
+		"Flattened:     1 records, " + fmt.Sprint(len(want)) + " characters, 0 DNS lookups",
+	} {
+		if !strings.Contains(summary, line) {
+			t.Errorf("Summary() =\n%s\nwant a line %q", summary, line)
+		}
+	}
+	if result.Stdout != listing {
+		t.Errorf("Stdout = %q, want the listing", result.Stdout)
+	}
+}
+
+func TestSPFProvider_NoRecord(t *testing.T) {
+	useFakeDig(t, map[string][]string{"example.com TXT": {"google-site-verification=abc"}})
+	provider, _ := lookup.GetProviderByFlagName("spf")
+	result, err := provider.Execute(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	report := result.Details.(*lookup.SPFReport)
+	if report.Flattening != nil || !strings.HasPrefix(report.Tree(), "✗ example.com  ") {
+		t.Errorf("Tree() = %q, Flattening = %+v", report.Tree(), report.Flattening)
+	}
+	if !strings.Contains(report.Summary(), "Record:        ") {
+		t.Errorf("Summary() = %q", report.Summary())
+	}
+}
+
+func TestSPFProvider_CachesDigLookups(t *testing.T) {
+	enableCache(t)
+	queries := useFakeDig(t, map[string][]string{
+		"example.com TXT":    {"v=spf1 include:_spf.mail.test -all"},
+		"_spf.mail.test TXT": {"v=spf1 ip4:198.51.100.0/25 -all"},
+	})
+	provider, _ := lookup.GetProviderByFlagName("spf")
+	if _, err := provider.Execute(context.Background(), "example.com"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	first := len(*queries)
+	if _, err := provider.Execute(context.Background(), "example.com"); err != nil {
+		t.Fatalf("second Execute() error = %v", err)
+	}
+	if len(*queries) != first {
+		t.Errorf("second run sent %v, want every dig answer from the cache", (*queries)[first:])
+	}
+}
@@ -0,0 +1,352 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RootServers are the name servers a trace starts from. Tests can replace
+// them.
+var RootServers = []NameServer{
+	{Host: "a.root-servers.net.", Addr: "198.41.0.4"},
+	{Host: "b.root-servers.net.", Addr: "170.247.170.2"},
+	{Host: "c.root-servers.net.", Addr: "192.33.4.12"},
+	{Host: "d.root-servers.net.", Addr: "199.7.91.13"},
+	{Host: "e.root-servers.net.", Addr: "192.203.230.10"},
+	{Host: "f.root-servers.net.", Addr: "192.5.5.241"},
+	{Host: "g.root-servers.net.", Addr: "192.112.36.4"},
+	{Host: "h.root-servers.net.", Addr: "198.97.190.53"},
+	{Host: "i.root-servers.net.", Addr: "192.36.148.17"},
+	{Host: "j.root-servers.net.", Addr: "192.58.128.30"},
+	{Host: "k.root-servers.net.", Addr: "193.0.14.129"},
+	{Host: "l.root-servers.net.", Addr: "199.7.83.42"},
+	{Host: "m.root-servers.net.", Addr: "202.12.27.33"},
+}
+
+const (
+	// traceMaxHops bounds the number of zones a trace descends through.
+	traceMaxHops = 16
+	// traceServersPerZone is how many of a zone's servers are tried before
+	// the trace gives up on it.
+	traceServersPerZone = 3
+)
+
+// TraceProvider follows the delegation of a name from the root servers down
+// to its authoritative servers, as `dig +trace` does, without relying on a
+// recursive resolver. It asks for the PTR record of reverse DNS names and
+// the A record of anything else.
+type TraceProvider struct{}
+
+func (p *TraceProvider) Name() string {
+	return "TRACE"
+}
+
+func (p *TraceProvider) FlagName() string {
+	return "trace"
+}
+
+func (p *TraceProvider) Usage() string {
+	return fmt.Sprintf("Run %s (delegation from the root servers down) on domains from <filename>", p.Name())
+}
+
+func (p *TraceProvider) CheckAvailability() bool {
+	return true
+}
+
+// Accepts reports that a trace takes domains and reverse DNS names.
+func (p *TraceProvider) Accepts(kind InputKind) bool {
+	return kind == KindDomain || kind == KindReverse
+}
+
+// Live reports that traces are never cached or run as part of a report.
+func (p *TraceProvider) Live() bool {
+	return true
+}
+
+// TraceReport is the Details of a trace: one hop per zone on the way down.
+type TraceReport struct {
+	Name string     `json:"name"`
+	Type string     `json:"type"`
+	Hops []TraceHop `json:"hops"`
+	// Error says where and why the trace stopped, if it did not reach an
+	// authoritative answer.
+	Error string `json:"error,omitempty"`
+}
+
+// TraceHop is the exchange with one zone's servers.
+type TraceHop struct {
+	Zone string `json:"zone"`
+	// Server is the server that answered, or the last one tried if none did.
+	Server    NameServer    `json:"server"`
+	Latency   time.Duration `json:"-"`
+	LatencyMS int64         `json:"latency_ms"`
+	// Failures lists the servers of the zone that were tried before Server
+	// and why they did not help.
+	Failures []TraceFailure `json:"failures,omitempty"`
+	// Referral is set when Server delegated to a zone further down.
+	Referral *TraceReferral `json:"referral,omitempty"`
+	// Answer holds the final answer, from the last hop.
+	Answer []RR   `json:"answer,omitempty"`
+	Rcode  string `json:"rcode,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// TraceFailure is a server that did not give a usable response.
+type TraceFailure struct {
+	Server    NameServer    `json:"server"`
+	Latency   time.Duration `json:"-"`
+	LatencyMS int64         `json:"latency_ms"`
+	Reason    string        `json:"reason"`
+}
+
+// TraceReferral is a delegation to a child zone.
+type TraceReferral struct {
+	Zone        string   `json:"zone"`
+	NameServers []string `json:"name_servers"`
+	// Glue holds the addresses the parent supplied for the name servers.
+	Glue []RR `json:"glue,omitempty"`
+}
+
+// Summary names the authoritative zone reached, or where the trace failed.
+func (r *TraceReport) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-14s %s %s\n", "Query:", r.Name, r.Type)
+	fmt.Fprintf(&b, "%-14s %d", "Hops:", len(r.Hops))
+	if r.Error != "" {
+		fmt.Fprintf(&b, "\n%-14s %s", "Failed:", r.Error)
+	} else if n := len(r.Hops); n > 0 {
+		last := r.Hops[n-1]
+		fmt.Fprintf(&b, "\n%-14s %s (%s)", "Answered by:", last.Server, last.Zone)
+	}
+	return b.String()
+}
+
+// Tree renders the trace as an indented tree, one level per zone. Lines of
+// servers that failed start with "✗" after the indentation.
+func (r *TraceReport) Tree() string {
+	var b strings.Builder
+	for depth, hop := range r.Hops {
+		indent := strings.Repeat("   ", depth)
+		branch := "└─ "
+		if depth == 0 {
+			branch = ""
+		}
+		fmt.Fprintf(&b, "%s%s%s\n", indent, branch, hop.Zone)
+		inner := indent + strings.Repeat(" ", len([]rune(branch)))
+		for _, f := range hop.Failures {
+			fmt.Fprintf(&b, "%s✗ %s  %s  %s\n", inner, f.Server, f.Latency.Round(time.Millisecond), f.Reason)
+		}
+		if hop.Error != "" {
+			fmt.Fprintf(&b, "%s✗ %s  %s  %s\n", inner, hop.Server, hop.Latency.Round(time.Millisecond), hop.Error)
+			continue
+		}
+		fmt.Fprintf(&b, "%s@ %s  %s\n", inner, hop.Server, hop.Latency.Round(time.Millisecond))
+		if ref := hop.Referral; ref != nil {
+			fmt.Fprintf(&b, "%s  referral to %s\n", inner, ref.Zone)
+			for _, ns := range ref.NameServers {
+				glue := glueFor(ref.Glue, ns)
+				if len(glue) == 0 {
+					glue = []string{"no glue"}
+				}
+				fmt.Fprintf(&b, "%s    NS %s  (%s)\n", inner, ns, strings.Join(glue, ", "))
+			}
+			continue
+		}
+		if hop.Rcode != "" && hop.Rcode != RcodeSuccess.String() {
+			fmt.Fprintf(&b, "%s  %s\n", inner, hop.Rcode)
+		}
+		if len(hop.Answer) == 0 && hop.Rcode == RcodeSuccess.String() {
+			fmt.Fprintf(&b, "%s  (no records)\n", inner)
+		}
+		for _, rr := range hop.Answer {
+			fmt.Fprintf(&b, "%s  %s\n", inner, rr)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// glueFor returns the addresses among glue that belong to host.
+func glueFor(glue []RR, host string) []string {
+	var addrs []string
+	for _, rr := range glue {
+		if strings.EqualFold(rr.Name, host) && rr.Data != nil {
+			addrs = append(addrs, rr.Data.String())
+		}
+	}
+	return addrs
+}
+
+// Execute walks down from the root servers, following referrals until a
+// server answers authoritatively or every tried server of a zone fails.
+func (p *TraceProvider) Execute(ctx context.Context, domain string) (*Result, error) {
+	name := Fqdn(domain)
+	qtype := TypeA
+	if DetectInputKind(name) == KindReverse {
+		qtype = TypePTR
+	}
+	report := &TraceReport{Name: name, Type: qtype.String()}
+	zone, servers := ".", RootServers
+
+	var traceErr error
+	for len(report.Hops) < traceMaxHops {
+		hop, resp := traceZone(ctx, zone, servers, name, qtype)
+		report.Hops = append(report.Hops, hop)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if resp == nil {
+			traceErr = fmt.Errorf("no server of %s answered: %s", zone, hop.Error)
+			break
+		}
+		if hop.Referral == nil {
+			break
+		}
+		zone = hop.Referral.Zone
+		servers = referralServers(ctx, hop.Referral)
+		if len(servers) == 0 {
+			traceErr = fmt.Errorf("no address found for any name server of %s", zone)
+			break
+		}
+	}
+	if traceErr == nil && len(report.Hops) == traceMaxHops && report.Hops[len(report.Hops)-1].Referral != nil {
+		traceErr = fmt.Errorf("gave up after %d referrals", traceMaxHops)
+	}
+	if traceErr != nil {
+		report.Error = traceErr.Error()
+	}
+
+	result := &Result{Stdout: report.Tree(), Details: report}
+	if n := len(report.Hops); traceErr == nil && n > 0 {
+		result.Records = report.Hops[n-1].Answer
+	}
+	return result, traceErr
+}
+
+// traceZone asks up to traceServersPerZone of a zone's servers for name and
+// returns the hop with the first usable response, which is nil if there was
+// none.
+func traceZone(ctx context.Context, zone string, servers []NameServer, name string, t RRType) (TraceHop, *Message) {
+	hop := TraceHop{Zone: zone}
+	for i, server := range servers {
+		if i == traceServersPerZone || ctx.Err() != nil {
+			break
+		}
+		start := time.Now()
+		resp, err := traceQuery(ctx, server.Addr, zone, name, t)
+		latency := time.Since(start)
+		if err != nil {
+			hop.Failures = append(hop.Failures, TraceFailure{Server: server, Latency: latency, LatencyMS: latency.Milliseconds(), Reason: err.Error()})
+			continue
+		}
+		hop.Server, hop.Latency, hop.LatencyMS = server, latency, latency.Milliseconds()
+		hop.Rcode = resp.Rcode.String()
+		if ref := referral(resp, zone, name); ref != nil {
+			hop.Referral = ref
+		} else {
+			hop.Answer = resp.Answer
+		}
+		return hop, resp
+	}
+	// Report the last failure as the hop's own error.
+	if n := len(hop.Failures); n > 0 {
+		last := hop.Failures[n-1]
+		hop.Failures = hop.Failures[:n-1]
+		hop.Server, hop.Latency, hop.LatencyMS, hop.Error = last.Server, last.Latency, last.LatencyMS, last.Reason
+	} else {
+		hop.Error = "no servers to ask"
+	}
+	return hop, nil
+}
+
+// traceQuery sends a non-recursive query to addr, a server of zone, and
+// checks that the response is usable: an authoritative answer or a referral
+// further down.
+func traceQuery(ctx context.Context, addr, zone, name string, t RRType) (*Message, error) {
+	q := NewQuery(name, t)
+	q.RecursionDesired = false
+	resp, _, err := resolverFor(addr).Exchange(ctx, q)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil:
+		return nil, errors.New("timeout")
+	case err != nil:
+		return nil, err
+	case resp.Rcode != RcodeSuccess && resp.Rcode != RcodeNameError:
+		return nil, errors.New(resp.Rcode.String())
+	case !resp.Authoritative && referral(resp, zone, name) == nil:
+		return nil, fmt.Errorf("lame delegation: not authoritative for %s", zone)
+	}
+	return resp, nil
+}
+
+// referral returns the delegation in resp to a zone below zone that name
+// belongs to, or nil if resp is not such a referral.
+func referral(resp *Message, zone, name string) *TraceReferral {
+	if resp.Authoritative && len(resp.Answer) > 0 {
+		return nil
+	}
+	var ref *TraceReferral
+	for _, rr := range resp.Authority {
+		ns, ok := rr.Data.(*NSRecord)
+		if !ok || strings.EqualFold(rr.Name, zone) || !isSubdomain(rr.Name, zone) || !isSubdomain(name, rr.Name) {
+			continue
+		}
+		if ref == nil {
+			ref = &TraceReferral{Zone: rr.Name}
+		}
+		if strings.EqualFold(rr.Name, ref.Zone) {
+			ref.NameServers = append(ref.NameServers, ns.Host)
+		}
+	}
+	if ref == nil {
+		return nil
+	}
+	for _, rr := range resp.Additional {
+		if rr.Type != TypeA && rr.Type != TypeAAAA {
+			continue
+		}
+		for _, ns := range ref.NameServers {
+			if strings.EqualFold(rr.Name, ns) {
+				ref.Glue = append(ref.Glue, rr)
+				break
+			}
+		}
+	}
+	return ref
+}
+
+// referralServers returns the servers to ask next: those with IPv4 glue
+// first, then the rest with addresses from the system resolver.
+func referralServers(ctx context.Context, ref *TraceReferral) []NameServer {
+	var glued, unglued []NameServer
+	for _, host := range ref.NameServers {
+		addr := ""
+		for _, rr := range ref.Glue {
+			if rr.Type == TypeA && strings.EqualFold(rr.Name, host) {
+				addr = rr.Data.String()
+				break
+			}
+		}
+		if addr != "" {
+			glued = append(glued, NameServer{Host: host, Addr: addr})
+		} else {
+			unglued = append(unglued, NameServer{Host: host})
+		}
+	}
+	servers := glued
+	for _, s := range unglued {
+		if len(servers) >= traceServersPerZone {
+			break
+		}
+		if s.Addr = lookupAddr(ctx, DefaultResolver, s.Host); s.Addr != "" {
+			servers = append(servers, s)
+		}
+	}
+	return servers
+}
+
+func init() {
+	RegisterProvider(&TraceProvider{})
+}
@@ -0,0 +1,146 @@
+package lookup_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"dlookup/lookup"
+)
+
+// useRootServers replaces the root servers a trace starts from.
+func useRootServers(t *testing.T, servers ...lookup.NameServer) {
+	t.Helper()
+	orig := lookup.RootServers
+	lookup.RootServers = servers
+	t.Cleanup(func() { lookup.RootServers = orig })
+}
+
+func referralTo(zone string, glue map[string]string, hosts ...string) dnsHandler {
+	return func(q *lookup.Message, tcp bool) *lookup.Message {
+		resp := &lookup.Message{}
+		for _, host := range hosts {
+			resp.Authority = append(resp.Authority, nsRecord(zone, host))
+			if ip, ok := glue[host]; ok {
+				resp.Additional = append(resp.Additional, aRecord(host, ip, 3600))
+			}
+		}
+		return resp
+	}
+}
+
+// traceHierarchy serves example.com through a root and a com. zone. exampleNS
+// answers for both of example.com's servers.
+func traceHierarchy(exampleNS dnsHandler) map[string]dnsHandler {
+	return map[string]dnsHandler{
+		"198.51.100.1:53": referralTo("com.", map[string]string{"a.gtld.test.": "198.51.100.2", "b.gtld.test.": "198.51.100.3"}, "a.gtld.test.", "b.gtld.test."),
+		"198.51.100.3:53": referralTo("example.com.", map[string]string{"ns1.example.com.": "192.0.2.11", "ns2.example.com.": "192.0.2.12"}, "ns1.example.com.", "ns2.example.com."),
+		"192.0.2.11:53": func(q *lookup.Message, tcp bool) *lookup.Message {
+			return &lookup.Message{Header: lookup.Header{Rcode: lookup.RcodeRefused}}
+		},
+		"192.0.2.12:53": exampleNS,
+	}
+}
+
+func TestTraceProvider_Execute(t *testing.T) {
+	// a.gtld.test (198.51.100.2) is unreachable.
+	useFakeDNS(t, traceHierarchy(func(q *lookup.Message, tcp bool) *lookup.Message {
+		return &lookup.Message{
+			Header: lookup.Header{Authoritative: true},
+			Answer: []lookup.RR{aRecord(q.Question[0].Name, "192.0.2.80", 300)},
+		}
+	}))
+	useRootServers(t, lookup.NameServer{Host: "a.root.test.", Addr: "198.51.100.1"})
+
+	provider, ok := lookup.GetProviderByFlagName("trace")
+	if !ok {
+		t.Fatal("trace provider not registered")
+	}
+	result, err := provider.Execute(context.Background(), "www.example.com")
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	report := result.Details.(*lookup.TraceReport)
+
+	var zones []string
+	for _, hop := range report.Hops {
+		zones = append(zones, hop.Zone)
+	}
+	if got := strings.Join(zones, " "); got != ". com. example.com." {
+		t.Fatalf("hops = %q, want . com. example.com.", got)
+	}
+	if ref := report.Hops[0].Referral; ref == nil || ref.Zone != "com." || len(ref.Glue) != 2 {
+		t.Errorf("root referral = %+v, want com. with 2 glue records", ref)
+	}
+	if f := report.Hops[1].Failures; len(f) != 1 || f[0].Server.Addr != "198.51.100.2" {
+		t.Errorf("com. failures = %+v, want a.gtld.test", f)
+	}
+	if f := report.Hops[2].Failures; len(f) != 1 || f[0].Reason != "REFUSED" {
+		t.Errorf("example.com. failures = %+v, want REFUSED from ns1", f)
+	}
+	if len(result.Records) != 1 || result.Records[0].Data.String() != "192.0.2.80" {
+		t.Errorf("Records = %v, want the authoritative answer", result.Records)
+	}
+
+	tree := result.Stdout
+	for _, want := range []string{
+		".\n@ a.root.test (198.51.100.1)",
+		"\n  referral to com.\n",
+		"NS a.gtld.test.  (198.51.100.2)",
+		"   └─ com.\n      ✗ a.gtld.test (198.51.100.2)",
+		"      └─ example.com.\n         ✗ ns1.example.com (192.0.2.11)  0s  REFUSED",
+		"         @ ns2.example.com (192.0.2.12)",
+		"www.example.com.\t300\tIN\tA\t192.0.2.80",
+	} {
+		if !strings.Contains(tree, want) {
+			t.Errorf("tree does not contain %q:\n%s", want, tree)
+		}
+	}
+}
+
+func TestTraceProvider_ReverseName(t *testing.T) {
+	useFakeDNS(t, map[string]dnsHandler{
+		"198.51.100.1:53": referralTo("2.0.192.in-addr.arpa.", map[string]string{"ns1.example.net.": "192.0.2.11"}, "ns1.example.net."),
+		"192.0.2.11:53": func(q *lookup.Message, tcp bool) *lookup.Message {
+			resp := &lookup.Message{Header: lookup.Header{Authoritative: true}}
+			if q.Question[0].Type == lookup.TypePTR {
+				resp.Answer = append(resp.Answer, ptrRecord(q.Question[0].Name, "host.example.net."))
+			}
+			return resp
+		},
+	})
+	useRootServers(t, lookup.NameServer{Host: "a.root.test.", Addr: "198.51.100.1"})
+
+	provider, _ := lookup.GetProviderByFlagName("trace")
+	result, err := provider.Execute(context.Background(), "80.2.0.192.in-addr.arpa")
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if report := result.Details.(*lookup.TraceReport); report.Type != "PTR" {
+		t.Errorf("Type = %q, want PTR", report.Type)
+	}
+	if len(result.Records) != 1 || result.Records[0].Data.String() != "host.example.net." {
+		t.Errorf("Records = %v, want the PTR answer", result.Records)
+	}
+}
+
+func TestTraceProvider_LameDelegation(t *testing.T) {
+	useFakeDNS(t, traceHierarchy(func(q *lookup.Message, tcp bool) *lookup.Message {
+		return &lookup.Message{} // Neither an answer nor a referral
+	}))
+	useRootServers(t, lookup.NameServer{Host: "a.root.test.", Addr: "198.51.100.1"})
+
+	provider, _ := lookup.GetProviderByFlagName("trace")
+	result, err := provider.Execute(context.Background(), "example.com")
+	if err == nil || !strings.Contains(err.Error(), "no server of example.com. answered") {
+		t.Fatalf("Execute() error = %v, want a failure at example.com.", err)
+	}
+	report := result.Details.(*lookup.TraceReport)
+	last := report.Hops[len(report.Hops)-1]
+	if !strings.Contains(last.Error, "lame delegation") {
+		t.Errorf("last hop error = %q, want lame delegation", last.Error)
+	}
+	if !strings.Contains(result.Summary(), "Failed:") {
+		t.Errorf("Summary() = %q, want the failure", result.Summary())
+	}
+}
@@ -1,6 +1,9 @@
 package lookup
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+)
 
 type WhoisProvider struct{}
 
@@ -16,12 +19,24 @@ func (p *WhoisProvider) Usage() string {
 	return fmt.Sprintf("Run %s lookup on domains from <filename>", p.Name())
 }
 
-func (p *WhoisProvider) Execute(domain string) (string, error) {
+func (p *WhoisProvider) Execute(ctx context.Context, domain string) (*Result, error) {
 	if !p.CheckAvailability() {
-		return "", fmt.Errorf("command not found: whois")
+		return nil, fmt.Errorf("command not found: whois")
 	}
 
-	return RunCommand("whois", domain) // Use exported RunCommand
+	result, err := RunCommand(ctx, "whois", domain) // Use exported RunCommand
+	if err != nil {
+		return result, err
+	}
+	if info := ParseWhois(result.Stdout); !info.IsEmpty() {
+		result.Details = info
+	}
+	return result, nil
+}
+
+// Accepts reports that WHOIS takes domains and IP addresses.
+func (p *WhoisProvider) Accepts(kind InputKind) bool {
+	return kind == KindDomain || kind.IsIP()
 }
 
 func (p *WhoisProvider) CheckAvailability() bool {
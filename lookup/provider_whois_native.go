@@ -0,0 +1,78 @@
+package lookup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// NativeWhoisProvider queries WHOIS servers directly instead of running the
+// system whois binary, whose behaviour differs between platforms.
+type NativeWhoisProvider struct{}
+
+func (p *NativeWhoisProvider) Name() string {
+	return "WHOIS (NATIVE)"
+}
+
+func (p *NativeWhoisProvider) FlagName() string {
+	return "whois-native"
+}
+
+func (p *NativeWhoisProvider) Usage() string {
+	return fmt.Sprintf("Run %s lookup on domains from <filename>", p.Name())
+}
+
+// Accepts reports that WHOIS takes domains and IP addresses.
+func (p *NativeWhoisProvider) Accepts(kind InputKind) bool {
+	return kind == KindDomain || kind.IsIP()
+}
+
+func (p *NativeWhoisProvider) CheckAvailability() bool {
+	return true
+}
+
+func (p *NativeWhoisProvider) Execute(ctx context.Context, domain string) (*Result, error) {
+	responses, err := DefaultWhoisClient.Lookup(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Stdout: formatWhoisResponses(responses)}
+	info := &WhoisInfo{}
+	for _, r := range responses {
+		if r.Err == nil {
+			info.merge(ParseWhois(r.Body))
+		}
+	}
+	if !info.IsEmpty() {
+		result.Details = info
+	}
+	return result, nil
+}
+
+func formatWhoisResponses(responses []WhoisResponse) string {
+	var b strings.Builder
+	for i, r := range responses {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		label := "Server"
+		if i > 0 {
+			label = "Referral"
+		}
+		if r.Err != nil {
+			fmt.Fprintf(&b, "%% %s: %s (failed: %v)", label, r.Server, r.Err)
+			continue
+		}
+		fmt.Fprintf(&b, "%% %s: %s\n", label, r.Server)
+		if r.Body == "" {
+			b.WriteString("(No results found)")
+		} else {
+			b.WriteString(r.Body)
+		}
+	}
+	return b.String()
+}
+
+func init() {
+	RegisterProvider(&NativeWhoisProvider{})
+}
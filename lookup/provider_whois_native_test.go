@@ -0,0 +1,77 @@
+package lookup_test
+
+import (
+	"context"
+	"dlookup/lookup"
+	"strings"
+	"testing"
+)
+
+func TestNativeWhoisProvider_StaticMethods(t *testing.T) {
+	provider, ok := lookup.GetProvider("WHOIS (NATIVE)")
+	if !ok {
+		t.Fatalf("Expected provider 'WHOIS (NATIVE)' not found.")
+	}
+	if flagName := provider.FlagName(); flagName != "whois-native" {
+		t.Errorf("FlagName() = %q, want %q", flagName, "whois-native")
+	}
+	if !provider.CheckAvailability() {
+		t.Errorf("CheckAvailability() = false, want true")
+	}
+	if usage := provider.Usage(); !strings.HasPrefix(usage, "Run WHOIS (NATIVE)") {
+		t.Errorf("Usage() = %q", usage)
+	}
+}
+
+func TestNativeWhoisProvider_Execute(t *testing.T) {
+	n := newWhoisNetwork(t, map[string]func(string) string{
+		"whois.verisign-grs.com": func(q string) string {
+			return "Domain Name: EXAMPLE.COM\nRegistrar WHOIS Server: whois.registrar.test\n"
+		},
+		"whois.registrar.test": func(q string) string {
+			return "Registrant Organization: Example Inc.\n"
+		},
+	})
+	orig := lookup.DefaultWhoisClient
+	lookup.DefaultWhoisClient = n.client()
+	defer func() { lookup.DefaultWhoisClient = orig }()
+
+	provider, _ := lookup.GetProvider("WHOIS (NATIVE)")
+	result, err := provider.Execute(context.Background(), "example.com")
+	output := outputOf(result)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	for _, want := range []string{
+		"% Server: whois.verisign-grs.com\nDomain Name: EXAMPLE.COM",
+		"% Referral: whois.registrar.test\nRegistrant Organization: Example Inc.",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Execute() output missing %q. Got:\n%s", want, output)
+		}
+	}
+
+	// The registry and registrar responses are merged into one summary.
+	info, ok := result.Details.(*lookup.WhoisInfo)
+	if !ok {
+		t.Fatalf("Execute() details = %T, want *lookup.WhoisInfo", result.Details)
+	}
+	if info.Domain != "example.com" || info.Organization != "Example Inc." {
+		t.Errorf("Execute() details = %+v, want domain and registrant organization", info)
+	}
+	if !strings.HasPrefix(result.Text(), "Domain:        example.com\n") {
+		t.Errorf("Text() does not start with the summary. Got:\n%s", result.Text())
+	}
+}
+
+func TestNativeWhoisProvider_ConnectFailure(t *testing.T) {
+	n := newWhoisNetwork(t, nil)
+	orig := lookup.DefaultWhoisClient
+	lookup.DefaultWhoisClient = n.client()
+	defer func() { lookup.DefaultWhoisClient = orig }()
+
+	provider, _ := lookup.GetProvider("WHOIS (NATIVE)")
+	if _, err := provider.Execute(context.Background(), "example.com"); err == nil {
+		t.Errorf("Execute() error = nil, want connection error")
+	}
+}
@@ -1,6 +1,7 @@
 package lookup_test
 
 import (
+	"context"
 	"dlookup/lookup"
 	"fmt"
 	"strings"
@@ -73,13 +74,14 @@ func TestWhoisProvider_Execute(t *testing.T) {
 	// Test Case 1: Successful execution
 	t.Run("Success", func(t *testing.T) {
 		expectedOutput := "Mocked whois output for " + domainToTest
-		lookup.OsRunCommand = func(cmdName string, args ...string) (string, error) {
+		lookup.OsRunCommand = func(ctx context.Context, cmdName string, args ...string) (string, string, error) {
 			capturedCmdName = cmdName
 			capturedArgs = args
-			return expectedOutput, nil
+			return expectedOutput, "", nil
 		}
 
-		output, err := provider.Execute(domainToTest)
+		result, err := provider.Execute(context.Background(), domainToTest)
+		output := outputOf(result)
 		if err != nil {
 			t.Errorf("Execute() error = %v, want nil", err)
 		}
@@ -98,13 +100,14 @@ func TestWhoisProvider_Execute(t *testing.T) {
 	// Test Case 2: Command execution failure
 	t.Run("CommandFailure", func(t *testing.T) {
 		mockError := fmt.Errorf("mocked whois error")
-		lookup.OsRunCommand = func(cmdName string, args ...string) (string, error) {
+		lookup.OsRunCommand = func(ctx context.Context, cmdName string, args ...string) (string, string, error) {
 			capturedCmdName = cmdName
 			capturedArgs = args
-			return "error whois output", mockError
+			return "error whois output", "", mockError
 		}
 
-		output, err := provider.Execute(domainToTest)
+		result, err := provider.Execute(context.Background(), domainToTest)
+		output := outputOf(result)
 		if err == nil {
 			t.Fatalf("Execute() error = nil, want non-nil")
 		}
@@ -116,7 +119,7 @@ func TestWhoisProvider_Execute(t *testing.T) {
 			t.Errorf("Execute() error = %q, want %q", err.Error(), expectedWrappedErrStr)
 		}
 
-		// The mock OsRunCommand returns "error whois output" as the string part.
+		// The mock OsRunCommand returns "error whois output" as stdout.
 		if output != "error whois output" {
 			t.Errorf("Execute() output on error = %q, want %q", output, "error whois output")
 		}
@@ -124,15 +127,16 @@ func TestWhoisProvider_Execute(t *testing.T) {
 
 	// Test Case 3: Command produces no output (but no error)
 	t.Run("NoOutput", func(t *testing.T) {
-		// RunCommand should convert empty raw output to "(No results found)"
+		// Result.Output should turn empty stdout into "(No results found)"
 		expectedOutput := "(No results found)"
-		lookup.OsRunCommand = func(cmdName string, args ...string) (string, error) {
+		lookup.OsRunCommand = func(ctx context.Context, cmdName string, args ...string) (string, string, error) {
 			capturedCmdName = cmdName
 			capturedArgs = args
-			return "", nil // Mock OsRunCommand returns empty string and nil error
+			return "", "", nil // Mock OsRunCommand returns empty output and nil error
 		}
 
-		output, err := provider.Execute("nodata.example.com")
+		result, err := provider.Execute(context.Background(), "nodata.example.com")
+		output := outputOf(result)
 		if err != nil {
 			t.Errorf("Execute() error = %v, want nil", err)
 		}
@@ -141,3 +145,32 @@ func TestWhoisProvider_Execute(t *testing.T) {
 		}
 	})
 }
+
+func TestWhoisProvider_ParsesSummary(t *testing.T) {
+	origRunCommand := lookup.OsRunCommand
+	defer func() { lookup.OsRunCommand = origRunCommand }()
+	origCheckCommandFunc := lookup.LookupCheckCommandFunc
+	defer func() { lookup.LookupCheckCommandFunc = origCheckCommandFunc }()
+	lookup.LookupCheckCommandFunc = func(cmd string) bool { return cmd == "whois" }
+	lookup.OsRunCommand = func(ctx context.Context, cmdName string, args ...string) (string, string, error) {
+		return readWhoisFixture(t, "verisign_thin.txt"), "", nil
+	}
+
+	provider, _ := lookup.GetProvider("WHOIS")
+	result, err := provider.Execute(context.Background(), "google.com")
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	info, ok := result.Details.(*lookup.WhoisInfo)
+	if !ok || info.Registrar != "MarkMonitor Inc." {
+		t.Errorf("Execute() details = %#v, want parsed WHOIS info", result.Details)
+	}
+
+	lookup.OsRunCommand = func(ctx context.Context, cmdName string, args ...string) (string, string, error) {
+		return "No match for \"NOPE.COM\".", "", nil
+	}
+	result, _ = provider.Execute(context.Background(), "nope.com")
+	if result.Details != nil {
+		t.Errorf("Execute() details = %#v for a no-match response, want nil", result.Details)
+	}
+}
@@ -0,0 +1,96 @@
+package lookup
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter is a set of token buckets keyed by server, shared by all lookups
+// so concurrent lookups together stay within a server's rate.
+type Limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// DefaultLimiter is the limiter the built-in clients wait on before each
+// query.
+var DefaultLimiter = &Limiter{}
+
+// Wait blocks until a query to key is allowed at rate queries per second
+// with bursts of up to burst, and returns how long it waited. A rate of
+// zero or less never waits.
+func (l *Limiter) Wait(ctx context.Context, key string, rate float64, burst int) (time.Duration, error) {
+	if rate <= 0 {
+		return 0, nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+
+	l.mu.Lock()
+	if l.buckets == nil {
+		l.buckets = make(map[string]*tokenBucket)
+	}
+	now := time.Now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	// Take the token now, even if that leaves the bucket in debt, so waiters
+	// are served in order.
+	b.tokens--
+	wait := time.Duration(0)
+	if b.tokens < 0 {
+		wait = time.Duration(-b.tokens / rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if err := sleepCtx(ctx, wait); err != nil {
+		l.mu.Lock()
+		b.tokens++
+		l.mu.Unlock()
+		return 0, err
+	}
+	return wait, nil
+}
+
+type attemptStatsKey struct{}
+
+// attemptStats collects what happened inside one RunLookup call.
+type attemptStats struct {
+	policy Policy
+
+	mu        sync.Mutex
+	throttled time.Duration
+}
+
+// retriedByRunLookup reports whether the running lookup is retried by
+// RunLookup, so a command such as dig should try only once per attempt and
+// leave the retries to the policy.
+func retriedByRunLookup(ctx context.Context) bool {
+	stats, ok := ctx.Value(attemptStatsKey{}).(*attemptStats)
+	return ok && stats.policy.MaxAttempts > 1
+}
+
+// waitServer waits until the running lookup may query server, under the
+// rate limit of its provider's policy. Outside RunLookup it does not wait.
+func waitServer(ctx context.Context, server string) error {
+	stats, ok := ctx.Value(attemptStatsKey{}).(*attemptStats)
+	if !ok {
+		return nil
+	}
+	waited, err := DefaultLimiter.Wait(ctx, server, stats.policy.RateLimit, stats.policy.Burst)
+	stats.mu.Lock()
+	stats.throttled += waited
+	stats.mu.Unlock()
+	return err
+}
@@ -0,0 +1,171 @@
+package lookup_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"dlookup/lookup"
+)
+
+func TestLimiter_Wait(t *testing.T) {
+	var l lookup.Limiter
+	ctx := context.Background()
+
+	// A burst of 2 passes at once; the third query waits for a token at 20/s.
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if waited, err := l.Wait(ctx, "a", 20, 2); err != nil || waited != 0 {
+			t.Fatalf("Wait() #%d = (%s, %v), want no wait", i+1, waited, err)
+		}
+	}
+	waited, err := l.Wait(ctx, "a", 20, 2)
+	if err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if waited < 40*time.Millisecond || time.Since(start) < 40*time.Millisecond {
+		t.Errorf("third Wait() waited %s, want about 50ms", waited)
+	}
+
+	// Buckets are per key.
+	if waited, _ := l.Wait(ctx, "b", 20, 2); waited != 0 {
+		t.Errorf("Wait() on another key waited %s, want 0", waited)
+	}
+	// No rate means no limit.
+	for i := 0; i < 10; i++ {
+		if waited, _ := l.Wait(ctx, "a", 0, 0); waited != 0 {
+			t.Fatalf("Wait() without a rate waited %s", waited)
+		}
+	}
+}
+
+func TestLimiter_WaitCancelled(t *testing.T) {
+	var l lookup.Limiter
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	l.Wait(ctx, "slow", 1, 1)
+	if _, err := l.Wait(ctx, "slow", 1, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestRunLookup_RateLimitsPerServer(t *testing.T) {
+	var mu sync.Mutex
+	var sent []time.Time
+	original := lookup.DNSExchange
+	lookup.DNSExchange = func(ctx context.Context, server string, q *lookup.Message) (*lookup.Message, error) {
+		mu.Lock()
+		sent = append(sent, time.Now())
+		mu.Unlock()
+		resp := *q
+		resp.Response = true
+		return &resp, nil
+	}
+	defer func() { lookup.DNSExchange = original }()
+	resolver := lookup.DefaultResolver.Servers
+	lookup.DefaultResolver.Servers = []string{"192.0.2.99"}
+	defer func() { lookup.DefaultResolver.Servers = resolver }()
+
+	provider, ok := lookup.GetProviderByFlagName("dns-a")
+	if !ok {
+		t.Fatal("dns-a provider not registered")
+	}
+	lookup.SetProviderPolicy("dns-a", lookup.Policy{MaxAttempts: 1, RateLimit: 25, Burst: 1})
+	defer lookup.SetProviderPolicy("dns-a", lookup.DefaultPolicy)
+
+	var throttled time.Duration
+	for i := 0; i < 3; i++ {
+		result, err := lookup.RunLookup(context.Background(), provider, "example.com")
+		if err != nil {
+			t.Fatalf("RunLookup() error = %v", err)
+		}
+		throttled += result.Throttled
+	}
+	if gap := sent[2].Sub(sent[0]); gap < 70*time.Millisecond {
+		t.Errorf("3 queries at 25/s took %s, want at least 80ms", gap)
+	}
+	if throttled < 70*time.Millisecond {
+		t.Errorf("results report %s throttled in total, want about 80ms", throttled)
+	}
+}
+
+func TestRunCommand_RateLimitsByChosenServer(t *testing.T) {
+	origRunCommand := lookup.OsRunCommand
+	origCheckCommandFunc := lookup.LookupCheckCommandFunc
+	defer func() {
+		lookup.OsRunCommand = origRunCommand
+		lookup.LookupCheckCommandFunc = origCheckCommandFunc
+	}()
+	lookup.LookupCheckCommandFunc = func(cmd string) bool { return cmd == "dig" }
+	lookup.OsRunCommand = func(ctx context.Context, cmdName string, args ...string) (string, string, error) {
+		return "192.0.2.10", "", nil
+	}
+
+	provider, ok := lookup.GetProviderByFlagName("dig-dig-a")
+	if !ok {
+		t.Fatal("dig-dig-a provider not registered")
+	}
+	lookup.SetProviderPolicy("dig-dig-a", lookup.Policy{MaxAttempts: 1, RateLimit: 10, Burst: 1})
+	defer lookup.SetProviderPolicy("dig-dig-a", lookup.DefaultPolicy)
+
+	run := func(server string) time.Duration {
+		t.Helper()
+		result, err := lookup.RunLookup(lookup.WithServer(context.Background(), server), provider, "example.com")
+		if err != nil {
+			t.Fatalf("RunLookup() @%s error = %v", server, err)
+		}
+		return result.Throttled
+	}
+	// Each server has its own bucket, so the second server is not held up
+	// by the first; a second run against the first server is.
+	run("192.0.2.51")
+	if throttled := run("192.0.2.52"); throttled != 0 {
+		t.Errorf("first run against another server throttled %s, want 0", throttled)
+	}
+	if throttled := run("192.0.2.51:53"); throttled < 50*time.Millisecond {
+		t.Errorf("second run against the same server throttled %s, want about 100ms", throttled)
+	}
+}
+
+func TestRunCommand_WhoisIgnoresChosenServer(t *testing.T) {
+	origRunCommand := lookup.OsRunCommand
+	origCheckCommandFunc := lookup.LookupCheckCommandFunc
+	defer func() {
+		lookup.OsRunCommand = origRunCommand
+		lookup.LookupCheckCommandFunc = origCheckCommandFunc
+	}()
+	lookup.LookupCheckCommandFunc = func(cmd string) bool { return cmd == "dig" || cmd == "whois" }
+	lookup.OsRunCommand = func(ctx context.Context, cmdName string, args ...string) (string, string, error) {
+		return "192.0.2.10", "", nil
+	}
+
+	dig, ok := lookup.GetProviderByFlagName("dig-dig-a")
+	if !ok {
+		t.Fatal("dig-dig-a provider not registered")
+	}
+	whois, ok := lookup.GetProviderByFlagName("whois")
+	if !ok {
+		t.Fatal("whois provider not registered")
+	}
+	policy := lookup.Policy{MaxAttempts: 1, RateLimit: 10, Burst: 1}
+	lookup.SetProviderPolicy("dig-dig-a", policy)
+	defer lookup.SetProviderPolicy("dig-dig-a", lookup.DefaultPolicy)
+	lookup.SetProviderPolicy("whois", policy)
+	defer lookup.SetProviderPolicy("whois", lookup.DefaultPolicy)
+
+	// dig empties the server's bucket; whois does not query that server, so
+	// it must not wait for it.
+	ctx := lookup.WithRefresh(lookup.WithServer(context.Background(), "192.0.2.53"))
+	if _, err := lookup.RunLookup(ctx, dig, "example.com"); err != nil {
+		t.Fatalf("RunLookup(dig) error = %v", err)
+	}
+	result, err := lookup.RunLookup(ctx, whois, "example.com")
+	if err != nil {
+		t.Fatalf("RunLookup(whois) error = %v", err)
+	}
+	if result.Throttled != 0 {
+		t.Errorf("whois run throttled %s, want 0", result.Throttled)
+	}
+}
@@ -0,0 +1,541 @@
+package lookup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RDAPClient looks up registration data over RDAP (RFC 9082/9083), locating
+// the authoritative server through the IANA bootstrap registries (RFC 9224).
+type RDAPClient struct {
+	// BootstrapURL is the base URL of the IANA bootstrap files (dns.json,
+	// ipv4.json, ipv6.json and asn.json).
+	BootstrapURL string
+	HTTPClient   *http.Client
+
+	mu        sync.Mutex
+	bootstrap map[string]*rdapBootstrap
+}
+
+// DefaultRDAPClient is used by the RDAP provider.
+var DefaultRDAPClient = &RDAPClient{
+	BootstrapURL: "https://data.iana.org/rdap/",
+	HTTPClient:   &http.Client{Timeout: 20 * time.Second},
+}
+
+type rdapBootstrap struct {
+	Services [][][]string `json:"services"`
+}
+
+// RDAPResponse is the subset of an RDAP object that dlookup renders. Domain,
+// IP network and autnum responses share the same shape.
+type RDAPResponse struct {
+	ObjectClassName string           `json:"objectClassName"`
+	Handle          string           `json:"handle"`
+	LDHName         string           `json:"ldhName"`
+	UnicodeName     string           `json:"unicodeName"`
+	Name            string           `json:"name"`
+	Type            string           `json:"type"`
+	Country         string           `json:"country"`
+	StartAddress    string           `json:"startAddress"`
+	EndAddress      string           `json:"endAddress"`
+	StartAutnum     *uint32          `json:"startAutnum"`
+	EndAutnum       *uint32          `json:"endAutnum"`
+	Status          []string         `json:"status"`
+	Events          []RDAPEvent      `json:"events"`
+	Nameservers     []RDAPNameserver `json:"nameservers"`
+	Entities        []RDAPEntity     `json:"entities"`
+	SecureDNS       *struct {
+		DelegationSigned bool `json:"delegationSigned"`
+	} `json:"secureDNS"`
+	Port43 string `json:"port43"`
+
+	// Source is the URL the response was fetched from.
+	Source string `json:"-"`
+}
+
+type RDAPEvent struct {
+	Action string `json:"eventAction"`
+	Date   string `json:"eventDate"`
+	Actor  string `json:"eventActor"`
+}
+
+type RDAPNameserver struct {
+	LDHName string `json:"ldhName"`
+}
+
+type RDAPEntity struct {
+	Handle    string          `json:"handle"`
+	Roles     []string        `json:"roles"`
+	VCard     json.RawMessage `json:"vcardArray"`
+	Entities  []RDAPEntity    `json:"entities"`
+	PublicIDs []struct {
+		Type       string `json:"type"`
+		Identifier string `json:"identifier"`
+	} `json:"publicIds"`
+}
+
+type rdapError struct {
+	ErrorCode   int      `json:"errorCode"`
+	Title       string   `json:"title"`
+	Description []string `json:"description"`
+}
+
+// Lookup fetches the RDAP object for a domain name, an IP address or an AS
+// number ("AS15169" or "15169").
+func (c *RDAPClient) Lookup(ctx context.Context, query string) (*RDAPResponse, error) {
+	query = strings.TrimSuffix(strings.TrimSpace(query), ".")
+	registry, path, key, err := rdapQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	base, err := c.baseURL(ctx, registry, key)
+	if err != nil {
+		return nil, err
+	}
+
+	url := strings.TrimSuffix(base, "/") + "/" + path
+	var resp RDAPResponse
+	if err := c.getJSON(ctx, url, &resp); err != nil {
+		return nil, err
+	}
+	resp.Source = url
+	return &resp, nil
+}
+
+// rdapQuery classifies the query and returns the bootstrap registry to use,
+// the RDAP path, and the value to match against the registry.
+func rdapQuery(query string) (registry, path, key string, err error) {
+	if ip := net.ParseIP(query); ip != nil {
+		if ip.To4() != nil {
+			return "ipv4", "ip/" + query, query, nil
+		}
+		return "ipv6", "ip/" + query, query, nil
+	}
+	asn := query
+	if len(asn) > 2 && strings.EqualFold(asn[:2], "AS") {
+		asn = asn[2:]
+	}
+	if _, err := strconv.ParseUint(asn, 10, 32); err == nil {
+		return "asn", "autnum/" + asn, asn, nil
+	}
+	if query == "" || strings.ContainsAny(query, "/ ") {
+		return "", "", "", fmt.Errorf("rdap: %q is not a domain, IP address or AS number", query)
+	}
+	return "dns", "domain/" + strings.ToLower(query), strings.ToLower(query), nil
+}
+
+// baseURL finds the RDAP service for key in the named bootstrap registry.
+func (c *RDAPClient) baseURL(ctx context.Context, registry, key string) (string, error) {
+	bs, err := c.loadBootstrap(ctx, registry)
+	if err != nil {
+		return "", err
+	}
+
+	best, bestLen := "", -1
+	for _, service := range bs.Services {
+		if len(service) < 2 || len(service[1]) == 0 {
+			continue
+		}
+		for _, entry := range service[0] {
+			if n := rdapMatch(registry, entry, key); n > bestLen {
+				best, bestLen = preferHTTPS(service[1]), n
+			}
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("rdap: no RDAP service listed for %s", key)
+	}
+	return best, nil
+}
+
+// rdapMatch reports how specifically a bootstrap entry matches key, or -1.
+func rdapMatch(registry, entry, key string) int {
+	switch registry {
+	case "dns":
+		entry = strings.ToLower(entry)
+		if key == entry || strings.HasSuffix(key, "."+entry) {
+			return strings.Count(entry, ".") + 1
+		}
+	case "ipv4", "ipv6":
+		prefix, err := netip.ParsePrefix(entry)
+		addr, err2 := netip.ParseAddr(key)
+		if err == nil && err2 == nil && prefix.Contains(addr) {
+			return prefix.Bits()
+		}
+	case "asn":
+		lo, hi, found := strings.Cut(entry, "-")
+		if !found {
+			hi = lo
+		}
+		start, err1 := strconv.ParseUint(lo, 10, 32)
+		end, err2 := strconv.ParseUint(hi, 10, 32)
+		n, err3 := strconv.ParseUint(key, 10, 32)
+		if err1 == nil && err2 == nil && err3 == nil && n >= start && n <= end {
+			// Narrower ranges are more specific.
+			return int(^uint32(0) - uint32(end-start))
+		}
+	}
+	return -1
+}
+
+func preferHTTPS(urls []string) string {
+	for _, u := range urls {
+		if strings.HasPrefix(u, "https://") {
+			return u
+		}
+	}
+	return urls[0]
+}
+
+func (c *RDAPClient) loadBootstrap(ctx context.Context, registry string) (*rdapBootstrap, error) {
+	c.mu.Lock()
+	bs, ok := c.bootstrap[registry]
+	c.mu.Unlock()
+	if ok {
+		return bs, nil
+	}
+
+	bs = &rdapBootstrap{}
+	url := strings.TrimSuffix(c.BootstrapURL, "/") + "/" + registry + ".json"
+	if err := c.getJSON(ctx, url, bs); err != nil {
+		return nil, fmt.Errorf("rdap: loading bootstrap %s: %w", registry, err)
+	}
+
+	c.mu.Lock()
+	if c.bootstrap == nil {
+		c.bootstrap = make(map[string]*rdapBootstrap)
+	}
+	c.bootstrap[registry] = bs
+	c.mu.Unlock()
+	return bs, nil
+}
+
+func (c *RDAPClient) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/rdap+json, application/json")
+	if err := waitServer(ctx, req.URL.Host); err != nil {
+		return err
+	}
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var rerr rdapError
+		if json.Unmarshal(body, &rerr) == nil && rerr.Title != "" {
+			return fmt.Errorf("rdap: %s returned %d %s", url, resp.StatusCode, rerr.Title)
+		}
+		return fmt.Errorf("rdap: %s returned %s", url, resp.Status)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("rdap: decoding %s: %w", url, err)
+	}
+	return nil
+}
+
+// vcard holds the vCard properties dlookup displays.
+type vcard struct {
+	FN    string
+	Org   string
+	Email string
+	Tel   string
+}
+
+// parseVCard decodes a jCard (RFC 7095) array.
+func parseVCard(raw json.RawMessage) vcard {
+	var v vcard
+	var arr []json.RawMessage
+	if len(raw) == 0 || json.Unmarshal(raw, &arr) != nil || len(arr) < 2 {
+		return v
+	}
+	var props [][]json.RawMessage
+	if json.Unmarshal(arr[1], &props) != nil {
+		return v
+	}
+	for _, prop := range props {
+		if len(prop) < 4 {
+			continue
+		}
+		var name, value string
+		if json.Unmarshal(prop[0], &name) != nil {
+			continue
+		}
+		if json.Unmarshal(prop[3], &value) != nil {
+			// Structured values (org units, addresses) are arrays of strings.
+			var parts []string
+			if json.Unmarshal(prop[3], &parts) != nil {
+				continue
+			}
+			value = strings.Join(parts, " ")
+		}
+		value = strings.TrimSpace(value)
+		switch name {
+		case "fn":
+			v.FN = value
+		case "org":
+			v.Org = value
+		case "email":
+			v.Email = value
+		case "tel":
+			v.Tel = strings.TrimPrefix(value, "tel:")
+		}
+	}
+	return v
+}
+
+func (e RDAPEntity) describe() string {
+	v := parseVCard(e.VCard)
+	parts := []string{}
+	if v.FN != "" {
+		parts = append(parts, v.FN)
+	}
+	if v.Org != "" && v.Org != v.FN {
+		parts = append(parts, v.Org)
+	}
+	if v.Email != "" {
+		parts = append(parts, "<"+v.Email+">")
+	}
+	if v.Tel != "" {
+		parts = append(parts, v.Tel)
+	}
+	for _, id := range e.PublicIDs {
+		parts = append(parts, fmt.Sprintf("(%s %s)", id.Type, id.Identifier))
+	}
+	if len(parts) == 0 {
+		if e.Handle != "" {
+			return e.Handle
+		}
+		return "(redacted)"
+	}
+	return strings.Join(parts, " ")
+}
+
+// Registrar returns a description of the entity with the registrar role.
+func (r *RDAPResponse) Registrar() string {
+	for _, e := range r.Entities {
+		for _, role := range e.Roles {
+			if role == "registrar" {
+				return e.describe()
+			}
+		}
+	}
+	return ""
+}
+
+// rangeText returns the address or AS number range of an IP network or
+// autnum response, or "" for other objects.
+func (r *RDAPResponse) rangeText() string {
+	switch r.ObjectClassName {
+	case "ip network":
+		return strings.TrimSpace(r.StartAddress + " - " + r.EndAddress)
+	case "autnum":
+		if r.StartAutnum == nil {
+			return ""
+		}
+		rng := fmt.Sprintf("AS%d", *r.StartAutnum)
+		if r.EndAutnum != nil && *r.EndAutnum != *r.StartAutnum {
+			rng += fmt.Sprintf(" - AS%d", *r.EndAutnum)
+		}
+		return rng
+	}
+	return ""
+}
+
+// dnssec returns "signed" or "unsigned", or "" when the response does not
+// say.
+func (r *RDAPResponse) dnssec() string {
+	switch {
+	case r.SecureDNS == nil:
+		return ""
+	case r.SecureDNS.DelegationSigned:
+		return "signed"
+	}
+	return "unsigned"
+}
+
+// nameServers returns the names of the name servers, lower-cased and sorted.
+func (r *RDAPResponse) nameServers() []string {
+	names := make([]string, 0, len(r.Nameservers))
+	for _, ns := range r.Nameservers {
+		names = append(names, strings.ToLower(ns.LDHName))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Format renders the response for the results viewport.
+func (r *RDAPResponse) Format() string {
+	var b strings.Builder
+	line := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "%-12s %s\n", label+":", value)
+		}
+	}
+
+	switch r.ObjectClassName {
+	case "ip network":
+		line("Network", r.Name)
+		line("Range", r.rangeText())
+		line("Type", r.Type)
+		line("Country", r.Country)
+	case "autnum":
+		line("AS Name", r.Name)
+		line("Range", r.rangeText())
+		line("Country", r.Country)
+	default:
+		line("Domain", r.LDHName)
+		if r.UnicodeName != "" && !strings.EqualFold(r.UnicodeName, r.LDHName) {
+			line("Unicode", r.UnicodeName)
+		}
+	}
+	line("Handle", r.Handle)
+	line("Registrar", r.Registrar())
+	if len(r.Status) > 0 {
+		line("Status", strings.Join(r.Status, ", "))
+	}
+	line("DNSSEC", r.dnssec())
+
+	if len(r.Events) > 0 {
+		b.WriteString("\nEvents:\n")
+		for _, e := range r.Events {
+			fmt.Fprintf(&b, "  %-22s %s\n", e.Action, e.Date)
+		}
+	}
+
+	if len(r.Nameservers) > 0 {
+		b.WriteString("\nNameservers:\n")
+		for _, n := range r.nameServers() {
+			fmt.Fprintf(&b, "  %s\n", n)
+		}
+	}
+
+	if len(r.Entities) > 0 {
+		b.WriteString("\nEntities:\n")
+		writeRDAPEntities(&b, r.Entities, "  ")
+	}
+
+	if r.Source != "" {
+		fmt.Fprintf(&b, "\nSource: %s\n", r.Source)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func writeRDAPEntities(b *strings.Builder, entities []RDAPEntity, indent string) {
+	for _, e := range entities {
+		roles := strings.Join(e.Roles, ", ")
+		if roles == "" {
+			roles = "entity"
+		}
+		fmt.Fprintf(b, "%s%s: %s\n", indent, roles, e.describe())
+		writeRDAPEntities(b, e.Entities, indent+"  ")
+	}
+}
+
+// RDAPInfo is the structured form of an RDAP response that the RDAP
+// provider returns as Result.Details.
+type RDAPInfo struct {
+	// Object is the RDAP object class: "domain", "ip network" or "autnum".
+	Object string `json:"object"`
+	Handle string `json:"handle,omitempty"`
+	// Name is the domain name, or the name of the network or AS.
+	Name        string `json:"name,omitempty"`
+	UnicodeName string `json:"unicode_name,omitempty"`
+	// Range is the address or AS number range of a network or AS.
+	Range       string          `json:"range,omitempty"`
+	Type        string          `json:"type,omitempty"`
+	Country     string          `json:"country,omitempty"`
+	Registrar   string          `json:"registrar,omitempty"`
+	Status      []string        `json:"status,omitempty"`
+	DNSSEC      string          `json:"dnssec,omitempty"` // "signed", "unsigned" or "" when not stated
+	Events      []RDAPInfoEvent `json:"events,omitempty"`
+	NameServers []string        `json:"name_servers,omitempty"`
+	Entities    []RDAPContact   `json:"entities,omitempty"`
+	Source      string          `json:"source,omitempty"`
+}
+
+// RDAPInfoEvent is an event in the life of an RDAP object, such as its
+// registration or expiration.
+type RDAPInfoEvent struct {
+	Action string `json:"action"`
+	Date   string `json:"date"`
+	Actor  string `json:"actor,omitempty"`
+}
+
+// RDAPContact is an entity of an RDAP response with its vCard decoded.
+type RDAPContact struct {
+	Handle       string        `json:"handle,omitempty"`
+	Roles        []string      `json:"roles,omitempty"`
+	Name         string        `json:"name,omitempty"`
+	Organization string        `json:"organization,omitempty"`
+	Email        string        `json:"email,omitempty"`
+	Phone        string        `json:"phone,omitempty"`
+	Entities     []RDAPContact `json:"entities,omitempty"`
+}
+
+// Info returns the structured form of r.
+func (r *RDAPResponse) Info() *RDAPInfo {
+	info := &RDAPInfo{
+		Object:      r.ObjectClassName,
+		Handle:      r.Handle,
+		Name:        r.LDHName,
+		Range:       r.rangeText(),
+		Type:        r.Type,
+		Country:     r.Country,
+		Registrar:   r.Registrar(),
+		Status:      r.Status,
+		DNSSEC:      r.dnssec(),
+		NameServers: r.nameServers(),
+		Entities:    rdapContacts(r.Entities),
+		Source:      r.Source,
+	}
+	if info.Name == "" {
+		info.Name = r.Name
+	}
+	if !strings.EqualFold(r.UnicodeName, r.LDHName) {
+		info.UnicodeName = r.UnicodeName
+	}
+	for _, e := range r.Events {
+		info.Events = append(info.Events, RDAPInfoEvent{Action: e.Action, Date: e.Date, Actor: e.Actor})
+	}
+	return info
+}
+
+func rdapContacts(entities []RDAPEntity) []RDAPContact {
+	var contacts []RDAPContact
+	for _, e := range entities {
+		v := parseVCard(e.VCard)
+		contacts = append(contacts, RDAPContact{
+			Handle:       e.Handle,
+			Roles:        e.Roles,
+			Name:         v.FN,
+			Organization: v.Org,
+			Email:        v.Email,
+			Phone:        v.Tel,
+			Entities:     rdapContacts(e.Entities),
+		})
+	}
+	return contacts
+}
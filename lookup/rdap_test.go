@@ -0,0 +1,134 @@
+package lookup_test
+
+import (
+	"context"
+	"dlookup/lookup"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// startRDAPServer serves the IANA bootstrap files and RDAP objects from
+// testdata/rdap over HTTPS. Objects are looked up by request path.
+func startRDAPServer(t *testing.T) (*lookup.RDAPClient, *atomic.Int32) {
+	t.Helper()
+	objects := map[string]string{
+		"/verisign/domain/example.com": "domain_example_com.json",
+		"/arin-specific/ip/8.8.8.8":    "ip_8_8_8_8.json",
+		"/arin/autnum/15169":           "autnum_15169.json",
+	}
+	var bootstrapFetches atomic.Int32
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file := ""
+		if strings.HasPrefix(r.URL.Path, "/bootstrap/") {
+			bootstrapFetches.Add(1)
+			file = strings.TrimPrefix(r.URL.Path, "/bootstrap/")
+		} else if f, ok := objects[r.URL.Path]; ok {
+			file = f
+		}
+		data, err := os.ReadFile(filepath.Join("testdata", "rdap", file))
+		if file == "" || err != nil {
+			w.Header().Set("Content-Type", "application/rdap+json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errorCode": 404, "title": "Not Found"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/rdap+json")
+		w.Write([]byte(strings.ReplaceAll(string(data), "{{BASE}}", srv.URL)))
+	}))
+	t.Cleanup(srv.Close)
+	return &lookup.RDAPClient{BootstrapURL: srv.URL + "/bootstrap/", HTTPClient: srv.Client()}, &bootstrapFetches
+}
+
+func TestRDAPClient_Domain(t *testing.T) {
+	client, fetches := startRDAPServer(t)
+
+	resp, err := client.Lookup(context.Background(), "Example.com.")
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if resp.LDHName != "EXAMPLE.COM" || len(resp.Events) != 3 || len(resp.Nameservers) != 2 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if got := resp.Registrar(); got != "RESERVED-Internet Assigned Numbers Authority (IANA Registrar ID 376)" {
+		t.Errorf("Registrar() = %q", got)
+	}
+
+	if _, err := client.Lookup(context.Background(), "example.com"); err != nil {
+		t.Fatalf("second Lookup() error = %v", err)
+	}
+	if fetches.Load() != 1 {
+		t.Errorf("bootstrap fetched %d times, want 1 (cached)", fetches.Load())
+	}
+}
+
+func TestRDAPClient_IPUsesMostSpecificPrefix(t *testing.T) {
+	client, _ := startRDAPServer(t)
+
+	resp, err := client.Lookup(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if resp.ObjectClassName != "ip network" || resp.Name != "GOGL" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if !strings.HasSuffix(resp.Source, "/arin-specific/ip/8.8.8.8") {
+		t.Errorf("Source = %q, want the /24 service", resp.Source)
+	}
+}
+
+func TestRDAPClient_ASN(t *testing.T) {
+	client, _ := startRDAPServer(t)
+
+	for _, q := range []string{"AS15169", "as15169", "15169"} {
+		resp, err := client.Lookup(context.Background(), q)
+		if err != nil {
+			t.Fatalf("Lookup(%q) error = %v", q, err)
+		}
+		if resp.Handle != "AS15169" {
+			t.Errorf("Lookup(%q) handle = %q", q, resp.Handle)
+		}
+	}
+}
+
+func TestRDAPClient_Errors(t *testing.T) {
+	client, _ := startRDAPServer(t)
+
+	if _, err := client.Lookup(context.Background(), "missing.com"); err == nil || !strings.Contains(err.Error(), "404 Not Found") {
+		t.Errorf("Lookup(missing.com) error = %v, want 404 Not Found", err)
+	}
+	if _, err := client.Lookup(context.Background(), "example.invalidtld"); err == nil || !strings.Contains(err.Error(), "no RDAP service") {
+		t.Errorf("Lookup(example.invalidtld) error = %v, want no RDAP service", err)
+	}
+	if _, err := client.Lookup(context.Background(), "not a domain"); err == nil {
+		t.Errorf("Lookup(\"not a domain\") error = nil, want error")
+	}
+}
+
+func TestRDAPResponse_Format(t *testing.T) {
+	client, _ := startRDAPServer(t)
+	resp, err := client.Lookup(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	out := resp.Format()
+	for _, want := range []string{
+		"Domain:      EXAMPLE.COM",
+		"Registrar:   RESERVED-Internet Assigned Numbers Authority (IANA Registrar ID 376)",
+		"Status:      client delete prohibited, client transfer prohibited, client update prohibited",
+		"DNSSEC:      signed",
+		"  registration           1995-08-14T04:00:00Z",
+		"  expiration             2025-08-13T04:00:00Z",
+		"Nameservers:\n  a.iana-servers.net\n  b.iana-servers.net",
+		"  registrar: RESERVED-Internet Assigned Numbers Authority (IANA Registrar ID 376)\n    abuse: <abuse@iana.org> +1.3103015800",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Format() missing %q. Got:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package lookup
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// RecordView is the Details of a lookup whose records read better sorted,
+// grouped or with their numeric fields named than in answer order: SRV,
+// CAA, NAPTR, SVCB/HTTPS, DS, DNSKEY, TLSA and SSHFP.
+type RecordView struct {
+	Type RRType `json:"-"`
+	// Records holds the records of Type from the answer, sorted the way
+	// Summary shows them.
+	Records []RR `json:"records"`
+}
+
+// newRecordView returns the view of the records of type t among rrs, or nil
+// if t has no special rendering or there are no such records.
+func newRecordView(t RRType, rrs []RR) *RecordView {
+	switch t {
+	case TypeSRV, TypeCAA, TypeNAPTR, TypeSVCB, TypeHTTPS, TypeDS, TypeDNSKEY, TypeTLSA, TypeSSHFP:
+	default:
+		return nil
+	}
+	v := &RecordView{Type: t}
+	for _, rr := range rrs {
+		if rr.Type == t && rr.Data != nil {
+			v.Records = append(v.Records, rr)
+		}
+	}
+	if len(v.Records) == 0 {
+		return nil
+	}
+	sort.SliceStable(v.Records, func(i, j int) bool {
+		return recordLess(v.Records[i].Data, v.Records[j].Data)
+	})
+	return v
+}
+
+// recordLess orders SRV records by priority and then by weight, heaviest
+// first; NAPTR by order and preference; SVCB and HTTPS by priority; CAA by
+// tag. Records dig printed but this package cannot decode keep their order.
+func recordLess(a, b RData) bool {
+	switch a := a.(type) {
+	case *SRVRecord:
+		if b, ok := b.(*SRVRecord); ok {
+			if a.Priority != b.Priority {
+				return a.Priority < b.Priority
+			}
+			return a.Weight > b.Weight
+		}
+	case *NAPTRRecord:
+		if b, ok := b.(*NAPTRRecord); ok {
+			if a.Order != b.Order {
+				return a.Order < b.Order
+			}
+			return a.Preference < b.Preference
+		}
+	case *SVCBRecord:
+		if b, ok := b.(*SVCBRecord); ok {
+			return a.Priority < b.Priority
+		}
+	case *CAARecord:
+		if b, ok := b.(*CAARecord); ok {
+			return caaTagRank(a.Tag) < caaTagRank(b.Tag)
+		}
+	}
+	return false
+}
+
+// caaTagRank puts the tags that control issuance first.
+func caaTagRank(tag string) int {
+	switch strings.ToLower(tag) {
+	case "issue":
+		return 0
+	case "issuewild":
+		return 1
+	case "issuemail":
+		return 2
+	case "iodef":
+		return 3
+	}
+	return 4
+}
+
+// Summary renders the records as a table, or for CAA as one group per tag.
+func (v *RecordView) Summary() string {
+	if v.Type == TypeCAA {
+		return v.caaSummary()
+	}
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	switch v.Type {
+	case TypeSRV:
+		fmt.Fprintln(w, "PRIORITY\tWEIGHT\tPORT\tTARGET")
+	case TypeNAPTR:
+		fmt.Fprintln(w, "ORDER\tPREF\tFLAGS\tSERVICES\tREGEXP\tREPLACEMENT")
+	case TypeSVCB, TypeHTTPS:
+		fmt.Fprintln(w, "PRIORITY\tTARGET\tPARAMETERS")
+	case TypeDS:
+		fmt.Fprintln(w, "KEY TAG\tALGORITHM\tDIGEST TYPE\tDIGEST")
+	case TypeDNSKEY:
+		fmt.Fprintln(w, "KEY TAG\tROLE\tALGORITHM\tKEY SIZE")
+	case TypeTLSA:
+		fmt.Fprintln(w, "USAGE\tSELECTOR\tMATCHING\tDATA")
+	case TypeSSHFP:
+		fmt.Fprintln(w, "ALGORITHM\tTYPE\tFINGERPRINT")
+	}
+	for _, rr := range v.Records {
+		switch d := rr.Data.(type) {
+		case *SRVRecord:
+			fmt.Fprintf(w, "%d\t%d\t%d\t%s\n", d.Priority, d.Weight, d.Port, d.Target)
+		case *NAPTRRecord:
+			fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\t%s\n", d.Order, d.Preference, quoteTXT(d.Flags), quoteTXT(d.Services), quoteTXT(d.Regexp), d.Replacement)
+		case *SVCBRecord:
+			params := make([]string, len(d.Params))
+			for i, p := range d.Params {
+				params[i] = p.String()
+			}
+			if d.Priority == 0 {
+				params = []string{"(alias)"}
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", d.Priority, d.Target, strings.Join(params, " "))
+		case *DSRecord:
+			fmt.Fprintf(w, "%d\t%s\t%s\t%X\n", d.KeyTag, dnssecAlgorithmName(d.Algorithm), digestTypeName(d.DigestType), d.Digest)
+		case *DNSKEYRecord:
+			role := "ZSK"
+			if d.IsSEP() {
+				role = "KSK"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d bytes\n", d.KeyTag(), role, dnssecAlgorithmName(d.Algorithm), len(d.PublicKey))
+		case *TLSARecord:
+			fmt.Fprintf(w, "%s\t%s\t%s\t%X\n", tlsaUsageName(d.Usage), tlsaSelectorName(d.Selector), tlsaMatchingName(d.MatchingType), d.Data)
+		case *SSHFPRecord:
+			fmt.Fprintf(w, "%s\t%s\t%X\n", sshfpAlgorithmName(d.Algorithm), sshfpTypeName(d.Type), d.Fingerprint)
+		default:
+			fmt.Fprintf(w, "%s\n", rr.Data)
+		}
+	}
+	w.Flush()
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// caaSummary lists the values of each CAA tag together, marking critical
+// records.
+func (v *RecordView) caaSummary() string {
+	var tags []string
+	values := make(map[string][]string)
+	for _, rr := range v.Records {
+		d, ok := rr.Data.(*CAARecord)
+		if !ok {
+			continue
+		}
+		tag := strings.ToLower(d.Tag)
+		if _, ok := values[tag]; !ok {
+			tags = append(tags, tag)
+		}
+		value := d.Value
+		if value == "" || value == ";" {
+			value = "(none: issuance forbidden)"
+		}
+		if d.Critical() {
+			value += " (critical)"
+		}
+		values[tag] = append(values[tag], value)
+	}
+	var b strings.Builder
+	for _, tag := range tags {
+		for i, value := range values[tag] {
+			label := tag + ":"
+			if i > 0 {
+				label = ""
+			}
+			fmt.Fprintf(&b, "%-14s %s\n", label, value)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+var dnssecAlgorithmNames = map[uint8]string{
+	1:  "RSAMD5",
+	3:  "DSA",
+	5:  "RSASHA1",
+	6:  "DSA-NSEC3-SHA1",
+	7:  "RSASHA1-NSEC3-SHA1",
+	8:  "RSASHA256",
+	10: "RSASHA512",
+	12: "ECC-GOST",
+	13: "ECDSAP256SHA256",
+	14: "ECDSAP384SHA384",
+	15: "ED25519",
+	16: "ED448",
+}
+
+func dnssecAlgorithmName(alg uint8) string {
+	return numberedName(dnssecAlgorithmNames, alg)
+}
+
+var digestTypeNames = map[uint8]string{1: "SHA-1", 2: "SHA-256", 3: "GOST", 4: "SHA-384"}
+
+func digestTypeName(t uint8) string {
+	return numberedName(digestTypeNames, t)
+}
+
+func tlsaUsageName(u uint8) string {
+	return numberedName(map[uint8]string{0: "PKIX-TA", 1: "PKIX-EE", 2: "DANE-TA", 3: "DANE-EE"}, u)
+}
+
+func tlsaSelectorName(s uint8) string {
+	return numberedName(map[uint8]string{0: "Cert", 1: "SPKI"}, s)
+}
+
+func tlsaMatchingName(m uint8) string {
+	return numberedName(map[uint8]string{0: "Full", 1: "SHA2-256", 2: "SHA2-512"}, m)
+}
+
+func sshfpAlgorithmName(alg uint8) string {
+	return numberedName(map[uint8]string{1: "RSA", 2: "DSA", 3: "ECDSA", 4: "Ed25519", 6: "Ed448"}, alg)
+}
+
+func sshfpTypeName(t uint8) string {
+	return numberedName(map[uint8]string{1: "SHA-1", 2: "SHA-256"}, t)
+}
+
+// numberedName renders n as "name (n)" if names knows it, or as the bare
+// number.
+func numberedName(names map[uint8]string, n uint8) string {
+	if name, ok := names[n]; ok {
+		return fmt.Sprintf("%s (%d)", name, n)
+	}
+	return fmt.Sprint(n)
+}
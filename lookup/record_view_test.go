@@ -0,0 +1,95 @@
+package lookup_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dlookup/lookup"
+)
+
+// digFixtureView runs provider as if dig had printed testdata/dig/<file>.txt
+// and returns the result and its summary.
+func digFixtureView(t *testing.T, provider, file string) (*lookup.Result, string) {
+	t.Helper()
+	input, err := os.ReadFile(filepath.Join("testdata", "dig", file+".txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	origRunCommand := lookup.OsRunCommand
+	origCheckCommandFunc := lookup.LookupCheckCommandFunc
+	t.Cleanup(func() {
+		lookup.OsRunCommand = origRunCommand
+		lookup.LookupCheckCommandFunc = origCheckCommandFunc
+	})
+	lookup.LookupCheckCommandFunc = func(cmd string) bool { return cmd == "dig" }
+	lookup.OsRunCommand = func(ctx context.Context, cmdName string, args ...string) (string, string, error) {
+		return string(input), "", nil
+	}
+	p, _ := lookup.GetProvider(provider)
+	result, err := p.Execute(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	return result, result.Summary()
+}
+
+func TestRecordView_SRVSortedByPriorityAndWeight(t *testing.T) {
+	_, summary := digFixtureView(t, "DIG (SRV)", "answer_srv")
+	lines := strings.Split(summary, "\n")
+	want := []string{"PRIORITY", "10        60", "10        40", "20        0"}
+	if len(lines) != len(want) {
+		t.Fatalf("Summary() =\n%s\nwant %d lines", summary, len(want))
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Errorf("line %d = %q, want prefix %q", i, lines[i], prefix)
+		}
+	}
+}
+
+func TestRecordView_CAAGroupedByTag(t *testing.T) {
+	_, summary := digFixtureView(t, "DIG (CAA)", "answer_caa")
+	want := strings.Join([]string{
+		"issue:         pki.goog",
+		"               letsencrypt.org; validationmethods=dns-01 (critical)",
+		"issuewild:     (none: issuance forbidden)",
+		"iodef:         mailto:security@example.com",
+	}, "\n")
+	if summary != want {
+		t.Errorf("Summary() =\n%s\nwant\n%s", summary, want)
+	}
+}
+
+func TestRecordView_NamesDNSSECFields(t *testing.T) {
+	_, summary := digFixtureView(t, "DIG (DNSKEY)", "answer_dnskey")
+	for _, want := range []string{"2371", "KSK", "ZSK", "ECDSAP256SHA256 (13)", "64 bytes"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("DNSKEY Summary() does not contain %q:\n%s", want, summary)
+		}
+	}
+	_, summary = digFixtureView(t, "DIG (TLSA)", "answer_tlsa")
+	if !strings.Contains(summary, "DANE-EE (3)  SPKI (1)  SHA2-256 (1)") {
+		t.Errorf("TLSA Summary() =\n%s\nwant named usage, selector and matching type", summary)
+	}
+}
+
+func TestRecordView_HTTPSAlias(t *testing.T) {
+	_, summary := digFixtureView(t, "DIG (SVCB)", "answer_svcb")
+	lines := strings.Split(summary, "\n")
+	if len(lines) != 3 || !strings.Contains(lines[1], "svc.example.") || !strings.HasSuffix(lines[1], "(alias)") {
+		t.Errorf("Summary() =\n%s\nwant the alias record first", summary)
+	}
+	if !strings.Contains(lines[2], `alpn="dot" port=853 key65000="custom value"`) {
+		t.Errorf("service record = %q, want its parameters", lines[2])
+	}
+}
+
+func TestRecordView_NoneForPlainTypes(t *testing.T) {
+	result, _ := digFixtureView(t, "DIG (NS)", "answer_ns")
+	if result.Details != nil || len(result.Records) != 2 {
+		t.Errorf("NS result = %d records, details %v; want 2 records and no view", len(result.Records), result.Details)
+	}
+}
@@ -0,0 +1,153 @@
+package lookup
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Result is the outcome of a single lookup.
+type Result struct {
+	Provider string
+	Query    string
+	// Records holds the parsed resource records, when the provider yields any.
+	Records []RR
+	// NoTTLs is set when the output the records were parsed from carries no
+	// TTLs, as with `dig +short`, so their TTLs of zero mean nothing.
+	NoTTLs bool
+	// NegativeTTL is how long a DNS answer without records may be cached:
+	// the smaller of the TTL and minimum of the SOA in its authority section
+	// (RFC 2308). It is zero when the answer carried no SOA.
+	NegativeTTL uint32
+	Stdout      string
+	Stderr      string
+	// ExitCode is the exit status of the external command, or -1 if it could
+	// not be started or was killed. It is 0 for native providers.
+	ExitCode int
+	Duration time.Duration
+	// Command is the exact command line executed; empty for native providers.
+	Command string
+	// Server is the DNS server the lookup was sent to when one was chosen
+	// with WithServer; empty for the system resolver.
+	Server string
+	// Attempts is how many times RunLookup ran the provider; Retries holds
+	// the transient failure that led to each retry.
+	Attempts int
+	Retries  []string
+	// Throttled is the time spent waiting for the per-server rate limit.
+	Throttled time.Duration
+	// Cached is set on results served from the cache, together with when
+	// they were stored and when they expire.
+	Cached   bool
+	CachedAt time.Time
+	Expires  time.Time
+	// Results holds the per-provider results of a comprehensive report.
+	Results []*Result
+	// Details holds provider-specific structured data, such as *WhoisInfo.
+	Details any
+	Err     error
+}
+
+// Summary returns a short digest of Details, shown above the raw output, or
+// "" if the provider has none.
+func (r *Result) Summary() string {
+	if s, ok := r.Details.(interface{ Summary() string }); ok {
+		return s.Summary()
+	}
+	return ""
+}
+
+// Output returns the text to show for the result.
+func (r *Result) Output() string {
+	if r.Stdout == "" {
+		return "(No results found)"
+	}
+	return r.Stdout
+}
+
+// Text renders the result as plain text: the summary, if any, then the
+// output, with stderr and any error included.
+func (r *Result) Text() string {
+	var b strings.Builder
+	if r.Err != nil {
+		fmt.Fprintf(&b, "Error: %v", r.Err)
+		if r.Stdout != "" {
+			b.WriteString("\nOutput:\n" + r.Stdout)
+		}
+	} else {
+		if summary := r.Summary(); summary != "" {
+			b.WriteString(summary + "\n\n")
+		}
+		b.WriteString(r.Output())
+	}
+	if r.Stderr != "" {
+		b.WriteString("\nStderr:\n" + r.Stderr)
+	}
+	return b.String()
+}
+
+type resultJSON struct {
+	Provider  string    `json:"provider"`
+	Query     string    `json:"query"`
+	Records   []RR      `json:"records,omitempty"`
+	Stdout    string    `json:"stdout,omitempty"`
+	Stderr    string    `json:"stderr,omitempty"`
+	ExitCode  int       `json:"exit_code"`
+	Duration  string    `json:"duration"`
+	Command   string    `json:"command,omitempty"`
+	Server    string    `json:"server,omitempty"`
+	Attempts  int       `json:"attempts,omitempty"`
+	Retries   []string  `json:"retries,omitempty"`
+	Throttled string    `json:"throttled,omitempty"`
+	Cached    bool      `json:"cached,omitempty"`
+	CachedAt  string    `json:"cached_at,omitempty"`
+	Expires   string    `json:"expires,omitempty"`
+	Results   []*Result `json:"results,omitempty"`
+	Details   any       `json:"details,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+func (r *Result) MarshalJSON() ([]byte, error) {
+	out := resultJSON{
+		Provider: r.Provider,
+		Query:    r.Query,
+		Records:  r.Records,
+		Stdout:   r.Stdout,
+		Stderr:   r.Stderr,
+		ExitCode: r.ExitCode,
+		Duration: r.Duration.String(),
+		Command:  r.Command,
+		Server:   r.Server,
+		Attempts: r.Attempts,
+		Retries:  r.Retries,
+		Results:  r.Results,
+		Details:  r.Details,
+	}
+	if r.Throttled > 0 {
+		out.Throttled = r.Throttled.String()
+	}
+	if r.Cached {
+		out.Cached = true
+		out.CachedAt = r.CachedAt.UTC().Format(time.RFC3339)
+		out.Expires = r.Expires.UTC().Format(time.RFC3339)
+	}
+	if r.Err != nil {
+		out.Error = r.Err.Error()
+	}
+	return json.Marshal(out)
+}
+
+func (rr RR) MarshalJSON() ([]byte, error) {
+	data := ""
+	if rr.Data != nil {
+		data = rr.Data.String()
+	}
+	return json.Marshal(struct {
+		Name  string `json:"name"`
+		Type  string `json:"type"`
+		Class string `json:"class"`
+		TTL   uint32 `json:"ttl"`
+		Data  string `json:"data"`
+	}{rr.Name, rr.Type.String(), rr.Class.String(), rr.TTL, data})
+}
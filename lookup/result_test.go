@@ -0,0 +1,135 @@
+package lookup_test
+
+import (
+	"context"
+	"dlookup/lookup"
+	"encoding/json"
+	"errors"
+	"net"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResult_Text(t *testing.T) {
+	tests := []struct {
+		name   string
+		result lookup.Result
+		want   string
+	}{
+		{"Empty", lookup.Result{}, "(No results found)"},
+		{"Stdout", lookup.Result{Stdout: "answer"}, "answer"},
+		{"Stderr", lookup.Result{Stdout: "answer", Stderr: "warning"}, "answer\nStderr:\nwarning"},
+		{"Error", lookup.Result{Stdout: "partial", Err: errors.New("boom")}, "Error: boom\nOutput:\npartial"},
+		{"ErrorWithoutOutput", lookup.Result{Err: errors.New("boom")}, "Error: boom"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.result.Text(); got != tc.want {
+				t.Errorf("Text() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestResult_MarshalJSON(t *testing.T) {
+	r := &lookup.Result{
+		Provider: "DNS (A)",
+		Query:    "example.com",
+		Records: []lookup.RR{{
+			Name: "example.com.", Type: lookup.TypeA, Class: lookup.ClassINET, TTL: 300,
+			Data: &lookup.ARecord{IP: net.ParseIP("192.0.2.1")},
+		}},
+		Stdout:   "example.com.\t300\tIN\tA\t192.0.2.1",
+		ExitCode: 0,
+		Duration: 1500 * time.Millisecond,
+		Err:      errors.New("partial failure"),
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	for key, want := range map[string]any{
+		"provider": "DNS (A)",
+		"query":    "example.com",
+		"duration": "1.5s",
+		"error":    "partial failure",
+	} {
+		if got[key] != want {
+			t.Errorf("JSON %q = %v, want %v", key, got[key], want)
+		}
+	}
+	records, _ := got["records"].([]any)
+	if len(records) != 1 {
+		t.Fatalf("JSON records = %v, want one record", got["records"])
+	}
+	rec := records[0].(map[string]any)
+	if rec["name"] != "example.com." || rec["type"] != "A" || rec["class"] != "IN" || rec["ttl"] != float64(300) || rec["data"] != "192.0.2.1" {
+		t.Errorf("JSON record = %v", rec)
+	}
+	if _, ok := got["command"]; ok {
+		t.Errorf("JSON has command for a native result: %s", data)
+	}
+}
+
+func TestRunCommand_RecordsCommandDetails(t *testing.T) {
+	origRunCommand := lookup.OsRunCommand
+	defer func() { lookup.OsRunCommand = origRunCommand }()
+	lookup.OsRunCommand = func(ctx context.Context, cmdName string, args ...string) (string, string, error) {
+		return "answer", ";; warning: recursion not available", nil
+	}
+
+	result, err := lookup.RunCommand(context.Background(), "dig", "example.com", "TXT", "+short")
+	if err != nil {
+		t.Fatalf("RunCommand() error = %v", err)
+	}
+	if result.Stdout != "answer" || result.Stderr != ";; warning: recursion not available" {
+		t.Errorf("RunCommand() stdout = %q, stderr = %q; want them kept apart", result.Stdout, result.Stderr)
+	}
+	if result.Command != "dig example.com TXT +short" {
+		t.Errorf("RunCommand() command = %q", result.Command)
+	}
+	if result.ExitCode != 0 {
+		t.Errorf("RunCommand() exit code = %d, want 0", result.ExitCode)
+	}
+}
+
+func TestRunCommand_ExitCode(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	result, err := lookup.RunCommand(context.Background(), "sh", "-c", "echo out; echo err >&2; exit 3")
+	if err == nil {
+		t.Fatalf("RunCommand() error = nil, want exit status error")
+	}
+	if result.ExitCode != 3 || result.Stdout != "out" || result.Stderr != "err" {
+		t.Errorf("RunCommand() = exit %d, stdout %q, stderr %q; want 3, out, err", result.ExitCode, result.Stdout, result.Stderr)
+	}
+	if !strings.HasPrefix(result.Command, "sh -c 'echo out;") {
+		t.Errorf("RunCommand() command = %q, want the argument quoted", result.Command)
+	}
+}
+
+func TestRunLookup_FillsResultMetadata(t *testing.T) {
+	p := &mockProvider{name: "metadata-test-provider", flagName: "metadata-test-flag", available: true}
+	result, err := lookup.RunLookup(context.Background(), p, "example.com")
+	if err != nil {
+		t.Fatalf("RunLookup() error = %v", err)
+	}
+	if result.Provider != "metadata-test-provider" || result.Query != "example.com" {
+		t.Errorf("RunLookup() provider = %q, query = %q", result.Provider, result.Query)
+	}
+
+	blocking := &blockingProvider{mockProvider{name: "metadata-cancel-provider", flagName: "metadata-cancel-flag", available: true}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err = lookup.RunLookup(ctx, blocking, "example.com")
+	if result == nil || result.Err != err || err == nil {
+		t.Errorf("RunLookup() = %+v, %v; want a result carrying the error", result, err)
+	}
+}
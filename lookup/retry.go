@@ -0,0 +1,139 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"regexp"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Policy controls how RunLookup retries a provider's transient failures and
+// how fast the provider may query any one server.
+type Policy struct {
+	// MaxAttempts is the number of tries, including the first. Values below
+	// 1 mean a single try.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry; it doubles for each
+	// further retry up to MaxBackoff. Half of each wait is random jitter.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// RateLimit is the number of queries per second sent to one server, with
+	// bursts of up to Burst queries. Zero means no limit.
+	RateLimit float64
+	Burst     int
+}
+
+// DefaultPolicy applies to providers without a policy of their own.
+var DefaultPolicy = Policy{MaxAttempts: 3, InitialBackoff: 500 * time.Millisecond, MaxBackoff: 8 * time.Second}
+
+var (
+	providerPolicies      = make(map[string]Policy)
+	providerPoliciesMutex sync.RWMutex
+)
+
+// SetProviderPolicy sets the policy for the provider with the given flag
+// name.
+func SetProviderPolicy(flagName string, p Policy) {
+	providerPoliciesMutex.Lock()
+	defer providerPoliciesMutex.Unlock()
+	providerPolicies[flagName] = p
+}
+
+// ProviderPolicy returns the policy RunLookup applies to provider.
+func ProviderPolicy(provider LookupProvider) Policy {
+	providerPoliciesMutex.RLock()
+	defer providerPoliciesMutex.RUnlock()
+	if p, ok := providerPolicies[provider.FlagName()]; ok {
+		return p
+	}
+	return DefaultPolicy
+}
+
+// Backoff returns the wait before retry number n (starting at 1): the
+// exponential delay with its upper half randomised.
+func (p Policy) Backoff(n int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < n && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// transientPattern matches the messages of failures worth retrying, from
+// errors, command output and servers that answer a throttled query with a
+// short explanation instead of data.
+var transientPattern = regexp.MustCompile(`(?i)rate limit|limit exceeded|too many (requests|queries|connections)|timed out|try again|temporarily unavailable|service unavailable|connection reset|servfail`)
+
+// rateLimitPattern matches successful responses that are really refusals.
+var rateLimitPattern = regexp.MustCompile(`(?i)rate limit|limit exceeded|too many (requests|queries|connections)|try again later`)
+
+// Transient classifies the outcome of one attempt. It reports whether the
+// failure is likely to go away on retry and why. Cancellation is never
+// transient.
+func Transient(result *Result, err error) (reason string, ok bool) {
+	if err == nil {
+		// A few WHOIS servers answer throttled queries with a short notice
+		// and no error.
+		if result != nil && len(result.Records) == 0 && len(result.Stdout) < 1024 {
+			if m := rateLimitPattern.FindString(result.Stdout); m != "" {
+				return "rate limited: " + m, true
+			}
+		}
+		return "", false
+	}
+	if errors.Is(err, context.Canceled) {
+		return "", false
+	}
+	var rcodeErr *RcodeError
+	if errors.As(err, &rcodeErr) {
+		if rcodeErr.Rcode == RcodeServerFailure {
+			return "SERVFAIL from " + rcodeErr.Server, true
+		}
+		return "", false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "timeout", true
+	}
+	if errors.Is(err, syscall.ECONNRESET) {
+		return "connection reset", true
+	}
+	for _, text := range []string{err.Error(), resultOutput(result)} {
+		if m := transientPattern.FindString(text); m != "" {
+			return m, true
+		}
+	}
+	return "", false
+}
+
+func resultOutput(r *Result) string {
+	if r == nil {
+		return ""
+	}
+	return r.Stdout + "\n" + r.Stderr
+}
+
+// sleepCtx waits for d or until ctx is done.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,150 @@
+package lookup_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"dlookup/lookup"
+)
+
+func TestTransient(t *testing.T) {
+	tests := []struct {
+		name       string
+		result     *lookup.Result
+		err        error
+		wantReason string
+		want       bool
+	}{
+		{name: "success", result: &lookup.Result{Stdout: "93.184.216.34"}},
+		{name: "SERVFAIL", err: &lookup.RcodeError{Name: "example.com", Server: "192.0.2.53:53", Rcode: lookup.RcodeServerFailure}, wantReason: "SERVFAIL from 192.0.2.53:53", want: true},
+		{name: "REFUSED", err: &lookup.RcodeError{Name: "example.com", Server: "192.0.2.53:53", Rcode: lookup.RcodeRefused}},
+		{name: "net timeout", err: fmt.Errorf("dns: %w", os.ErrDeadlineExceeded), wantReason: "timeout", want: true},
+		{name: "connection reset", err: fmt.Errorf("whois: read: %w", syscall.ECONNRESET), wantReason: "connection reset", want: true},
+		{name: "cancelled", err: fmt.Errorf("whois: %w", context.Canceled)},
+		{name: "dig timeout", result: &lookup.Result{Stdout: ";; connection timed out; no servers could be reached"}, err: errors.New("command 'dig example.com A' failed: exit status 9"), wantReason: "timed out", want: true},
+		{name: "rdap throttled", err: errors.New("rdap: https://rdap.example/domain/x returned 429 Too Many Requests"), wantReason: "Too Many Requests", want: true},
+		{name: "not found", err: errors.New("rdap: https://rdap.example/domain/x returned 404 Not Found")},
+		{name: "whois limit notice", result: &lookup.Result{Stdout: "WHOIS LIMIT EXCEEDED - SEE WWW.PIR.ORG/WHOIS FOR DETAILS"}, wantReason: "rate limited: LIMIT EXCEEDED", want: true},
+		{name: "long whois record mentioning limits", result: &lookup.Result{Stdout: fmt.Sprintf("%01100d\nquery rate limit exceeded", 0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, ok := lookup.Transient(tt.result, tt.err)
+			if ok != tt.want || reason != tt.wantReason {
+				t.Errorf("Transient() = (%q, %v), want (%q, %v)", reason, ok, tt.wantReason, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicy_Backoff(t *testing.T) {
+	p := lookup.Policy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+	for _, tt := range []struct {
+		retry    int
+		min, max time.Duration
+	}{
+		{1, 50 * time.Millisecond, 100 * time.Millisecond},
+		{2, 100 * time.Millisecond, 200 * time.Millisecond},
+		{3, 150 * time.Millisecond, 300 * time.Millisecond},
+		{10, 150 * time.Millisecond, 300 * time.Millisecond},
+	} {
+		for i := 0; i < 20; i++ {
+			if d := p.Backoff(tt.retry); d < tt.min || d > tt.max {
+				t.Fatalf("Backoff(%d) = %s, want between %s and %s", tt.retry, d, tt.min, tt.max)
+			}
+		}
+	}
+	if d := (lookup.Policy{}).Backoff(1); d != 0 {
+		t.Errorf("zero policy Backoff(1) = %s, want 0", d)
+	}
+}
+
+// flakyProvider fails with SERVFAIL until it has been called failures times.
+type flakyProvider struct {
+	mockProvider
+	failures int
+	calls    int
+}
+
+func (f *flakyProvider) Execute(ctx context.Context, domain string) (*lookup.Result, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return nil, &lookup.RcodeError{Name: domain, Server: "192.0.2.53:53", Rcode: lookup.RcodeServerFailure}
+	}
+	return &lookup.Result{Stdout: "ok"}, nil
+}
+
+func TestRunLookup_RetriesTransientErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		wantAttempts int
+		wantErr      bool
+	}{
+		{name: "first try", failures: 0, wantAttempts: 1},
+		{name: "recovers", failures: 2, wantAttempts: 3},
+		{name: "gives up", failures: 5, wantAttempts: 3, wantErr: true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flag := fmt.Sprintf("retry-test-%d", i)
+			lookup.SetProviderPolicy(flag, lookup.Policy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+			p := &flakyProvider{mockProvider: mockProvider{name: flag, flagName: flag}, failures: tt.failures}
+
+			result, err := lookup.RunLookup(context.Background(), p, "example.com")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RunLookup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if result.Attempts != tt.wantAttempts || p.calls != tt.wantAttempts {
+				t.Errorf("Attempts = %d after %d calls, want %d", result.Attempts, p.calls, tt.wantAttempts)
+			}
+			if got, want := len(result.Retries), tt.wantAttempts-1; got != want {
+				t.Errorf("Retries = %q, want %d entries", result.Retries, want)
+			}
+
+			data, err := json.Marshal(result)
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+			var decoded map[string]any
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			if got := decoded["attempts"]; got != float64(tt.wantAttempts) {
+				t.Errorf(`JSON "attempts" = %v, want %d`, got, tt.wantAttempts)
+			}
+			if _, ok := decoded["retries"]; ok != (tt.wantAttempts > 1) {
+				t.Errorf(`JSON "retries" present = %v, want %v`, ok, tt.wantAttempts > 1)
+			}
+		})
+	}
+}
+
+func TestRunLookup_DoesNotRetryPermanentErrors(t *testing.T) {
+	lookup.SetProviderPolicy("retry-test-permanent", lookup.Policy{MaxAttempts: 5, InitialBackoff: time.Millisecond})
+	p := &countingProvider{mockProvider: mockProvider{name: "retry-test-permanent", flagName: "retry-test-permanent"}}
+
+	result, err := lookup.RunLookup(context.Background(), p, "example.com")
+	if err == nil {
+		t.Fatal("RunLookup() error = nil, want an error")
+	}
+	if p.calls != 1 || result.Attempts != 1 {
+		t.Errorf("provider called %d times (Attempts = %d), want 1", p.calls, result.Attempts)
+	}
+}
+
+type countingProvider struct {
+	mockProvider
+	calls int
+}
+
+func (c *countingProvider) Execute(ctx context.Context, domain string) (*lookup.Result, error) {
+	c.calls++
+	return nil, errors.New("no such domain")
+}
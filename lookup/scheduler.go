@@ -0,0 +1,238 @@
+package lookup
+
+import (
+	"context"
+	"sync"
+)
+
+// Scheduler runs lookups with a bound on how many run at once, overall and
+// per provider, so a large batch does not start thousands of processes or
+// get rate-limited by WHOIS servers. Lookups start in the order they were
+// scheduled, except that one held back by its provider's limit does not
+// block lookups of other providers behind it.
+type Scheduler struct {
+	mu             sync.Mutex
+	limit          int
+	providerLimits map[string]int // Keyed by provider flag name
+	running        int
+	runningBy      map[string]int
+	queue          []*Job
+	finished       int
+	total          int
+	changes        chan struct{}
+}
+
+// SchedulerStats is a snapshot of a scheduler's progress. Finished and
+// Total count the lookups scheduled since the scheduler was last idle.
+type SchedulerStats struct {
+	Running  int
+	Queued   int
+	Finished int
+	Total    int
+}
+
+// Job is a lookup handed to a Scheduler.
+type Job struct {
+	s        *Scheduler
+	ctx      context.Context
+	provider LookupProvider
+	domain   string
+	started  bool // Guarded by s.mu
+	start    chan struct{}
+	done     chan struct{}
+	result   *Result
+	err      error
+}
+
+// DefaultScheduler is the scheduler used by the TUI and headless mode.
+var DefaultScheduler = NewScheduler(16, map[string]int{
+	"whois":        2,
+	"whois-native": 2,
+	"rdap":         4,
+})
+
+// NewScheduler returns a scheduler that runs at most limit lookups at once,
+// and at most providerLimits[flagName] of a single provider. A limit of zero
+// or less means no limit.
+func NewScheduler(limit int, providerLimits map[string]int) *Scheduler {
+	s := &Scheduler{
+		runningBy: make(map[string]int),
+		changes:   make(chan struct{}, 1),
+	}
+	s.SetLimits(limit, providerLimits)
+	return s
+}
+
+// SetLimits replaces the scheduler's limits. Lookups already running are
+// not affected; raised limits let queued lookups start at once.
+func (s *Scheduler) SetLimits(limit int, providerLimits map[string]int) {
+	s.mu.Lock()
+	s.limit = limit
+	s.providerLimits = make(map[string]int, len(providerLimits))
+	for flagName, n := range providerLimits {
+		s.providerLimits[flagName] = n
+	}
+	s.dispatch()
+	s.mu.Unlock()
+	s.notify()
+}
+
+// Schedule queues a lookup of domain with provider and returns at once. The
+// lookup runs through RunLookup when a slot is free; its timeout only
+// starts then. Cancelling ctx removes a queued lookup from the queue. A
+// lookup the cache can answer is not queued at all, and neither is a
+// report, whose lookups are queued on s instead.
+func (s *Scheduler) Schedule(ctx context.Context, provider LookupProvider, domain string) *Job {
+	j := &Job{
+		s:        s,
+		ctx:      ctx,
+		provider: provider,
+		domain:   domain,
+		start:    make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	// A cached result needs no slot.
+	if r, ok := cachedResult(ctx, provider, domain); ok {
+		j.result = r
+		close(j.done)
+		return j
+	}
+	// Nor does a report: it only waits for the lookups it schedules here,
+	// which would never start if reports held every slot.
+	if _, ok := provider.(*ComprehensiveProvider); ok {
+		j.ctx = context.WithValue(ctx, schedulerKey{}, s)
+		go func() {
+			defer close(j.done)
+			j.result, j.err = RunLookup(j.ctx, provider, domain)
+		}()
+		return j
+	}
+	s.mu.Lock()
+	if s.running == 0 && len(s.queue) == 0 {
+		s.finished, s.total = 0, 0
+	}
+	s.total++
+	s.queue = append(s.queue, j)
+	s.dispatch()
+	s.mu.Unlock()
+	s.notify()
+
+	go j.run()
+	return j
+}
+
+type schedulerKey struct{}
+
+// schedulerFromContext returns the scheduler a report was scheduled on, or
+// DefaultScheduler when it was run directly.
+func schedulerFromContext(ctx context.Context) *Scheduler {
+	if s, ok := ctx.Value(schedulerKey{}).(*Scheduler); ok {
+		return s
+	}
+	return DefaultScheduler
+}
+
+func (j *Job) run() {
+	defer close(j.done)
+	select {
+	case <-j.start:
+	case <-j.ctx.Done():
+		if j.s.dequeue(j) {
+			j.result, j.err = &Result{Provider: j.provider.Name(), Query: j.domain, Err: j.ctx.Err()}, j.ctx.Err()
+			return
+		}
+		// The slot was granted as ctx was cancelled; RunLookup returns at once.
+	}
+	j.result, j.err = RunLookup(j.ctx, j.provider, j.domain)
+	j.s.release(j)
+}
+
+// Wait blocks until the lookup has finished and returns its outcome, as
+// RunLookup does.
+func (j *Job) Wait() (*Result, error) {
+	<-j.done
+	return j.result, j.err
+}
+
+// Position returns the job's 1-based place in the queue, or 0 once it has
+// started.
+func (j *Job) Position() int {
+	j.s.mu.Lock()
+	defer j.s.mu.Unlock()
+	for i, q := range j.s.queue {
+		if q == j {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Stats returns a snapshot of the scheduler's progress.
+func (s *Scheduler) Stats() SchedulerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SchedulerStats{Running: s.running, Queued: len(s.queue), Finished: s.finished, Total: s.total}
+}
+
+// Changes returns a channel that receives a value after jobs are queued,
+// start or finish. Notifications are coalesced, so a slow reader sees the
+// latest state rather than every change.
+func (s *Scheduler) Changes() <-chan struct{} {
+	return s.changes
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.changes <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch starts queued jobs while there are free slots. s.mu must be held.
+func (s *Scheduler) dispatch() {
+	for i := 0; i < len(s.queue); {
+		if s.limit > 0 && s.running >= s.limit {
+			return
+		}
+		j := s.queue[i]
+		flagName := j.provider.FlagName()
+		if n, ok := s.providerLimits[flagName]; ok && n > 0 && s.runningBy[flagName] >= n {
+			i++
+			continue
+		}
+		s.queue = append(s.queue[:i], s.queue[i+1:]...)
+		s.running++
+		s.runningBy[flagName]++
+		j.started = true
+		close(j.start)
+	}
+}
+
+// dequeue removes a job that has not started and reports whether it did.
+func (s *Scheduler) dequeue(j *Job) bool {
+	s.mu.Lock()
+	if j.started {
+		s.mu.Unlock()
+		return false
+	}
+	for i, q := range s.queue {
+		if q == j {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			break
+		}
+	}
+	s.finished++
+	s.mu.Unlock()
+	s.notify()
+	return true
+}
+
+func (s *Scheduler) release(j *Job) {
+	s.mu.Lock()
+	s.running--
+	s.runningBy[j.provider.FlagName()]--
+	s.finished++
+	s.dispatch()
+	s.mu.Unlock()
+	s.notify()
+}
@@ -0,0 +1,216 @@
+package lookup_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"dlookup/lookup"
+)
+
+// gateProvider blocks each lookup until release is closed and records how
+// many ran at once.
+type gateProvider struct {
+	mockProvider
+	release chan struct{}
+
+	mu         sync.Mutex
+	running    int
+	maxRunning int
+	started    []string
+}
+
+func newGateProvider(flagName string) *gateProvider {
+	return &gateProvider{
+		mockProvider: mockProvider{name: flagName, flagName: flagName, available: true},
+		release:      make(chan struct{}),
+	}
+}
+
+func (g *gateProvider) Execute(ctx context.Context, domain string) (*lookup.Result, error) {
+	g.mu.Lock()
+	g.running++
+	g.maxRunning = max(g.maxRunning, g.running)
+	g.started = append(g.started, domain)
+	g.mu.Unlock()
+	defer func() {
+		g.mu.Lock()
+		g.running--
+		g.mu.Unlock()
+	}()
+	select {
+	case <-g.release:
+		return &lookup.Result{Stdout: domain}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (g *gateProvider) startedDomains() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]string(nil), g.started...)
+}
+
+// waitFor polls cond until it holds or a second has passed.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestScheduler_Limits(t *testing.T) {
+	dns := newGateProvider("sched-dns")
+	whois := newGateProvider("sched-whois")
+	s := lookup.NewScheduler(3, map[string]int{"sched-whois": 1})
+
+	ctx := context.Background()
+	var jobs []*lookup.Job
+	for _, d := range []string{"w1", "w2", "w3"} {
+		jobs = append(jobs, s.Schedule(ctx, whois, d))
+	}
+	for _, d := range []string{"d1", "d2", "d3"} {
+		jobs = append(jobs, s.Schedule(ctx, dns, d))
+	}
+
+	// One WHOIS slot, and the other two global slots go to DNS lookups queued
+	// behind the held-back WHOIS lookups.
+	waitFor(t, "three lookups to start", func() bool { return s.Stats().Running == 3 })
+	if got := s.Stats(); got != (lookup.SchedulerStats{Running: 3, Queued: 3, Finished: 0, Total: 6}) {
+		t.Errorf("Stats() = %+v", got)
+	}
+	if got := jobs[0].Position(); got != 0 {
+		t.Errorf("running job Position() = %d, want 0", got)
+	}
+	if got, want := jobs[1].Position(), 1; got != want {
+		t.Errorf("first queued job Position() = %d, want %d", got, want)
+	}
+	if got, want := jobs[5].Position(), 3; got != want {
+		t.Errorf("last queued job Position() = %d, want %d", got, want)
+	}
+
+	close(dns.release)
+	close(whois.release)
+	for i, j := range jobs {
+		result, err := j.Wait()
+		if err != nil {
+			t.Fatalf("job %d: Wait() error = %v", i, err)
+		}
+		if result.Provider == "" || result.Query == "" {
+			t.Errorf("job %d: result missing metadata: %+v", i, result)
+		}
+	}
+
+	if whois.maxRunning != 1 {
+		t.Errorf("whois ran %d lookups at once, want 1", whois.maxRunning)
+	}
+	if dns.maxRunning > 2 {
+		t.Errorf("dns ran %d lookups at once, want at most 2", dns.maxRunning)
+	}
+	if got := whois.startedDomains(); len(got) != 3 || got[0] != "w1" || got[1] != "w2" || got[2] != "w3" {
+		t.Errorf("whois lookups started in order %q, want w1, w2, w3", got)
+	}
+	if got := s.Stats(); got != (lookup.SchedulerStats{Finished: 6, Total: 6}) {
+		t.Errorf("Stats() after finishing = %+v", got)
+	}
+}
+
+func TestScheduler_CancelQueued(t *testing.T) {
+	p := newGateProvider("sched-cancel")
+	s := lookup.NewScheduler(1, nil)
+
+	first := s.Schedule(context.Background(), p, "first")
+	ctx, cancel := context.WithCancel(context.Background())
+	queued := s.Schedule(ctx, p, "queued")
+	waitFor(t, "the first lookup to start", func() bool { return s.Stats().Running == 1 })
+
+	cancel()
+	result, err := queued.Wait()
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait() error = %v, want context.Canceled", err)
+	}
+	if result == nil || result.Query != "queued" || !errors.Is(result.Err, context.Canceled) {
+		t.Errorf("Wait() result = %+v, want the query and error filled in", result)
+	}
+	if got := s.Stats(); got.Queued != 0 || got.Finished != 1 {
+		t.Errorf("Stats() after cancel = %+v, want nothing queued and one finished", got)
+	}
+
+	close(p.release)
+	if _, err := first.Wait(); err != nil {
+		t.Errorf("first lookup: Wait() error = %v", err)
+	}
+	if got := p.startedDomains(); len(got) != 1 {
+		t.Errorf("cancelled lookup was run: started %q", got)
+	}
+}
+
+func TestScheduler_SetLimitsStartsQueued(t *testing.T) {
+	p := newGateProvider("sched-setlimits")
+	s := lookup.NewScheduler(1, nil)
+	ctx := context.Background()
+	a, b := s.Schedule(ctx, p, "a"), s.Schedule(ctx, p, "b")
+	waitFor(t, "the first lookup to start", func() bool { return s.Stats().Running == 1 })
+
+	<-s.Changes()
+	s.SetLimits(0, nil)
+	waitFor(t, "both lookups to start", func() bool { return s.Stats().Running == 2 })
+	select {
+	case <-s.Changes():
+	default:
+		t.Error("Changes() did not signal after SetLimits")
+	}
+
+	close(p.release)
+	a.Wait()
+	b.Wait()
+}
+
+func TestScheduler_ReportKeepsToLimits(t *testing.T) {
+	disableNetwork(t)
+	gate := newGateProvider("sched-report")
+	lookup.RegisterProvider(gate)
+	// The gate stays registered, so it must not hold up later reports.
+	var open sync.Once
+	release := func() { open.Do(func() { close(gate.release) }) }
+	t.Cleanup(release)
+
+	report, ok := lookup.GetProvider(lookup.ComprehensiveReportName)
+	if !ok {
+		t.Fatal("report provider not registered")
+	}
+	// Reports holding both slots would wait forever for their lookups.
+	s := lookup.NewScheduler(2, map[string]int{"sched-report": 1})
+	ctx := context.Background()
+	a, b := s.Schedule(ctx, report, "a.example"), s.Schedule(ctx, report, "b.example")
+	waitFor(t, "a gated lookup to start", func() bool { return len(gate.startedDomains()) == 1 })
+	time.Sleep(20 * time.Millisecond)
+	if got := gate.startedDomains(); len(got) != 1 {
+		t.Errorf("gated lookups started for %q, want one at a time", got)
+	}
+
+	release()
+	for _, job := range []*lookup.Job{a, b} {
+		result, err := job.Wait()
+		if err != nil {
+			t.Fatalf("report Wait() error = %v", err)
+		}
+		found := false
+		for _, child := range result.Results {
+			found = found || child.Provider == "sched-report"
+		}
+		if !found {
+			t.Errorf("report for %s has no result from the gated provider", result.Query)
+		}
+	}
+	if gate.maxRunning != 1 {
+		t.Errorf("%d gated lookups ran at once, want 1", gate.maxRunning)
+	}
+}
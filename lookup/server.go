@@ -0,0 +1,129 @@
+package lookup
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// NamedResolver is a DNS server that can be chosen by name, such as
+// "google" for 8.8.8.8.
+type NamedResolver struct {
+	Name    string
+	Address string
+}
+
+var (
+	namedResolvers      []NamedResolver
+	namedResolversMutex sync.RWMutex
+)
+
+// SetNamedResolvers replaces the resolvers ResolveServer accepts by name.
+func SetNamedResolvers(resolvers []NamedResolver) {
+	namedResolversMutex.Lock()
+	defer namedResolversMutex.Unlock()
+	namedResolvers = append([]NamedResolver(nil), resolvers...)
+}
+
+// NamedResolvers returns the resolvers that can be chosen by name, in the
+// order they were configured.
+func NamedResolvers() []NamedResolver {
+	namedResolversMutex.RLock()
+	defer namedResolversMutex.RUnlock()
+	return append([]NamedResolver(nil), namedResolvers...)
+}
+
+// ResolveServer turns what a user typed to choose a DNS server into the
+// address to query. s may be the name of a named resolver, or a host name or
+// IP address with an optional port, with or without dig's leading "@". An
+// empty s selects the system resolver and resolves to "".
+func ResolveServer(s string) (string, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "@")
+	if s == "" {
+		return "", nil
+	}
+	for _, r := range NamedResolvers() {
+		if strings.EqualFold(r.Name, s) {
+			return r.Address, nil
+		}
+	}
+	host, port := splitServer(s)
+	if port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return "", fmt.Errorf("invalid port %q in server %q", port, s)
+		}
+	}
+	if net.ParseIP(host) == nil && !validHostname(host) {
+		return "", fmt.Errorf("invalid server %q: not a resolver name, host name or IP address", s)
+	}
+	return s, nil
+}
+
+// ServerName returns the name of the named resolver with the given address,
+// or "" if there is none.
+func ServerName(address string) string {
+	for _, r := range NamedResolvers() {
+		if r.Address == address {
+			return r.Name
+		}
+	}
+	return ""
+}
+
+// ServerLabel describes a server for display: "google (8.8.8.8)" for a named
+// resolver, the address otherwise, and "system resolver" for "".
+func ServerLabel(address string) string {
+	if address == "" {
+		return "system resolver"
+	}
+	if name := ServerName(address); name != "" {
+		return fmt.Sprintf("%s (%s)", name, address)
+	}
+	return address
+}
+
+// splitServer splits a server address into host and port. The port is ""
+// when the address has none; IPv6 addresses may be bare or bracketed.
+func splitServer(s string) (host, port string) {
+	if h, p, err := net.SplitHostPort(s); err == nil {
+		return h, p
+	}
+	return strings.Trim(s, "[]"), ""
+}
+
+func validHostname(s string) bool {
+	s = strings.TrimSuffix(s, ".")
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+type serverKey struct{}
+
+// WithServer returns a context whose DNS lookups query server instead of
+// the system resolver. server is an address as returned by ResolveServer;
+// "" keeps the system resolver. Providers that do not query DNS servers,
+// such as WHOIS and RDAP, ignore it.
+func WithServer(ctx context.Context, server string) context.Context {
+	return context.WithValue(ctx, serverKey{}, server)
+}
+
+// ServerFromContext returns the server set with WithServer, or "".
+func ServerFromContext(ctx context.Context) string {
+	server, _ := ctx.Value(serverKey{}).(string)
+	return server
+}
@@ -0,0 +1,85 @@
+package lookup_test
+
+import (
+	"context"
+	"testing"
+
+	"dlookup/lookup"
+)
+
+// useNamedResolvers sets the named resolvers for the duration of a test.
+func useNamedResolvers(t *testing.T, resolvers ...lookup.NamedResolver) {
+	t.Helper()
+	orig := lookup.NamedResolvers()
+	lookup.SetNamedResolvers(resolvers)
+	t.Cleanup(func() { lookup.SetNamedResolvers(orig) })
+}
+
+func TestResolveServer(t *testing.T) {
+	useNamedResolvers(t, lookup.NamedResolver{Name: "google", Address: "8.8.8.8"})
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "", want: ""},
+		{in: "google", want: "8.8.8.8"},
+		{in: "Google", want: "8.8.8.8"},
+		{in: "@1.1.1.1", want: "1.1.1.1"},
+		{in: "192.0.2.53:5353", want: "192.0.2.53:5353"},
+		{in: "2001:4860:4860::8888", want: "2001:4860:4860::8888"},
+		{in: "[2001:db8::53]:5353", want: "[2001:db8::53]:5353"},
+		{in: "ns1.example.com", want: "ns1.example.com"},
+		{in: "ns1.example.com:99999", wantErr: true},
+		{in: "not a server", wantErr: true},
+		{in: "-bad.example", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := lookup.ResolveServer(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ResolveServer(%q) = (%q, %v), want %q (error %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestServerLabel(t *testing.T) {
+	useNamedResolvers(t, lookup.NamedResolver{Name: "cloudflare", Address: "1.1.1.1"})
+	for in, want := range map[string]string{
+		"":        "system resolver",
+		"1.1.1.1": "cloudflare (1.1.1.1)",
+		"9.9.9.9": "9.9.9.9",
+	} {
+		if got := lookup.ServerLabel(in); got != want {
+			t.Errorf("ServerLabel(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestServerFromContext(t *testing.T) {
+	if got := lookup.ServerFromContext(context.Background()); got != "" {
+		t.Errorf("ServerFromContext() without a server = %q, want \"\"", got)
+	}
+	ctx := lookup.WithServer(context.Background(), "8.8.8.8")
+	if got := lookup.ServerFromContext(ctx); got != "8.8.8.8" {
+		t.Errorf("ServerFromContext() = %q, want 8.8.8.8", got)
+	}
+}
+
+func TestRunLookup_CachesPerServer(t *testing.T) {
+	enableCache(t)
+	p := &callCountProvider{
+		mockProvider: mockProvider{name: "cache-server", flagName: "cache-server"},
+		result:       &lookup.Result{Stdout: "live"},
+	}
+	ctx := context.Background()
+
+	lookup.RunLookup(ctx, p, "example.com")
+	lookup.RunLookup(lookup.WithServer(ctx, "8.8.8.8"), p, "example.com")
+	if p.calls != 2 {
+		t.Errorf("provider called %d times, want 2 (one per server)", p.calls)
+	}
+	cached, _ := lookup.RunLookup(lookup.WithServer(ctx, "8.8.8.8"), p, "example.com")
+	if !cached.Cached || p.calls != 2 {
+		t.Errorf("repeat on the same server: cached %v after %d calls, want a cached result", cached.Cached, p.calls)
+	}
+}
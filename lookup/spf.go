@@ -0,0 +1,348 @@
+package lookup
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SPFRecordSizeLimit is the size an SPF record should stay under so that
+// the answer fits in a 512-byte UDP response (RFC 7208, section 3.4).
+// Longer flattened records are split into included records.
+const SPFRecordSizeLimit = 450
+
+// spfVoidLookupLimit is how many a, mx and exists lookups may come back
+// empty before receivers may fail the check (RFC 7208, section 4.6.4).
+const spfVoidLookupLimit = 2
+
+// spfMaxMX is how many MX records an mx term may have.
+const spfMaxMX = 10
+
+// forEachTerm calls fn with each term of n and, for the terms that pull in
+// another record, the node of that record.
+func (n *SPFNode) forEachTerm(fn func(t *SPFTerm, child *SPFNode)) {
+	next := 0
+	for i := range n.Terms {
+		var child *SPFNode
+		if n.Terms[i].followed() && next < len(n.Children) {
+			child = n.Children[next]
+			next++
+		}
+		fn(&n.Terms[i], child)
+	}
+}
+
+// spfTarget splits the value of an a or mx term, "domain/24//64" with
+// every part optional, into the domain to look up, defaulting to domain,
+// and the prefix lengths to apply to its IPv4 and IPv6 addresses.
+func spfTarget(value, domain string) (target string, v4, v6 int) {
+	target, v4, v6 = value, 32, 128
+	if i := strings.Index(target, "//"); i >= 0 {
+		if n, err := strconv.Atoi(target[i+2:]); err == nil {
+			v6 = n
+		}
+		target = target[:i]
+	}
+	if i := strings.LastIndexByte(target, '/'); i >= 0 {
+		if n, err := strconv.Atoi(target[i+1:]); err == nil {
+			v4 = n
+		}
+		target = target[:i]
+	}
+	if target == "" {
+		target = domain
+	}
+	return target, v4, v6
+}
+
+// spfAddressLookup resolves the names of a and mx terms for the SPF view.
+type spfAddressLookup struct {
+	addrs func(name string) ([]net.IP, error)
+	mx    func(name string) ([]string, error)
+	// voids counts the lookups that found nothing.
+	voids int
+}
+
+// resolve fills in the networks of the ip4, ip6, a and mx terms of n and
+// of the records it includes.
+func (l *spfAddressLookup) resolve(n *SPFNode) {
+	n.forEachTerm(func(t *SPFTerm, child *SPFNode) {
+		if child != nil {
+			l.resolve(child)
+			return
+		}
+		if t.Modifier {
+			return
+		}
+		switch t.Name {
+		case "ip4", "ip6":
+			value := t.Value
+			if !strings.Contains(value, "/") {
+				value += "/32"
+				if t.Name == "ip6" {
+					value = t.Value + "/128"
+				}
+			}
+			if _, ipnet, err := net.ParseCIDR(value); err == nil {
+				t.Networks = []string{ipnet.String()}
+			}
+		case "a", "mx":
+			if strings.Contains(t.Value, "%") {
+				return
+			}
+			target, v4, v6 := spfTarget(t.Value, n.Domain)
+			hosts := []string{target}
+			if t.Name == "mx" {
+				var err error
+				if hosts, err = l.mx(target); err != nil {
+					t.Error = err.Error()
+					return
+				}
+				if len(hosts) > spfMaxMX {
+					t.Error = fmt.Sprintf("%s has %d MX records, more than the %d an mx term may use", target, len(hosts), spfMaxMX)
+					return
+				}
+			}
+			for _, host := range hosts {
+				ips, err := l.addrs(host)
+				if err != nil {
+					t.Error = err.Error()
+					return
+				}
+				for _, ip := range ips {
+					bits, size := v6, 128
+					if ip.To4() != nil {
+						ip, bits, size = ip.To4(), v4, 32
+					}
+					ipnet := &net.IPNet{IP: ip.Mask(net.CIDRMask(bits, size)), Mask: net.CIDRMask(bits, size)}
+					t.Networks = append(t.Networks, ipnet.String())
+				}
+			}
+			if len(t.Networks) == 0 {
+				l.voids++
+			}
+		}
+	})
+}
+
+// SPFNetwork is a network an SPF record passes, with the terms that lead
+// to it, such as "include:_spf.example.net mx".
+type SPFNetwork struct {
+	Network string `json:"network"`
+	Source  string `json:"source"`
+	ipnet   *net.IPNet
+}
+
+// SPFOverlap is a network that a flattened record can leave out because
+// another network already covers it: the same network (a duplicate) or a
+// larger one.
+type SPFOverlap struct {
+	SPFNetwork
+	CoveredBy SPFNetwork `json:"covered_by"`
+}
+
+// SPFFlatRecord is a TXT record of a flattened SPF configuration.
+type SPFFlatRecord struct {
+	Name   string `json:"name"`
+	Record string `json:"record"`
+}
+
+// SPFFlattening is an SPF record rewritten without the lookups of its
+// includes: the networks they resolve to are listed directly.
+type SPFFlattening struct {
+	// Networks are all the networks the record passes, in evaluation
+	// order, before duplicates and overlaps are removed.
+	Networks   []SPFNetwork `json:"networks"`
+	Duplicates []SPFOverlap `json:"duplicates,omitempty"`
+	Overlaps   []SPFOverlap `json:"overlaps,omitempty"`
+	// Kept are the terms that cannot be flattened and are copied as they
+	// are: exists, ptr, terms with macros and includes that failed.
+	Kept []string `json:"kept,omitempty"`
+	// Records are the suggested TXT records: the domain's own, and the
+	// records it includes when the networks do not fit in one.
+	Records []SPFFlatRecord `json:"records"`
+	// Lookups is how many DNS lookups the flattened records use.
+	Lookups int      `json:"lookups"`
+	Notes   []string `json:"notes,omitempty"`
+}
+
+// FlattenSPF rewrites the SPF tree root, whose networks have been
+// resolved, into records that list those networks directly.
+func FlattenSPF(root *SPFNode) *SPFFlattening {
+	f := &SPFFlattening{}
+	var all, exp string
+	var collect func(n *SPFNode, path string, top bool)
+	collect = func(n *SPFNode, path string, top bool) {
+		done := false
+		n.forEachTerm(func(t *SPFTerm, child *SPFNode) {
+			switch {
+			case t.Modifier && t.Name == "redirect":
+				// Handled below: redirect only applies without "all".
+			case t.Modifier:
+				if top && t.Name == "exp" {
+					exp = t.String()
+				}
+			case done:
+				// Mechanisms after "all" are never evaluated.
+			case t.Name == "all":
+				if top {
+					all = t.String()
+				}
+				done = true
+			case t.Qualifier != "+":
+				if top {
+					f.keep(t.String())
+					f.Notes = append(f.Notes, fmt.Sprintf("%s%s moves after the listed networks; check that it does not overlap them", path, t))
+				} else {
+					f.Notes = append(f.Notes, fmt.Sprintf("%s%s is left out: only the passing terms of an included record matter", path, t))
+				}
+			case child != nil && child.Error == "" && t.Name == "include":
+				collect(child, path+t.String()+" ", false)
+			case t.Name == "ip4" || t.Name == "ip6" || t.Name == "a" || t.Name == "mx":
+				if t.Error != "" || strings.Contains(t.Value, "%") {
+					f.keep(t.String())
+					return
+				}
+				for _, network := range t.Networks {
+					_, ipnet, _ := net.ParseCIDR(network)
+					f.Networks = append(f.Networks, SPFNetwork{Network: network, Source: path + t.String(), ipnet: ipnet})
+				}
+			default:
+				f.keep(t.String())
+			}
+		})
+		if done {
+			return
+		}
+		n.forEachTerm(func(t *SPFTerm, child *SPFNode) {
+			if !t.Modifier || t.Name != "redirect" {
+				return
+			}
+			if child == nil || child.Error != "" {
+				f.keep(t.String())
+				return
+			}
+			// The redirected record takes the place of this one, "all"
+			// included.
+			collect(child, path+t.String()+" ", top)
+		})
+	}
+	collect(root, "", true)
+
+	merged := f.merge()
+	var terms []string
+	for _, n := range merged {
+		terms = append(terms, spfNetworkTerm(n.ipnet))
+	}
+	tail := append([]string(nil), f.Kept...)
+	if all != "" {
+		tail = append(tail, all)
+	}
+	if exp != "" {
+		tail = append(tail, exp)
+	}
+	for _, k := range f.Kept {
+		if t, err := parseSPFTerm(k); err == nil && t.countsLookup() {
+			f.Lookups++
+		}
+	}
+
+	record := strings.Join(append(append([]string{"v=spf1"}, terms...), tail...), " ")
+	if len(record) <= SPFRecordSizeLimit {
+		f.Records = []SPFFlatRecord{{Name: root.Domain, Record: record}}
+		return f
+	}
+	// Split the networks into included records that each fit.
+	var includes []string
+	for len(terms) > 0 {
+		name := fmt.Sprintf("_spf%d.%s", len(includes)+1, root.Domain)
+		chunk := "v=spf1"
+		for len(terms) > 0 && len(chunk)+1+len(terms[0]) <= SPFRecordSizeLimit {
+			chunk, terms = chunk+" "+terms[0], terms[1:]
+		}
+		f.Records = append(f.Records, SPFFlatRecord{Name: name, Record: chunk})
+		includes = append(includes, "include:"+name)
+	}
+	f.Lookups += len(includes)
+	main := strings.Join(append(append([]string{"v=spf1"}, includes...), tail...), " ")
+	f.Records = append([]SPFFlatRecord{{Name: root.Domain, Record: main}}, f.Records...)
+	return f
+}
+
+func (f *SPFFlattening) keep(term string) {
+	for _, k := range f.Kept {
+		if k == term {
+			return
+		}
+	}
+	f.Kept = append(f.Kept, term)
+}
+
+// merge finds the duplicate and overlapping networks and returns the
+// others, IPv4 first, in address order.
+func (f *SPFFlattening) merge() []SPFNetwork {
+	var unique []SPFNetwork
+	first := make(map[string]SPFNetwork)
+	for _, n := range f.Networks {
+		if n.ipnet == nil {
+			continue
+		}
+		if prev, ok := first[n.Network]; ok {
+			f.Duplicates = append(f.Duplicates, SPFOverlap{SPFNetwork: n, CoveredBy: prev})
+			continue
+		}
+		first[n.Network] = n
+		unique = append(unique, n)
+	}
+	var merged []SPFNetwork
+	for _, n := range unique {
+		covered := false
+		for _, other := range unique {
+			if other.Network != n.Network && spfContains(other.ipnet, n.ipnet) {
+				f.Overlaps = append(f.Overlaps, SPFOverlap{SPFNetwork: n, CoveredBy: other})
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			merged = append(merged, n)
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		a, b := merged[i].ipnet, merged[j].ipnet
+		if (len(a.IP) == net.IPv4len) != (len(b.IP) == net.IPv4len) {
+			return len(a.IP) == net.IPv4len
+		}
+		if c := bytes.Compare(a.IP, b.IP); c != 0 {
+			return c < 0
+		}
+		ones, _ := a.Mask.Size()
+		otherOnes, _ := b.Mask.Size()
+		return ones < otherOnes
+	})
+	return merged
+}
+
+// spfContains reports whether network outer covers all of inner.
+func spfContains(outer, inner *net.IPNet) bool {
+	outerOnes, outerBits := outer.Mask.Size()
+	innerOnes, innerBits := inner.Mask.Size()
+	return outerBits == innerBits && outerOnes <= innerOnes && outer.Contains(inner.IP)
+}
+
+// spfNetworkTerm writes a network as an ip4 or ip6 term, without the
+// prefix length for single addresses.
+func spfNetworkTerm(n *net.IPNet) string {
+	ones, bits := n.Mask.Size()
+	name := "ip4"
+	if bits == 128 {
+		name = "ip6"
+	}
+	if ones == bits {
+		return name + ":" + n.IP.String()
+	}
+	return name + ":" + n.String()
+}
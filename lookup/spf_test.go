@@ -0,0 +1,95 @@
+package lookup_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"dlookup/lookup"
+)
+
+// spfNode parses record into a node for domain and gives its ip4 and ip6
+// terms their networks, as the SPF view does before flattening.
+func spfNode(t *testing.T, domain, record string, children ...*lookup.SPFNode) *lookup.SPFNode {
+	t.Helper()
+	terms, err := lookup.ParseSPF(record)
+	if err != nil {
+		t.Fatalf("ParseSPF(%q) error = %v", record, err)
+	}
+	for i, term := range terms {
+		if term.Name == "ip4" || term.Name == "ip6" {
+			network := term.Value
+			if !strings.Contains(network, "/") {
+				network += map[string]string{"ip4": "/32", "ip6": "/128"}[term.Name]
+			}
+			terms[i].Networks = []string{network}
+		}
+	}
+	return &lookup.SPFNode{Domain: domain, Record: record, Terms: terms, Children: children}
+}
+
+func TestFlattenSPF(t *testing.T) {
+	inner := spfNode(t, "_spf.mail.test", "v=spf1 ip4:198.51.100.0/24 ip4:192.0.2.0/24 ip6:2001:db8::/32 ~all")
+	root := spfNode(t, "example.com", "v=spf1 ip4:192.0.2.128/25 include:_spf.mail.test ip4:198.51.100.0/24 exists:%{i}.spf.test -all exp=why.example.com", inner)
+
+	f := lookup.FlattenSPF(root)
+	want := "v=spf1 ip4:192.0.2.0/24 ip4:198.51.100.0/24 ip6:2001:db8::/32 exists:%{i}.spf.test -all exp=why.example.com"
+	if len(f.Records) != 1 || f.Records[0].Record != want {
+		t.Fatalf("Records = %+v, want %q", f.Records, want)
+	}
+	if f.Lookups != 1 {
+		t.Errorf("Lookups = %d, want 1 for the exists term", f.Lookups)
+	}
+	if len(f.Duplicates) != 1 || f.Duplicates[0].Source != "ip4:198.51.100.0/24" || f.Duplicates[0].CoveredBy.Source != "include:_spf.mail.test ip4:198.51.100.0/24" {
+		t.Errorf("Duplicates = %+v", f.Duplicates)
+	}
+	if len(f.Overlaps) != 1 || f.Overlaps[0].Network != "192.0.2.128/25" || f.Overlaps[0].CoveredBy.Network != "192.0.2.0/24" {
+		t.Errorf("Overlaps = %+v", f.Overlaps)
+	}
+}
+
+func TestFlattenSPF_Redirect(t *testing.T) {
+	target := spfNode(t, "_spf.example.com", "v=spf1 ip4:192.0.2.1 -ip4:192.0.2.2 ~all")
+	root := spfNode(t, "example.com", "v=spf1 ip6:2001:db8::1 redirect=_spf.example.com", target)
+
+	f := lookup.FlattenSPF(root)
+	want := "v=spf1 ip4:192.0.2.1 ip6:2001:db8::1 -ip4:192.0.2.2 ~all"
+	if len(f.Records) != 1 || f.Records[0].Record != want {
+		t.Errorf("Records = %+v, want %q", f.Records, want)
+	}
+	// The redirected record replaces the domain's own, so its failing
+	// terms still apply.
+	if len(f.Kept) != 1 || f.Kept[0] != "-ip4:192.0.2.2" || len(f.Notes) != 1 {
+		t.Errorf("Kept = %v, Notes = %v", f.Kept, f.Notes)
+	}
+}
+
+func TestFlattenSPF_Split(t *testing.T) {
+	var terms []string
+	for i := 1; i <= 40; i++ {
+		terms = append(terms, fmt.Sprintf("ip4:198.51.100.%d", i))
+	}
+	inner := spfNode(t, "_spf.mail.test", "v=spf1 "+strings.Join(terms, " ")+" -all")
+	root := spfNode(t, "example.com", "v=spf1 include:_spf.mail.test -all", inner)
+
+	f := lookup.FlattenSPF(root)
+	if len(f.Records) != 3 {
+		t.Fatalf("Records = %+v, want the domain's record and two included ones", f.Records)
+	}
+	if want := "v=spf1 include:_spf1.example.com include:_spf2.example.com -all"; f.Records[0].Record != want {
+		t.Errorf("Records[0] = %q, want %q", f.Records[0].Record, want)
+	}
+	if f.Records[1].Name != "_spf1.example.com" || f.Lookups != 2 {
+		t.Errorf("Records[1].Name = %q, Lookups = %d", f.Records[1].Name, f.Lookups)
+	}
+	var flat []string
+	for _, rec := range f.Records[1:] {
+		if len(rec.Record) > lookup.SPFRecordSizeLimit {
+			t.Errorf("%s is %d characters, more than %d", rec.Name, len(rec.Record), lookup.SPFRecordSizeLimit)
+		}
+		flat = append(flat, strings.TrimPrefix(rec.Record, "v=spf1 "))
+	}
+	if got := strings.Fields(strings.Join(flat, " ")); len(got) != 40 {
+		t.Errorf("included records list %d networks, want 40", len(got))
+	}
+}
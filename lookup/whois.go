@@ -0,0 +1,198 @@
+package lookup
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WhoisClient speaks the WHOIS protocol (RFC 3912) on port 43 directly.
+type WhoisClient struct {
+	// Servers maps TLDs (without the leading dot, e.g. "com" or "co.uk") to
+	// WHOIS servers and takes precedence over the built-in table. A server may
+	// carry an explicit port ("whois.example:4343").
+	Servers map[string]string
+	// Timeout bounds each individual server conversation.
+	Timeout time.Duration
+	// MaxReferrals limits how many referrals are followed after the first server.
+	MaxReferrals int
+	// Dial opens connections. Tests replace it to reach local stand-in servers.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	discoveredMu sync.Mutex
+	discovered   map[string]string
+}
+
+// DefaultWhoisClient is used by the native WHOIS provider.
+var DefaultWhoisClient = &WhoisClient{
+	Timeout:      15 * time.Second,
+	MaxReferrals: 3,
+}
+
+// WhoisResponse is the answer of a single server in a referral chain.
+type WhoisResponse struct {
+	Server string
+	Query  string
+	Body   string
+	// Err is set when this hop failed; earlier hops are still returned.
+	Err error
+}
+
+const whoisMaxResponse = 1 << 20
+
+var whoisReferralRegex = regexp.MustCompile(`(?im)^\s*(Registrar WHOIS Server|Whois Server|ReferralServer|refer|whois):[ \t]*(\S+)[ \t]*$`)
+
+// Lookup queries the authoritative WHOIS server for query (a domain name or an
+// IP address) and follows referrals to registrar or RIR servers. The first
+// response is the registry's; later ones are referrals.
+func (c *WhoisClient) Lookup(ctx context.Context, query string) ([]WhoisResponse, error) {
+	query = strings.TrimSuffix(strings.TrimSpace(query), ".")
+	if query == "" {
+		return nil, fmt.Errorf("whois: empty query")
+	}
+
+	server := whoisIPServer
+	if net.ParseIP(query) == nil {
+		var err error
+		server, err = c.serverForDomain(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var responses []WhoisResponse
+	visited := make(map[string]bool)
+	for hop := 0; hop <= c.MaxReferrals; hop++ {
+		visited[strings.ToLower(server)] = true
+		body, err := c.query(ctx, server, query)
+		if err != nil {
+			if hop == 0 {
+				return nil, err
+			}
+			responses = append(responses, WhoisResponse{Server: server, Query: query, Err: err})
+			break
+		}
+		responses = append(responses, WhoisResponse{Server: server, Query: query, Body: body})
+
+		next := whoisReferral(server, body)
+		if next == "" || visited[strings.ToLower(next)] {
+			break
+		}
+		server = next
+	}
+	return responses, nil
+}
+
+// serverForDomain picks the registry server for the domain's TLD, preferring
+// the longest configured suffix so "co.uk" overrides can win over "uk".
+func (c *WhoisClient) serverForDomain(ctx context.Context, domain string) (string, error) {
+	labels := strings.Split(strings.ToLower(domain), ".")
+	for i := 0; i < len(labels); i++ {
+		suffix := strings.Join(labels[i:], ".")
+		if s, ok := c.Servers[suffix]; ok && s != "" {
+			return s, nil
+		}
+	}
+	tld := labels[len(labels)-1]
+	if s, ok := whoisServers[tld]; ok {
+		return s, nil
+	}
+
+	c.discoveredMu.Lock()
+	s, ok := c.discovered[tld]
+	c.discoveredMu.Unlock()
+	if ok {
+		return s, nil
+	}
+
+	body, err := c.query(ctx, whoisIANAServer, tld)
+	if err != nil {
+		return "", fmt.Errorf("whois: could not find server for .%s: %w", tld, err)
+	}
+	s = whoisReferral(whoisIANAServer, body)
+	if s == "" {
+		return "", fmt.Errorf("whois: IANA lists no WHOIS server for .%s", tld)
+	}
+	c.discoveredMu.Lock()
+	if c.discovered == nil {
+		c.discovered = make(map[string]string)
+	}
+	c.discovered[tld] = s
+	c.discoveredMu.Unlock()
+	return s, nil
+}
+
+// query sends one WHOIS request and returns the full response.
+func (c *WhoisClient) query(ctx context.Context, server, query string) (string, error) {
+	if err := waitServer(ctx, server); err != nil {
+		return "", err
+	}
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	dial := c.Dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	conn, err := dial(ctx, "tcp", hostPort(server, "43"))
+	if err != nil {
+		return "", fmt.Errorf("whois: connect to %s: %w", server, err)
+	}
+	defer conn.Close()
+	stop := closeOnDone(ctx, conn)
+	defer stop()
+
+	request := query
+	if format, ok := whoisQueryFormats[strings.ToLower(server)]; ok {
+		request = fmt.Sprintf(format, query)
+	}
+	if _, err := io.WriteString(conn, request+"\r\n"); err != nil {
+		return "", fmt.Errorf("whois: send to %s: %w", server, ctxErr(ctx, err))
+	}
+	data, err := io.ReadAll(io.LimitReader(bufio.NewReader(conn), whoisMaxResponse))
+	if err != nil {
+		return "", fmt.Errorf("whois: read from %s: %w", server, ctxErr(ctx, err))
+	}
+	return strings.TrimSpace(strings.ReplaceAll(string(data), "\r\n", "\n")), nil
+}
+
+// whoisReferral extracts the next server to ask from a response. IANA's
+// "refer:"/"whois:" lines are only trusted when they come from IANA.
+func whoisReferral(server, body string) string {
+	fromIANA := strings.EqualFold(server, whoisIANAServer)
+	for _, m := range whoisReferralRegex.FindAllStringSubmatch(body, -1) {
+		key := strings.ToLower(m[1])
+		if (key == "refer" || key == "whois") != fromIANA {
+			continue
+		}
+		if next := cleanWhoisServer(m[2]); next != "" && !strings.EqualFold(next, server) {
+			return next
+		}
+	}
+	return ""
+}
+
+// cleanWhoisServer turns values like "whois://whois.ripe.net" into a dialable
+// host, rejecting web URLs and rwhois endpoints.
+func cleanWhoisServer(v string) string {
+	v = strings.TrimSpace(v)
+	if i := strings.Index(v, "://"); i >= 0 {
+		if !strings.EqualFold(v[:i], "whois") {
+			return ""
+		}
+		v = v[i+3:]
+	}
+	v = strings.TrimSuffix(v, "/")
+	if v == "" || strings.ContainsAny(v, "/ ") || !strings.Contains(v, ".") {
+		return ""
+	}
+	return strings.ToLower(v)
+}
@@ -0,0 +1,336 @@
+package lookup
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// WhoisInfo holds the registration fields extracted from a WHOIS response.
+// Fields a registry does not publish are left empty.
+type WhoisInfo struct {
+	Domain       string    `json:"domain,omitempty"`
+	Registrar    string    `json:"registrar,omitempty"`
+	Created      WhoisDate `json:"created"`
+	Expires      WhoisDate `json:"expires"`
+	Updated      WhoisDate `json:"updated"`
+	Status       []string  `json:"status,omitempty"`
+	NameServers  []string  `json:"name_servers,omitempty"`
+	DNSSEC       string    `json:"dnssec,omitempty"` // "signed", "unsigned" or "" when not stated
+	AbuseContact string    `json:"abuse_contact,omitempty"`
+
+	// Fields of RIR responses for IP blocks.
+	Network      string `json:"network,omitempty"`
+	NetName      string `json:"net_name,omitempty"`
+	Organization string `json:"organization,omitempty"`
+	Country      string `json:"country,omitempty"`
+}
+
+// WhoisDate is a date from a WHOIS response. Time is zero when Raw is in a
+// format the parser does not recognise, such as Nominet's "before Aug-1996".
+type WhoisDate struct {
+	Time time.Time
+	Raw  string
+}
+
+func (d WhoisDate) IsZero() bool { return d.Raw == "" }
+
+func (d WhoisDate) String() string {
+	if d.Time.IsZero() {
+		return d.Raw
+	}
+	return d.Time.UTC().Format("2006-01-02")
+}
+
+// MarshalJSON encodes the date as RFC 3339, or as the raw text if it could
+// not be parsed. An absent date is null.
+func (d WhoisDate) MarshalJSON() ([]byte, error) {
+	switch {
+	case d.IsZero():
+		return []byte("null"), nil
+	case d.Time.IsZero():
+		return json.Marshal(d.Raw)
+	}
+	return json.Marshal(d.Time.UTC().Format(time.RFC3339))
+}
+
+var whoisDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02",
+	"02-Jan-2006",
+	"2-Jan-2006",
+	"2006.01.02",
+	"02.01.2006",
+	"2006/01/02",
+}
+
+func parseWhoisDate(s string) WhoisDate {
+	d := WhoisDate{Raw: s}
+	for _, layout := range whoisDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			d.Time = t
+			break
+		}
+	}
+	return d
+}
+
+// whoisKeys maps normalised WHOIS keys to the WhoisInfo field they fill.
+// Keys are lowercased with runs of whitespace collapsed.
+var whoisKeys = map[string]string{
+	"domain name": "domain",
+	"domain":      "domain",
+
+	"registrar":            "registrar",
+	"sponsoring registrar": "registrar",
+	"registrar name":       "registrar",
+
+	"creation date":            "created",
+	"created":                  "created",
+	"created on":               "created",
+	"registered on":            "created",
+	"registration time":        "created",
+	"domain registration date": "created",
+	"regdate":                  "created",
+
+	"registry expiry date":                   "expires",
+	"registrar registration expiration date": "expires",
+	"expiry date":                            "expires",
+	"expiration date":                        "expires",
+	"expires":                                "expires",
+	"expires on":                             "expires",
+	"expiration time":                        "expires",
+	"paid-till":                              "expires",
+
+	"updated date":  "updated",
+	"last updated":  "updated",
+	"last-modified": "updated",
+	"last modified": "updated",
+	"changed":       "updated",
+	"updated":       "updated",
+
+	"domain status":       "status",
+	"status":              "status",
+	"registration status": "status",
+
+	"name server":  "nameserver",
+	"name servers": "nameserver",
+	"nameserver":   "nameserver",
+	"nameservers":  "nameserver",
+	"nserver":      "nameserver",
+
+	"dnssec": "dnssec",
+	"dnskey": "dnskey",
+
+	"registrar abuse contact email": "abuse",
+	"abuse-mailbox":                 "abuse",
+	"orgabuseemail":                 "abuse",
+	"registrar abuse contact phone": "abusephone",
+
+	"inetnum":                 "network",
+	"inet6num":                "network",
+	"netrange":                "network",
+	"netname":                 "netname",
+	"org-name":                "organization",
+	"orgname":                 "organization",
+	"organization":            "organization",
+	"registrant organization": "organization",
+	"registrant":              "organization",
+	"country":                 "country",
+}
+
+var (
+	whoisKeyRegex   = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9 ./()_-]{0,40}?):(?:\s+(.*))?$`)
+	ripeAbuseRegex  = regexp.MustCompile(`^% Abuse contact for .* is '([^']+)'`)
+	whoisSpaceRegex = regexp.MustCompile(`\s+`)
+)
+
+// ParseWhois extracts registration fields from a WHOIS response. It
+// understands the ICANN registry and registrar formats (Verisign thin and
+// thick), Nominet (.uk), DENIC (.de) and the RIPE and ARIN formats for IP
+// blocks. When a field appears more than once the first value wins, so for
+// output that concatenates registry and registrar responses the registry
+// data takes precedence.
+func ParseWhois(text string) *WhoisInfo {
+	info := &WhoisInfo{}
+	var abusePhone string
+	var section string
+	sectionIndent := 0
+
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			section = ""
+			continue
+		}
+		if m := ripeAbuseRegex.FindStringSubmatch(trimmed); m != nil {
+			setOnce(&info.AbuseContact, m[1])
+			continue
+		}
+		if strings.HasPrefix(trimmed, "%") || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ">>>") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+
+		key, value := "", ""
+		if m := whoisKeyRegex.FindStringSubmatch(trimmed); m != nil {
+			key, value = normaliseWhoisKey(m[1]), strings.TrimSpace(m[2])
+			if value == "" {
+				// Nominet style: "Name servers:" followed by indented values.
+				section, sectionIndent = key, indent
+				continue
+			}
+		} else if section != "" && indent > sectionIndent {
+			key, value = section, trimmed
+		} else {
+			continue
+		}
+
+		switch whoisKeys[key] {
+		case "domain":
+			setOnce(&info.Domain, strings.ToLower(value))
+		case "registrar":
+			setOnce(&info.Registrar, value)
+		case "created":
+			setDateOnce(&info.Created, value)
+		case "expires":
+			setDateOnce(&info.Expires, value)
+		case "updated":
+			setDateOnce(&info.Updated, value)
+		case "status":
+			info.Status = appendUnique(info.Status, whoisStatus(value))
+		case "nameserver":
+			ns := strings.ToLower(strings.TrimSuffix(strings.Fields(value)[0], "."))
+			info.NameServers = appendUnique(info.NameServers, ns)
+		case "dnssec":
+			if info.DNSSEC == "" {
+				info.DNSSEC = whoisDNSSEC(value)
+			}
+		case "dnskey":
+			info.DNSSEC = "signed"
+		case "abuse":
+			setOnce(&info.AbuseContact, value)
+		case "abusephone":
+			setOnce(&abusePhone, value)
+		case "network":
+			setOnce(&info.Network, value)
+		case "netname":
+			setOnce(&info.NetName, value)
+		case "organization":
+			setOnce(&info.Organization, value)
+		case "country":
+			setOnce(&info.Country, strings.ToUpper(value))
+		}
+	}
+	setOnce(&info.AbuseContact, abusePhone)
+	return info
+}
+
+func normaliseWhoisKey(key string) string {
+	return whoisSpaceRegex.ReplaceAllString(strings.ToLower(strings.TrimSpace(key)), " ")
+}
+
+func setOnce(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
+
+func setDateOnce(field *WhoisDate, value string) {
+	if field.IsZero() {
+		*field = parseWhoisDate(value)
+	}
+}
+
+func appendUnique(list []string, value string) []string {
+	if value == "" {
+		return list
+	}
+	for _, v := range list {
+		if strings.EqualFold(v, value) {
+			return list
+		}
+	}
+	return append(list, value)
+}
+
+// whoisStatus drops the explanatory URL ICANN formats append to EPP status
+// codes ("clientHold https://icann.org/epp#clientHold").
+func whoisStatus(value string) string {
+	fields := strings.Fields(value)
+	if len(fields) == 2 && (strings.HasPrefix(fields[1], "http") || strings.HasPrefix(fields[1], "(http")) {
+		return fields[0]
+	}
+	return value
+}
+
+func whoisDNSSEC(value string) string {
+	switch v := strings.ToLower(value); {
+	case strings.HasPrefix(v, "unsigned"), v == "no", v == "inactive":
+		return "unsigned"
+	case strings.HasPrefix(v, "signed"), v == "yes", v == "active":
+		return "signed"
+	}
+	return value
+}
+
+// merge fills the fields of w that are empty from other.
+func (w *WhoisInfo) merge(other *WhoisInfo) {
+	setOnce(&w.Domain, other.Domain)
+	setOnce(&w.Registrar, other.Registrar)
+	if w.Created.IsZero() {
+		w.Created = other.Created
+	}
+	if w.Expires.IsZero() {
+		w.Expires = other.Expires
+	}
+	if w.Updated.IsZero() {
+		w.Updated = other.Updated
+	}
+	for _, s := range other.Status {
+		w.Status = appendUnique(w.Status, s)
+	}
+	for _, ns := range other.NameServers {
+		w.NameServers = appendUnique(w.NameServers, ns)
+	}
+	setOnce(&w.DNSSEC, other.DNSSEC)
+	setOnce(&w.AbuseContact, other.AbuseContact)
+	setOnce(&w.Network, other.Network)
+	setOnce(&w.NetName, other.NetName)
+	setOnce(&w.Organization, other.Organization)
+	setOnce(&w.Country, other.Country)
+}
+
+// IsEmpty reports whether no field could be extracted.
+func (w *WhoisInfo) IsEmpty() bool {
+	return w.Summary() == ""
+}
+
+// Summary renders the extracted fields, one per line.
+func (w *WhoisInfo) Summary() string {
+	var b strings.Builder
+	line := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "%-14s %s\n", label+":", value)
+		}
+	}
+	line("Domain", w.Domain)
+	line("Network", w.Network)
+	line("Net Name", w.NetName)
+	line("Organization", w.Organization)
+	line("Country", w.Country)
+	line("Registrar", w.Registrar)
+	line("Created", w.Created.String())
+	line("Expires", w.Expires.String())
+	line("Updated", w.Updated.String())
+	line("Status", strings.Join(w.Status, ", "))
+	line("Name Servers", strings.Join(w.NameServers, ", "))
+	line("DNSSEC", w.DNSSEC)
+	line("Abuse Contact", w.AbuseContact)
+	return strings.TrimSuffix(b.String(), "\n")
+}
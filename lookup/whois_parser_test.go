@@ -0,0 +1,131 @@
+package lookup_test
+
+import (
+	"dlookup/lookup"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readWhoisFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "whois", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestParseWhois(t *testing.T) {
+	tests := []struct {
+		file string
+		want map[string]string // field -> expected String() form
+		ns   []string
+	}{
+		{
+			file: "verisign_thin.txt",
+			want: map[string]string{
+				"Domain": "google.com", "Registrar": "MarkMonitor Inc.",
+				"Created": "1997-09-15", "Expires": "2028-09-14", "Updated": "2019-09-09",
+				"Status":       "clientDeleteProhibited, clientTransferProhibited, clientUpdateProhibited, serverDeleteProhibited, serverTransferProhibited, serverUpdateProhibited",
+				"DNSSEC":       "unsigned",
+				"AbuseContact": "abusecomplaints@markmonitor.com",
+			},
+			ns: []string{"ns1.google.com", "ns2.google.com", "ns3.google.com", "ns4.google.com"},
+		},
+		{
+			file: "registrar_thick.txt",
+			want: map[string]string{
+				"Domain": "cloudflare.com", "Registrar": "Cloudflare, Inc.",
+				"Created": "2009-02-17", "Expires": "2033-02-17", "Updated": "2024-01-09",
+				"Status":       "clientTransferProhibited, clientDeleteProhibited",
+				"DNSSEC":       "signed",
+				"AbuseContact": "registrar-abuse@cloudflare.com",
+				"Organization": "Cloudflare, Inc.",
+			},
+			ns: []string{"ns3.cloudflare.com", "ns4.cloudflare.com", "ns5.cloudflare.com", "ns6.cloudflare.com", "ns7.cloudflare.com"},
+		},
+		{
+			file: "nominet.txt",
+			want: map[string]string{
+				"Domain": "bbc.co.uk", "Registrar": "British Broadcasting Corporation [Tag = BBC]",
+				"Created": "before Aug-1996", "Expires": "2025-12-13", "Updated": "2023-11-13",
+				"Status": "Registered until expiry date.",
+			},
+			ns: []string{"dns0.bbc.co.uk", "dns0.bbc.com", "dns1.bbc.co.uk", "dns1.bbc.com", "ddns0.bbc.co.uk", "ddns1.bbc.com"},
+		},
+		{
+			file: "denic.txt",
+			want: map[string]string{
+				"Domain": "denic.de", "Updated": "2018-03-12", "Status": "connect", "DNSSEC": "signed",
+			},
+			ns: []string{"ns1.denic.de", "ns2.denic.de", "ns3.denic.de", "ns4.denic.net"},
+		},
+		{
+			file: "ripe.txt",
+			want: map[string]string{
+				"Network": "193.0.0.0 - 193.0.7.255", "NetName": "RIPE-NCC",
+				"Organization": "Reseaux IP Europeens Network Coordination Centre (RIPE NCC)",
+				"Country":      "NL", "Status": "ASSIGNED PA",
+				"Created": "2003-03-17", "Updated": "2017-12-04",
+				"AbuseContact": "abuse@ripe.net",
+			},
+		},
+		{
+			file: "arin.txt",
+			want: map[string]string{
+				"Network": "8.8.8.0 - 8.8.8.255", "NetName": "GOGL",
+				"Organization": "Google LLC (GOGL)", "Country": "US",
+				"Created": "2023-12-28", "Updated": "2023-12-28",
+				"AbuseContact": "network-abuse@google.com",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.file, func(t *testing.T) {
+			info := lookup.ParseWhois(readWhoisFixture(t, tc.file))
+			got := map[string]string{
+				"Domain": info.Domain, "Registrar": info.Registrar,
+				"Created": info.Created.String(), "Expires": info.Expires.String(), "Updated": info.Updated.String(),
+				"Status": strings.Join(info.Status, ", "), "DNSSEC": info.DNSSEC, "AbuseContact": info.AbuseContact,
+				"Network": info.Network, "NetName": info.NetName, "Organization": info.Organization, "Country": info.Country,
+			}
+			for field, value := range got {
+				if want := tc.want[field]; value != want {
+					t.Errorf("%s = %q, want %q", field, value, want)
+				}
+			}
+			if !reflect.DeepEqual(info.NameServers, tc.ns) {
+				t.Errorf("NameServers = %q, want %q", info.NameServers, tc.ns)
+			}
+		})
+	}
+}
+
+func TestParseWhois_Empty(t *testing.T) {
+	info := lookup.ParseWhois("No match for \"NOPE.COM\".\n>>> Last update of whois database: 2025-10-14T09:41:12Z <<<\n")
+	if !info.IsEmpty() {
+		t.Errorf("IsEmpty() = false for a no-match response; got summary:\n%s", info.Summary())
+	}
+}
+
+func TestWhoisInfo_Summary(t *testing.T) {
+	info := lookup.ParseWhois(readWhoisFixture(t, "verisign_thin.txt"))
+	summary := info.Summary()
+	for _, want := range []string{
+		"Registrar:     MarkMonitor Inc.",
+		"Expires:       2028-09-14",
+		"Name Servers:  ns1.google.com, ns2.google.com, ns3.google.com, ns4.google.com",
+		"Abuse Contact: abusecomplaints@markmonitor.com",
+	} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("Summary() missing %q. Got:\n%s", want, summary)
+		}
+	}
+	if strings.Contains(summary, "Network:") {
+		t.Errorf("Summary() shows empty fields. Got:\n%s", summary)
+	}
+}
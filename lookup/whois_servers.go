@@ -0,0 +1,107 @@
+package lookup
+
+// whoisServers maps TLDs (without the leading dot) to their registry WHOIS
+// servers. It is a snapshot of the IANA root zone database for the TLDs we see
+// most; anything missing is resolved at runtime through whois.iana.org.
+var whoisServers = map[string]string{
+	// Generic TLDs
+	"com":    "whois.verisign-grs.com",
+	"net":    "whois.verisign-grs.com",
+	"org":    "whois.publicinterestregistry.org",
+	"info":   "whois.nic.info",
+	"biz":    "whois.nic.biz",
+	"name":   "whois.nic.name",
+	"pro":    "whois.nic.pro",
+	"mobi":   "whois.nic.mobi",
+	"edu":    "whois.educause.edu",
+	"gov":    "whois.dotgov.gov",
+	"int":    "whois.iana.org",
+	"arpa":   "whois.iana.org",
+	"app":    "whois.nic.google",
+	"dev":    "whois.nic.google",
+	"page":   "whois.nic.google",
+	"xyz":    "whois.nic.xyz",
+	"online": "whois.nic.online",
+	"site":   "whois.nic.site",
+	"store":  "whois.nic.store",
+	"tech":   "whois.nic.tech",
+	"shop":   "whois.nic.shop",
+	"cloud":  "whois.nic.cloud",
+
+	// Country-code TLDs
+	"ac": "whois.nic.ac",
+	"ai": "whois.nic.ai",
+	"at": "whois.nic.at",
+	"au": "whois.auda.org.au",
+	"be": "whois.dns.be",
+	"br": "whois.registro.br",
+	"ca": "whois.cira.ca",
+	"cc": "ccwhois.verisign-grs.com",
+	"ch": "whois.nic.ch",
+	"cl": "whois.nic.cl",
+	"cn": "whois.cnnic.cn",
+	"co": "whois.nic.co",
+	"cz": "whois.nic.cz",
+	"de": "whois.denic.de",
+	"dk": "whois.punktum.dk",
+	"ee": "whois.tld.ee",
+	"eu": "whois.eu",
+	"fi": "whois.fi",
+	"fm": "whois.nic.fm",
+	"fr": "whois.nic.fr",
+	"gg": "whois.gg",
+	"hk": "whois.hkirc.hk",
+	"hr": "whois.dns.hr",
+	"hu": "whois.nic.hu",
+	"id": "whois.id",
+	"ie": "whois.weare.ie",
+	"im": "whois.nic.im",
+	"in": "whois.registry.in",
+	"io": "whois.nic.io",
+	"is": "whois.isnic.is",
+	"it": "whois.nic.it",
+	"jp": "whois.jprs.jp",
+	"kr": "whois.kr",
+	"la": "whois.nic.la",
+	"li": "whois.nic.li",
+	"lt": "whois.domreg.lt",
+	"lv": "whois.nic.lv",
+	"me": "whois.nic.me",
+	"mx": "whois.mx",
+	"my": "whois.mynic.my",
+	"nl": "whois.domain-registry.nl",
+	"no": "whois.norid.no",
+	"nu": "whois.iis.nu",
+	"nz": "whois.irs.net.nz",
+	"pl": "whois.dns.pl",
+	"pt": "whois.dns.pt",
+	"ro": "whois.rotld.ro",
+	"ru": "whois.tcinet.ru",
+	"se": "whois.iis.se",
+	"sg": "whois.sgnic.sg",
+	"sh": "whois.nic.sh",
+	"si": "whois.register.si",
+	"sk": "whois.sk-nic.sk",
+	"th": "whois.thnic.co.th",
+	"to": "whois.tonic.to",
+	"tv": "whois.nic.tv",
+	"tw": "whois.twnic.net.tw",
+	"ua": "whois.ua",
+	"uk": "whois.nic.uk",
+	"us": "whois.nic.us",
+	"ws": "whois.website.ws",
+}
+
+// whoisQueryFormats holds servers that expect more than the bare query.
+var whoisQueryFormats = map[string]string{
+	"whois.arin.net":         "n + %s",
+	"whois.denic.de":         "-T dn,ace %s",
+	"whois.verisign-grs.com": "domain %s",
+}
+
+const (
+	whoisIANAServer = "whois.iana.org"
+	// whoisIPServer is where IP queries start; ARIN refers queries for address
+	// space managed by the other RIRs via ReferralServer.
+	whoisIPServer = "whois.arin.net"
+)
@@ -0,0 +1,222 @@
+package lookup_test
+
+import (
+	"bufio"
+	"context"
+	"dlookup/lookup"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startWhoisServer runs a stand-in port-43 server on loopback. handler maps
+// the received query line to the response body.
+func startWhoisServer(t *testing.T, handler func(query string) string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				line, err := bufio.NewReader(c).ReadString('\n')
+				if err != nil {
+					return
+				}
+				fmt.Fprint(c, handler(strings.TrimRight(line, "\r\n")))
+			}(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// whoisNetwork routes dials for named WHOIS hosts to stand-in servers and
+// records every query in order.
+type whoisNetwork struct {
+	mu      sync.Mutex
+	servers map[string]func(query string) string
+	log     []string
+	addrs   map[string]string
+}
+
+func newWhoisNetwork(t *testing.T, servers map[string]func(query string) string) *whoisNetwork {
+	n := &whoisNetwork{servers: servers, addrs: make(map[string]string)}
+	for host, handler := range servers {
+		host, handler := host, handler
+		n.addrs[host] = startWhoisServer(t, func(q string) string {
+			n.mu.Lock()
+			n.log = append(n.log, host+" "+q)
+			n.mu.Unlock()
+			return handler(q)
+		})
+	}
+	return n
+}
+
+func (n *whoisNetwork) client() *lookup.WhoisClient {
+	return &lookup.WhoisClient{
+		Timeout:      2 * time.Second,
+		MaxReferrals: 3,
+		Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			host, _, _ := net.SplitHostPort(addr)
+			target, ok := n.addrs[host]
+			if !ok {
+				return nil, fmt.Errorf("no stand-in server for %s", host)
+			}
+			var d net.Dialer
+			return d.DialContext(ctx, network, target)
+		},
+	}
+}
+
+func (n *whoisNetwork) queries() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string(nil), n.log...)
+}
+
+func TestWhoisClient_FollowsRegistrarReferral(t *testing.T) {
+	n := newWhoisNetwork(t, map[string]func(string) string{
+		"whois.verisign-grs.com": func(q string) string {
+			return "   Domain Name: EXAMPLE.COM\r\n   Registrar WHOIS Server: whois.registrar.test\r\n   Registrar: Example Registrar\r\n"
+		},
+		"whois.registrar.test": func(q string) string {
+			return "Domain Name: example.com\nRegistrant Organization: Example Inc.\nRegistrar WHOIS Server: whois.registrar.test\n"
+		},
+	})
+
+	responses, err := n.client().Lookup(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if len(responses) != 2 {
+		t.Fatalf("got %d responses, want 2: %+v", len(responses), responses)
+	}
+	if responses[0].Server != "whois.verisign-grs.com" || responses[1].Server != "whois.registrar.test" {
+		t.Errorf("servers = %q, %q", responses[0].Server, responses[1].Server)
+	}
+	if !strings.Contains(responses[1].Body, "Registrant Organization: Example Inc.") {
+		t.Errorf("registrar body missing: %q", responses[1].Body)
+	}
+	want := []string{"whois.verisign-grs.com domain example.com", "whois.registrar.test example.com"}
+	if got := n.queries(); !equalSlices(got, want) {
+		t.Errorf("queries = %q, want %q", got, want)
+	}
+}
+
+func TestWhoisClient_UnknownTLDUsesIANA(t *testing.T) {
+	n := newWhoisNetwork(t, map[string]func(string) string{
+		"whois.iana.org": func(q string) string {
+			return "% IANA WHOIS server\n\ndomain:       EXAMPLETLD\nwhois:        whois.nic.exampletld\n"
+		},
+		"whois.nic.exampletld": func(q string) string {
+			return "Domain Name: " + q + "\n"
+		},
+	})
+	client := n.client()
+
+	for i := 0; i < 2; i++ {
+		responses, err := client.Lookup(context.Background(), "foo.exampletld")
+		if err != nil {
+			t.Fatalf("Lookup() error = %v", err)
+		}
+		if len(responses) != 1 || responses[0].Server != "whois.nic.exampletld" {
+			t.Fatalf("unexpected responses: %+v", responses)
+		}
+	}
+	// The IANA answer is cached, so IANA is only asked once.
+	want := []string{"whois.iana.org exampletld", "whois.nic.exampletld foo.exampletld", "whois.nic.exampletld foo.exampletld"}
+	if got := n.queries(); !equalSlices(got, want) {
+		t.Errorf("queries = %q, want %q", got, want)
+	}
+}
+
+func TestWhoisClient_ServerOverride(t *testing.T) {
+	n := newWhoisNetwork(t, map[string]func(string) string{
+		"whois.internal.test": func(q string) string { return "internal answer for " + q },
+	})
+	client := n.client()
+	client.Servers = map[string]string{"co.uk": "whois.internal.test"}
+
+	responses, err := client.Lookup(context.Background(), "example.co.uk")
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if responses[0].Server != "whois.internal.test" || responses[0].Body != "internal answer for example.co.uk" {
+		t.Errorf("unexpected response: %+v", responses[0])
+	}
+}
+
+func TestWhoisClient_IPReferralToRIR(t *testing.T) {
+	n := newWhoisNetwork(t, map[string]func(string) string{
+		"whois.arin.net": func(q string) string {
+			return "NetRange:       193.0.0.0 - 193.255.255.255\nOrgName:        RIPE Network Coordination Centre\nReferralServer:  whois://whois.ripe.net\n"
+		},
+		"whois.ripe.net": func(q string) string {
+			return "inetnum:        193.0.0.0 - 193.0.7.255\nnetname:        RIPE-NCC\n"
+		},
+	})
+
+	responses, err := n.client().Lookup(context.Background(), "193.0.6.139")
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	want := []string{"whois.arin.net n + 193.0.6.139", "whois.ripe.net 193.0.6.139"}
+	if got := n.queries(); !equalSlices(got, want) {
+		t.Errorf("queries = %q, want %q", got, want)
+	}
+	if len(responses) != 2 || !strings.Contains(responses[1].Body, "RIPE-NCC") {
+		t.Errorf("unexpected responses: %+v", responses)
+	}
+}
+
+func TestWhoisClient_ReferralFailureKeepsRegistryAnswer(t *testing.T) {
+	n := newWhoisNetwork(t, map[string]func(string) string{
+		"whois.verisign-grs.com": func(q string) string {
+			return "Domain Name: EXAMPLE.NET\nRegistrar WHOIS Server: whois.unreachable.test\n"
+		},
+	})
+
+	responses, err := n.client().Lookup(context.Background(), "example.net")
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if len(responses) != 2 || responses[1].Err == nil {
+		t.Fatalf("expected failed referral hop, got %+v", responses)
+	}
+	if !strings.Contains(responses[0].Body, "EXAMPLE.NET") {
+		t.Errorf("registry answer missing: %+v", responses[0])
+	}
+}
+
+func TestWhoisClient_IgnoresWebAndRWhoisReferrals(t *testing.T) {
+	n := newWhoisNetwork(t, map[string]func(string) string{
+		"whois.arin.net": func(q string) string {
+			return "NetRange: 198.51.100.0 - 198.51.100.255\nReferralServer: rwhois://rwhois.example.net:4321\n"
+		},
+		"whois.verisign-grs.com": func(q string) string {
+			return "Domain Name: EXAMPLE.COM\nRegistrar WHOIS Server: https://registrar.example/whois\n"
+		},
+	})
+	client := n.client()
+
+	for _, q := range []string{"198.51.100.7", "example.com"} {
+		responses, err := client.Lookup(context.Background(), q)
+		if err != nil {
+			t.Fatalf("Lookup(%q) error = %v", q, err)
+		}
+		if len(responses) != 1 {
+			t.Errorf("Lookup(%q) followed a referral it should have ignored: %+v", q, responses)
+		}
+	}
+}
@@ -1,18 +1,23 @@
 package main
 
 import (
-	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
+	"github.com/atotto/clipboard"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
@@ -25,6 +30,11 @@ import (
 
 var (
 	lookupFlagValues = make(map[string]*string)
+	noTUIFlag        *bool
+	outputFlag       *string
+	columnFlag       *string
+	typesFlag        *string
+	serverFlag       *string
 )
 
 func init() {
@@ -38,6 +48,12 @@ func init() {
 		usage := p.Usage()
 		lookupFlagValues[flagName] = flag.String(flagName, "", usage)
 	}
+
+	noTUIFlag = flag.Bool("no-tui", false, "Print results to stdout instead of starting the interactive UI")
+	outputFlag = flag.String("output", "", fmt.Sprintf("Output format without the UI: %s (implies --no-tui)", strings.Join(lookup.OutputFormats, ", ")))
+	typesFlag = flag.String("types", "", "Comma-separated lookup types to run on every domain, e.g. a,mx,txt (DNS record types or lookup flag names)")
+	serverFlag = flag.String("server", "", "DNS server for dig, nslookup and the native DNS lookups: a resolver name from the config, or an IP address or host name with optional port")
+	columnFlag = flag.String("column", "", "Read input files as CSV and take domains from this column (1-based index or header name)")
 }
 
 var (
@@ -75,6 +91,15 @@ var (
 
 	errorStyle   = lipgloss.NewStyle().Foreground(colorRed)
 	loadingStyle = lipgloss.NewStyle().Foreground(colorOrange).Padding(1, 1)
+	commandStyle = lipgloss.NewStyle().Foreground(colorLightGrey)
+	summaryStyle = lipgloss.NewStyle().
+			Border(lipgloss.NormalBorder(), false, false, false, true).
+			BorderForeground(colorGreen).
+			Padding(0, 1)
+	stderrStyle   = lipgloss.NewStyle().Foreground(colorOrange)
+	progressStyle = lipgloss.NewStyle().Foreground(colorOrange).Padding(0, 1)
+	agreeStyle    = lipgloss.NewStyle().Foreground(colorGreen)
+	disagreeStyle = lipgloss.NewStyle().Foreground(colorRed).Bold(true)
 
 	helpKeyStyle       = lipgloss.NewStyle().Foreground(colorHelpKey)
 	helpDescStyle      = lipgloss.NewStyle().Foreground(colorHelpDesc)
@@ -110,12 +135,120 @@ func max(a, b int) int {
 
 type lookupResultMsg struct {
 	tabId  int
-	kind   string
-	output string
+	runID  int
+	result *lookup.Result
 }
 type errorMsg struct {
-	tabId int
-	err   error
+	tabId  int
+	runID  int
+	err    error
+	result *lookup.Result // Partial result, if the lookup produced one
+}
+
+var errLookupCancelled = errors.New("lookup cancelled")
+
+// schedulerMsg reports that lookups were queued, started or finished, so
+// queue positions and progress are redrawn.
+type schedulerMsg struct{}
+
+// watchTickMsg asks a watching tab to run its lookup again. watchID tells
+// the ticks of the current watch apart from those of one already stopped.
+type watchTickMsg struct {
+	tabId   int
+	watchID int
+}
+
+// lookupRun tracks the in-flight lookup of a tab so it can be cancelled.
+// tabModel is copied on every update, so copies share it through a pointer.
+type lookupRun struct {
+	mu       sync.Mutex
+	id       int
+	cancel   context.CancelFunc
+	job      *lookup.Job
+	rangeJob *lookup.RangeJob
+}
+
+// start cancels any previous lookup and returns the context and ID for a new one.
+func (r *lookupRun) start() (context.Context, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		r.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.id++
+	r.cancel = cancel
+	r.job = nil
+	r.rangeJob = nil
+	return ctx, r.id
+}
+
+// setJob records the scheduled job of run id.
+func (r *lookupRun) setJob(id int, job *lookup.Job) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.id == id {
+		r.job = job
+	}
+}
+
+// setRangeJob records the range lookup of run id.
+func (r *lookupRun) setRangeJob(id int, job *lookup.RangeJob) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.id == id {
+		r.rangeJob = job
+	}
+}
+
+// rangeProgress returns how many addresses of a range lookup are done, and
+// how many there are; total is 0 if the run is not a range lookup.
+func (r *lookupRun) rangeProgress() (done, total int) {
+	r.mu.Lock()
+	job := r.rangeJob
+	r.mu.Unlock()
+	if job == nil {
+		return 0, 0
+	}
+	return job.Progress()
+}
+
+// queuePosition returns the place of the lookup in the scheduler's queue, or
+// 0 if it is running or there is none.
+func (r *lookupRun) queuePosition() int {
+	r.mu.Lock()
+	job := r.job
+	r.mu.Unlock()
+	if job == nil {
+		return 0
+	}
+	return job.Position()
+}
+
+// pending reports whether a lookup has been scheduled and not yet stopped.
+func (r *lookupRun) pending() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cancel != nil
+}
+
+// stop cancels the running lookup; results it still delivers are ignored.
+func (r *lookupRun) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+	r.job = nil
+	r.rangeJob = nil
+	r.id++
+}
+
+func (r *lookupRun) isCurrent(id int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.id == id
 }
 
 type tabState int
@@ -128,6 +261,7 @@ const (
 	stateError
 	stateWatchIntervalInput
 	stateExportFilenameInput
+	stateServerInput
 )
 
 type lookupItem string
@@ -167,7 +301,7 @@ type tabModel struct {
 	lookupList    list.Model
 	domain        string
 	lookupType    string
-	result        string
+	result        *lookup.Result
 	err           error
 	loadingMsg    string
 	width         int
@@ -176,32 +310,43 @@ type tabModel struct {
 
 	isWatching    bool
 	watchInterval time.Duration
+	watchID       int
 	intervalInput textinput.Model
 	lastState     tabState
 	exportInput   textinput.Model
 	exportMsg     string
+
+	// inputErr explains why the entered domain was rejected.
+	inputErr string
+
+	// server is the DNS server the tab's lookups query; "" for the system
+	// resolver.
+	server      string
+	serverInput textinput.Model
+	serverMsg   string
+
+	// copyMsg reports the outcome of the copy key in the result header
+	// until the result is shown again.
+	copyMsg string
+
+	// rangeSort selects the order of a range lookup's table: the column is
+	// lookup.RangeColumns[rangeSort/2], descending if rangeSort is odd.
+	rangeSort int
+
+	run *lookupRun
 }
 
 func newTabModel(width, height int, initialDomain string, initialLookupType string) tabModel {
 	ti := textinput.New()
-	ti.Placeholder = "example.com or IP"
-	ti.CharLimit = 256
+	ti.Placeholder = "example.com, IP or URL"
+	ti.CharLimit = 2048
 	ti.Width = max(40, width-4)
 	ti.PromptStyle = focusedStyle
 	ti.TextStyle = focusedStyle
 	ti.Cursor.Style = cursorStyle
 
-	providers := lookup.AvailableProviders()
-	sort.Slice(providers, func(i, j int) bool {
-		return providers[i].Name() < providers[j].Name()
-	})
-	items := make([]list.Item, len(providers))
-	for i, p := range providers {
-		items[i] = lookupItem(p.Name())
-	}
-
 	delegate := itemDelegate{}
-	lookupList := list.New(items, delegate, width-4, 10)
+	lookupList := list.New(lookupItems(lookup.KindDomain), delegate, width-4, 10)
 	lookupList.Title = "Select Lookup Type:"
 	lookupList.Styles.Title = listHeaderStyle
 	lookupList.SetShowStatusBar(false)
@@ -221,6 +366,11 @@ func newTabModel(width, height int, initialDomain string, initialLookupType stri
 	exportInput.CharLimit = 256
 	exportInput.Width = max(50, width-10)
 
+	serverInput := textinput.New()
+	serverInput.Placeholder = "google, 8.8.8.8 or ns1.example.com"
+	serverInput.CharLimit = 256
+	serverInput.Width = max(40, width/2-10)
+
 	m := tabModel{
 		id:            nextTabID,
 		textInput:     ti,
@@ -232,7 +382,9 @@ func newTabModel(width, height int, initialDomain string, initialLookupType stri
 		isWatching:    false,
 		intervalInput: intervalInput,
 		exportInput:   exportInput,
+		serverInput:   serverInput,
 		lastState:     stateInputDomain,
+		run:           &lookupRun{},
 	}
 	nextTabID++
 	m.textInput.SetValue(initialDomain)
@@ -241,7 +393,7 @@ func newTabModel(width, height int, initialDomain string, initialLookupType stri
 		if _, exists := lookup.GetProvider(initialLookupType); exists {
 			m.state = stateLoading
 			m.lookupType = initialLookupType
-			m.loadingMsg = fmt.Sprintf("Running %s on %s...", m.lookupType, m.domain)
+			m.loadingMsg = fmt.Sprintf("Running %s on %s...", m.lookupType, lookup.DisplayName(m.domain))
 			m.textInput.Blur()
 		} else {
 
@@ -258,6 +410,22 @@ func newTabModel(width, height int, initialDomain string, initialLookupType stri
 	return m
 }
 
+// lookupItems lists the available providers that accept input of kind,
+// sorted by name.
+func lookupItems(kind lookup.InputKind) []list.Item {
+	providers := lookup.AvailableProviders()
+	sort.Slice(providers, func(i, j int) bool {
+		return providers[i].Name() < providers[j].Name()
+	})
+	var items []list.Item
+	for _, p := range providers {
+		if lookup.Accepts(p, kind) {
+			items = append(items, lookupItem(p.Name()))
+		}
+	}
+	return items
+}
+
 func (m *tabModel) setSize(width, height int) {
 	m.width = width
 	m.height = height
@@ -274,6 +442,9 @@ func (m *tabModel) setSize(width, height int) {
 		m.textInput.Width = max(40, width-4)
 		inputRendered := m.textInput.View()
 		tabInternalHeaderHeight = lipgloss.Height(promptRendered) + lipgloss.Height(inputRendered) + 1
+		if m.inputErr != "" {
+			tabInternalHeaderHeight += lipgloss.Height(m.inputErr)
+		}
 	} else {
 
 		tabInternalHeaderHeight = 1 + 1
@@ -319,8 +490,12 @@ func (m tabModel) Init() tea.Cmd {
 		}
 		return m.textInput.Focus()
 	case stateLoading:
-
-		return m.runSelectedLookup()
+		// Batch tabs are all scheduled at startup; don't requeue them when
+		// switching to them.
+		if m.run.pending() {
+			return nil
+		}
+		return m.runSelectedLookup(false)
 
 	default:
 		return nil
@@ -345,13 +520,13 @@ func (m tabModel) Update(msg tea.Msg, k Keybindings) (tabModel, tea.Cmd) {
 				m.isWatching = false
 				m.state = stateInputDomain
 				m.textInput.Focus()
-				m.textInput.SetValue(m.domain)
+				m.textInput.SetValue(lookup.ToUnicode(m.domain))
 				m.textInput.CursorEnd()
 				cmds = append(cmds, textinput.Blink)
 				m.setSize(m.width, m.height)
 				return m, tea.Batch(cmds...)
 			case k.WatchToggle:
-				if m.lookupType != lookup.ComprehensiveReportName {
+				if m.watchable() {
 					m.lastState = m.state
 					m.state = stateWatchIntervalInput
 					m.intervalInput.Focus()
@@ -360,6 +535,34 @@ func (m tabModel) Update(msg tea.Msg, k Keybindings) (tabModel, tea.Cmd) {
 					m.setSize(m.width, m.height)
 					return m, tea.Batch(cmds...)
 				}
+			case k.Refresh:
+				if m.lookupType != "" {
+					m.state = stateLoading
+					m.loadingMsg = fmt.Sprintf("Refreshing %s on %s...", m.lookupType, lookup.DisplayName(m.domain))
+					m.err = nil
+					m.result = nil
+					cmds = append(cmds, m.runSelectedLookup(true))
+					m.setSize(m.width, m.height)
+					return m, tea.Batch(cmds...)
+				}
+			case k.Server:
+				return m, m.openServerInput()
+			case k.Sort:
+				if _, ok := m.rangeReport(); ok && m.state == stateViewResults {
+					m.rangeSort = (m.rangeSort + 1) % (2 * len(lookup.RangeColumns))
+					m.showResult()
+					return m, tea.Batch(cmds...)
+				}
+			case k.Copy:
+				if text, ok := m.copyText(); ok && m.state == stateViewResults {
+					if err := clipboard.WriteAll(text); err != nil {
+						m.copyMsg = fmt.Sprintf("copy failed: %v", err)
+					} else {
+						m.copyMsg = "copied to the clipboard"
+					}
+					m.showResult()
+					return m, tea.Batch(cmds...)
+				}
 			case k.Export:
 				m.lastState = m.state
 				m.state = stateExportFilenameInput
@@ -376,26 +579,43 @@ func (m tabModel) Update(msg tea.Msg, k Keybindings) (tabModel, tea.Cmd) {
 		case stateInputDomain:
 			switch msg.String() {
 			case k.Confirm:
-				trimmedDomain := strings.TrimSpace(m.textInput.Value())
-				if trimmedDomain != "" {
-					m.domain = trimmedDomain
+				// Nothing runs until the input is a valid domain or address.
+				input, err := lookup.NormalizeInput(m.textInput.Value())
+				var items []list.Item
+				if err == nil {
+					if items = lookupItems(input.Kind); len(items) == 0 {
+						err = fmt.Errorf("no lookups apply to a %s", input.Kind)
+					}
+				}
+				if err != nil {
+					m.inputErr = err.Error()
+					m.setSize(m.width, m.height)
+					cmd = textinput.Blink
+				} else {
+					m.inputErr = ""
+					m.domain = input.Query
 					m.state = stateSelectLookup
 					m.textInput.Blur()
+					m.lookupList.SetItems(items)
+					m.lookupList.Title = fmt.Sprintf("Select Lookup Type (%s):", input.Kind)
 					m.lookupList.ResetFilter()
 					m.lookupList.Select(0)
 					m.setSize(m.width, m.height)
-				} else {
-					m.textInput.SetValue("")
-					cmd = textinput.Blink
 				}
 			case "ctrl+c":
 				return m, tea.Quit
 			default:
 				m.textInput, cmd = m.textInput.Update(msg)
 				cmds = append(cmds, cmd)
+				if m.inputErr != "" {
+					m.inputErr = ""
+					m.setSize(m.width, m.height)
+				}
 			}
 		case stateSelectLookup:
 			switch msg.String() {
+			case k.Server:
+				return m, m.openServerInput()
 			case k.Back:
 				m.state = stateInputDomain
 				m.isWatching = false
@@ -412,11 +632,11 @@ func (m tabModel) Update(msg tea.Msg, k Keybindings) (tabModel, tea.Cmd) {
 					m.lookupType = selectedItem.(lookupItem).FilterValue()
 					m.state = stateLoading
 					m.isWatching = false
-					m.loadingMsg = fmt.Sprintf("Running %s on %s...", m.lookupType, m.domain)
+					m.loadingMsg = fmt.Sprintf("Running %s on %s...", m.lookupType, lookup.DisplayName(m.domain))
 					m.err = nil
-					m.result = ""
+					m.result = nil
 					m.viewport.GotoTop()
-					cmds = append(cmds, m.runSelectedLookup())
+					cmds = append(cmds, m.runSelectedLookup(false))
 					m.setSize(m.width, m.height)
 				}
 			case tea.KeyEsc:
@@ -439,9 +659,10 @@ func (m tabModel) Update(msg tea.Msg, k Keybindings) (tabModel, tea.Cmd) {
 					m.watchInterval = time.Duration(intervalSec) * time.Second
 					m.isWatching = true
 					m.state = stateLoading
-					m.loadingMsg = fmt.Sprintf("Watching %s on %s (every %ds)...", m.lookupType, m.domain, intervalSec)
+					m.loadingMsg = fmt.Sprintf("Watching %s on %s (every %ds)...", m.lookupType, lookup.DisplayName(m.domain), intervalSec)
 					m.intervalInput.Blur()
-					cmds = append(cmds, m.runSelectedLookup())
+					m.watchID++
+					cmds = append(cmds, m.runSelectedLookup(true), m.watchTick())
 
 					m.setSize(m.width, m.height)
 				} else {
@@ -458,6 +679,40 @@ func (m tabModel) Update(msg tea.Msg, k Keybindings) (tabModel, tea.Cmd) {
 				m.intervalInput, cmd = m.intervalInput.Update(msg)
 				cmds = append(cmds, cmd)
 			}
+		case stateServerInput:
+			switch msg.String() {
+			case k.Confirm:
+				server, err := lookup.ResolveServer(m.serverInput.Value())
+				if err != nil {
+					m.serverMsg = err.Error()
+					cmds = append(cmds, textinput.Blink)
+					return m, tea.Batch(cmds...)
+				}
+				m.server = server
+				m.serverInput.Blur()
+				m.serverMsg = ""
+				m.state = m.lastState
+				// A result on display was answered by the old server; ask the new one.
+				if (m.lastState == stateViewResults || m.lastState == stateError) && m.lookupType != "" {
+					m.state = stateLoading
+					m.loadingMsg = fmt.Sprintf("Running %s on %s via %s...", m.lookupType, lookup.DisplayName(m.domain), lookup.ServerLabel(m.server))
+					m.err = nil
+					m.result = nil
+					cmds = append(cmds, m.runSelectedLookup(m.isWatching))
+				}
+				m.setSize(m.width, m.height)
+			case "tab":
+				m.serverInput.SetValue(nextResolverName(m.serverInput.Value()))
+				m.serverInput.CursorEnd()
+			case k.Cancel:
+				m.state = m.lastState
+				m.serverInput.Blur()
+				m.serverMsg = ""
+				m.setSize(m.width, m.height)
+			default:
+				m.serverInput, cmd = m.serverInput.Update(msg)
+				cmds = append(cmds, cmd)
+			}
 		case stateExportFilenameInput:
 			switch msg.String() {
 			case k.Confirm:
@@ -480,14 +735,29 @@ func (m tabModel) Update(msg tea.Msg, k Keybindings) (tabModel, tea.Cmd) {
 					}
 
 					contentToSave := ""
-					if m.lastState == stateViewResults {
-						contentToSave = m.result
+					if strings.EqualFold(filepath.Ext(filename), ".json") && m.result != nil {
+						data, err := json.MarshalIndent(m.result, "", "  ")
+						if err != nil {
+							m.exportMsg = fmt.Sprintf("Error encoding result: %v", err)
+							cmd = textinput.Blink
+							cmds = append(cmds, cmd)
+							return m, tea.Batch(cmds...) // Stop processing
+						}
+						contentToSave = string(data) + "\n"
+					} else if m.lastState == stateViewResults && m.result != nil {
+						contentToSave = m.result.Text()
+						if m.result.Server != "" {
+							contentToSave = fmt.Sprintf("Server: %s\n\n%s", lookup.ServerLabel(m.result.Server), contentToSave)
+						}
 					} else if m.lastState == stateError {
-						header := fmt.Sprintf("Error running %s for %s", m.lookupType, m.domain)
+						header := fmt.Sprintf("Error running %s for %s", m.lookupType, lookup.DisplayName(m.domain))
 						if m.isWatching {
 							header += fmt.Sprintf(" [Watching: %s]", m.watchInterval)
 						}
 						contentToSave = fmt.Sprintf("%s\nError:\n%v", header, m.err)
+						if m.result != nil && (m.result.Stdout != "" || m.result.Stderr != "") {
+							contentToSave += "\n\n" + m.result.Text()
+						}
 					} else {
 						// Should not happen, but handle gracefully
 						contentToSave = "Error: Cannot determine content to export."
@@ -529,55 +799,259 @@ func (m tabModel) Update(msg tea.Msg, k Keybindings) (tabModel, tea.Cmd) {
 		case stateViewResults, stateError:
 			break
 		case stateLoading:
-			if msg.String() == "ctrl+c" {
+			switch msg.String() {
+			case "ctrl+c":
 				return m, tea.Quit
+			case k.Cancel, k.Back:
+				m.run.stop()
+				m.isWatching = false
+				m.state = stateError
+				m.err = errLookupCancelled
+				m.loadingMsg = ""
+				m.result = nil
+				m.showError()
+				m.setSize(m.width, m.height)
 			}
 		}
 
-	case time.Time:
-		if m.isWatching {
-			cmds = append(cmds, m.runSelectedLookup())
-
-			cmds = append(cmds, tea.Tick(m.watchInterval, func(t time.Time) tea.Msg { return t }))
+	case watchTickMsg:
+		if msg.tabId == m.id && msg.watchID == m.watchID && m.isWatching {
+			cmds = append(cmds, m.runSelectedLookup(true), m.watchTick())
 		}
 
 	case lookupResultMsg:
-		if msg.tabId == m.id {
+		if msg.tabId == m.id && m.run.isCurrent(msg.runID) {
 			m.state = stateViewResults
-			m.result = msg.output
+			m.result = msg.result
 			m.loadingMsg = ""
 			m.err = nil
-			header := resultHeaderStyle.Render(fmt.Sprintf("%s Results for %s", m.lookupType, m.domain))
-
-			if m.isWatching {
-				header += fmt.Sprintf(" [Watching: %s]", m.watchInterval)
-			}
-			fullContent := header + "\n" + m.result
-			m.viewport.SetContent(fullContent)
-
-			m.viewport.GotoTop()
-
+			m.showResult()
 		}
 	case errorMsg:
-		if msg.tabId == m.id {
+		if msg.tabId == m.id && m.run.isCurrent(msg.runID) {
 			m.state = stateError
 			m.err = msg.err
 			m.loadingMsg = ""
-			m.result = ""
+			m.result = msg.result
+			m.showError()
+		}
+	}
+	return m, tea.Batch(cmds...)
+}
 
-			header := fmt.Sprintf("Error running %s for %s", m.lookupType, m.domain)
-			if m.isWatching {
-				header += fmt.Sprintf(" [Watching: %s]", m.watchInterval)
-			}
-			errorRendered := errorStyle.Render(fmt.Sprintf(`%s
+// watchable reports whether the tab's lookup can be watched: reports and
+// range lookups are too large to repeat every few seconds.
+func (m *tabModel) watchable() bool {
+	return m.lookupType != lookup.ComprehensiveReportName && lookup.DetectInputKind(m.domain) != lookup.KindRange
+}
+
+// rangeReport returns the report of the tab's range lookup, if it has one.
+func (m *tabModel) rangeReport() (*lookup.RangeReport, bool) {
+	if m.result == nil {
+		return nil, false
+	}
+	report, ok := m.result.Details.(*lookup.RangeReport)
+	return report, ok
+}
+
+// copyText returns what the copy key puts on the clipboard for the tab's
+// result, such as the flattened record of an SPF lookup, if it has any.
+func (m *tabModel) copyText() (string, bool) {
+	if m.result == nil {
+		return "", false
+	}
+	c, ok := m.result.Details.(interface{ CopyText() string })
+	if !ok || c.CopyText() == "" {
+		return "", false
+	}
+	return c.CopyText(), true
+}
+
+// showResult renders m.result into the viewport.
+func (m *tabModel) showResult() {
+	if report, ok := m.rangeReport(); ok {
+		report.Sort(lookup.RangeColumns[m.rangeSort/2], m.rangeSort%2 == 1)
+		m.result.Stdout = strings.Join(report.Table(), "\n")
+	}
+	header := resultHeaderStyle.Render(fmt.Sprintf("%s Results for %s (%s)", m.lookupType, lookup.DisplayName(m.domain), m.result.Duration.Round(time.Millisecond)))
+	if m.isWatching {
+		header += fmt.Sprintf(" [Watching: %s]", m.watchInterval)
+	}
+	if m.result.Cached {
+		now := time.Now()
+		header += fmt.Sprintf(" [cached %s ago, expires in %s]", now.Sub(m.result.CachedAt).Round(time.Second), m.result.Expires.Sub(now).Round(time.Second))
+	}
+	if m.copyMsg != "" {
+		header += " [" + m.copyMsg + "]"
+		m.copyMsg = ""
+	}
+	content := header + "\n"
+	if summary := m.result.Summary(); summary != "" {
+		content += summaryStyle.Render(summary) + "\n\n"
+	}
+	if m.result.Command != "" {
+		content += commandStyle.Render("$ "+m.result.Command) + "\n\n"
+	}
+	if note := retryNote(m.result); note != "" {
+		content += commandStyle.Render(note) + "\n\n"
+	}
+	switch report := m.result.Details.(type) {
+	case *lookup.PropagationReport:
+		content += propagationTable(report)
+	case *lookup.ConsistencyReport:
+		content += consistencyTable(report)
+	case *lookup.TraceReport:
+		content += traceTree(report)
+	case *lookup.DNSSECReport:
+		content += dnssecTree(report)
+	case *lookup.EmailReport:
+		content += highlightMarks(report.Listing())
+	case *lookup.SPFReport:
+		content += highlightMarks(report.Listing())
+	case *lookup.CNAMEChainReport:
+		content += highlightMarks(report.Chain())
+	case *lookup.RangeReport:
+		content += rangeTable(report)
+	default:
+		content += m.result.Output()
+	}
+	if m.result.Stderr != "" {
+		content += "\n\n" + stderrStyle.Render("stderr:\n"+m.result.Stderr)
+	}
+	m.viewport.SetContent(content)
+	m.viewport.GotoTop()
+}
+
+// propagationTable renders the answers of a propagation check, with the
+// servers that disagree with the authoritative answer highlighted.
+func propagationTable(report *lookup.PropagationReport) string {
+	lines := report.Table()
+	out := []string{lipgloss.NewStyle().Bold(true).Render(lines[0])}
+	for i, a := range report.Answers {
+		style := agreeStyle
+		if !a.Agrees {
+			style = disagreeStyle
+		}
+		out = append(out, style.Render(lines[i+1]))
+	}
+	return strings.Join(out, "\n")
+}
+
+// consistencyTable renders the servers of a name server consistency check,
+// with those that disagree or failed highlighted, followed by the problems.
+func consistencyTable(report *lookup.ConsistencyReport) string {
+	lines := report.Table()
+	out := []string{lipgloss.NewStyle().Bold(true).Render(lines[0])}
+	for i := range report.Servers {
+		style := agreeStyle
+		if !report.Consistent(&report.Servers[i]) {
+			style = disagreeStyle
+		}
+		out = append(out, style.Render(lines[i+1]))
+	}
+	if len(report.Problems) > 0 {
+		out = append(out, "", "Problems:")
+		for _, p := range report.Problems {
+			out = append(out, disagreeStyle.Render("✗ "+p))
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// traceTree renders the delegation tree of a trace, with the servers that
+// failed highlighted.
+func traceTree(report *lookup.TraceReport) string {
+	lines := strings.Split(report.Tree(), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimLeft(line, " "), "✗") {
+			lines[i] = disagreeStyle.Render(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// dnssecTree renders the chain of trust of a DNSSEC validation, with bogus
+// links and problems highlighted and warnings set apart.
+func dnssecTree(report *lookup.DNSSECReport) string {
+	return highlightMarks(report.Tree())
+}
+
+// highlightMarks styles the lines of text that start with a mark: "✗"
+// (failures) like disagreeing answers, "!" (warnings) like stderr.
+func highlightMarks(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		switch trimmed := strings.TrimLeft(line, " "); {
+		case strings.HasPrefix(trimmed, "✗"):
+			lines[i] = disagreeStyle.Render(line)
+		case strings.HasPrefix(trimmed, "! "):
+			lines[i] = stderrStyle.Render(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// rangeTable renders the rows of a range lookup, with the addresses whose
+// lookup failed highlighted.
+func rangeTable(report *lookup.RangeReport) string {
+	lines := report.Table()
+	out := []string{lipgloss.NewStyle().Bold(true).Render(lines[0])}
+	for i, row := range report.Rows {
+		line := lines[i+1]
+		switch row.Status {
+		case lookup.RangeFailed:
+			line = disagreeStyle.Render(line)
+		case lookup.RangeEmpty:
+			line = commandStyle.Render(line)
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// retryNote describes the retries and rate-limit waits of r, or returns ""
+// if it ran once without waiting.
+func retryNote(r *lookup.Result) string {
+	var parts []string
+	if len(r.Retries) > 0 {
+		parts = append(parts, fmt.Sprintf("%d attempts, retried after: %s", r.Attempts, strings.Join(r.Retries, "; ")))
+	}
+	if r.Throttled > 0 {
+		parts = append(parts, fmt.Sprintf("waited %s for rate limit", r.Throttled.Round(time.Millisecond)))
+	}
+	return strings.Join(parts, " | ")
+}
+
+// showError renders m.err, and any output the failed lookup produced, into
+// the viewport.
+func (m *tabModel) showError() {
+	header := fmt.Sprintf("Error running %s for %s", m.lookupType, lookup.DisplayName(m.domain))
+	if m.isWatching {
+		header += fmt.Sprintf(" [Watching: %s]", m.watchInterval)
+	}
+	errorRendered := errorStyle.Render(fmt.Sprintf(`%s
 Error:
 %v`, header, m.err))
-			m.viewport.SetContent(errorRendered)
-			m.viewport.GotoTop()
-
+	if r := m.result; r != nil {
+		if r.Command != "" {
+			errorRendered += "\n\n" + commandStyle.Render(fmt.Sprintf("$ %s (exit code %d)", r.Command, r.ExitCode))
+		}
+		if note := retryNote(r); note != "" {
+			errorRendered += "\n\n" + commandStyle.Render(note)
+		}
+		if report, ok := r.Details.(*lookup.TraceReport); ok {
+			errorRendered += "\n\n" + traceTree(report)
+		} else if report, ok := r.Details.(*lookup.DNSSECReport); ok && len(report.Links) > 0 {
+			errorRendered += "\n\n" + dnssecTree(report)
+		} else if r.Stdout != "" {
+			errorRendered += "\n\n" + r.Stdout
+		}
+		if r.Stderr != "" {
+			errorRendered += "\n\n" + stderrStyle.Render("stderr:\n"+r.Stderr)
 		}
 	}
-	return m, tea.Batch(cmds...)
+	m.viewport.SetContent(errorRendered)
+	m.viewport.GotoTop()
 }
 
 func (m tabModel) View() string {
@@ -587,20 +1061,27 @@ func (m tabModel) View() string {
 		b.WriteString("\n")
 		inputStyle := lipgloss.NewStyle().Padding(0, 1)
 		b.WriteString(inputStyle.Render(m.textInput.View()))
+		if m.inputErr != "" {
+			b.WriteString("\n")
+			b.WriteString(inputStyle.Render(errorStyle.Render(m.inputErr)))
+		}
 	} else if m.state != stateWatchIntervalInput {
-		domainStr := m.domain
+		domainStr := lookup.DisplayName(m.domain)
 		watchStatus := ""
 		if m.isWatching {
 			watchStatus = fmt.Sprintf(" [Watching: %s]", m.watchInterval)
 		}
+		if m.server != "" {
+			watchStatus = fmt.Sprintf(" | Server: %s", lookup.ServerLabel(m.server)) + watchStatus
+		}
 
 		maxHeaderContentLen := m.width - 25 - lipgloss.Width(watchStatus)
 		if maxHeaderContentLen < 10 {
 			maxHeaderContentLen = 10
 		}
 
-		if len(domainStr) > maxHeaderContentLen {
-			domainStr = domainStr[:maxHeaderContentLen-3] + "..."
+		if r := []rune(domainStr); len(r) > maxHeaderContentLen {
+			domainStr = string(r[:maxHeaderContentLen-3]) + "..."
 		}
 		header := fmt.Sprintf("Domain: %s", domainStr)
 		if m.lookupType != "" {
@@ -629,7 +1110,14 @@ func (m tabModel) View() string {
 	case stateSelectLookup:
 		b.WriteString(m.lookupList.View())
 	case stateLoading:
-		b.WriteString(loadingStyle.Render(m.loadingMsg))
+		loadingMsg := m.loadingMsg
+		if pos := m.run.queuePosition(); pos > 0 {
+			loadingMsg += fmt.Sprintf("\nQueued: position %d of %d", pos, lookup.DefaultScheduler.Stats().Queued)
+		}
+		if done, total := m.run.rangeProgress(); total > 0 {
+			loadingMsg += fmt.Sprintf("\nLooked up %d of %d addresses", done, total)
+		}
+		b.WriteString(loadingStyle.Render(loadingMsg))
 	case stateError:
 		if m.viewportReady {
 
@@ -662,6 +1150,28 @@ func (m tabModel) View() string {
 		modalView := modalStyle.Render(modalContent)
 		centeredModal := lipgloss.Place(availableWidth, availableHeight, lipgloss.Center, lipgloss.Center, modalView)
 		b.WriteString(centeredModal)
+	case stateServerInput:
+		prompt := fmt.Sprintf("DNS server for this tab (now: %s):", lookup.ServerLabel(m.server))
+		if m.serverMsg != "" {
+			prompt = errorStyle.Render(m.serverMsg)
+		}
+		var names []string
+		for _, r := range lookup.NamedResolvers() {
+			names = append(names, fmt.Sprintf("%s (%s)", r.Name, r.Address))
+		}
+		modalContent := fmt.Sprintf("%s\n%s\n", prompt, m.serverInput.View())
+		if len(names) > 0 {
+			modalContent += "Named: " + strings.Join(names, ", ") + "\n"
+		}
+		modalContent += "(Enter to confirm, empty for the system resolver, Tab for the next name, Esc to cancel)"
+		modalStyle := lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(colorLightBlue).
+			Padding(1, 2).Width(max(60, m.width/2))
+
+		modalView := modalStyle.Render(modalContent)
+		centeredModal := lipgloss.Place(m.width, m.height-2, lipgloss.Center, lipgloss.Center, modalView)
+		b.WriteString(centeredModal)
 	case stateExportFilenameInput:
 		// Render the main content area (e.g., the result view) dimmed or blurred if possible
 		// (Simple approach: just render the modal over whatever was there)
@@ -691,84 +1201,94 @@ func (m tabModel) View() string {
 	return b.String()
 }
 
-func (m *tabModel) runSelectedLookup() tea.Cmd {
-
-	if m.lookupType == lookup.ComprehensiveReportName {
-		return runComprehensiveLookup(m.id, m.domain)
+// runSelectedLookup schedules the tab's lookup. With refresh set it bypasses
+// the cache, as watch mode and the refresh key need live answers.
+func (m *tabModel) runSelectedLookup(refresh bool) tea.Cmd {
+	ctx, runID := m.run.start()
+	if refresh {
+		ctx = lookup.WithRefresh(ctx)
+	}
+	if m.server != "" {
+		ctx = lookup.WithServer(ctx, m.server)
 	}
+	tabID, domain := m.id, m.domain
 
 	provider, exists := lookup.GetProvider(m.lookupType)
 	if !exists {
 		return func() tea.Msg {
-			return errorMsg{tabId: m.id, err: fmt.Errorf("internal error: unknown lookup type selected: %s", m.lookupType)}
+			return errorMsg{tabId: tabID, runID: runID, err: fmt.Errorf("internal error: unknown lookup type selected: %s", m.lookupType)}
 		}
 	}
 	if !provider.CheckAvailability() {
 		return func() tea.Msg {
-			return errorMsg{tabId: m.id, err: fmt.Errorf("required command for %s not found", provider.Name())}
+			return errorMsg{tabId: tabID, runID: runID, err: fmt.Errorf("required command for %s not found", provider.Name())}
 		}
 	}
-	return func() tea.Msg {
-		output, err := provider.Execute(m.domain)
+	// A range is looked up address by address and shown as one table.
+	if lookup.DetectInputKind(domain) == lookup.KindRange {
+		rangeJob, err := lookup.DefaultScheduler.ScheduleRange(ctx, provider, domain)
 		if err != nil {
-			return errorMsg{tabId: m.id, err: err}
+			return func() tea.Msg {
+				return errorMsg{tabId: tabID, runID: runID, err: err}
+			}
+		}
+		m.run.setRangeJob(runID, rangeJob)
+		return func() tea.Msg {
+			result, err := rangeJob.Wait()
+			if err != nil {
+				return errorMsg{tabId: tabID, runID: runID, err: err, result: result}
+			}
+			return lookupResultMsg{tabId: tabID, runID: runID, result: result}
 		}
-		return lookupResultMsg{tabId: m.id, kind: provider.Name(), output: output}
 	}
-}
-
-func runComprehensiveLookup(tabId int, domain string) tea.Cmd {
+	job := lookup.DefaultScheduler.Schedule(ctx, provider, domain)
+	m.run.setJob(runID, job)
 	return func() tea.Msg {
-
-		providers := lookup.AvailableProviders()
-		providersToRun := make([]lookup.LookupProvider, 0, len(providers))
-		for _, p := range providers {
-			if p.Name() != lookup.ComprehensiveReportName {
-				providersToRun = append(providersToRun, p)
-			}
+		result, err := job.Wait()
+		if err != nil {
+			return errorMsg{tabId: tabID, runID: runID, err: err, result: result}
 		}
+		return lookupResultMsg{tabId: tabID, runID: runID, result: result}
+	}
+}
 
-		sort.Slice(providersToRun, func(i, j int) bool {
-
-			return providersToRun[i].Name() < providersToRun[j].Name()
-		})
-
-		var wg sync.WaitGroup
-		results := make(map[string]string)
-		var resultsMutex sync.Mutex
-
-		completedCount := 0
+// watchTick schedules the next run of the current watch.
+func (m *tabModel) watchTick() tea.Cmd {
+	tabID, watchID := m.id, m.watchID
+	return tea.Tick(m.watchInterval, func(time.Time) tea.Msg {
+		return watchTickMsg{tabId: tabID, watchID: watchID}
+	})
+}
 
-		for _, prov := range providersToRun {
-			wg.Add(1)
-			go func(p lookup.LookupProvider) {
-				defer wg.Done()
-				var output string
-				if p.CheckAvailability() {
-					var err error
-					output, err = p.Execute(domain)
-					if err != nil {
-						output = fmt.Sprintf("Error executing %s: %v\n--- Raw Output (if any) ---\n%s", p.Name(), err, output)
-					}
-				} else {
-					output = fmt.Sprintf("(Command for %s not available)", p.Name())
-				}
-				resultsMutex.Lock()
-				results[p.Name()] = output
-				completedCount++
+// openServerInput switches to the server prompt, prefilled with the tab's
+// server.
+func (m *tabModel) openServerInput() tea.Cmd {
+	m.lastState = m.state
+	m.state = stateServerInput
+	m.serverMsg = ""
+	value := lookup.ServerName(m.server)
+	if value == "" {
+		value = m.server
+	}
+	m.serverInput.SetValue(value)
+	m.serverInput.CursorEnd()
+	m.setSize(m.width, m.height)
+	return tea.Batch(m.serverInput.Focus(), textinput.Blink)
+}
 
-				resultsMutex.Unlock()
-			}(prov)
+// nextResolverName returns the name of the named resolver after the one
+// named current, wrapping around, or the first name if current is not one.
+func nextResolverName(current string) string {
+	resolvers := lookup.NamedResolvers()
+	if len(resolvers) == 0 {
+		return current
+	}
+	for i, r := range resolvers {
+		if strings.EqualFold(r.Name, current) {
+			return resolvers[(i+1)%len(resolvers)].Name
 		}
-
-		wg.Wait()
-
-		providerOrder := lookup.GetComprehensiveReportOrder()
-
-		finalOutput := lookup.FormatComprehensiveReport(domain, results, providerOrder)
-
-		return lookupResultMsg{tabId: tabId, kind: lookup.ComprehensiveReportName, output: finalOutput}
 	}
+	return resolvers[0].Name
 }
 
 type mainModel struct {
@@ -777,22 +1297,37 @@ type mainModel struct {
 	width     int
 	height    int
 	config    AppConfig
+	// server is the DNS server new tabs start with, from --server.
+	server string
 }
 
-func initialMainModel(initialDomains []string, initialLookupType string, cfg AppConfig) mainModel {
+// initialMainModel opens a tab for each domain and lookup type. Without
+// lookup types each domain gets a tab waiting for a lookup to be chosen.
+// Every tab, including ones opened later, starts out querying server.
+func initialMainModel(initialDomains []string, initialLookupTypes []string, server string, cfg AppConfig) mainModel {
 	m := mainModel{
 		activeTab: 0,
 		width:     80,
 		height:    24,
 		config:    cfg,
+		server:    server,
 	}
 
 	if len(initialDomains) == 0 {
 		m.tabs = []tabModel{newTabModel(m.width, m.height, "", "")}
 	} else {
-		m.tabs = make([]tabModel, 0, len(initialDomains))
+		if len(initialLookupTypes) == 0 {
+			initialLookupTypes = []string{""}
+		}
+		m.tabs = make([]tabModel, 0, len(initialDomains)*len(initialLookupTypes))
 		for _, domain := range initialDomains {
-			m.tabs = append(m.tabs, newTabModel(m.width, m.height, domain, initialLookupType))
+			for _, lookupType := range initialLookupTypes {
+				if p, ok := lookup.GetProvider(lookupType); ok && !lookup.Accepts(p, lookup.DetectInputKind(domain)) {
+					log.Printf("Skipping %s: it does not apply to %s (%s)", lookupType, domain, lookup.DetectInputKind(domain))
+					continue
+				}
+				m.tabs = append(m.tabs, newTabModel(m.width, m.height, domain, lookupType))
+			}
 		}
 	}
 
@@ -802,15 +1337,23 @@ func initialMainModel(initialDomains []string, initialLookupType string, cfg App
 		m.tabs = []tabModel{newTabModel(m.width, m.height, "", "")}
 		m.activeTab = 0
 	}
+	for i := range m.tabs {
+		m.tabs[i].server = server
+	}
 
 	return m
 }
 
+// Init starts the active tab and schedules the lookups of every other tab
+// opened with one; the scheduler bounds how many run at once.
 func (m mainModel) Init() tea.Cmd {
-	if len(m.tabs) > 0 && m.activeTab >= 0 && m.activeTab < len(m.tabs) {
-		return m.tabs[m.activeTab].Init()
+	var cmds []tea.Cmd
+	for i := range m.tabs {
+		if i == m.activeTab || m.tabs[i].state == stateLoading {
+			cmds = append(cmds, m.tabs[i].Init())
+		}
 	}
-	return nil
+	return tea.Batch(cmds...)
 }
 
 func (m mainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -825,13 +1368,10 @@ func (m mainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.tabs[i].setSize(m.width, m.height)
 
 			currentState := m.tabs[i].state
-			if currentState == stateViewResults && m.tabs[i].result != "" {
-				header := resultHeaderStyle.Render(fmt.Sprintf("%s Results for %s", m.tabs[i].lookupType, m.tabs[i].domain))
-				fullContent := header + "\n" + m.tabs[i].result
-				m.tabs[i].viewport.SetContent(fullContent)
+			if currentState == stateViewResults && m.tabs[i].result != nil {
+				m.tabs[i].showResult()
 			} else if currentState == stateError && m.tabs[i].err != nil {
-				errorRendered := errorStyle.Render(fmt.Sprintf("Error:\n%v", m.tabs[i].err))
-				m.tabs[i].viewport.SetContent(errorRendered)
+				m.tabs[i].showError()
 			}
 		}
 
@@ -844,7 +1384,8 @@ func (m mainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.activeTab >= 0 && m.activeTab < len(m.tabs) &&
 			(m.tabs[m.activeTab].textInput.Focused() ||
 				m.tabs[m.activeTab].intervalInput.Focused() ||
-				m.tabs[m.activeTab].exportInput.Focused()) {
+				m.tabs[m.activeTab].exportInput.Focused() ||
+				m.tabs[m.activeTab].serverInput.Focused()) {
 			// Pass the key event to the tab's Update only
 			var updatedTab tabModel
 			updatedTab, cmd = m.tabs[m.activeTab].Update(msg, k)
@@ -858,6 +1399,7 @@ func (m mainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tea.Quit
 		case k.NewTab:
 			newTab := newTabModel(m.width, m.height, "", "")
+			newTab.server = m.server
 			m.tabs = append(m.tabs, newTab)
 			if m.activeTab >= 0 && m.activeTab < len(m.tabs)-1 {
 				if m.tabs[m.activeTab].textInput.Focused() {
@@ -870,6 +1412,7 @@ func (m mainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case k.CloseTab:
 			if len(m.tabs) > 1 {
 				currentActive := m.activeTab
+				m.tabs[currentActive].run.stop()
 				m.tabs = append(m.tabs[:currentActive], m.tabs[currentActive+1:]...)
 				if currentActive >= len(m.tabs) {
 					m.activeTab = len(m.tabs) - 1
@@ -913,13 +1456,18 @@ func (m mainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			cmds = append(cmds, cmd)
 		}
 
-	case lookupResultMsg, errorMsg:
+	case schedulerMsg:
+		// Nothing to update; View reads the scheduler's state.
+
+	case lookupResultMsg, errorMsg, watchTickMsg:
 		tabID := -1
 		switch specificMsg := msg.(type) {
 		case lookupResultMsg:
 			tabID = specificMsg.tabId
 		case errorMsg:
 			tabID = specificMsg.tabId
+		case watchTickMsg:
+			tabID = specificMsg.tabId
 		}
 		if tabID != -1 {
 			for i := range m.tabs {
@@ -955,16 +1503,24 @@ func (m mainModel) View() string {
 	maxWidthPerTab := m.width / max(1, numTabs)
 	maxTabNameWidth := max(10, min(25, maxWidthPerTab-2))
 
+	// Tabs running several lookups on one domain are labelled with the lookup.
+	domainTabs := make(map[string]int)
+	for _, t := range m.tabs {
+		domainTabs[t.domain]++
+	}
+
 	for i, t := range m.tabs {
 		tabName := fmt.Sprintf("Tab %d", i+1)
-		dispValue := t.domain
+		dispValue := lookup.ToUnicode(t.domain)
 		if dispValue == "" {
 			dispValue = t.textInput.Value()
+		} else if domainTabs[t.domain] > 1 && t.lookupType != "" {
+			dispValue += " " + t.lookupType
 		}
 
 		if dispValue != "" {
-			if len(dispValue) > maxTabNameWidth {
-				dispValue = dispValue[:maxTabNameWidth-1] + "…"
+			if r := []rune(dispValue); len(r) > maxTabNameWidth {
+				dispValue = string(r[:maxTabNameWidth-1]) + "…"
 			}
 			tabName = dispValue
 		}
@@ -977,6 +1533,12 @@ func (m mainModel) View() string {
 	}
 
 	tabBar := lipgloss.JoinHorizontal(lipgloss.Top, tabViews...)
+	if stats := lookup.DefaultScheduler.Stats(); stats.Running+stats.Queued > 0 {
+		progress := progressStyle.Render(fmt.Sprintf("%d/%d done, %d running, %d queued", stats.Finished, stats.Total, stats.Running, stats.Queued))
+		// Keep the progress visible by truncating the tabs, not the progress.
+		tabBar = lipgloss.NewStyle().MaxWidth(max(0, m.width-lipgloss.Width(progress))).Render(tabBar)
+		tabBar = lipgloss.JoinHorizontal(lipgloss.Top, tabBar, progress)
+	}
 	tabBar = lipgloss.NewStyle().MaxWidth(m.width).Render(tabBar)
 
 	separator := lipgloss.NewStyle().
@@ -1002,14 +1564,27 @@ func (m mainModel) View() string {
 	// Check if watch is available in the current active tab view
 	if m.activeTab >= 0 && m.activeTab < len(m.tabs) {
 		activeTabState := m.tabs[m.activeTab].state
-		activeLookupType := m.tabs[m.activeTab].lookupType
-		if (activeTabState == stateViewResults || activeTabState == stateError) &&
-			activeLookupType != lookup.ComprehensiveReportName {
-			helpParts = append(helpParts, fmt.Sprintf("%s Watch", helpKeyStyle.Render(k.WatchToggle+":")))
-			// Add Export help if applicable
-			if activeTabState == stateViewResults || activeTabState == stateError {
-				helpParts = append(helpParts, fmt.Sprintf("%s Export", helpKeyStyle.Render(k.Export+":")))
+		if activeTabState == stateLoading {
+			helpParts = append(helpParts, fmt.Sprintf("%s Cancel", helpKeyStyle.Render(k.Cancel+":")))
+		}
+		if activeTabState == stateViewResults || activeTabState == stateError {
+			helpParts = append(helpParts, fmt.Sprintf("%s Refresh", helpKeyStyle.Render(k.Refresh+":")))
+		}
+		if activeTabState == stateSelectLookup || activeTabState == stateViewResults || activeTabState == stateError {
+			helpParts = append(helpParts, fmt.Sprintf("%s Server", helpKeyStyle.Render(k.Server+":")))
+		}
+		activeTab := &m.tabs[m.activeTab]
+		if activeTabState == stateViewResults || activeTabState == stateError {
+			if activeTab.watchable() {
+				helpParts = append(helpParts, fmt.Sprintf("%s Watch", helpKeyStyle.Render(k.WatchToggle+":")))
 			}
+			helpParts = append(helpParts, fmt.Sprintf("%s Export", helpKeyStyle.Render(k.Export+":")))
+		}
+		if _, ok := activeTab.rangeReport(); ok && activeTabState == stateViewResults {
+			helpParts = append(helpParts, fmt.Sprintf("%s Sort", helpKeyStyle.Render(k.Sort+":")))
+		}
+		if _, ok := activeTab.copyText(); ok && activeTabState == stateViewResults {
+			helpParts = append(helpParts, fmt.Sprintf("%s Copy", helpKeyStyle.Render(k.Copy+":")))
 		}
 	}
 
@@ -1027,6 +1602,67 @@ func (m mainModel) View() string {
 	return finalView
 }
 
+func containsProvider(providers []lookup.LookupProvider, p lookup.LookupProvider) bool {
+	for _, q := range providers {
+		if q.Name() == p.Name() {
+			return true
+		}
+	}
+	return false
+}
+
+// parseArgs parses the command line and returns the positional arguments.
+// Unlike flag.Parse it also accepts flags after them, as in
+// "dlookup --dns-mx example.com example.org --output json".
+func parseArgs() []string {
+	flag.Parse()
+	var positional []string
+	for rest := flag.Args(); len(rest) > 0; rest = flag.Args() {
+		positional = append(positional, rest[0])
+		flag.CommandLine.Parse(rest[1:])
+	}
+	return positional
+}
+
+// listFileExts are extensions of domain list files. No TLD uses them, so a
+// missing file with one is reported rather than looked up as a domain.
+var listFileExts = map[string]bool{".txt": true, ".csv": true, ".list": true, ".lst": true}
+
+// loadDomains reads the domains named by sources: "-" is stdin, an existing
+// file is read line by line (or as CSV), and anything else is a domain. It
+// reports whether stdin was read.
+func loadDomains(sources []string, opts lookup.InputOptions) (list lookup.DomainList, readStdin bool) {
+	for i, src := range sources {
+		if src == "-" {
+			if !readStdin {
+				readStdin = true
+				if err := list.Read(os.Stdin, "-", opts); err != nil {
+					usageFatal("Error reading stdin: %v", err)
+				}
+			}
+			continue
+		}
+		info, err := os.Stat(src)
+		if err != nil || info.IsDir() {
+			if listFileExts[strings.ToLower(filepath.Ext(src))] {
+				usageFatal("Error opening file '%s': file not found", src)
+			}
+			list.Add("args", i+1, src)
+			continue
+		}
+		file, err := os.Open(src)
+		if err != nil {
+			usageFatal("Error opening file '%s': %v", src, err)
+		}
+		err = list.Read(file, src, opts)
+		file.Close()
+		if err != nil {
+			usageFatal("Error reading file '%s': %v", src, err)
+		}
+	}
+	return list, readStdin
+}
+
 func main() {
 	// --- Load Configuration ---
 	cfg, err := loadConfig()
@@ -1036,9 +1672,15 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Error loading config: %v. Using defaults.\n", err)
 		cfg = DefaultConfig() // Ensure we have defaults if loadConfig returned partial error
 	}
+	applyConfig(cfg)
 
 	// --- Flag Parsing (flags defined in init() using lookup package) ---
-	flag.Parse()
+	args := parseArgs()
+	headless := *noTUIFlag || *outputFlag != ""
+	server, err := lookup.ResolveServer(*serverFlag)
+	if err != nil {
+		usageFatal("Error: --server: %v", err)
+	}
 
 	providers := lookup.AvailableProviders()
 
@@ -1055,11 +1697,11 @@ func main() {
 			baseCmd = "whois"
 		}
 
-		if baseCmd != "" && !checkedCommands[baseCmd] {
+		// Native providers share flag prefixes with the exec-based ones but are
+		// always available, so only a failing check marks a command as missing.
+		if baseCmd != "" && !checkedCommands[baseCmd] && !p.CheckAvailability() {
 			checkedCommands[baseCmd] = true
-			if !p.CheckAvailability() {
-				missingCommands = append(missingCommands, baseCmd)
-			}
+			missingCommands = append(missingCommands, baseCmd)
 		}
 	}
 	if len(missingCommands) > 0 {
@@ -1068,68 +1710,101 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Please install them. Some lookup types may fail.\n")
 	}
 
-	initialDomains := []string{}
-	selectedLookupProviderName := ""
-	targetFilename := ""
-	flagsSetCount := 0
-	selectedFlagName := ""
-
-	for flagName, flagValuePtr := range lookupFlagValues {
-		if *flagValuePtr != "" {
-			flagsSetCount++
-			targetFilename = *flagValuePtr
-			selectedFlagName = flagName
-			if flagsSetCount > 1 {
-				log.Fatal("Error: Please use only one lookup type flag (e.g., --nslookup, --dig-a) at a time.")
-			}
-		}
+	// Every lookup flag that is set selects a provider; --types adds more.
+	// Their values and any positional arguments are files, "-" for stdin, or
+	// domains, and together make up one list shared by all the lookups.
+	var selectedProviders []lookup.LookupProvider
+	var sources []string
+	seenSources := make(map[string]bool)
+	flagNames := make([]string, 0, len(lookupFlagValues))
+	for flagName := range lookupFlagValues {
+		flagNames = append(flagNames, flagName)
 	}
-
-	if flagsSetCount == 1 {
-		provider, found := lookup.GetProviderByFlagName(selectedFlagName)
+	sort.Strings(flagNames)
+	for _, flagName := range flagNames {
+		source := *lookupFlagValues[flagName]
+		if source == "" {
+			continue
+		}
+		provider, found := lookup.GetProviderByFlagName(flagName)
 		if !found {
 
-			log.Fatalf("Internal Error: Flag --%s was set, but no corresponding provider found.", selectedFlagName)
+			log.Fatalf("Internal Error: Flag --%s was set, but no corresponding provider found.", flagName)
 		}
-		selectedLookupProviderName = provider.Name()
-
-		if targetFilename == "" {
-
-			log.Fatalf("Error: No filename provided for --%s flag.", selectedFlagName)
+		selectedProviders = append(selectedProviders, provider)
+		if !seenSources[source] {
+			seenSources[source] = true
+			sources = append(sources, source)
 		}
-
-		file, err := os.Open(targetFilename)
+	}
+	if *typesFlag != "" {
+		typeProviders, err := lookup.ProvidersForTypes(*typesFlag)
 		if err != nil {
-			log.Fatalf("Error opening file '%s': %v", targetFilename, err)
+			usageFatal("Error: --types: %v", err)
 		}
-		defer file.Close()
-
-		scanner := bufio.NewScanner(file)
-		lineNum := 0
-		for scanner.Scan() {
-			lineNum++
-			line := strings.TrimSpace(scanner.Text())
-
-			if line != "" && !strings.HasPrefix(line, "#") {
-
-				initialDomains = append(initialDomains, line)
+		for _, provider := range typeProviders {
+			if !containsProvider(selectedProviders, provider) {
+				selectedProviders = append(selectedProviders, provider)
 			}
 		}
-		if err := scanner.Err(); err != nil {
-			log.Fatalf("Error reading file '%s': %v", targetFilename, err)
+	}
+	if headless && len(selectedProviders) == 0 {
+		usageFatal("Error: --no-tui and --output need a lookup flag or --types (e.g., --dig-a domains.txt).")
+	}
+	sources = append(sources, args...)
+
+	domainList, readStdin := loadDomains(sources, lookup.InputOptions{Column: *columnFlag})
+	initialDomains := domainList.Domains
+	if summary := domainList.SkippedSummary(); summary != "" {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", summary)
+		for _, s := range domainList.Skipped {
+			if s.Reason != "duplicate" {
+				fmt.Fprintf(os.Stderr, "  %s\n", s)
+			}
 		}
+	}
+
+	var selectedLookupTypes []string
+	if len(selectedProviders) > 0 {
 		if len(initialDomains) == 0 {
-			fmt.Fprintf(os.Stderr, "Warning: File '%s' was empty or contained no valid domains/IPs.\n", targetFilename)
+			// Headless mode has nothing to do and fails; the UI still starts.
+			level := "Warning"
+			if headless {
+				level = "Error"
+			}
+			fmt.Fprintf(os.Stderr, "%s: No valid domains/IPs found in %s.\n", level, strings.Join(sources, ", "))
+		}
 
+		if headless {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			if server != "" {
+				ctx = lookup.WithServer(ctx, server)
+			}
+			code := runHeadless(ctx, selectedProviders, initialDomains, *outputFlag, os.Stdout)
+			stop()
+			os.Exit(code)
 		}
 
-		fmt.Printf("Attempting to run '%s' on %d domains/IPs from '%s'...\n", selectedLookupProviderName, len(initialDomains), targetFilename)
+		for _, provider := range selectedProviders {
+			selectedLookupTypes = append(selectedLookupTypes, provider.Name())
+		}
+		fmt.Printf("Attempting to run '%s' on %d domains/IPs from %s...\n", strings.Join(selectedLookupTypes, "', '"), len(initialDomains), strings.Join(sources, ", "))
 	}
 
 	// --- Initialize and Run Bubble Tea Program ---
-	// Pass domains, selected lookup provider name (if any), and loaded config
-	m := initialMainModel(initialDomains, selectedLookupProviderName, cfg)
-	p := tea.NewProgram(m, tea.WithMouseCellMotion())
+	// Pass domains, selected lookup provider names (if any), and loaded config
+	m := initialMainModel(initialDomains, selectedLookupTypes, server, cfg)
+	opts := []tea.ProgramOption{tea.WithMouseCellMotion()}
+	if readStdin {
+		// Stdin held the domain list, so read keys from the terminal.
+		opts = append(opts, tea.WithInputTTY())
+	}
+	p := tea.NewProgram(m, opts...)
+	go func() {
+		for range lookup.DefaultScheduler.Changes() {
+			p.Send(schedulerMsg{})
+		}
+	}()
 
 	if _, err := p.Run(); err != nil {
 		// Use log.Fatalf to print error and exit(1)